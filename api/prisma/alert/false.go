@@ -12,19 +12,27 @@ const (
 	Virginia              = "us-east-1"
 )
 
-// FalseAlert
-// return true if CloudType is not aws,
-// non-Virginia region, ResourceID is
-// DetachInternetGateway, DeleteInternetGateway,
-// DeleteSubnet, DeleteVpc
-func FalseAWSRegionViolationAlert(alert *events.Alert) bool {
-	if alert.ResourceRegionID == Virginia {
+// FalseAWSRegionViolationAlert
+// return true if the alert is an AWS event outside Virginia and
+// the event is DetachInternetGateway, DeleteInternetGateway,
+// DeleteSubnet or DeleteVpc. The event name and the region are read
+// from the CloudTrail event, an alert without an event name is not a false alert
+func FalseAWSRegionViolationAlert(alert *events.AlertEvent) bool {
+	if alert.CloudType != "aws" {
 		return false
 	}
-	if alert.CloudType != "aws" {
+	trail, err := alert.CloudTrail()
+	if err != nil {
+		return false
+	}
+	region := trail.AwsRegion
+	if region == "" {
+		region = alert.ResourceRegionID
+	}
+	if region == Virginia {
 		return false
 	}
-	switch alert.ResourceID {
+	switch trail.EventName {
 	case DetachInternetGateway, DeleteInternetGateway, DeleteSubnet, DeleteVpc:
 		return true
 	}
@@ -1,6 +1,7 @@
 package alert_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"testing"
 
@@ -10,74 +11,97 @@ import (
 	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
 )
 
+func auditEvent(cloudType string, region string, eventName string) *events.AlertEvent {
+	trail, _ := json.Marshal(&events.CloudTrailEvent{
+		EventName: eventName,
+		AwsRegion: region,
+	})
+	return &events.AlertEvent{
+		ResourceRegionID: region,
+		// Prisma fill the resource ID of an audit event with the event name
+		ResourceID:     eventName,
+		CloudType:      cloudType,
+		ResourceType:   "Audit Event",
+		ResourceConfig: trail,
+	}
+}
+
 func TestFalseAWSRegionViolationAlert(t *testing.T) {
 	testCases := []struct {
 		name     string
-		alert    *events.Alert
+		alert    *events.AlertEvent
 		expected bool
 	}{
 		{
-			name: "Azure CloudType",
-			alert: &events.Alert{
-				ResourceRegionID: "us-east-1",
-				ResourceID:       "DetachInternetGateway",
-				CloudType:        "azure",
-			},
+			name:     "Azure CloudType",
+			alert:    auditEvent("azure", "us-east-2", "DetachInternetGateway"),
 			expected: false,
 		},
 		{
-			name: "Virginia Region",
-			alert: &events.Alert{
-				ResourceRegionID: "us-east-1",
-				ResourceID:       "DetachInternetGateway",
-				CloudType:        "aws",
-			},
+			name:     "Virginia Region",
+			alert:    auditEvent("aws", "us-east-1", "DetachInternetGateway"),
 			expected: false,
 		},
 		{
-			name: "Not region violation ResourceID",
-			alert: &events.Alert{
-				ResourceRegionID: "us-east-2",
-				ResourceID:       "CreateEC2",
-				CloudType:        "aws",
-			},
+			name:     "Not region violation event name",
+			alert:    auditEvent("aws", "us-east-2", "CreateEC2"),
 			expected: false,
 		},
 		{
-			name: "False alert DetachInternetGateway alert in Non-Virginia region",
-			alert: &events.Alert{
-				ResourceRegionID: "us-east-2",
-				ResourceID:       "DetachInternetGateway",
-				CloudType:        "aws",
-			},
+			name:     "False alert DetachInternetGateway alert in Non-Virginia region",
+			alert:    auditEvent("aws", "us-east-2", "DetachInternetGateway"),
 			expected: true,
 		},
 		{
-			name: "False alert DeleteInternetGateway alert in Non-Virginia region",
-			alert: &events.Alert{
-				ResourceRegionID: "us-west-1",
-				ResourceID:       "DeleteInternetGateway",
-				CloudType:        "aws",
-			},
+			name:     "False alert DeleteInternetGateway alert in Non-Virginia region",
+			alert:    auditEvent("aws", "us-west-1", "DeleteInternetGateway"),
 			expected: true,
 		},
 		{
-			name: "False alert DeleteSubnet alert in Non-Virginia region",
-			alert: &events.Alert{
-				ResourceRegionID: "us-east-2",
-				ResourceID:       "DeleteSubnet",
-				CloudType:        "aws",
-			},
+			name:     "False alert DeleteSubnet alert in Non-Virginia region",
+			alert:    auditEvent("aws", "us-east-2", "DeleteSubnet"),
+			expected: true,
+		},
+		{
+			name:     "False alert DeleteVpc alert in Non-Virginia region",
+			alert:    auditEvent("aws", "us-west-2", "DeleteVpc"),
+			expected: true,
+		},
+		{
+			name: "Virginia CloudTrail event of a non-Virginia alert",
+			alert: func() *events.AlertEvent {
+				event := auditEvent("aws", "us-east-1", "DeleteVpc")
+				event.ResourceRegionID = "us-west-2"
+				return event
+			}(),
+			expected: false,
+		},
+		{
+			name: "Resource ID is not the event name",
+			alert: func() *events.AlertEvent {
+				event := auditEvent("aws", "us-west-2", "DeleteVpc")
+				event.ResourceID = "vpc-123"
+				return event
+			}(),
 			expected: true,
 		},
 		{
-			name: "False alert DeleteVpc alert in Non-Virginia region",
-			alert: &events.Alert{
-				ResourceRegionID: "us-west-2",
+			name: "No event name",
+			alert: &events.AlertEvent{
+				ResourceRegionID: "us-east-2",
 				ResourceID:       "DeleteVpc",
 				CloudType:        "aws",
 			},
-			expected: true,
+			expected: false,
+		},
+		{
+			name: "CloudTrail event without event name",
+			alert: func() *events.AlertEvent {
+				event := auditEvent("aws", "us-west-2", "")
+				event.ResourceID = "DeleteVpc"
+				return event
+			}(),
+			expected: false,
 		},
 	}
 
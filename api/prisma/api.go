@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
 )
@@ -57,6 +58,27 @@ type AccountNames []struct {
 	ParentAccountName string `json:"parentAccountName,omitempty"`
 }
 
+// ResponseError is returned when Prisma respond with an unexpected status code
+type ResponseError struct {
+	StatusCode int
+	URL        string
+	// Message precede the status code, "Unexpected error" by default
+	Message string
+}
+
+func (e *ResponseError) Error() string {
+	message := e.Message
+	if message == "" {
+		message = "Unexpected error"
+	}
+	return fmt.Sprintf("%s %d", message, e.StatusCode)
+}
+
+// HTTPStatusCode return the status code, it is used to classify the error
+func (e *ResponseError) HTTPStatusCode() int {
+	return e.StatusCode
+}
+
 // PrismaAPIRequestInput input for API request
 type PrismaAPIRequestInput struct {
 	Action   string
@@ -70,19 +92,19 @@ type PrismaAPIRequestOutput struct {
 }
 
 type ListAlertsPayload struct {
-	Filters Filters  `json:"filters"`
+	Filters Filters  `json:"filters" validate:"required"`
 	Fields  []string `json:"fields"`
 }
 
 type ListAlertsInput struct {
-	Params map[string]string
+	Params map[string]string `validate:"required"`
 	ListAlertsPayload
 }
 
 type Filters []struct {
-	Name     string `json:"name"`
+	Name     string `json:"name" validate:"required"`
 	Value    string `json:"value"`
-	Operator string `json:"operator"`
+	Operator string `json:"operator" validate:"required"`
 }
 
 type ListAlertsResponse struct {
@@ -103,8 +125,8 @@ type ListAlertsResponse struct {
 
 // DismissAlertInput DissmissAlerts parameter
 type DismissAlertInput struct {
-	Alerts             []string           `json:"alerts"`
-	DismissalNote      string             `json:"dismissalNote"`
+	Alerts             []string           `json:"alerts" validate:"required"`
+	DismissalNote      string             `json:"dismissalNote" validate:"required"`
 	DismissAlertFilter DismissAlertFilter `json:"filter"`
 }
 
@@ -114,13 +136,13 @@ type DismissAlertFilter struct {
 }
 
 type FilterTimeRange struct {
-	Type  string         `json:"type"`
+	Type  string         `json:"type" validate:"omitempty,oneof=relative absolute to_now"`
 	Value TimeRangeValue `json:"value"`
 }
 
 type TimeRangeValue struct {
-	Amount int    `json:"amount"`
-	Unit   string `json:"unit"`
+	Amount int    `json:"amount" validate:"min=0"`
+	Unit   string `json:"unit" validate:"omitempty,oneof=minute hour day week month year"`
 }
 
 type Alerts []struct {
@@ -161,13 +183,23 @@ type LoginPrismaResponse struct {
 
 // LoginPrismaInput request parameter for Login
 type LoginPrismaInput struct {
-	Auth []byte
+	Auth []byte `validate:"required"`
 }
 
 type RegisterAccountInput struct {
 	Payload []byte
 }
 
+// URL return the full url of the endpoint on the tenant
+// Tenant is either the prisma stack name such as api3, or a base url starting with https://
+func (pc *PrismaClient) URL(endpoint string) string {
+	endpoint = strings.TrimPrefix(endpoint, "/")
+	if strings.HasPrefix(pc.Tenant, "https://") || strings.HasPrefix(pc.Tenant, "http://") {
+		return fmt.Sprintf("%s/%s", strings.TrimSuffix(pc.Tenant, "/"), endpoint)
+	}
+	return fmt.Sprintf("https://%s.prismacloud.io/%s", pc.Tenant, endpoint)
+}
+
 // Request accept an input as HTTP API request to call Prisma API
 func (pc *PrismaClient) Request(request *PrismaAPIRequestInput) (io.ReadCloser, error) {
 	if err := errors.FieldsVerifier(pc); err != nil {
@@ -188,7 +220,7 @@ func (pc *PrismaClient) Request(request *PrismaAPIRequestInput) (io.ReadCloser,
 		}
 	}
 
-	url := fmt.Sprintf("https://%s.prismacloud.io/%s", pc.Tenant, request.Endpoint)
+	url := pc.URL(request.Endpoint)
 
 	req, err := http.NewRequest(request.Action, url, bytes.NewBuffer(request.Payload))
 	if err != nil {
@@ -208,7 +240,7 @@ func (pc *PrismaClient) Request(request *PrismaAPIRequestInput) (io.ReadCloser,
 		return resp.Body, nil
 	}
 
-	return nil, fmt.Errorf("Response error: %d", resp.StatusCode)
+	return nil, &ResponseError{StatusCode: resp.StatusCode, URL: url, Message: "Response error:"}
 }
 
 // ListAlerts return a filered list of alerts
@@ -221,7 +253,7 @@ func (pc *PrismaClient) ListAlerts(listAlertInput *ListAlertsInput) (*Alerts, er
 		return nil, errors.New("ListAlertsInput is nil")
 	}
 
-	if err := errors.FieldsVerifier(listAlertInput); err != nil {
+	if err := errors.Validate(listAlertInput); err != nil {
 		return nil, err
 	}
 
@@ -230,7 +262,7 @@ func (pc *PrismaClient) ListAlerts(listAlertInput *ListAlertsInput) (*Alerts, er
 		return nil, err
 	}
 
-	url := fmt.Sprintf("https://%s.prismacloud.io/alert", pc.Tenant)
+	url := pc.URL("alert")
 
 	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(payload))
 	if err != nil {
@@ -252,7 +284,7 @@ func (pc *PrismaClient) ListAlerts(listAlertInput *ListAlertsInput) (*Alerts, er
 		}
 		return alerts, nil
 	}
-	return nil, fmt.Errorf("Unexpected error %d", resp.StatusCode)
+	return nil, &ResponseError{StatusCode: resp.StatusCode, URL: url}
 }
 
 // DismissAlerts accpet a DismissAlertInput which contain Alert ID
@@ -261,7 +293,7 @@ func (pc *PrismaClient) DismissAlerts(dismissAlertInput *DismissAlertInput) ([]b
 		return nil, errors.New("DismissAlertInput is nil")
 	}
 
-	if err := errors.FieldsVerifier(dismissAlertInput, "DismissAlertFilter"); err != nil {
+	if err := errors.Validate(dismissAlertInput); err != nil {
 		return nil, err
 	}
 	if err := errors.FieldsVerifier(pc); err != nil {
@@ -300,7 +332,7 @@ func (pc *PrismaClient) DismissAlerts(dismissAlertInput *DismissAlertInput) ([]b
 
 	fmt.Println(string(payload))
 
-	url := fmt.Sprintf("https://%s.prismacloud.io/alert/dismiss", pc.Tenant)
+	url := pc.URL("alert/dismiss")
 
 	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(payload))
 	if err != nil {
@@ -322,7 +354,7 @@ func (pc *PrismaClient) DismissAlerts(dismissAlertInput *DismissAlertInput) ([]b
 		}
 		return data, nil
 	}
-	return nil, fmt.Errorf("Unexpected error %d", resp.StatusCode)
+	return nil, &ResponseError{StatusCode: resp.StatusCode, URL: url}
 }
 
 // ListAccountGroups return AccountGroups that contain group id and name
@@ -330,7 +362,7 @@ func (pc *PrismaClient) ListAccountGroups() (*AccountGroups, error) {
 	if err := errors.FieldsVerifier(pc); err != nil {
 		return nil, err
 	}
-	url := fmt.Sprintf("https://%s.prismacloud.io/cloud/group/name", pc.Tenant)
+	url := pc.URL("cloud/group/name")
 
 	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
@@ -353,7 +385,7 @@ func (pc *PrismaClient) ListAccountGroups() (*AccountGroups, error) {
 		}
 		return accountGroups, nil
 	}
-	return nil, fmt.Errorf("Unexpected error %d", resp.StatusCode)
+	return nil, &ResponseError{StatusCode: resp.StatusCode, URL: url}
 }
 
 // LoginPrisma get Token from prisma and return the Token
@@ -366,11 +398,11 @@ func (pc *PrismaClient) LoginPrisma(reuqest *LoginPrismaInput) error {
 		return errors.New("LoginPrismaInput is nil")
 	}
 
-	if err := errors.FieldsVerifier(reuqest); err != nil {
+	if err := errors.Validate(reuqest); err != nil {
 		return err
 	}
 
-	url := fmt.Sprintf("https://%s.prismacloud.io/login", pc.Tenant)
+	url := pc.URL("login")
 
 	resp, err := pc.Post(url, "application/json", bytes.NewBuffer(reuqest.Auth))
 
@@ -386,7 +418,7 @@ func (pc *PrismaClient) LoginPrisma(reuqest *LoginPrismaInput) error {
 		pc.Token = loginResponse.Token
 		return nil
 	}
-	return fmt.Errorf("Unexpected error %d", resp.StatusCode)
+	return &ResponseError{StatusCode: resp.StatusCode, URL: url}
 }
 
 // ListAccountNames returns a list of cloud account IDs and names.
@@ -395,7 +427,7 @@ func (pc *PrismaClient) ListAccountNames() (*AccountNames, error) {
 		return nil, err
 	}
 
-	url := fmt.Sprintf("https://%s.prismacloud.io/cloud/name", pc.Tenant)
+	url := pc.URL("cloud/name")
 
 	req, reqErr := http.NewRequest(http.MethodGet, url, nil)
 	if reqErr != nil {
@@ -420,7 +452,7 @@ func (pc *PrismaClient) ListAccountNames() (*AccountNames, error) {
 		return accountNames, nil
 	}
 
-	return nil, fmt.Errorf("Unexpected error %d", resp.StatusCode)
+	return nil, &ResponseError{StatusCode: resp.StatusCode, URL: url}
 }
 
 // RegisterAccount register new account
@@ -433,7 +465,7 @@ func (pc *PrismaClient) RegisterAccount(payload []byte) error {
 		return fmt.Errorf("required parameter payload is empty")
 	}
 
-	url := fmt.Sprintf("https://%s.prismacloud.io/cloud/cloud_type", pc.Tenant)
+	url := pc.URL("cloud/cloud_type")
 
 	req, err := http.NewRequest(http.MethodPost, url, nil)
 	if err != nil {
@@ -458,7 +490,7 @@ func (pc *PrismaClient) RegisterAccount(payload []byte) error {
 		return nil
 	}
 
-	return fmt.Errorf("Unexpected error %d", resp.StatusCode)
+	return &ResponseError{StatusCode: resp.StatusCode, URL: url}
 }
 
 // DefaultHeader accept Token and return an http.request.header
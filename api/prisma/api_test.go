@@ -146,6 +146,16 @@ func TestRequestDo(t *testing.T) {
 	mockClient.AssertExpectations(t)
 }
 
+func TestRequestResponseError(t *testing.T) {
+	mockClient := new(mockHttpClient)
+	client := createMockHttpClient(mockClient)
+	mockClient.On("Do", mock.Anything).Return(createHttpResponse(500), nil)
+
+	_, err := client.Request(createPrismaAPIRequestInput(http.MethodGet))
+	assert.EqualError(t, err, "Response error: 500")
+	assert.Equal(t, 500, err.(*prisma.ResponseError).HTTPStatusCode())
+}
+
 func TestInvalidListAlerts(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -181,20 +191,29 @@ func TestInvalidListAlerts(t *testing.T) {
 			name:     "empty ListAlertsInput.Params",
 			client:   &prisma.PrismaClient{Token: "token", Tenant: "api", PrismaHTTPiface: &http.Client{}},
 			input:    &prisma.ListAlertsInput{},
-			expected: errors.New("required field Params type of map[string]string is empty"),
+			expected: errors.New("required field Params type of map[string]string is empty; required field filters type of prisma.Filters is empty"),
 		},
 		{
 			name:     "empty ListAlertsInput.ListAlertsPayload",
 			client:   &prisma.PrismaClient{Token: "token", Tenant: "api", PrismaHTTPiface: &http.Client{}},
 			input:    &prisma.ListAlertsInput{Params: map[string]string{"test": "test"}},
-			expected: errors.New("required field ListAlertsPayload type of prisma.ListAlertsPayload is empty"),
+			expected: errors.New("required field filters type of prisma.Filters is empty"),
+		},
+		{
+			name:   "empty ListAlertsInput filter operator",
+			client: &prisma.PrismaClient{Token: "token", Tenant: "api", PrismaHTTPiface: &http.Client{}},
+			input: &prisma.ListAlertsInput{
+				Params:            map[string]string{"test": "test"},
+				ListAlertsPayload: prisma.ListAlertsPayload{Filters: prisma.Filters{{Name: "alert.status", Value: "Open"}}},
+			},
+			expected: errors.New("required field filters[0].operator type of string is empty"),
 		},
 	}
 
 	for i, testCase := range testCases {
 		t.Run(fmt.Sprintf("testCase[%d] %s", i, testCase.name), func(t *testing.T) {
 			_, err := testCase.client.ListAlerts(testCase.input)
-			assert.Equal(t, testCase.expected, err)
+			assert.EqualError(t, err, testCase.expected.Error())
 		})
 	}
 }
@@ -251,13 +270,25 @@ func TestInvalidDismissAlerts(t *testing.T) {
 		{
 			name:     "nil Alerts",
 			input:    &prisma.DismissAlertInput{},
-			expected: errors.New("required field Alerts type of []string is empty"),
+			expected: errors.New("required field alerts type of []string is empty; required field dismissalNote type of string is empty"),
 		},
 		{
 			name:     "empty dismissalNote",
 			client:   &prisma.PrismaClient{},
 			input:    &prisma.DismissAlertInput{Alerts: []string{"P-123"}},
-			expected: errors.New("required field DismissalNote type of string is empty"),
+			expected: errors.New("required field dismissalNote type of string is empty"),
+		},
+		{
+			name:   "invalid time range",
+			client: &prisma.PrismaClient{},
+			input: &prisma.DismissAlertInput{
+				Alerts:        []string{"P-123"},
+				DismissalNote: "Test",
+				DismissAlertFilter: prisma.DismissAlertFilter{
+					TimeRange: prisma.FilterTimeRange{Type: "relative", Value: prisma.TimeRangeValue{Amount: 1, Unit: "weeks"}},
+				},
+			},
+			expected: errors.New(`field filter.timeRange.value.unit must be one of [minute hour day week month year], got "weeks"`),
 		},
 		{
 			name:     "empty prisma client token",
@@ -282,7 +313,7 @@ func TestInvalidDismissAlerts(t *testing.T) {
 	for i, testCase := range testCases {
 		t.Run(fmt.Sprintf("testCase[%d] %s", i, testCase.name), func(t *testing.T) {
 			_, err := testCase.client.DismissAlerts(testCase.input)
-			assert.Equal(t, testCase.expected, err)
+			assert.EqualError(t, err, testCase.expected.Error())
 		})
 	}
 }
@@ -345,7 +376,7 @@ func TestLoginPrismaError(t *testing.T) {
 	for i, testCase := range testCases {
 		t.Run(fmt.Sprintf("testCase[%d] %s", i, testCase.name), func(t *testing.T) {
 			err := testCase.client.LoginPrisma(testCase.input)
-			assert.Equal(t, testCase.expected, err)
+			assert.EqualError(t, err, testCase.expected.Error())
 		})
 	}
 }
@@ -0,0 +1,158 @@
+package prisma
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// RedactedValue replace sensitive header value in the log
+const RedactedValue = "[REDACTED]"
+
+// sensitiveHeaders contain the header that should never be logged
+var sensitiveHeaders = []string{"x-redlock-auth", "Authorization", "Cookie", "Set-Cookie"}
+
+// Handler perform a http request and return the response
+type Handler func(*http.Request) (*http.Response, error)
+
+// Middleware wrap a Handler to inspect or modify the request and response
+type Middleware func(next Handler) Handler
+
+// middlewareClient route every request of the wrapped PrismaHTTPiface through the handler chain
+type middlewareClient struct {
+	PrismaHTTPiface
+	middlewares []Middleware
+	handler     Handler
+}
+
+// Use add middlewares to the PrismaClient
+// The first middleware is the outermost one, it see the request first and the response last.
+// Use can be called more than once, later middlewares run inside the existing ones
+func (pc *PrismaClient) Use(middlewares ...Middleware) {
+	pc.PrismaHTTPiface = Chain(pc.PrismaHTTPiface, middlewares...)
+}
+
+// Chain return a PrismaHTTPiface that pass every request through the middlewares
+// before calling the Do of client
+func Chain(client PrismaHTTPiface, middlewares ...Middleware) PrismaHTTPiface {
+	if client == nil || len(middlewares) == 0 {
+		return client
+	}
+	chain := []Middleware{}
+	if inner, ok := client.(*middlewareClient); ok {
+		chain = append(chain, inner.middlewares...)
+		client = inner.PrismaHTTPiface
+	}
+	chain = append(chain, middlewares...)
+
+	handler := client.Do
+	// wrap from the inside out so chain[0] ends up outermost
+	for i := len(chain) - 1; i >= 0; i-- {
+		handler = chain[i](handler)
+	}
+	return &middlewareClient{
+		PrismaHTTPiface: client,
+		middlewares:     chain,
+		handler:         handler,
+	}
+}
+
+// Do send the request through the middleware chain
+func (mc *middlewareClient) Do(req *http.Request) (*http.Response, error) {
+	return mc.handler(req)
+}
+
+// Get send a GET request through the middleware chain
+func (mc *middlewareClient) Get(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return mc.Do(req)
+}
+
+// Head send a HEAD request through the middleware chain
+func (mc *middlewareClient) Head(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodHead, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return mc.Do(req)
+}
+
+// Post send a POST request through the middleware chain
+func (mc *middlewareClient) Post(url string, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return mc.Do(req)
+}
+
+// PostForm send a form encoded POST request through the middleware chain
+func (mc *middlewareClient) PostForm(url string, data url.Values) (*http.Response, error) {
+	return mc.Post(url, "application/x-www-form-urlencoded", strings.NewReader(data.Encode()))
+}
+
+// LoggingMiddleware log every request and response with logf
+// Token and credential headers are redacted
+func LoggingMiddleware(logf func(format string, v ...interface{})) Middleware {
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			logf("prisma request: %s %s header: %v", req.Method, req.URL.String(), RedactHeader(req.Header))
+			resp, err := next(req)
+			if err != nil {
+				logf("prisma request: %s %s error: %s", req.Method, req.URL.String(), err.Error())
+				return resp, err
+			}
+			logf("prisma response: %s %s status: %d header: %v", req.Method, req.URL.String(), resp.StatusCode, RedactHeader(resp.Header))
+			return resp, nil
+		}
+	}
+}
+
+// TimingMiddleware call observe with the latency of every request
+// err is the transport error, status is 0 when the request failed
+func TimingMiddleware(observe func(req *http.Request, status int, latency time.Duration, err error)) Middleware {
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next(req)
+			status := 0
+			if resp != nil {
+				status = resp.StatusCode
+			}
+			observe(req, status, time.Since(start), err)
+			return resp, err
+		}
+	}
+}
+
+// HeaderMiddleware add the headers to every request
+// Headers already set by the caller are not overwritten
+func HeaderMiddleware(headers map[string]string) Middleware {
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			for key, value := range headers {
+				if req.Header.Get(key) == "" {
+					req.Header.Set(key, value)
+				}
+			}
+			return next(req)
+		}
+	}
+}
+
+// RedactHeader return a copy of header with sensitive values replaced
+func RedactHeader(header http.Header) http.Header {
+	redacted := header.Clone()
+	for _, name := range sensitiveHeaders {
+		if redacted.Get(name) != "" {
+			redacted.Set(name, RedactedValue)
+		}
+	}
+	return redacted
+}
@@ -0,0 +1,217 @@
+package prisma_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/api/prisma"
+)
+
+// recorder return a middleware append its name to calls before and after the request
+func recorder(name string, calls *[]string) prisma.Middleware {
+	return func(next prisma.Handler) prisma.Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			*calls = append(*calls, name+" before")
+			resp, err := next(req)
+			*calls = append(*calls, name+" after")
+			return resp, err
+		}
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	mockClient := new(mockHttpClient)
+	mockClient.On("Do", mock.Anything).Return(createHttpResponse(200, []byte(`[]`)), nil)
+	client := createMockHttpClient(mockClient)
+
+	calls := []string{}
+	client.Use(recorder("first", &calls), recorder("second", &calls))
+	client.Use(recorder("third", &calls))
+
+	_, err := client.ListAccountGroups()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"first before",
+		"second before",
+		"third before",
+		"third after",
+		"second after",
+		"first after",
+	}, calls)
+	mockClient.AssertNumberOfCalls(t, "Do", 1)
+}
+
+func TestMiddlewareWrapPost(t *testing.T) {
+	mockClient := new(mockHttpClient)
+	mockClient.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.Method == http.MethodPost &&
+			req.URL.String() == "https://api.prismacloud.io/login" &&
+			req.Header.Get("Content-Type") == "application/json"
+	})).Return(createHttpResponse(200, []byte(`{"token":"token"}`)), nil)
+	client := &prisma.PrismaClient{
+		Tenant:          "api",
+		PrismaHTTPiface: mockClient,
+	}
+
+	calls := []string{}
+	client.Use(recorder("login", &calls))
+
+	err := client.LoginPrisma(&prisma.LoginPrismaInput{Auth: []byte(`{}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, "token", client.Token)
+	assert.Equal(t, []string{"login before", "login after"}, calls)
+	mockClient.AssertNotCalled(t, "Post", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestLoggingMiddlewareRedactToken(t *testing.T) {
+	mockClient := new(mockHttpClient)
+	mockClient.On("Do", mock.Anything).Return(createHttpResponse(200, []byte(`[]`)), nil)
+	client := createMockHttpClient(mockClient)
+
+	var logs bytes.Buffer
+	client.Use(prisma.LoggingMiddleware(func(format string, v ...interface{}) {
+		fmt.Fprintf(&logs, format+"\n", v...)
+	}))
+
+	_, err := client.ListAccountGroups()
+	assert.NoError(t, err)
+	assert.Contains(t, logs.String(), "GET https://api.prismacloud.io/cloud/group/name")
+	assert.Contains(t, logs.String(), prisma.RedactedValue)
+	assert.Contains(t, logs.String(), "status: 200")
+	assert.NotContains(t, logs.String(), "[token]")
+}
+
+func TestTimingMiddleware(t *testing.T) {
+	mockClient := new(mockHttpClient)
+	mockClient.On("Do", mock.Anything).Return(createHttpResponse(503), nil)
+	client := createMockHttpClient(mockClient)
+
+	var observedStatus int
+	var observedPath string
+	client.Use(prisma.TimingMiddleware(func(req *http.Request, status int, latency time.Duration, err error) {
+		observedStatus = status
+		observedPath = req.URL.Path
+		assert.True(t, latency >= 0)
+		assert.NoError(t, err)
+	}))
+
+	_, err := client.ListAccountNames()
+	assert.EqualError(t, err, "Unexpected error 503")
+	assert.Equal(t, 503, observedStatus)
+	assert.Equal(t, "/cloud/name", observedPath)
+}
+
+func TestHeaderMiddleware(t *testing.T) {
+	mockClient := new(mockHttpClient)
+	mockClient.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.Header.Get("x-request-source") == "test" && req.Header.Get("Content-Type") == "application/json"
+	})).Return(createHttpResponse(200, []byte(`[]`)), nil)
+	client := createMockHttpClient(mockClient)
+	client.Use(prisma.HeaderMiddleware(map[string]string{
+		"x-request-source": "test",
+		"Content-Type":     "text/plain",
+	}))
+
+	_, err := client.ListAccountGroups()
+	assert.NoError(t, err)
+	mockClient.AssertExpectations(t)
+}
+
+func TestChaosMiddleware(t *testing.T) {
+	mockClient := new(mockHttpClient)
+	client := createMockHttpClient(mockClient)
+	client.Use(func(next prisma.Handler) prisma.Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			if strings.HasSuffix(req.URL.Path, "/alert/dismiss") {
+				return nil, errors.New("injected failure")
+			}
+			return next(req)
+		}
+	})
+
+	_, err := client.DismissAlerts(&prisma.DismissAlertInput{
+		Alerts:        []string{"P-123"},
+		DismissalNote: "Test",
+	})
+	assert.EqualError(t, err, "injected failure")
+	mockClient.AssertNotCalled(t, "Do", mock.Anything)
+}
+
+func TestRedactHeader(t *testing.T) {
+	header := http.Header{}
+	header.Set("x-redlock-auth", "token")
+	header.Set("Content-Type", "application/json")
+
+	redacted := prisma.RedactHeader(header)
+	assert.Equal(t, prisma.RedactedValue, redacted.Get("x-redlock-auth"))
+	assert.Equal(t, "application/json", redacted.Get("Content-Type"))
+	assert.Equal(t, "token", header.Get("x-redlock-auth"))
+}
+
+func TestChainWithoutMiddleware(t *testing.T) {
+	httpClient := &http.Client{}
+	assert.Equal(t, httpClient, prisma.Chain(httpClient))
+	assert.Nil(t, prisma.Chain(nil, recorder("nil", &[]string{})))
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	mockClient := new(mockHttpClient)
+	for i := 0; i < 3; i++ {
+		mockClient.On("Do", mock.Anything).Return(createHttpResponse(200, []byte(`[]`)), nil).Once()
+	}
+	client := createMockHttpClient(mockClient)
+	client.Use(prisma.RateLimitMiddleware(20, 1))
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := client.ListAccountGroups()
+		assert.NoError(t, err)
+	}
+	// the first request use the burst, the next two wait 50ms each
+	assert.True(t, time.Since(start) >= 90*time.Millisecond)
+	mockClient.AssertNumberOfCalls(t, "Do", 3)
+}
+
+func TestRateLimitMiddlewareContextDone(t *testing.T) {
+	mockClient := new(mockHttpClient)
+	mockClient.On("Do", mock.Anything).Return(createHttpResponse(200), nil)
+	handler := prisma.RateLimitMiddleware(0.001, 1)(mockClient.Do)
+
+	req, _ := http.NewRequest(http.MethodGet, "https://api.prismacloud.io/cloud/name", nil)
+	_, err := handler(req)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = handler(req.WithContext(ctx))
+	assert.Equal(t, context.DeadlineExceeded, err)
+	mockClient.AssertNumberOfCalls(t, "Do", 1)
+}
+
+func TestURL(t *testing.T) {
+	testCases := []struct {
+		tenant   string
+		endpoint string
+		expected string
+	}{
+		{tenant: "api3", endpoint: "alert", expected: "https://api3.prismacloud.io/alert"},
+		{tenant: "api.eu", endpoint: "/login", expected: "https://api.eu.prismacloud.io/login"},
+		{tenant: "https://prisma.example.com/", endpoint: "cloud/name", expected: "https://prisma.example.com/cloud/name"},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, testCase.tenant), func(t *testing.T) {
+			client := &prisma.PrismaClient{Tenant: testCase.tenant}
+			assert.Equal(t, testCase.expected, client.URL(testCase.endpoint))
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package prisma
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// tokenBucket is a simple token bucket shared by every request of a client
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// reserve take a token and return how long the caller need to wait before using it
+func (tb *tokenBucket) reserve() time.Duration {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	now := time.Now()
+	tb.tokens += now.Sub(tb.last).Seconds() * tb.rate
+	if tb.tokens > tb.burst {
+		tb.tokens = tb.burst
+	}
+	tb.last = now
+	tb.tokens--
+	if tb.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-tb.tokens / tb.rate * float64(time.Second))
+}
+
+// cancel give back a token reserved by a request that never ran
+func (tb *tokenBucket) cancel() {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.tokens++
+}
+
+// RateLimitMiddleware allow at most requestsPerSecond requests with bursts up to burst
+// Requests over the limit wait for a token, or return the context error when
+// the request context is done first. A rate of zero or less disable the limit
+func RateLimitMiddleware(requestsPerSecond float64, burst int) Middleware {
+	bucket := newTokenBucket(requestsPerSecond, burst)
+	return func(next Handler) Handler {
+		if requestsPerSecond <= 0 {
+			return next
+		}
+		return func(req *http.Request) (*http.Response, error) {
+			if wait := bucket.reserve(); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-req.Context().Done():
+					timer.Stop()
+					bucket.cancel()
+					return nil, req.Context().Err()
+				}
+			}
+			return next(req)
+		}
+	}
+}
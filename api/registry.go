@@ -0,0 +1,254 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/api/prisma"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/api/prisma/prismaiface"
+)
+
+const (
+	// TenantsEnv contain the tenant profiles as a JSON document
+	TenantsEnv = "PRISMA_TENANTS"
+	// TenantsFileEnv contain the path of a JSON file with the tenant profiles
+	TenantsFileEnv = "PRISMA_TENANTS_FILE"
+	// TenantEnv select the profile when the event does not specify one
+	TenantEnv = "PRISMA_TENANT"
+	// DefaultProfileName is used when neither the event nor the environment select a profile
+	DefaultProfileName = "default"
+	// TokenTTL is how long a cached client is reused, Prisma token expire after 10 minutes
+	TokenTTL = 9 * time.Minute
+)
+
+// RateLimit limit the request rate of a tenant client
+type RateLimit struct {
+	RequestsPerSecond float64 `json:"requestsPerSecond"`
+	Burst             int     `json:"burst"`
+}
+
+// Profile describe how to login a Prisma tenant
+type Profile struct {
+	Name         string    `json:"name"`
+	Tenant       string    `json:"tenant,omitempty"`
+	BaseURL      string    `json:"baseUrl,omitempty"`
+	SecretName   string    `json:"secretName"`
+	CustomerName string    `json:"customerName"`
+	RateLimit    RateLimit `json:"rateLimit"`
+}
+
+// Validate return an error if the profile can not be used to login
+func (p *Profile) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("profile name is empty")
+	}
+	if p.Tenant == "" && p.BaseURL == "" {
+		return fmt.Errorf("profile %s: tenant or baseUrl is required", p.Name)
+	}
+	if p.SecretName == "" {
+		return fmt.Errorf("profile %s: secretName is required", p.Name)
+	}
+	if p.RateLimit.RequestsPerSecond < 0 || p.RateLimit.Burst < 0 {
+		return fmt.Errorf("profile %s: rateLimit must not be negative", p.Name)
+	}
+	return nil
+}
+
+// NewClient create a prisma client for the profile, the client is not logged in
+func (p *Profile) NewClient() *prisma.PrismaClient {
+	tenant := p.Tenant
+	if p.BaseURL != "" {
+		tenant = p.BaseURL
+	}
+	client := CreatePrismaClient(tenant)
+	if p.RateLimit.RequestsPerSecond > 0 {
+		client.Use(prisma.RateLimitMiddleware(p.RateLimit.RequestsPerSecond, p.RateLimit.Burst))
+	}
+	return client
+}
+
+// LoadProfiles decode a JSON array of profiles and validate them
+func LoadProfiles(reader io.Reader) ([]Profile, error) {
+	profiles := []Profile{}
+	if err := json.NewDecoder(reader).Decode(&profiles); err != nil {
+		return nil, fmt.Errorf("invalid tenant profiles: %s", err.Error())
+	}
+	names := map[string]bool{}
+	for i := range profiles {
+		if err := profiles[i].Validate(); err != nil {
+			return nil, err
+		}
+		if names[profiles[i].Name] {
+			return nil, fmt.Errorf("duplicate profile name: %s", profiles[i].Name)
+		}
+		names[profiles[i].Name] = true
+	}
+	return profiles, nil
+}
+
+// LoadProfilesFromEnv load the profiles from PRISMA_TENANTS or the file in PRISMA_TENANTS_FILE
+// fallback is returned when neither is set. The profile of the events without tenant must exist,
+// it is the profile in PRISMA_TENANT or the default profile
+func LoadProfilesFromEnv(fallback Profile) ([]Profile, error) {
+	profiles, err := loadProfilesFromEnv(fallback)
+	if err != nil {
+		return nil, err
+	}
+	name := ProfileName("")
+	for _, profile := range profiles {
+		if profile.Name == name {
+			return profiles, nil
+		}
+	}
+	return nil, fmt.Errorf("No tenant profile: %s, add it or select another profile with %s", name, TenantEnv)
+}
+
+func loadProfilesFromEnv(fallback Profile) ([]Profile, error) {
+	if document := os.Getenv(TenantsEnv); document != "" {
+		return LoadProfiles(strings.NewReader(document))
+	}
+	if path := os.Getenv(TenantsFileEnv); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return LoadProfiles(file)
+	}
+	if err := fallback.Validate(); err != nil {
+		return nil, err
+	}
+	return []Profile{fallback}, nil
+}
+
+// ProfileName return the profile selected by the event, or the PRISMA_TENANT
+// environment variable, or the default profile
+func ProfileName(eventTenant string) string {
+	if eventTenant != "" {
+		return eventTenant
+	}
+	if name := os.Getenv(TenantEnv); name != "" {
+		return name
+	}
+	return DefaultProfileName
+}
+
+type cachedClient struct {
+	client     prismaiface.PrismaAPI
+	loggedInAt time.Time
+}
+
+// Registry return logged in clients by profile name
+// Clients are cached so a warm Lambda reuse the token of the previous invocation
+type Registry struct {
+	// Login create a logged in client of the profile, it default to LoginProfile
+	Login func(profile *Profile) (prismaiface.PrismaAPI, error)
+	// Now return the current time, it is used to expire cached clients
+	Now func() time.Time
+
+	profiles map[string]Profile
+	mu       sync.Mutex
+	clients  map[string]*cachedClient
+}
+
+// NewRegistry create a registry of the profiles, svc is used to read the profile secret
+func NewRegistry(profiles []Profile, svc secretsmanageriface.SecretsManagerAPI) (*Registry, error) {
+	if len(profiles) == 0 {
+		return nil, fmt.Errorf("no tenant profile")
+	}
+	registry := &Registry{
+		profiles: map[string]Profile{},
+		clients:  map[string]*cachedClient{},
+		Now:      time.Now,
+	}
+	for _, profile := range profiles {
+		if err := profile.Validate(); err != nil {
+			return nil, err
+		}
+		registry.profiles[profile.Name] = profile
+	}
+	registry.Login = func(profile *Profile) (prismaiface.PrismaAPI, error) {
+		return LoginProfile(profile, svc)
+	}
+	return registry, nil
+}
+
+// LoginProfile create a client of the profile and login with the profile secret
+func LoginProfile(profile *Profile, svc secretsmanageriface.SecretsManagerAPI) (prismaiface.PrismaAPI, error) {
+	return LoginPrismaWithAWSSecret(profile.SecretName, profile.CustomerName, svc, profile.NewClient())
+}
+
+// Profile return the profile by name
+func (r *Registry) Profile(name string) (Profile, bool) {
+	profile, ok := r.profiles[name]
+	return profile, ok
+}
+
+// Client return a logged in client of the profile
+// An empty name select the profile with ProfileName
+func (r *Registry) Client(name string) (prismaiface.PrismaAPI, error) {
+	name = ProfileName(name)
+	profile, ok := r.profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("No tenant profile: %s", name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cached, ok := r.clients[name]; ok && r.Now().Sub(cached.loggedInAt) < TokenTTL {
+		return cached.client, nil
+	}
+
+	client, err := r.Login(&profile)
+	if err != nil {
+		delete(r.clients, name)
+		return nil, err
+	}
+	r.clients[name] = &cachedClient{
+		client:     client,
+		loggedInAt: r.Now(),
+	}
+	return client, nil
+}
+
+// Invalidate drop the cached client of the profile, the next Client call login again
+func (r *Registry) Invalidate(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.clients, ProfileName(name))
+}
+
+// Do call fn with a logged in client of the profile
+// A token revoked or expired before TokenTTL is refused with 401, the profile is then invalidated
+// and fn is called once more with a new client
+func (r *Registry) Do(name string, fn func(client prismaiface.PrismaAPI) error) error {
+	client, err := r.Client(name)
+	if err != nil {
+		return err
+	}
+	if err := fn(client); !unauthorized(err) {
+		return err
+	}
+	fmt.Printf("Token of tenant profile %s is refused, login again\n", ProfileName(name))
+	r.Invalidate(name)
+	if client, err = r.Client(name); err != nil {
+		return err
+	}
+	return fn(client)
+}
+
+// unauthorized return true when Prisma refused the token of the request
+func unauthorized(err error) bool {
+	var status interface{ HTTPStatusCode() int }
+	return errors.As(err, &status) && status.HTTPStatusCode() == http.StatusUnauthorized
+}
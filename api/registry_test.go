@@ -0,0 +1,241 @@
+package api_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/api"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/api/prisma"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/api/prisma/prismaiface"
+)
+
+const profilesJSON = `[
+	{"name": "prod", "tenant": "api3", "secretName": "Prisma", "customerName": "NYC"},
+	{"name": "sandbox", "baseUrl": "https://api.eu.prismacloud.io", "secretName": "PrismaSandbox", "customerName": "NYC-SBX", "rateLimit": {"requestsPerSecond": 5, "burst": 2}}
+]`
+
+func TestLoadProfiles(t *testing.T) {
+	profiles, err := api.LoadProfiles(strings.NewReader(profilesJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, []api.Profile{
+		{Name: "prod", Tenant: "api3", SecretName: "Prisma", CustomerName: "NYC"},
+		{Name: "sandbox", BaseURL: "https://api.eu.prismacloud.io", SecretName: "PrismaSandbox", CustomerName: "NYC-SBX", RateLimit: api.RateLimit{RequestsPerSecond: 5, Burst: 2}},
+	}, profiles)
+}
+
+func TestLoadProfilesError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		document string
+		expected string
+	}{
+		{
+			name:     "malformed json",
+			document: `{"name": `,
+			expected: "invalid tenant profiles: unexpected EOF",
+		},
+		{
+			name:     "missing name",
+			document: `[{"tenant": "api3", "secretName": "Prisma"}]`,
+			expected: "profile name is empty",
+		},
+		{
+			name:     "missing tenant",
+			document: `[{"name": "prod", "secretName": "Prisma"}]`,
+			expected: "profile prod: tenant or baseUrl is required",
+		},
+		{
+			name:     "missing secret",
+			document: `[{"name": "prod", "tenant": "api3"}]`,
+			expected: "profile prod: secretName is required",
+		},
+		{
+			name:     "negative rate limit",
+			document: `[{"name": "prod", "tenant": "api3", "secretName": "Prisma", "rateLimit": {"requestsPerSecond": -1}}]`,
+			expected: "profile prod: rateLimit must not be negative",
+		},
+		{
+			name:     "duplicate name",
+			document: `[{"name": "prod", "tenant": "api3", "secretName": "Prisma"}, {"name": "prod", "tenant": "api2", "secretName": "Prisma"}]`,
+			expected: "duplicate profile name: prod",
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, testCase.name), func(t *testing.T) {
+			_, err := api.LoadProfiles(strings.NewReader(testCase.document))
+			assert.EqualError(t, err, testCase.expected)
+		})
+	}
+}
+
+func TestLoadProfilesFromEnv(t *testing.T) {
+	fallback := api.Profile{Name: api.DefaultProfileName, Tenant: "api3", SecretName: "Prisma"}
+
+	os.Unsetenv(api.TenantsEnv)
+	os.Unsetenv(api.TenantsFileEnv)
+	os.Unsetenv(api.TenantEnv)
+	profiles, err := api.LoadProfilesFromEnv(fallback)
+	assert.NoError(t, err)
+	assert.Equal(t, []api.Profile{fallback}, profiles)
+
+	dir, err := ioutil.TempDir("", "tenants")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tenants.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(profilesJSON), 0600))
+
+	os.Setenv(api.TenantsFileEnv, path)
+	defer os.Unsetenv(api.TenantsFileEnv)
+	_, err = api.LoadProfilesFromEnv(fallback)
+	assert.EqualError(t, err, "No tenant profile: default, add it or select another profile with PRISMA_TENANT")
+
+	os.Setenv(api.TenantEnv, "prod")
+	defer os.Unsetenv(api.TenantEnv)
+	profiles, err = api.LoadProfilesFromEnv(fallback)
+	assert.NoError(t, err)
+	assert.Len(t, profiles, 2)
+
+	os.Setenv(api.TenantsEnv, `[{"name": "default", "tenant": "api2", "secretName": "Prisma"}]`)
+	defer os.Unsetenv(api.TenantsEnv)
+	_, err = api.LoadProfilesFromEnv(fallback)
+	assert.EqualError(t, err, "No tenant profile: prod, add it or select another profile with PRISMA_TENANT")
+
+	os.Unsetenv(api.TenantEnv)
+	profiles, err = api.LoadProfilesFromEnv(fallback)
+	assert.NoError(t, err)
+	assert.Equal(t, "default", profiles[0].Name)
+}
+
+func TestProfileName(t *testing.T) {
+	os.Unsetenv(api.TenantEnv)
+	assert.Equal(t, api.DefaultProfileName, api.ProfileName(""))
+	assert.Equal(t, "sandbox", api.ProfileName("sandbox"))
+
+	os.Setenv(api.TenantEnv, "prod")
+	defer os.Unsetenv(api.TenantEnv)
+	assert.Equal(t, "prod", api.ProfileName(""))
+	assert.Equal(t, "sandbox", api.ProfileName("sandbox"))
+}
+
+func TestProfileNewClient(t *testing.T) {
+	profile := &api.Profile{Name: "sandbox", BaseURL: "https://prisma.example.com", SecretName: "Prisma"}
+	client := profile.NewClient()
+	assert.Equal(t, "https://prisma.example.com/login", client.URL("login"))
+
+	profile = &api.Profile{Name: "prod", Tenant: "api3", SecretName: "Prisma"}
+	client = profile.NewClient()
+	assert.Equal(t, "https://api3.prismacloud.io/login", client.URL("login"))
+}
+
+func TestRegistryClient(t *testing.T) {
+	profiles, err := api.LoadProfiles(strings.NewReader(profilesJSON))
+	assert.NoError(t, err)
+	registry, err := api.NewRegistry(profiles, &mockSecretsManager{})
+	assert.NoError(t, err)
+
+	now := time.Now()
+	registry.Now = func() time.Time { return now }
+	logins := map[string]int{}
+	registry.Login = func(profile *api.Profile) (prismaiface.PrismaAPI, error) {
+		logins[profile.Name]++
+		if profile.Name == "sandbox" {
+			return nil, errors.New("login failed")
+		}
+		return &prisma.PrismaClient{Token: "token", Tenant: profile.Tenant}, nil
+	}
+
+	first, err := registry.Client("prod")
+	assert.NoError(t, err)
+	second, err := registry.Client("prod")
+	assert.NoError(t, err)
+	assert.True(t, first == second)
+	assert.Equal(t, 1, logins["prod"])
+
+	now = now.Add(api.TokenTTL)
+	_, err = registry.Client("prod")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, logins["prod"])
+
+	registry.Invalidate("prod")
+	_, err = registry.Client("prod")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, logins["prod"])
+
+	_, err = registry.Client("sandbox")
+	assert.EqualError(t, err, "login failed")
+	_, err = registry.Client("sandbox")
+	assert.EqualError(t, err, "login failed")
+	assert.Equal(t, 2, logins["sandbox"])
+
+	_, err = registry.Client("missing")
+	assert.EqualError(t, err, "No tenant profile: missing")
+}
+
+func TestRegistryDo(t *testing.T) {
+	profiles, err := api.LoadProfiles(strings.NewReader(profilesJSON))
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		errs     []error
+		calls    int
+		logins   int
+		expected string
+	}{
+		{name: "success", errs: []error{nil}, calls: 1, logins: 1},
+		{name: "refused token", errs: []error{&prisma.ResponseError{StatusCode: 401}, nil}, calls: 2, logins: 2},
+		{name: "refused twice", errs: []error{&prisma.ResponseError{StatusCode: 401}, &prisma.ResponseError{StatusCode: 401}}, calls: 2, logins: 2, expected: "Unexpected error 401"},
+		{name: "forbidden", errs: []error{&prisma.ResponseError{StatusCode: 403}}, calls: 1, logins: 1, expected: "Unexpected error 403"},
+		{name: "wrapped refused token", errs: []error{fmt.Errorf("list alerts: %w", &prisma.ResponseError{StatusCode: 401}), nil}, calls: 2, logins: 2},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, testCase.name), func(t *testing.T) {
+			registry, err := api.NewRegistry(profiles, &mockSecretsManager{})
+			assert.NoError(t, err)
+			logins := 0
+			registry.Login = func(profile *api.Profile) (prismaiface.PrismaAPI, error) {
+				logins++
+				return &prisma.PrismaClient{Token: fmt.Sprintf("token-%d", logins), Tenant: profile.Tenant}, nil
+			}
+			calls := 0
+			err = registry.Do("prod", func(client prismaiface.PrismaAPI) error {
+				assert.Equal(t, fmt.Sprintf("token-%d", calls+1), client.(*prisma.PrismaClient).Token)
+				calls++
+				return testCase.errs[calls-1]
+			})
+			if testCase.expected == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, testCase.expected)
+			}
+			assert.Equal(t, testCase.calls, calls)
+			assert.Equal(t, testCase.logins, logins)
+		})
+	}
+}
+
+func TestRegistryProfile(t *testing.T) {
+	secretString := "{\"Key\":\"Key\", \"ID\":\"ID\"}"
+	svc := &mockSecretsManager{resp: secretsmanager.GetSecretValueOutput{SecretString: &secretString}}
+	registry, err := api.NewRegistry([]api.Profile{{Name: "default", Tenant: "api3", SecretName: "Prisma"}}, svc)
+	assert.NoError(t, err)
+
+	os.Unsetenv(api.TenantEnv)
+	profile, ok := registry.Profile("default")
+	assert.True(t, ok)
+	assert.Equal(t, "Prisma", profile.SecretName)
+
+	_, err = api.NewRegistry(nil, svc)
+	assert.EqualError(t, err, "no tenant profile")
+}
@@ -122,10 +122,10 @@ func DefaultHeader(token string) map[string]string {
 // RefreshSession refresh the prismaClient token
 func RefreshSession(token string, prismaClient prismaiface.PrismaAPI) error {
 	resp, err := prismaClient.Request(&prisma.PrismaAPIRequestInput{
-		"Get",
-		"auth_token/extend",
-		nil,
-		DefaultHeader(token),
+		Action:   "Get",
+		Endpoint: "auth_token/extend",
+		Payload:  nil,
+		Header:   DefaultHeader(token),
 	})
 	if err != nil {
 		return err
@@ -0,0 +1,105 @@
+// Command explain show how the dispatcher route sample alerts, nothing is sent
+//
+//	explain events/testdata/vpc-killer-event.json
+//	explain -routes routes.yaml -json events/testdata/golden
+//
+// Without -routes the routes are loaded from ROUTES or ROUTES_FILE like the dispatcher
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/router"
+)
+
+func main() {
+	routesFile := flag.String("routes", "", "JSON or YAML routing file")
+	asJSON := flag.Bool("json", false, "print the explanations as JSON")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		fmt.Println("usage: explain [-routes file] [-json] alert.json|directory ...")
+		os.Exit(2)
+	}
+
+	table, err := loadTable(*routesFile)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	if err := explainFiles(table, flag.Args(), os.Stdout, *asJSON); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+}
+
+func loadTable(routesFile string) (*router.Table, error) {
+	if routesFile == "" {
+		return router.LoadFromEnv()
+	}
+	file, err := os.Open(routesFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return router.Load(file)
+}
+
+// explainFiles explain the alert of every file, a directory is the .json files in it
+func explainFiles(table *router.Table, paths []string, out io.Writer, asJSON bool) error {
+	files, err := alertFiles(paths)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		body, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		explanation, err := table.ExplainMessage(body)
+		if err != nil {
+			return fmt.Errorf("%s: %s", file, err.Error())
+		}
+		if asJSON {
+			document, err := json.MarshalIndent(struct {
+				File string `json:"file"`
+				*router.Explanation
+			}{file, explanation}, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(out, string(document))
+			continue
+		}
+		fmt.Fprintf(out, "%s\n%s\n", file, explanation.String())
+	}
+	return nil
+}
+
+func alertFiles(paths []string) ([]string, error) {
+	files := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	return files, nil
+}
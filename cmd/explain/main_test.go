@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/router"
+)
+
+func TestExplainFiles(t *testing.T) {
+	table, err := router.NewTable(router.DefaultConfig())
+	assert.NoError(t, err)
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, explainFiles(table, []string{"../../events/testdata/vpc-killer-event.json"}, out, false))
+	assert.True(t, strings.HasPrefix(out.String(), "../../events/testdata/vpc-killer-event.json\nAlert P-39425 (VPCKiller) alert\n"))
+	assert.Contains(t, out.String(), "* route vpc-killer: matched\n")
+	assert.Contains(t, out.String(), "  targets: PrismaVPCKiller\n")
+
+	out.Reset()
+	assert.NoError(t, explainFiles(table, []string{"../../events/testdata/golden"}, out, true))
+	decoder := json.NewDecoder(out)
+	count := 0
+	for decoder.More() {
+		explanation := struct {
+			File string `json:"file"`
+			router.Explanation
+		}{}
+		assert.NoError(t, decoder.Decode(&explanation))
+		assert.True(t, strings.HasPrefix(explanation.File, "../../events/testdata/golden/"))
+		assert.Empty(t, explanation.Errors, explanation.File)
+		assert.Len(t, explanation.Routes, 4)
+		count++
+	}
+	assert.Equal(t, 15, count)
+
+	assert.Error(t, explainFiles(table, []string{"../../events/testdata/missing.json"}, out, false))
+}
+
+func TestLoadTable(t *testing.T) {
+	table, err := loadTable("../../remediation/dispatcher/router/testdata/routes.yaml")
+	assert.NoError(t, err)
+	assert.NotNil(t, table)
+
+	_, err = loadTable("missing.yaml")
+	assert.Error(t, err)
+}
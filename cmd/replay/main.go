@@ -0,0 +1,94 @@
+// Command replay resubmit dead-lettered alerts through the dispatcher routes
+//
+//	replay -queue-url https://sqs.us-east-1.amazonaws.com/123456789012/PrismaAlertDLQ -category Retryable -dry-run
+//	replay -file failed.jsonl -alert-id P-39425,P-39426
+//
+// The routes and targets are loaded from ROUTES or ROUTES_FILE like the dispatcher
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/deadletter"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/router"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/target"
+)
+
+func main() {
+	queueURL := flag.String("queue-url", os.Getenv(deadletter.QueueURLEnv), "URL of the dead-letter queue")
+	file := flag.String("file", "", "JSONL file of dead-letter records, read instead of the queue")
+	alertIDs := flag.String("alert-id", "", "comma separated alert IDs to replay")
+	category := flag.String("category", "", "replay the records of an error category, such as Retryable")
+	source := flag.String("source", "", "replay the records of a function, such as PrismaAlertDispatcher")
+	targetName := flag.String("target", "", "replay the records of a target")
+	limit := flag.Int("limit", 0, "replay at most limit records of the queue, 0 for every record")
+	visibilityTimeout := flag.Int64("visibility-timeout", 300, "seconds the received messages are hidden from other consumers")
+	region := flag.String("region", "us-east-1", "AWS region")
+	dryRun := flag.Bool("dry-run", false, "validate and route the records without sending them")
+	flag.Parse()
+
+	if *file == "" && *queueURL == "" {
+		fmt.Println("-queue-url or -file is required")
+		os.Exit(2)
+	}
+
+	routes, err := router.LoadFromEnv()
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+	clients := target.NewClients(sess, &aws.Config{Region: aws.String(*region)})
+	targets, err := target.NewSet(routes.TargetSpecs(), clients)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	replayer := &Replayer{
+		routes:  routes,
+		targets: targets,
+		filter: deadletter.Filter{
+			Category: *category,
+			Source:   *source,
+			Target:   *targetName,
+		},
+		dryRun: *dryRun,
+	}
+	if *alertIDs != "" {
+		replayer.filter.AlertIDs = strings.Split(*alertIDs, ",")
+	}
+
+	var summary Summary
+	if *file != "" {
+		reader, err := os.Open(*file)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		defer reader.Close()
+		records, err := deadletter.ReadRecords(reader)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		summary = replayer.ReplayRecords(context.Background(), records)
+	} else if summary, err = replayer.ReplayQueue(context.Background(), clients.SQS, *queueURL, *visibilityTimeout, *limit); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	fmt.Println(summary)
+	if summary.Failed > 0 {
+		os.Exit(1)
+	}
+}
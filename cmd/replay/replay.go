@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/deadletter"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/router"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/target"
+)
+
+// Replayer resubmit dead-lettered alerts through the dispatcher routes
+type Replayer struct {
+	routes  *router.Table
+	targets *target.Set
+	filter  deadletter.Filter
+	dryRun  bool
+}
+
+// Summary count the records of a replay
+type Summary struct {
+	Skipped  int
+	Replayed int
+	Failed   int
+}
+
+func (s Summary) String() string {
+	return fmt.Sprintf("%d replayed, %d failed, %d skipped", s.Replayed, s.Failed, s.Skipped)
+}
+
+// Replay validate the alert of the record and send it to its targets
+// A record of a target is only sent to that target, if the routes still select it.
+// The targets are returned, in a dry run nothing is sent
+func (r *Replayer) Replay(ctx context.Context, record *deadletter.Record) ([]string, error) {
+	alert, err := events.ParseMessage(record.Event)
+	if err != nil {
+		return nil, err
+	}
+	if alert.Kind() == events.MessageKindTest {
+		return nil, fmt.Errorf("test message, nothing to replay")
+	}
+
+	names := r.routes.Targets(alert)
+	if record.Target != "" {
+		if !contains(names, record.Target) {
+			return nil, fmt.Errorf("target %s is not a route of alert %s anymore", record.Target, alert.AlertID)
+		}
+		names = []string{record.Target}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no route for alert %s", alert.AlertID)
+	}
+
+	for _, name := range names {
+		if r.dryRun {
+			fmt.Printf("Dry run: %s -> %s\n", alert.AlertID, name)
+			continue
+		}
+		if err := r.targets.Get(name).Send(ctx, alert, record.Event); err != nil {
+			return names, fmt.Errorf("send alert %s to %s: %w", alert.AlertID, name, err)
+		}
+		fmt.Printf("Replayed %s -> %s\n", alert.AlertID, name)
+	}
+	return names, nil
+}
+
+// ReplayRecords replay the selected records
+func (r *Replayer) ReplayRecords(ctx context.Context, records []*deadletter.Record) Summary {
+	summary := Summary{}
+	for _, record := range records {
+		r.replay(ctx, record, &summary)
+	}
+	return summary
+}
+
+// ReplayQueue receive the dead-letter queue until it is empty, or limit records are replayed.
+// A replayed message is deleted, unless it is a dry run.
+// The other messages are visible again after the visibility timeout, the messages of a dry run when it end
+func (r *Replayer) ReplayQueue(ctx context.Context, client sqsiface.SQSAPI, queueURL string, visibilityTimeout int64, limit int) (Summary, error) {
+	summary := Summary{}
+	reached := func() bool { return limit > 0 && summary.Replayed+summary.Failed >= limit }
+	var received []*string
+	if r.dryRun {
+		// the messages are received until the queue is empty, so they are only visible again at the end
+		defer func() { release(ctx, client, queueURL, received) }()
+	}
+	for !reached() {
+		output, err := client.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(queueURL),
+			MaxNumberOfMessages: aws.Int64(10),
+			VisibilityTimeout:   aws.Int64(visibilityTimeout),
+			WaitTimeSeconds:     aws.Int64(1),
+		})
+		if err != nil {
+			return summary, err
+		}
+		if len(output.Messages) == 0 {
+			return summary, nil
+		}
+		for _, message := range output.Messages {
+			if reached() {
+				break
+			}
+			received = append(received, message.ReceiptHandle)
+			record, err := deadletter.Parse([]byte(aws.StringValue(message.Body)))
+			if err != nil {
+				fmt.Printf("Message %s: %s\n", aws.StringValue(message.MessageId), err.Error())
+				summary.Failed++
+				continue
+			}
+			record.MessageID = aws.StringValue(message.MessageId)
+			if !r.replay(ctx, record, &summary) || r.dryRun {
+				continue
+			}
+			if _, err := client.DeleteMessageWithContext(ctx, &sqs.DeleteMessageInput{
+				QueueUrl:      aws.String(queueURL),
+				ReceiptHandle: message.ReceiptHandle,
+			}); err != nil {
+				return summary, err
+			}
+		}
+	}
+	return summary, nil
+}
+
+// release make the received messages visible again
+func release(ctx context.Context, client sqsiface.SQSAPI, queueURL string, receiptHandles []*string) {
+	for _, receiptHandle := range receiptHandles {
+		if _, err := client.ChangeMessageVisibilityWithContext(ctx, &sqs.ChangeMessageVisibilityInput{
+			QueueUrl:          aws.String(queueURL),
+			ReceiptHandle:     receiptHandle,
+			VisibilityTimeout: aws.Int64(0),
+		}); err != nil {
+			fmt.Printf("Release message failed: %s\n", err.Error())
+		}
+	}
+}
+
+// replay a record and count it, true is returned when the record is replayed
+func (r *Replayer) replay(ctx context.Context, record *deadletter.Record, summary *Summary) bool {
+	if !r.filter.Match(record) {
+		summary.Skipped++
+		return false
+	}
+	if _, err := r.Replay(ctx, record); err != nil {
+		fmt.Printf("Replay alert %s failed: %s\n", record.AlertID, err.Error())
+		summary.Failed++
+		return false
+	}
+	summary.Replayed++
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/deadletter"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/router"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/target"
+)
+
+type mockLambda struct {
+	lambdaiface.LambdaAPI
+	invoked []string
+}
+
+func (m *mockLambda) InvokeWithContext(ctx aws.Context, input *lambda.InvokeInput, opts ...request.Option) (*lambda.InvokeOutput, error) {
+	m.invoked = append(m.invoked, aws.StringValue(input.FunctionName))
+	return &lambda.InvokeOutput{StatusCode: aws.Int64(202)}, nil
+}
+
+type mockSQS struct {
+	sqsiface.SQSAPI
+	batches  [][]*sqs.Message
+	deleted  []string
+	released []string
+}
+
+func (m *mockSQS) ChangeMessageVisibilityWithContext(ctx aws.Context, input *sqs.ChangeMessageVisibilityInput, opts ...request.Option) (*sqs.ChangeMessageVisibilityOutput, error) {
+	if aws.Int64Value(input.VisibilityTimeout) == 0 {
+		m.released = append(m.released, aws.StringValue(input.ReceiptHandle))
+	}
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+func (m *mockSQS) ReceiveMessageWithContext(ctx aws.Context, input *sqs.ReceiveMessageInput, opts ...request.Option) (*sqs.ReceiveMessageOutput, error) {
+	if len(m.batches) == 0 {
+		return &sqs.ReceiveMessageOutput{}, nil
+	}
+	batch := m.batches[0]
+	m.batches = m.batches[1:]
+	return &sqs.ReceiveMessageOutput{Messages: batch}, nil
+}
+
+func (m *mockSQS) DeleteMessageWithContext(ctx aws.Context, input *sqs.DeleteMessageInput, opts ...request.Option) (*sqs.DeleteMessageOutput, error) {
+	m.deleted = append(m.deleted, aws.StringValue(input.ReceiptHandle))
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func newReplayer(t *testing.T, client *mockLambda, filter deadletter.Filter, dryRun bool) *Replayer {
+	config := router.DefaultConfig()
+	config.Routes[1].Targets = append(config.Routes[1].Targets, "PrismaAlertNotification")
+	routes, err := router.NewTable(config)
+	assert.NoError(t, err)
+	targets, err := target.NewSet(nil, &target.Clients{Lambda: client})
+	assert.NoError(t, err)
+	return &Replayer{routes: routes, targets: targets, filter: filter, dryRun: dryRun}
+}
+
+func readEvent(t *testing.T, name string) []byte {
+	body, err := ioutil.ReadFile("../../events/testdata/" + name)
+	assert.NoError(t, err)
+	return body
+}
+
+func TestReplay(t *testing.T) {
+	vpcKiller := readEvent(t, "vpc-killer-event.json")
+	testCases := []struct {
+		name     string
+		record   *deadletter.Record
+		dryRun   bool
+		expected []string
+		invoked  []string
+		err      string
+	}{
+		{
+			name:     "every target",
+			record:   &deadletter.Record{Event: vpcKiller},
+			expected: []string{"PrismaVPCKiller", "PrismaAlertNotification"},
+			invoked:  []string{"PrismaVPCKiller", "PrismaAlertNotification"},
+		},
+		{
+			name:     "failed target",
+			record:   &deadletter.Record{Target: "PrismaVPCKiller", Event: vpcKiller},
+			expected: []string{"PrismaVPCKiller"},
+			invoked:  []string{"PrismaVPCKiller"},
+		},
+		{
+			name:     "dry run",
+			record:   &deadletter.Record{Event: vpcKiller},
+			dryRun:   true,
+			expected: []string{"PrismaVPCKiller", "PrismaAlertNotification"},
+		},
+		{
+			name:   "target not routed",
+			record: &deadletter.Record{Target: "PrismaScienceLogic", Event: vpcKiller},
+			err:    "target PrismaScienceLogic is not a route of alert P-39425 anymore",
+		},
+		{
+			name:   "invalid alert",
+			record: &deadletter.Record{Event: []byte(`{"alertId": "P-1", "alertRuleName": "VPCKiller"}`)},
+			err:    "policyId",
+		},
+		{
+			name:   "no route",
+			record: &deadletter.Record{Event: readEvent(t, "golden/aws-config.json")},
+			err:    "no route for alert",
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, testCase.name), func(t *testing.T) {
+			client := &mockLambda{}
+			replayer := newReplayer(t, client, deadletter.Filter{}, testCase.dryRun)
+			names, err := replayer.Replay(context.Background(), testCase.record)
+			if testCase.err != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), testCase.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expected, names)
+			assert.Equal(t, testCase.invoked, client.invoked)
+		})
+	}
+}
+
+func TestReplayRecords(t *testing.T) {
+	vpcKiller := readEvent(t, "vpc-killer-event.json")
+	client := &mockLambda{}
+	replayer := newReplayer(t, client, deadletter.Filter{Category: "Retryable"}, false)
+	summary := replayer.ReplayRecords(context.Background(), []*deadletter.Record{
+		{Category: "Retryable", Target: "PrismaVPCKiller", Event: vpcKiller},
+		{Category: "Validation", Event: []byte(`"{\"alertId\":"`)},
+		{Category: "Retryable", Event: []byte(`{}`)},
+	})
+	assert.Equal(t, Summary{Replayed: 1, Failed: 1, Skipped: 1}, summary)
+	assert.Equal(t, "1 replayed, 1 failed, 1 skipped", summary.String())
+	assert.Equal(t, []string{"PrismaVPCKiller"}, client.invoked)
+}
+
+func TestReplayQueue(t *testing.T) {
+	vpcKiller := string(readEvent(t, "vpc-killer-event.json"))
+	message := func(id string, body string) *sqs.Message {
+		return &sqs.Message{MessageId: aws.String(id), ReceiptHandle: aws.String("receipt-" + id), Body: aws.String(body)}
+	}
+	batches := func() [][]*sqs.Message {
+		return [][]*sqs.Message{
+			{
+				message("1", `{"source": "PrismaAlertDispatcher", "target": "PrismaVPCKiller", "category": "Retryable", "event": `+vpcKiller+`}`),
+				message("2", "not json"),
+			},
+			{message("3", vpcKiller)},
+		}
+	}
+
+	client := &mockLambda{}
+	queue := &mockSQS{batches: batches()}
+	summary, err := newReplayer(t, client, deadletter.Filter{}, false).ReplayQueue(context.Background(), queue, "dlq", 300, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, Summary{Replayed: 2, Failed: 1}, summary)
+	assert.Equal(t, []string{"receipt-1", "receipt-3"}, queue.deleted)
+	assert.Empty(t, queue.released)
+	assert.Equal(t, []string{"PrismaVPCKiller", "PrismaVPCKiller", "PrismaAlertNotification"}, client.invoked)
+
+	// a dry run keep the messages
+	client = &mockLambda{}
+	queue = &mockSQS{batches: batches()}
+	summary, err = newReplayer(t, client, deadletter.Filter{Target: "PrismaVPCKiller"}, true).ReplayQueue(context.Background(), queue, "dlq", 300, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, Summary{Replayed: 1}, summary)
+	assert.Empty(t, queue.deleted)
+	assert.Equal(t, []string{"receipt-1"}, queue.released)
+	assert.Empty(t, client.invoked)
+
+	// every message read by a dry run is visible again
+	queue = &mockSQS{batches: batches()}
+	summary, err = newReplayer(t, client, deadletter.Filter{}, true).ReplayQueue(context.Background(), queue, "dlq", 300, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, Summary{Replayed: 2, Failed: 1}, summary)
+	assert.Equal(t, []string{"receipt-1", "receipt-2", "receipt-3"}, queue.released)
+	assert.Empty(t, client.invoked)
+}
@@ -0,0 +1,258 @@
+package errors
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// Category classify an error to decide how the failed event is handled
+type Category int
+
+const (
+	// Retryable is a transient failure, such as a throttle or a service error
+	Retryable Category = iota + 1
+	// Permanent will fail again with the same input
+	Permanent
+	// NotFound is a missing resource, there is nothing left to remediate
+	NotFound
+	// Forbidden is a permission failure, such as a denied AssumeRole
+	Forbidden
+	// Validation is an invalid event or parameter
+	Validation
+	// Skipped is an event that was deliberately not processed
+	Skipped
+)
+
+var categoryNames = map[Category]string{
+	Retryable:  "Retryable",
+	Permanent:  "Permanent",
+	NotFound:   "NotFound",
+	Forbidden:  "Forbidden",
+	Validation: "Validation",
+	Skipped:    "Skipped",
+}
+
+func (c Category) String() string {
+	if name, ok := categoryNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("Category(%d)", int(c))
+}
+
+// Disposition is what the caller should do with an event that failed
+type Disposition int
+
+const (
+	// Ack the event, it must not be retried
+	Ack Disposition = iota
+	// Retry the event
+	Retry
+	// DeadLetter the event, retrying will not help
+	DeadLetter
+)
+
+var dispositionNames = map[Disposition]string{
+	Ack:        "Ack",
+	Retry:      "Retry",
+	DeadLetter: "DeadLetter",
+}
+
+func (d Disposition) String() string {
+	if name, ok := dispositionNames[d]; ok {
+		return name
+	}
+	return fmt.Sprintf("Disposition(%d)", int(d))
+}
+
+// Error is an error with a Category
+type Error struct {
+	Category Category
+	Err      error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap return the wrapped error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap return err with the category, nil is returned when err is nil
+func Wrap(category Category, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Category: category, Err: err}
+}
+
+// Wrapf format an error with the category
+func Wrapf(category Category, format string, args ...interface{}) error {
+	return &Error{Category: category, Err: fmt.Errorf(format, args...)}
+}
+
+// Classify wrap err with its category, see CategoryOf
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+	return Wrap(CategoryOf(err), err)
+}
+
+// Is return true if err belong to the category
+func Is(err error, category Category) bool {
+	return err != nil && CategoryOf(err) == category
+}
+
+// CategoryOf return the category of err
+// A wrapped *Error keep its category, AWS SDK errors are classified by code,
+// errors with a HTTP status code by the status, network errors and deadlines are Retryable.
+// Any other error, such as a JSON decode error, is Permanent
+func CategoryOf(err error) Category {
+	for current := err; current != nil; current = unwrap(current) {
+		if current == context.DeadlineExceeded {
+			return Retryable
+		}
+		switch typed := current.(type) {
+		case *Error:
+			return typed.Category
+		case ValidationErrors, *FieldError:
+			return Validation
+		case awserr.Error:
+			return awsCategory(typed)
+		case interface{ HTTPStatusCode() int }:
+			return statusCategory(typed.HTTPStatusCode())
+		case net.Error:
+			return Retryable
+		}
+	}
+	return Permanent
+}
+
+// DispositionOf return what the caller should do with an event that failed with err
+// A nil error, Skipped and NotFound are acknowledged, Retryable is retried,
+// every other category is dead-lettered
+func DispositionOf(err error) Disposition {
+	if err == nil {
+		return Ack
+	}
+	switch CategoryOf(err) {
+	case Retryable:
+		return Retry
+	case Skipped, NotFound:
+		return Ack
+	}
+	return DeadLetter
+}
+
+func unwrap(err error) error {
+	if wrapper, ok := err.(interface{ Unwrap() error }); ok {
+		return wrapper.Unwrap()
+	}
+	return nil
+}
+
+var awsCodeCategories = map[string]Category{
+	// throttles and service errors
+	"Throttling":                             Retryable,
+	"ThrottlingException":                    Retryable,
+	"ThrottledException":                     Retryable,
+	"RequestThrottled":                       Retryable,
+	"RequestThrottledException":              Retryable,
+	"RequestLimitExceeded":                   Retryable,
+	"TooManyRequestsException":               Retryable,
+	"ProvisionedThroughputExceededException": Retryable,
+	"ServiceUnavailable":                     Retryable,
+	"ServiceUnavailableException":            Retryable,
+	"InternalError":                          Retryable,
+	"InternalFailure":                        Retryable,
+	"InternalServiceError":                   Retryable,
+	"RequestTimeout":                         Retryable,
+	"RequestTimeoutException":                Retryable,
+	"DependencyViolation":                    Retryable,
+	request.ErrCodeRequestError:              Retryable,
+	request.ErrCodeResponseTimeout:           Retryable,
+	request.WaiterResourceNotReadyErrorCode:  Retryable,
+	// permissions and credentials
+	"AccessDenied":                Forbidden,
+	"AccessDeniedException":       Forbidden,
+	"UnauthorizedOperation":       Forbidden,
+	"AuthFailure":                 Forbidden,
+	"InvalidClientTokenId":        Forbidden,
+	"ExpiredToken":                Forbidden,
+	"ExpiredTokenException":       Forbidden,
+	"UnrecognizedClientException": Forbidden,
+	"SignatureDoesNotMatch":       Forbidden,
+	"OptInRequired":               Forbidden,
+	// missing resources
+	"ResourceNotFoundException": NotFound,
+	"NotFoundException":         NotFound,
+	"NoSuchEntity":              NotFound,
+	"NoSuchBucket":              NotFound,
+	"NoSuchKey":                 NotFound,
+	// invalid input
+	"ValidationError":             Validation,
+	"ValidationException":         Validation,
+	"InvalidParameter":            Validation,
+	"InvalidParameterValue":       Validation,
+	"InvalidParameterException":   Validation,
+	"InvalidParameterCombination": Validation,
+	"MissingParameter":            Validation,
+	"InvalidRequestException":     Validation,
+}
+
+// awsCategory classify an AWS SDK error by its code, then by the HTTP status of the request
+func awsCategory(err awserr.Error) Category {
+	code := err.Code()
+	if category, ok := awsCodeCategories[code]; ok {
+		return category
+	}
+	// EC2 use <Resource>.NotFound codes such as InvalidVpcID.NotFound
+	if strings.HasSuffix(code, ".NotFound") {
+		return NotFound
+	}
+	if strings.HasSuffix(code, ".Malformed") {
+		return Validation
+	}
+	if failure, ok := err.(awserr.RequestFailure); ok && failure.StatusCode() > 0 {
+		return statusCategory(failure.StatusCode())
+	}
+	if err.OrigErr() != nil {
+		return CategoryOf(err.OrigErr())
+	}
+	return Permanent
+}
+
+// statusCategory classify a HTTP status code, such as a Prisma API response
+func statusCategory(status int) Category {
+	switch {
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return Forbidden
+	case status == http.StatusNotFound:
+		return NotFound
+	case status == http.StatusBadRequest, status == http.StatusUnprocessableEntity:
+		return Validation
+	case status == http.StatusTooManyRequests, status == http.StatusRequestTimeout, status >= http.StatusInternalServerError:
+		return Retryable
+	}
+	return Permanent
+}
+
+// Handle return nil when the event that failed with err should be acknowledged,
+// otherwise the classified error is returned so the caller retry or dead-letter the event
+func Handle(err error) error {
+	if DispositionOf(err) == Ack {
+		return nil
+	}
+	return Classify(err)
+}
@@ -0,0 +1,119 @@
+package errors
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/assert"
+)
+
+type statusError int
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("status %d", int(e))
+}
+
+func (e statusError) HTTPStatusCode() int {
+	return int(e)
+}
+
+func TestCategoryOf(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected Category
+	}{
+		{name: "wrapped", err: Wrap(Skipped, New("not a false alert")), expected: Skipped},
+		{name: "wrapped twice", err: fmt.Errorf("handler: %w", Wrap(Forbidden, New("denied"))), expected: Forbidden},
+		{name: "validation errors", err: ValidationErrors{{Path: "alertId"}}, expected: Validation},
+		{name: "throttle", err: awserr.New("RequestLimitExceeded", "slow down", nil), expected: Retryable},
+		{name: "dependency violation", err: awserr.New("DependencyViolation", "in use", nil), expected: Retryable},
+		{name: "network", err: awserr.New("RequestError", "send request failed", stderrors.New("timeout")), expected: Retryable},
+		{name: "assume role denied", err: awserr.New("AccessDenied", "not authorized to perform sts:AssumeRole", nil), expected: Forbidden},
+		{name: "unauthorized operation", err: awserr.New("UnauthorizedOperation", "denied", nil), expected: Forbidden},
+		{name: "missing vpc", err: awserr.New("InvalidVpcID.NotFound", "vpc-123 does not exist", nil), expected: NotFound},
+		{name: "missing secret", err: awserr.New("ResourceNotFoundException", "Prisma", nil), expected: NotFound},
+		{name: "malformed id", err: awserr.New("InvalidVpcID.Malformed", "bad id", nil), expected: Validation},
+		{name: "invalid parameter", err: awserr.New("InvalidParameterValue", "bad value", nil), expected: Validation},
+		{name: "aws status 503", err: awserr.NewRequestFailure(awserr.New("Unknown", "unavailable", nil), 503, "id"), expected: Retryable},
+		{name: "aws unknown code", err: awserr.New("Unknown", "unknown", nil), expected: Permanent},
+		{name: "prisma 401", err: statusError(401), expected: Forbidden},
+		{name: "prisma 404", err: statusError(404), expected: NotFound},
+		{name: "prisma 400", err: statusError(400), expected: Validation},
+		{name: "prisma 429", err: statusError(429), expected: Retryable},
+		{name: "prisma 502", err: statusError(502), expected: Retryable},
+		{name: "prisma 409", err: statusError(409), expected: Permanent},
+		{name: "network timeout", err: &net.OpError{Op: "dial", Err: &net.DNSError{IsTimeout: true}}, expected: Retryable},
+		{name: "deadline", err: fmt.Errorf("send: %w", context.DeadlineExceeded), expected: Retryable},
+		{name: "json decode", err: &json.SyntaxError{Offset: 1}, expected: Permanent},
+		{name: "plain error", err: New("unknown"), expected: Permanent},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, testCase.name), func(t *testing.T) {
+			assert.Equal(t, testCase.expected, CategoryOf(testCase.err))
+			assert.True(t, Is(testCase.err, testCase.expected))
+		})
+	}
+}
+
+func TestDispositionOf(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected Disposition
+	}{
+		{err: nil, expected: Ack},
+		{err: Wrap(Skipped, New("skip")), expected: Ack},
+		{err: Wrap(NotFound, New("gone")), expected: Ack},
+		{err: Wrap(Retryable, New("throttle")), expected: Retry},
+		{err: Wrap(Permanent, New("broken")), expected: DeadLetter},
+		{err: Wrap(Forbidden, New("denied")), expected: DeadLetter},
+		{err: Wrap(Validation, New("invalid")), expected: DeadLetter},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, testCase.expected), func(t *testing.T) {
+			assert.Equal(t, testCase.expected, DispositionOf(testCase.err))
+		})
+	}
+}
+
+func TestWrap(t *testing.T) {
+	assert.Nil(t, Wrap(Retryable, nil))
+	assert.Nil(t, Classify(nil))
+
+	original := awserr.New("InvalidVpcID.NotFound", "missing", nil)
+	err := Classify(original)
+	assert.Equal(t, original.Error(), err.Error())
+	assert.True(t, stderrors.Is(err, original))
+	assert.Equal(t, err, Classify(err))
+
+	var aerr awserr.Error
+	assert.True(t, stderrors.As(err, &aerr))
+	assert.Equal(t, "InvalidVpcID.NotFound", aerr.Code())
+
+	formatted := Wrapf(Validation, "missing %s", "alertId")
+	assert.EqualError(t, formatted, "missing alertId")
+	assert.Equal(t, Validation, CategoryOf(formatted))
+}
+
+func TestHandle(t *testing.T) {
+	assert.NoError(t, Handle(nil))
+	assert.NoError(t, Handle(Wrap(Skipped, New("skip"))))
+	assert.NoError(t, Handle(awserr.New("InvalidVpcID.NotFound", "missing", nil)))
+
+	err := Handle(awserr.New("AccessDenied", "denied", nil))
+	assert.Error(t, err)
+	assert.Equal(t, Forbidden, CategoryOf(err))
+}
+
+func TestCategoryString(t *testing.T) {
+	assert.Equal(t, "NotFound", NotFound.String())
+	assert.Equal(t, "Category(42)", Category(42).String())
+	assert.Equal(t, "DeadLetter", DeadLetter.String())
+}
@@ -0,0 +1,322 @@
+package errors
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// TagName is the struct tag read by Validate
+const TagName = "validate"
+
+// FieldError describe a field that failed a validation rule
+// Path is the JSON path of the field, for example filter.timeRange.type or alerts[0]
+type FieldError struct {
+	Path    string
+	Rule    string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+// ValidationErrors contain every field that failed validation
+type ValidationErrors []*FieldError
+
+func (ve ValidationErrors) Error() string {
+	messages := make([]string, 0, len(ve))
+	for _, fieldError := range ve {
+		messages = append(messages, fieldError.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Paths return the JSON path of every failed field
+func (ve ValidationErrors) Paths() []string {
+	paths := make([]string, 0, len(ve))
+	for _, fieldError := range ve {
+		paths = append(paths, fieldError.Path)
+	}
+	return paths
+}
+
+// regexps cache compiled regexp rules by pattern
+var regexps sync.Map
+
+// Validate verify the fields of a struct, or a pointer to a struct, with the rules in the validate tag
+//
+// Supported rules, separated by comma:
+//
+//	required     the field is not empty. A bool is always present, use *bool to require it
+//	omitempty    skip the other rules when the field is empty
+//	min=N, max=N the length of a string, slice or map, or the value of a number
+//	oneof=a b c  the field is one of the space separated values
+//	regexp=expr  a string match expr. It must be the last rule, the rest of the tag is the expression
+//
+// Nested structs, pointers, slices and maps of structs are validated recursively.
+// Every failure is reported in a single ValidationErrors, an invalid input or tag return a plain error
+func Validate(v interface{}) error {
+	value := reflect.ValueOf(v)
+	if !value.IsValid() {
+		return New("validate: input is nil")
+	}
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return fmt.Errorf("validate: input %s is nil", value.Type().String())
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return fmt.Errorf("validate: expected a struct or a pointer to a struct, got %s", value.Type().String())
+	}
+
+	validator := &validator{}
+	if err := validator.validateStruct(value, ""); err != nil {
+		return err
+	}
+	if len(validator.errors) > 0 {
+		return validator.errors
+	}
+	return nil
+}
+
+type validator struct {
+	errors ValidationErrors
+}
+
+func (v *validator) validateStruct(value reflect.Value, path string) error {
+	valueType := value.Type()
+	for i := 0; i < value.NumField(); i++ {
+		structField := valueType.Field(i)
+		if structField.PkgPath != "" && !structField.Anonymous {
+			continue
+		}
+		field := value.Field(i)
+		fieldPath := path
+		name, skip := jsonName(structField)
+		if skip {
+			continue
+		}
+		// embedded struct without json name are flattened like encoding/json
+		if !(structField.Anonymous && name == "") {
+			if name == "" {
+				name = structField.Name
+			}
+			fieldPath = joinPath(path, name)
+		}
+
+		if tag, ok := structField.Tag.Lookup(TagName); ok {
+			if err := v.validateField(field, fieldPath, tag); err != nil {
+				return err
+			}
+		}
+		if err := v.validateNested(field, fieldPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateNested recurse into structs, pointers, slices, arrays and maps
+func (v *validator) validateNested(field reflect.Value, path string) error {
+	switch field.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if field.IsNil() {
+			return nil
+		}
+		return v.validateNested(field.Elem(), path)
+	case reflect.Struct:
+		return v.validateStruct(field, path)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < field.Len(); i++ {
+			if err := v.validateNested(field.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := field.MapRange()
+		for iter.Next() {
+			if err := v.validateNested(iter.Value(), fmt.Sprintf("%s[%v]", path, iter.Key())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v *validator) validateField(field reflect.Value, path string, tag string) error {
+	rules := splitRules(tag)
+	empty := isEmpty(field)
+	for _, rule := range rules {
+		if rule == "omitempty" && empty {
+			return nil
+		}
+	}
+	// a nil pointer has no value to compare, only required apply
+	nilPointer := (field.Kind() == reflect.Ptr || field.Kind() == reflect.Interface) && field.IsNil()
+
+	for _, rule := range rules {
+		name, param := rule, ""
+		if index := strings.Index(rule, "="); index >= 0 {
+			name, param = rule[:index], rule[index+1:]
+		}
+		switch name {
+		case "", "omitempty":
+		case "required":
+			if empty && field.Kind() != reflect.Bool {
+				v.fail(path, name, fmt.Sprintf("required field %s type of %s is empty", path, field.Type().String()))
+				return nil
+			}
+		case "min", "max", "oneof", "regexp":
+			if nilPointer {
+				continue
+			}
+			if err := v.validateRule(indirect(field), path, name, param); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("validate: unknown rule %s on %s", name, path)
+		}
+	}
+	return nil
+}
+
+// validateRule apply a rule that compare the value of the field
+func (v *validator) validateRule(field reflect.Value, path string, rule string, param string) error {
+	switch rule {
+	case "min", "max":
+		return v.validateBound(field, path, rule, param)
+	case "oneof":
+		options := strings.Fields(param)
+		actual := fmt.Sprintf("%v", field)
+		if !contains(options, actual) {
+			v.fail(path, rule, fmt.Sprintf("field %s must be one of [%s], got %q", path, strings.Join(options, " "), actual))
+		}
+	case "regexp":
+		expression, err := compile(param)
+		if err != nil {
+			return fmt.Errorf("validate: invalid regexp on %s: %s", path, err.Error())
+		}
+		if field.Kind() != reflect.String {
+			return fmt.Errorf("validate: regexp on %s require a string, got %s", path, field.Type().String())
+		}
+		if !expression.MatchString(field.String()) {
+			v.fail(path, rule, fmt.Sprintf("field %s must match %s", path, param))
+		}
+	}
+	return nil
+}
+
+func (v *validator) validateBound(field reflect.Value, path string, rule string, param string) error {
+	bound, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return fmt.Errorf("validate: invalid %s=%s on %s", rule, param, path)
+	}
+	var actual float64
+	unit := ""
+	switch field.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		actual = float64(field.Len())
+		unit = " in length"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		actual = float64(field.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		actual = float64(field.Uint())
+	case reflect.Float32, reflect.Float64:
+		actual = field.Float()
+	default:
+		return fmt.Errorf("validate: %s on %s is not supported for %s", rule, path, field.Type().String())
+	}
+
+	if rule == "min" && actual < bound {
+		v.fail(path, rule, fmt.Sprintf("field %s must be at least %s%s", path, param, unit))
+	}
+	if rule == "max" && actual > bound {
+		v.fail(path, rule, fmt.Sprintf("field %s must be at most %s%s", path, param, unit))
+	}
+	return nil
+}
+
+func (v *validator) fail(path string, rule string, message string) {
+	v.errors = append(v.errors, &FieldError{
+		Path:    path,
+		Rule:    rule,
+		Message: message,
+	})
+}
+
+// splitRules split the tag by comma, a regexp rule take the rest of the tag
+func splitRules(tag string) []string {
+	rules := []string{}
+	for tag != "" {
+		if strings.HasPrefix(tag, "regexp=") {
+			return append(rules, tag)
+		}
+		index := strings.Index(tag, ",")
+		if index < 0 {
+			return append(rules, strings.TrimSpace(tag))
+		}
+		rules = append(rules, strings.TrimSpace(tag[:index]))
+		tag = strings.TrimSpace(tag[index+1:])
+	}
+	return rules
+}
+
+// jsonName return the name in the json tag, skip is true for json:"-"
+func jsonName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", true
+	}
+	return strings.Split(tag, ",")[0], false
+}
+
+func joinPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func isEmpty(field reflect.Value) bool {
+	switch field.Kind() {
+	case reflect.Map, reflect.Array, reflect.Slice, reflect.String:
+		return field.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return field.IsNil()
+	}
+	return field.IsZero()
+}
+
+// indirect dereference pointers to reach the value validated by a rule
+func indirect(field reflect.Value) reflect.Value {
+	for (field.Kind() == reflect.Ptr || field.Kind() == reflect.Interface) && !field.IsNil() {
+		field = field.Elem()
+	}
+	return field
+}
+
+func contains(options []string, value string) bool {
+	for _, option := range options {
+		if option == value {
+			return true
+		}
+	}
+	return false
+}
+
+func compile(pattern string) (*regexp.Regexp, error) {
+	if cached, ok := regexps.Load(pattern); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+	expression, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexps.Store(pattern, expression)
+	return expression, nil
+}
@@ -0,0 +1,232 @@
+package errors
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type validateFilter struct {
+	Name     string `json:"name" validate:"required"`
+	Operator string `json:"operator" validate:"oneof== != in"`
+}
+
+type validateTimeRange struct {
+	Type   string `json:"type" validate:"omitempty,oneof=relative absolute to_now"`
+	Amount int    `json:"amount" validate:"min=0,max=52"`
+}
+
+type validateEmbedded struct {
+	Fields []string `json:"fields" validate:"max=2"`
+}
+
+type validateInput struct {
+	validateEmbedded
+	AlertIDs  []string                  `json:"alerts" validate:"required,min=1"`
+	Note      string                    `json:"dismissalNote" validate:"required,max=10"`
+	AlertID   string                    `json:"alertId" validate:"omitempty,regexp=^[PI]-[0-9]{1,10}$"`
+	Enabled   bool                      `json:"enabled" validate:"required"`
+	Confirmed *bool                     `json:"confirmed" validate:"required"`
+	Limit     *int                      `json:"limit" validate:"min=1"`
+	TimeRange validateTimeRange         `json:"timeRange"`
+	Filters   []validateFilter          `json:"filters"`
+	Named     map[string]validateFilter `json:"named"`
+	Ignored   validateFilter            `json:"-"`
+	NoJSON    string                    `validate:"required"`
+	hidden    string
+}
+
+func validInput() *validateInput {
+	confirmed := true
+	return &validateInput{
+		AlertIDs:  []string{"P-1"},
+		Note:      "note",
+		AlertID:   "P-123",
+		Confirmed: &confirmed,
+		TimeRange: validateTimeRange{Type: "relative", Amount: 1},
+		Filters:   []validateFilter{{Name: "alert.status", Operator: "="}},
+		Named:     map[string]validateFilter{"status": {Name: "alert.status", Operator: "!="}},
+		NoJSON:    "set",
+	}
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(validInput()))
+	assert.NoError(t, Validate(*validInput()))
+}
+
+func TestValidateFieldErrors(t *testing.T) {
+	limit := 0
+	testCases := []struct {
+		name     string
+		modify   func(input *validateInput)
+		paths    []string
+		expected string
+	}{
+		{
+			name:     "required slice",
+			modify:   func(input *validateInput) { input.AlertIDs = nil },
+			paths:    []string{"alerts"},
+			expected: "required field alerts type of []string is empty",
+		},
+		{
+			name:     "max length",
+			modify:   func(input *validateInput) { input.Note = "a long dismissal note" },
+			paths:    []string{"dismissalNote"},
+			expected: "field dismissalNote must be at most 10 in length",
+		},
+		{
+			name:     "regexp",
+			modify:   func(input *validateInput) { input.AlertID = "X-1" },
+			paths:    []string{"alertId"},
+			expected: "field alertId must match ^[PI]-[0-9]{1,10}$",
+		},
+		{
+			name:     "required pointer",
+			modify:   func(input *validateInput) { input.Confirmed = nil },
+			paths:    []string{"confirmed"},
+			expected: "required field confirmed type of *bool is empty",
+		},
+		{
+			name:     "min of pointer value",
+			modify:   func(input *validateInput) { input.Limit = &limit },
+			paths:    []string{"limit"},
+			expected: "field limit must be at least 1",
+		},
+		{
+			name:     "nested struct",
+			modify:   func(input *validateInput) { input.TimeRange = validateTimeRange{Type: "week", Amount: 53} },
+			paths:    []string{"timeRange.type", "timeRange.amount"},
+			expected: `field timeRange.type must be one of [relative absolute to_now], got "week"; field timeRange.amount must be at most 52`,
+		},
+		{
+			name: "slice of struct",
+			modify: func(input *validateInput) {
+				input.Filters = append(input.Filters, validateFilter{Operator: "like"})
+			},
+			paths:    []string{"filters[1].name", "filters[1].operator"},
+			expected: `required field filters[1].name type of string is empty; field filters[1].operator must be one of [= != in], got "like"`,
+		},
+		{
+			name:     "map of struct",
+			modify:   func(input *validateInput) { input.Named["status"] = validateFilter{Operator: "="} },
+			paths:    []string{"named[status].name"},
+			expected: "required field named[status].name type of string is empty",
+		},
+		{
+			name:     "embedded struct is flattened",
+			modify:   func(input *validateInput) { input.Fields = []string{"a", "b", "c"} },
+			paths:    []string{"fields"},
+			expected: "field fields must be at most 2 in length",
+		},
+		{
+			name:     "field without json tag use the field name",
+			modify:   func(input *validateInput) { input.NoJSON = "" },
+			paths:    []string{"NoJSON"},
+			expected: "required field NoJSON type of string is empty",
+		},
+		{
+			name: "every failure is reported",
+			modify: func(input *validateInput) {
+				input.AlertIDs = nil
+				input.Note = ""
+				input.Confirmed = nil
+			},
+			paths:    []string{"alerts", "dismissalNote", "confirmed"},
+			expected: "required field alerts type of []string is empty; required field dismissalNote type of string is empty; required field confirmed type of *bool is empty",
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, testCase.name), func(t *testing.T) {
+			input := validInput()
+			testCase.modify(input)
+			err := Validate(input)
+			assert.EqualError(t, err, testCase.expected)
+			validationErrors, ok := err.(ValidationErrors)
+			assert.True(t, ok)
+			assert.Equal(t, testCase.paths, validationErrors.Paths())
+		})
+	}
+}
+
+func TestValidateIgnoredFields(t *testing.T) {
+	input := validInput()
+	input.Ignored = validateFilter{Operator: "like"}
+	input.hidden = ""
+	input.Enabled = false
+	input.AlertID = ""
+	input.TimeRange.Type = ""
+	assert.NoError(t, Validate(input))
+}
+
+func TestValidateInvalidInput(t *testing.T) {
+	var nilInput *validateInput
+	number := 1
+
+	testCases := []struct {
+		name     string
+		input    interface{}
+		expected string
+	}{
+		{
+			name:     "nil",
+			input:    nil,
+			expected: "validate: input is nil",
+		},
+		{
+			name:     "nil pointer",
+			input:    nilInput,
+			expected: "validate: input *errors.validateInput is nil",
+		},
+		{
+			name:     "not a struct",
+			input:    &number,
+			expected: "validate: expected a struct or a pointer to a struct, got int",
+		},
+		{
+			name: "unknown rule",
+			input: &struct {
+				Name string `json:"name" validate:"email"`
+			}{},
+			expected: "validate: unknown rule email on name",
+		},
+		{
+			name: "invalid bound",
+			input: &struct {
+				Name string `json:"name" validate:"min=a"`
+			}{},
+			expected: "validate: invalid min=a on name",
+		},
+		{
+			name: "invalid regexp",
+			input: &struct {
+				Name string `json:"name" validate:"regexp=[a"`
+			}{},
+			expected: "validate: invalid regexp on name: error parsing regexp: missing closing ]: `[a`",
+		},
+		{
+			name: "regexp on number",
+			input: &struct {
+				Count int `json:"count" validate:"regexp=^1$"`
+			}{},
+			expected: "validate: regexp on count require a string, got int",
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, testCase.name), func(t *testing.T) {
+			err := Validate(testCase.input)
+			assert.EqualError(t, err, testCase.expected)
+			_, isValidationErrors := err.(ValidationErrors)
+			assert.False(t, isValidationErrors)
+		})
+	}
+}
+
+func TestSplitRules(t *testing.T) {
+	assert.Equal(t, []string{"required", "min=1"}, splitRules("required, min=1"))
+	assert.Equal(t, []string{"omitempty", "regexp=^a,b{1,2}$"}, splitRules("omitempty,regexp=^a,b{1,2}$"))
+	assert.Equal(t, []string{}, splitRules(""))
+}
@@ -14,8 +14,13 @@ func New(message string) error {
 // An error is returned when field is an empty string or nil
 // It does not verifier integer or float
 // omitfields specify the field name that will be omitted
+// New structs should use validate tags and Validate instead
 func FieldsVerifier(structPointer interface{}, omitfields ...string) error {
-	elements := reflect.ValueOf(structPointer).Elem()
+	pointer := reflect.ValueOf(structPointer)
+	if pointer.Kind() != reflect.Ptr || pointer.IsNil() || pointer.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("FieldsVerifier: expected a struct pointer, got %T", structPointer)
+	}
+	elements := pointer.Elem()
 
 	for i := 0; i < elements.NumField(); i++ {
 		fieldName := elements.Type().Field(i).Name
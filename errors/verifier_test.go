@@ -37,4 +37,11 @@ func TestFieldsVerifier(t *testing.T) {
 	}
 
 	assert.NoError(t, FieldsVerifier(noErrorTestCase))
-}
\ No newline at end of file
+}
+
+func TestFieldsVerifierInvalidInput(t *testing.T) {
+	var nilField *TestField
+	assert.EqualError(t, FieldsVerifier(TestField{}), "FieldsVerifier: expected a struct pointer, got errors.TestField")
+	assert.EqualError(t, FieldsVerifier(nilField), "FieldsVerifier: expected a struct pointer, got *errors.TestField")
+	assert.EqualError(t, FieldsVerifier(nil), "FieldsVerifier: expected a struct pointer, got <nil>")
+}
@@ -0,0 +1,201 @@
+package events
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+)
+
+// AlertEvent is the alert payload Prisma send to the SQS integration
+// resource.data and resourceConfig are kept raw and decoded by resource type on demand
+type AlertEvent struct {
+	ResourceID           string          `json:"resourceId"`
+	AlertRuleName        string          `json:"alertRuleName"`
+	AccountName          string          `json:"accountName"`
+	HasFinding           bool            `json:"hasFinding"`
+	ResourceRegionID     string          `json:"resourceRegionId"`
+	AlertRemediationCli  interface{}     `json:"alertRemediationCli"`
+	Source               string          `json:"source"`
+	CloudType            string          `json:"cloudType" validate:"required,oneof=aws azure gcp alibaba_cloud oci"`
+	ComplianceMetadata   interface{}     `json:"complianceMetadata"`
+	CallbackURL          string          `json:"callbackUrl"`
+	AlertID              string          `json:"alertId" validate:"required,regexp=^[PI]-[0-9]+$"`
+	PolicyLabels         []string        `json:"policyLabels"`
+	AlertAttribution     interface{}     `json:"alertAttribution"`
+	Severity             string          `json:"severity" validate:"required,oneof=critical high medium low informational"`
+	PolicyName           string          `json:"policyName"`
+	Resource             AlertResource   `json:"resource"`
+	ResourceName         string          `json:"resourceName"`
+	RiskRating           string          `json:"riskRating"`
+	ResourceRegion       string          `json:"resourceRegion"`
+	PolicyDescription    string          `json:"policyDescription"`
+	PolicyRecommendation string          `json:"policyRecommendation"`
+	AccountID            string          `json:"accountId" validate:"required"`
+	ResourceConfig       json.RawMessage `json:"resourceConfig"`
+	PolicyID             string          `json:"policyId" validate:"required"`
+	ResourceCloudService string          `json:"resourceCloudService"`
+	AlertTs              int64           `json:"alertTs" validate:"min=1000000000000,max=9999999999999"`
+	FindingSummary       interface{}     `json:"findingSummary"`
+	ResourceType         string          `json:"resourceType"`
+	// PolicyType is sent by newer Prisma integrations, it is inferred from the resource type when empty
+	PolicyType string `json:"policyType,omitempty" validate:"omitempty,oneof=config audit_event network anomaly iam"`
+	// AlertStatus is sent with status updates, such as a resolved alert
+	AlertStatus string `json:"alertStatus,omitempty" validate:"omitempty,oneof=open resolved dismissed snoozed"`
+	// Message is the text of a test message
+	Message string `json:"message,omitempty"`
+	// Tenant select the Prisma tenant profile, it is not part of the Prisma payload
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// AlertResource is the resource of the alert
+type AlertResource struct {
+	Data               json.RawMessage `json:"data"`
+	URL                interface{}     `json:"url"`
+	Rrn                *string         `json:"rrn"`
+	CloudAccountGroups json.RawMessage `json:"cloudAccountGroups,omitempty"`
+	AccountID          string          `json:"accountId"`
+	ResourceTags       json.RawMessage `json:"resourceTags,omitempty"`
+	RegionID           string          `json:"regionId"`
+	CloudType          string          `json:"cloudType"`
+	ResourceAPIName    interface{}     `json:"resourceApiName"`
+	Name               string          `json:"name"`
+	AdditionalInfo     interface{}     `json:"additionalInfo"`
+	ID                 string          `json:"id"`
+	Region             string          `json:"region"`
+	Account            string          `json:"account"`
+	ResourceType       string          `json:"resourceType"`
+}
+
+// resourceTypes map a normalized resource type to the factory of its config type
+var resourceTypes = struct {
+	sync.RWMutex
+	factories map[string]func() interface{}
+}{factories: map[string]func() interface{}{}}
+
+// RegisterResourceType register the type a resource config of the resource types is decoded into
+// factory return a pointer to a new value, such as func() interface{} { return &VPCConfig{} }
+func RegisterResourceType(factory func() interface{}, resourceTypeNames ...string) {
+	resourceTypes.Lock()
+	defer resourceTypes.Unlock()
+	for _, name := range resourceTypeNames {
+		resourceTypes.factories[NormalizeResourceType(name)] = factory
+	}
+}
+
+// NormalizeResourceType return the registry key of a resource type
+// "Virtual Network" and "VIRTUAL_NETWORK" are the same resource type
+func NormalizeResourceType(resourceType string) string {
+	resourceType = strings.TrimSpace(strings.ToUpper(resourceType))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(resourceType)
+}
+
+// ResourceTypeKey return the normalized resource type of the alert
+// resource.resourceType is used first, then the top level resourceType
+func (e *AlertEvent) ResourceTypeKey() string {
+	if e.Resource.ResourceType != "" {
+		return NormalizeResourceType(e.Resource.ResourceType)
+	}
+	return NormalizeResourceType(e.ResourceType)
+}
+
+// DecodeResourceConfig decode resourceConfig into the type registered for the resource type
+func (e *AlertEvent) DecodeResourceConfig() (interface{}, error) {
+	return e.decodeRegistered(e.ResourceConfig, "resourceConfig")
+}
+
+// DecodeResourceData decode resource.data into the type registered for the resource type
+func (e *AlertEvent) DecodeResourceData() (interface{}, error) {
+	return e.decodeRegistered(e.Resource.Data, "resource.data")
+}
+
+func (e *AlertEvent) decodeRegistered(raw json.RawMessage, field string) (interface{}, error) {
+	resourceType := e.ResourceTypeKey()
+	resourceTypes.RLock()
+	factory, ok := resourceTypes.factories[resourceType]
+	resourceTypes.RUnlock()
+	if !ok {
+		return nil, errors.Wrapf(errors.Validation, "unsupported resource type: %s", resourceType)
+	}
+	config := factory()
+	if err := decodeRaw(raw, field, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// decodeConfig decode resourceConfig into config when the alert is one of the resource types
+func (e *AlertEvent) decodeConfig(config interface{}, resourceTypeNames ...string) error {
+	resourceType := e.ResourceTypeKey()
+	for _, name := range resourceTypeNames {
+		if NormalizeResourceType(name) == resourceType {
+			return decodeRaw(e.ResourceConfig, "resourceConfig", config)
+		}
+	}
+	return errors.Wrapf(errors.Validation, "resource type %s is not %s", resourceType, strings.Join(resourceTypeNames, " or "))
+}
+
+func decodeRaw(raw json.RawMessage, field string, target interface{}) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return errors.Wrapf(errors.Validation, "%s is empty", field)
+	}
+	if err := json.Unmarshal(raw, target); err != nil {
+		return errors.Wrapf(errors.Validation, "invalid %s: %s", field, err.Error())
+	}
+	return nil
+}
+
+// VPC return the VPC config of a virtual network alert
+func (e *AlertEvent) VPC() (*VPCConfig, error) {
+	config := &VPCConfig{}
+	if err := e.decodeConfig(config, vpcResourceTypes...); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Subnet return the subnet config of a subnet alert
+func (e *AlertEvent) Subnet() (*SubnetConfig, error) {
+	config := &SubnetConfig{}
+	if err := e.decodeConfig(config, subnetResourceTypes...); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Instance return the instance config of an instance alert
+func (e *AlertEvent) Instance() (*InstanceConfig, error) {
+	config := &InstanceConfig{}
+	if err := e.decodeConfig(config, instanceResourceTypes...); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// SecurityGroup return the security group config of a security group alert
+func (e *AlertEvent) SecurityGroup() (*SecurityGroupConfig, error) {
+	config := &SecurityGroupConfig{}
+	if err := e.decodeConfig(config, securityGroupResourceTypes...); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// S3Bucket return the bucket config of a S3 bucket alert
+func (e *AlertEvent) S3Bucket() (*S3BucketConfig, error) {
+	config := &S3BucketConfig{}
+	if err := e.decodeConfig(config, s3BucketResourceTypes...); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// IAMUser return the user config of an IAM user alert
+func (e *AlertEvent) IAMUser() (*IAMUserConfig, error) {
+	config := &IAMUserConfig{}
+	if err := e.decodeConfig(config, iamUserResourceTypes...); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
@@ -0,0 +1,220 @@
+package events_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+	"github.com/aws/aws-lambda-go/events/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func readAlertEvent(t *testing.T, path string) events.AlertEvent {
+	var event events.AlertEvent
+	if err := json.Unmarshal(test.ReadJSONFromFile(t, path), &event); err != nil {
+		t.Fatalf("could not unmarshal event. details: %v", err)
+	}
+	return event
+}
+
+func TestAlertEventMarshaling(t *testing.T) {
+	testCases := []string{
+		"./testdata/prisma-event.json",
+		"./testdata/vpc-killer-event.json",
+		"./testdata/delete-subnet-event.json",
+	}
+
+	for i, path := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, path), func(t *testing.T) {
+			inputJSON := test.ReadJSONFromFile(t, path)
+
+			var inputEvent events.AlertEvent
+			if err := json.Unmarshal(inputJSON, &inputEvent); err != nil {
+				t.Errorf("could not unmarshal event. details: %v", err)
+			}
+
+			outputJSON, err := json.Marshal(inputEvent)
+			if err != nil {
+				t.Errorf("could not marshal event. details: %v", err)
+			}
+
+			assert.JSONEq(t, string(inputJSON), string(outputJSON))
+		})
+	}
+}
+
+func TestAlertEventPartialPayload(t *testing.T) {
+	event := readAlertEvent(t, "./testdata/high-alert.json")
+
+	assert.Equal(t, "i-test", event.ResourceID)
+	assert.Equal(t, "High Alert Notifications", event.AlertRuleName)
+	assert.Equal(t, "us-east-1", event.ResourceRegionID)
+	assert.Equal(t, "aws", event.CloudType)
+	assert.Equal(t, "P-44729", event.AlertID)
+	assert.Equal(t, "618305550854", event.AccountID)
+	assert.Empty(t, event.ResourceConfig)
+	assert.Empty(t, event.Tenant)
+}
+
+func TestAlertEventMarshalingMalformedJson(t *testing.T) {
+	test.TestMalformedJson(t, &events.AlertEvent{})
+}
+
+func TestNormalizeResourceType(t *testing.T) {
+	assert.Equal(t, "VIRTUAL_NETWORK", events.NormalizeResourceType("Virtual Network"))
+	assert.Equal(t, "VIRTUAL_NETWORK", events.NormalizeResourceType(" virtual-network "))
+	assert.Equal(t, "INSTANCE", events.NormalizeResourceType("INSTANCE"))
+}
+
+func TestAlertEventVPC(t *testing.T) {
+	event := readAlertEvent(t, "./testdata/vpc-killer-event.json")
+	assert.Equal(t, events.ResourceTypeVPC, event.ResourceTypeKey())
+
+	vpc, err := event.VPC()
+	assert.NoError(t, err)
+	assert.Equal(t, "vpc-123", vpc.VpcID)
+	assert.Equal(t, []events.Tag{{Key: "Name", Value: "ptest"}}, vpc.Tags)
+	assert.Equal(t, "associated", vpc.CidrBlockAssociationSet[0].CidrBlockState.State)
+
+	config, err := event.DecodeResourceConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, vpc, config)
+
+	data, err := event.DecodeResourceData()
+	assert.NoError(t, err)
+	assert.IsType(t, &events.VPCConfig{}, data)
+
+	_, err = event.Instance()
+	assert.EqualError(t, err, "resource type VIRTUAL_NETWORK is not INSTANCE or EC2_INSTANCE")
+	assert.True(t, errors.Is(err, errors.Validation))
+}
+
+func TestAlertEventTypedAccessors(t *testing.T) {
+	testCases := []struct {
+		name         string
+		resourceType string
+		config       string
+		decode       func(event *events.AlertEvent) (interface{}, error)
+		expected     interface{}
+	}{
+		{
+			name:         "subnet",
+			resourceType: "SUBNET",
+			config:       `{"subnetId":"subnet-1","vpcId":"vpc-1","mapPublicIpOnLaunch":true}`,
+			decode:       func(event *events.AlertEvent) (interface{}, error) { return event.Subnet() },
+			expected:     &events.SubnetConfig{SubnetID: "subnet-1", VpcID: "vpc-1", MapPublicIPOnLaunch: true},
+		},
+		{
+			name:         "instance",
+			resourceType: "Instance",
+			config:       `{"instanceId":"i-1","vpcId":"vpc-1","securityGroups":[{"groupId":"sg-1","groupName":"web"}]}`,
+			decode:       func(event *events.AlertEvent) (interface{}, error) { return event.Instance() },
+			expected: &events.InstanceConfig{
+				InstanceID:     "i-1",
+				VpcID:          "vpc-1",
+				SecurityGroups: []events.GroupIdentifier{{GroupID: "sg-1", GroupName: "web"}},
+			},
+		},
+		{
+			name:         "security group",
+			resourceType: "Security Group",
+			config:       `{"groupId":"sg-1","vpcId":"vpc-1","ipPermissions":[{"ipProtocol":"tcp","fromPort":22,"toPort":22}]}`,
+			decode:       func(event *events.AlertEvent) (interface{}, error) { return event.SecurityGroup() },
+			expected: &events.SecurityGroupConfig{
+				GroupID:       "sg-1",
+				VpcID:         "vpc-1",
+				IPPermissions: []events.IPPermission{{IPProtocol: "tcp", FromPort: 22, ToPort: 22}},
+			},
+		},
+		{
+			name:         "S3 bucket",
+			resourceType: "STORAGE_BUCKET",
+			config:       `{"bucketName":"bucket","region":"us-east-1"}`,
+			decode:       func(event *events.AlertEvent) (interface{}, error) { return event.S3Bucket() },
+			expected:     &events.S3BucketConfig{BucketName: "bucket", Region: "us-east-1"},
+		},
+		{
+			name:         "IAM user",
+			resourceType: "IAM_USER",
+			config:       `{"userName":"user","arn":"arn:aws:iam::123456789012:user/user","groups":["admins"]}`,
+			decode:       func(event *events.AlertEvent) (interface{}, error) { return event.IAMUser() },
+			expected: &events.IAMUserConfig{
+				UserName: "user",
+				Arn:      "arn:aws:iam::123456789012:user/user",
+				Groups:   []string{"admins"},
+			},
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, testCase.name), func(t *testing.T) {
+			event := &events.AlertEvent{
+				ResourceType:   testCase.resourceType,
+				ResourceConfig: json.RawMessage(testCase.config),
+			}
+			config, err := testCase.decode(event)
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expected, config)
+
+			registered, err := event.DecodeResourceConfig()
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expected, registered)
+		})
+	}
+}
+
+func TestAlertEventDecodeErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		event    *events.AlertEvent
+		expected string
+	}{
+		{
+			name:     "unsupported resource type",
+			event:    &events.AlertEvent{ResourceType: "Managed Database", ResourceConfig: json.RawMessage(`{}`)},
+			expected: "unsupported resource type: MANAGED_DATABASE",
+		},
+		{
+			name:     "missing config",
+			event:    &events.AlertEvent{ResourceType: "Subnet"},
+			expected: "resourceConfig is empty",
+		},
+		{
+			name:     "null config",
+			event:    &events.AlertEvent{ResourceType: "Subnet", ResourceConfig: json.RawMessage(`null`)},
+			expected: "resourceConfig is empty",
+		},
+		{
+			name:     "invalid config",
+			event:    &events.AlertEvent{ResourceType: "Subnet", ResourceConfig: json.RawMessage(`{"subnetId":1}`)},
+			expected: "invalid resourceConfig: json: cannot unmarshal number into Go struct field SubnetConfig.subnetId of type string",
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, testCase.name), func(t *testing.T) {
+			_, err := testCase.event.DecodeResourceConfig()
+			assert.EqualError(t, err, testCase.expected)
+			assert.True(t, errors.Is(err, errors.Validation))
+		})
+	}
+}
+
+type databaseConfig struct {
+	Engine string `json:"engine"`
+}
+
+func TestRegisterResourceType(t *testing.T) {
+	events.RegisterResourceType(func() interface{} { return &databaseConfig{} }, "Database Instance")
+
+	event := &events.AlertEvent{
+		Resource:       events.AlertResource{ResourceType: "DATABASE_INSTANCE"},
+		ResourceType:   "Database Instance",
+		ResourceConfig: json.RawMessage(`{"engine":"postgres"}`),
+	}
+	config, err := event.DecodeResourceConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, &databaseConfig{Engine: "postgres"}, config)
+}
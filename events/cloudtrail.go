@@ -0,0 +1,104 @@
+package events
+
+import (
+	"time"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+)
+
+// User identity types of a CloudTrail event
+const (
+	IdentityTypeRoot          = "Root"
+	IdentityTypeIAMUser       = "IAMUser"
+	IdentityTypeAssumedRole   = "AssumedRole"
+	IdentityTypeFederatedUser = "FederatedUser"
+	IdentityTypeAWSService    = "AWSService"
+	IdentityTypeAWSAccount    = "AWSAccount"
+)
+
+func init() {
+	RegisterResourceType(func() interface{} { return &CloudTrailEvent{} }, ResourceTypeAuditEvent)
+}
+
+// CloudTrailEvent is the CloudTrail record of an AWS audit event alert
+type CloudTrailEvent struct {
+	EventVersion        string                 `json:"eventVersion"`
+	EventID             string                 `json:"eventID"`
+	EventTime           time.Time              `json:"eventTime"`
+	EventSource         string                 `json:"eventSource"`
+	EventName           string                 `json:"eventName"`
+	EventType           string                 `json:"eventType"`
+	AwsRegion           string                 `json:"awsRegion"`
+	SourceIPAddress     string                 `json:"sourceIPAddress"`
+	UserAgent           string                 `json:"userAgent"`
+	UserIdentity        UserIdentity           `json:"userIdentity"`
+	RequestParameters   map[string]interface{} `json:"requestParameters"`
+	ResponseElements    map[string]interface{} `json:"responseElements"`
+	AdditionalEventData map[string]interface{} `json:"additionalEventData,omitempty"`
+	Resources           []CloudTrailResource   `json:"resources,omitempty"`
+	ErrorCode           string                 `json:"errorCode,omitempty"`
+	ErrorMessage        string                 `json:"errorMessage,omitempty"`
+	RequestID           string                 `json:"requestID"`
+	RecipientAccountID  string                 `json:"recipientAccountId"`
+}
+
+// CloudTrailResource is a resource the CloudTrail event acted on
+type CloudTrailResource struct {
+	ResourceName string `json:"resourceName"`
+	ResourceType string `json:"resourceType"`
+}
+
+// UserIdentity is the identity that made the request
+type UserIdentity struct {
+	Type           string         `json:"type"`
+	PrincipalID    string         `json:"principalId"`
+	Arn            string         `json:"arn"`
+	AccountID      string         `json:"accountId"`
+	AccessKeyID    string         `json:"accessKeyId,omitempty"`
+	UserName       string         `json:"userName,omitempty"`
+	InvokedBy      string         `json:"invokedBy,omitempty"`
+	SessionContext SessionContext `json:"sessionContext"`
+}
+
+// SessionContext is the session of temporary credentials
+type SessionContext struct {
+	SessionIssuer SessionIssuer `json:"sessionIssuer"`
+	Attributes    struct {
+		MfaAuthenticated string `json:"mfaAuthenticated"`
+		CreationDate     string `json:"creationDate"`
+	} `json:"attributes"`
+}
+
+// SessionIssuer is the role or user that issued the temporary credentials
+type SessionIssuer struct {
+	Type        string `json:"type"`
+	PrincipalID string `json:"principalId"`
+	Arn         string `json:"arn"`
+	AccountID   string `json:"accountId"`
+	UserName    string `json:"userName"`
+}
+
+// Failed return true when the request was denied or failed
+func (c *CloudTrailEvent) Failed() bool {
+	return c.ErrorCode != ""
+}
+
+// CloudTrail return the CloudTrail event of an AWS audit event alert
+// resourceConfig is decoded, resource.data is used when Prisma did not send it
+func (e *AlertEvent) CloudTrail() (*CloudTrailEvent, error) {
+	if e.CloudType != "aws" {
+		return nil, errors.Wrapf(errors.Validation, "cloud type %s has no CloudTrail event", e.CloudType)
+	}
+	if isEmptyField(e.ResourceConfig) && e.ResourceTypeKey() == ResourceTypeAuditEvent {
+		config := &CloudTrailEvent{}
+		if err := decodeRaw(e.Resource.Data, "resource.data", config); err != nil {
+			return nil, err
+		}
+		return config, nil
+	}
+	config := &CloudTrailEvent{}
+	if err := e.decodeConfig(config, ResourceTypeAuditEvent); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
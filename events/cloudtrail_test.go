@@ -0,0 +1,60 @@
+package events_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlertEventCloudTrail(t *testing.T) {
+	event := readAlertEvent(t, "./testdata/delete-subnet-event.json")
+
+	trail, err := event.CloudTrail()
+	assert.NoError(t, err)
+	assert.Equal(t, "DeleteSubnet", trail.EventName)
+	assert.Equal(t, "ec2.amazonaws.com", trail.EventSource)
+	assert.Equal(t, "ap-northeast-1", trail.AwsRegion)
+	assert.Equal(t, "1.2.3.4", trail.SourceIPAddress)
+	assert.Equal(t, "subnet-1a1b", trail.RequestParameters["subnetId"])
+	assert.Equal(t, time.Date(2020, 2, 4, 15, 51, 27, 0, time.UTC), trail.EventTime)
+	assert.Equal(t, []events.CloudTrailResource{{ResourceName: "subnet-1a1b", ResourceType: "AWS::EC2::Subnet"}}, trail.Resources)
+	assert.Equal(t, events.IdentityTypeAssumedRole, trail.UserIdentity.Type)
+	assert.Equal(t, "AWSReservedSSO", trail.UserIdentity.SessionContext.SessionIssuer.UserName)
+	assert.False(t, trail.Failed())
+
+	config, err := event.DecodeResourceConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, trail, config)
+}
+
+func TestAlertEventCloudTrailFromResourceData(t *testing.T) {
+	event := readAlertEvent(t, "./testdata/golden/aws-audit-event.json")
+	event.ResourceConfig = nil
+
+	trail, err := event.CloudTrail()
+	assert.NoError(t, err)
+	assert.Equal(t, "DeleteVpc", trail.EventName)
+	assert.Equal(t, events.IdentityTypeAssumedRole, trail.UserIdentity.Type)
+}
+
+func TestAlertEventCloudTrailErrors(t *testing.T) {
+	azure := readAlertEvent(t, "./testdata/golden/azure-audit-event.json")
+	_, err := azure.CloudTrail()
+	assert.EqualError(t, err, "cloud type azure has no CloudTrail event")
+
+	config := readAlertEvent(t, "./testdata/golden/aws-config.json")
+	_, err = config.CloudTrail()
+	assert.EqualError(t, err, "resource type SECURITY_GROUP is not AUDIT_EVENT")
+
+	failed := &events.AlertEvent{
+		CloudType:      "aws",
+		ResourceType:   "Audit Event",
+		ResourceConfig: json.RawMessage(`{"eventName":"DeleteVpc","errorCode":"Client.UnauthorizedOperation"}`),
+	}
+	trail, err := failed.CloudTrail()
+	assert.NoError(t, err)
+	assert.True(t, trail.Failed())
+}
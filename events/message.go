@@ -0,0 +1,60 @@
+package events
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+)
+
+// Alert status of a status update
+const (
+	AlertStatusOpen      = "open"
+	AlertStatusResolved  = "resolved"
+	AlertStatusDismissed = "dismissed"
+	AlertStatusSnoozed   = "snoozed"
+)
+
+// MessageKind is the kind of a message Prisma send to the SQS integration
+type MessageKind string
+
+const (
+	// MessageKindAlert is an open alert to remediate
+	MessageKindAlert MessageKind = "alert"
+	// MessageKindTest is sent when the integration is saved or tested, it has no alert
+	MessageKindTest MessageKind = "test"
+	// MessageKindResolved is sent when the alert is closed
+	MessageKindResolved MessageKind = "resolved"
+)
+
+// Kind return the kind of the message
+// A message without alertId, policyId and alertRuleName is a test message
+func (e *AlertEvent) Kind() MessageKind {
+	if e.AlertID == "" && e.PolicyID == "" && e.AlertRuleName == "" {
+		return MessageKindTest
+	}
+	if strings.EqualFold(e.AlertStatus, AlertStatusResolved) {
+		return MessageKindResolved
+	}
+	return MessageKindAlert
+}
+
+// IsResolved return true when the message is a resolved notification
+func (e *AlertEvent) IsResolved() bool {
+	return e.Kind() == MessageKindResolved
+}
+
+// ParseMessage decode the body of a SQS message
+// Alerts are validated, test and resolved messages are returned as is
+func ParseMessage(body []byte) (*AlertEvent, error) {
+	event := &AlertEvent{}
+	if err := json.Unmarshal(body, event); err != nil {
+		return nil, errors.Wrapf(errors.Validation, "invalid message: %s", err.Error())
+	}
+	if event.Kind() == MessageKindAlert {
+		if err := event.Validate(); err != nil {
+			return event, err
+		}
+	}
+	return event, nil
+}
@@ -0,0 +1,84 @@
+package events_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+	"github.com/aws/aws-lambda-go/events/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMessage(t *testing.T) {
+	testCases := []struct {
+		path     string
+		kind     events.MessageKind
+		resolved bool
+	}{
+		{
+			path: "./testdata/vpc-killer-event.json",
+			kind: events.MessageKindAlert,
+		},
+		{
+			path: "./testdata/golden/gcp-iam.json",
+			kind: events.MessageKindAlert,
+		},
+		{
+			path:     "./testdata/resolved-event.json",
+			kind:     events.MessageKindResolved,
+			resolved: true,
+		},
+		{
+			path: "./testdata/test-message.json",
+			kind: events.MessageKindTest,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, testCase.path), func(t *testing.T) {
+			event, err := events.ParseMessage(test.ReadJSONFromFile(t, testCase.path))
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.kind, event.Kind())
+			assert.Equal(t, testCase.resolved, event.IsResolved())
+		})
+	}
+}
+
+func TestParseTestMessage(t *testing.T) {
+	event, err := events.ParseMessage(test.ReadJSONFromFile(t, "./testdata/test-message.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, "This is a test notification from Prisma Cloud", event.Message)
+}
+
+func TestParseMessageErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{
+			name:     "not json",
+			body:     "Unsupported Alert",
+			expected: "invalid message: invalid character 'U' looking for beginning of value",
+		},
+		{
+			name:     "invalid alert",
+			body:     `{"alertId":"P-1","alertRuleName":"VPCKiller","policyId":"1","cloudType":"aws","severity":"high","accountId":"123456789012","alertTs":1583337711208}`,
+			expected: "required field resourceId type of string is empty; required field resourceType type of string is empty; required field resourceRegionId type of string is empty; required field resourceConfig type of json.RawMessage is empty",
+		},
+		{
+			name:     "unknown status",
+			body:     `{"alertId":"P-1","alertRuleName":"VPCKiller","policyId":"1","cloudType":"aws","severity":"high","accountId":"123456789012","alertTs":1583337711208,"alertStatus":"closed","resourceId":"vpc-1","resourceType":"Virtual Network","resourceRegionId":"us-east-1","resourceConfig":{}}`,
+			expected: `field alertStatus must be one of [open resolved dismissed snoozed], got "closed"`,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, testCase.name), func(t *testing.T) {
+			_, err := events.ParseMessage([]byte(testCase.body))
+			assert.EqualError(t, err, testCase.expected)
+			assert.True(t, errors.Is(err, errors.Validation))
+		})
+	}
+}
@@ -6,6 +6,8 @@ type OnBoardEvent struct {
 	AWS        AWSAccount   `json:"awsAccount"`
 	Azure      AzureAccount `json:"azureAccount"`
 	GCP        GCP          `json:"gcpAccount"`
+	// Tenant select the Prisma tenant profile to onboard the account
+	Tenant string `json:"tenant,omitempty"`
 }
 
 type AWSAccount struct {
@@ -0,0 +1,178 @@
+package events
+
+// Resource types of the Prisma resource, the top level resourceType use the display name such as "Virtual Network"
+const (
+	ResourceTypeVPC           = "VIRTUAL_NETWORK"
+	ResourceTypeSubnet        = "SUBNET"
+	ResourceTypeInstance      = "INSTANCE"
+	ResourceTypeSecurityGroup = "SECURITY_GROUP"
+	ResourceTypeS3Bucket      = "STORAGE_BUCKET"
+	ResourceTypeIAMUser       = "IAM_USER"
+	ResourceTypeAuditEvent    = "AUDIT_EVENT"
+)
+
+var (
+	vpcResourceTypes           = []string{ResourceTypeVPC, "VPC"}
+	subnetResourceTypes        = []string{ResourceTypeSubnet}
+	instanceResourceTypes      = []string{ResourceTypeInstance, "EC2_INSTANCE"}
+	securityGroupResourceTypes = []string{ResourceTypeSecurityGroup}
+	s3BucketResourceTypes      = []string{ResourceTypeS3Bucket, "S3_BUCKET", "BUCKET"}
+	iamUserResourceTypes       = []string{ResourceTypeIAMUser, "USER"}
+)
+
+func init() {
+	RegisterResourceType(func() interface{} { return &VPCConfig{} }, vpcResourceTypes...)
+	RegisterResourceType(func() interface{} { return &SubnetConfig{} }, subnetResourceTypes...)
+	RegisterResourceType(func() interface{} { return &InstanceConfig{} }, instanceResourceTypes...)
+	RegisterResourceType(func() interface{} { return &SecurityGroupConfig{} }, securityGroupResourceTypes...)
+	RegisterResourceType(func() interface{} { return &S3BucketConfig{} }, s3BucketResourceTypes...)
+	RegisterResourceType(func() interface{} { return &IAMUserConfig{} }, iamUserResourceTypes...)
+}
+
+// Tag is a resource tag
+type Tag struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// CidrBlockAssociation is a CIDR block of a VPC
+type CidrBlockAssociation struct {
+	CidrBlock      string `json:"cidrBlock"`
+	CidrBlockState struct {
+		State string `json:"state"`
+	} `json:"cidrBlockState"`
+	AssociationID string `json:"associationId"`
+}
+
+// VPCConfig is the resource config of a VPC
+type VPCConfig struct {
+	InstanceTenancy             string                 `json:"instanceTenancy"`
+	CidrBlock                   string                 `json:"cidrBlock"`
+	CidrBlockAssociationSet     []CidrBlockAssociation `json:"cidrBlockAssociationSet"`
+	OwnerID                     string                 `json:"ownerId"`
+	Tags                        []Tag                  `json:"tags"`
+	Default                     bool                   `json:"default"`
+	IsDefault                   bool                   `json:"isDefault"`
+	DhcpOptionsID               string                 `json:"dhcpOptionsId"`
+	VpcID                       string                 `json:"vpcId"`
+	State                       string                 `json:"state"`
+	SecurityGroupCount          int                    `json:"securityGroupCount"`
+	SubnetCount                 int                    `json:"subnetCount"`
+	Ipv6CidrBlockAssociationSet []interface{}          `json:"ipv6CidrBlockAssociationSet"`
+}
+
+// SubnetConfig is the resource config of a subnet
+type SubnetConfig struct {
+	SubnetID                string `json:"subnetId"`
+	SubnetArn               string `json:"subnetArn"`
+	VpcID                   string `json:"vpcId"`
+	CidrBlock               string `json:"cidrBlock"`
+	AvailabilityZone        string `json:"availabilityZone"`
+	AvailableIPAddressCount int    `json:"availableIpAddressCount"`
+	DefaultForAz            bool   `json:"defaultForAz"`
+	MapPublicIPOnLaunch     bool   `json:"mapPublicIpOnLaunch"`
+	OwnerID                 string `json:"ownerId"`
+	State                   string `json:"state"`
+	Tags                    []Tag  `json:"tags"`
+}
+
+// GroupIdentifier is a security group attached to a resource
+type GroupIdentifier struct {
+	GroupID   string `json:"groupId"`
+	GroupName string `json:"groupName"`
+}
+
+// InstanceConfig is the resource config of an EC2 instance
+type InstanceConfig struct {
+	InstanceID         string            `json:"instanceId"`
+	InstanceType       string            `json:"instanceType"`
+	ImageID            string            `json:"imageId"`
+	VpcID              string            `json:"vpcId"`
+	SubnetID           string            `json:"subnetId"`
+	PrivateIPAddress   string            `json:"privateIpAddress"`
+	PublicIPAddress    string            `json:"publicIpAddress"`
+	KeyName            string            `json:"keyName"`
+	LaunchTime         string            `json:"launchTime"`
+	SecurityGroups     []GroupIdentifier `json:"securityGroups"`
+	Tags               []Tag             `json:"tags"`
+	IamInstanceProfile struct {
+		Arn string `json:"arn"`
+		ID  string `json:"id"`
+	} `json:"iamInstanceProfile"`
+	State struct {
+		Code int    `json:"code"`
+		Name string `json:"name"`
+	} `json:"state"`
+}
+
+// IPPermission is an ingress or egress rule of a security group
+type IPPermission struct {
+	IPProtocol string `json:"ipProtocol"`
+	FromPort   int    `json:"fromPort"`
+	ToPort     int    `json:"toPort"`
+	IPRanges   []struct {
+		CidrIP      string `json:"cidrIp"`
+		Description string `json:"description"`
+	} `json:"ipv4Ranges"`
+	Ipv6Ranges []struct {
+		CidrIpv6    string `json:"cidrIpv6"`
+		Description string `json:"description"`
+	} `json:"ipv6Ranges"`
+	UserIDGroupPairs []struct {
+		GroupID string `json:"groupId"`
+		UserID  string `json:"userId"`
+	} `json:"userIdGroupPairs"`
+}
+
+// SecurityGroupConfig is the resource config of a security group
+type SecurityGroupConfig struct {
+	GroupID             string         `json:"groupId"`
+	GroupName           string         `json:"groupName"`
+	Description         string         `json:"description"`
+	VpcID               string         `json:"vpcId"`
+	OwnerID             string         `json:"ownerId"`
+	IPPermissions       []IPPermission `json:"ipPermissions"`
+	IPPermissionsEgress []IPPermission `json:"ipPermissionsEgress"`
+	Tags                []Tag          `json:"tags"`
+}
+
+// S3BucketConfig is the resource config of a S3 bucket
+type S3BucketConfig struct {
+	BucketName   string `json:"bucketName"`
+	Region       string `json:"region"`
+	CreationDate string `json:"creationDate"`
+	Owner        struct {
+		ID          string `json:"id"`
+		DisplayName string `json:"displayName"`
+	} `json:"owner"`
+	VersioningConfiguration struct {
+		Status           string `json:"status"`
+		MfaDeleteEnabled bool   `json:"mfaDeleteEnabled"`
+	} `json:"versioningConfiguration"`
+	PublicAccessBlockConfiguration struct {
+		BlockPublicAcls       bool `json:"blockPublicAcls"`
+		IgnorePublicAcls      bool `json:"ignorePublicAcls"`
+		BlockPublicPolicy     bool `json:"blockPublicPolicy"`
+		RestrictPublicBuckets bool `json:"restrictPublicBuckets"`
+	} `json:"publicAccessBlockConfiguration"`
+	SSEAlgorithm string      `json:"sseAlgorithm"`
+	Policy       interface{} `json:"policy"`
+	Tags         []Tag       `json:"tags"`
+}
+
+// IAMUserConfig is the resource config of an IAM user
+type IAMUserConfig struct {
+	UserName         string   `json:"userName"`
+	UserID           string   `json:"userId"`
+	Arn              string   `json:"arn"`
+	Path             string   `json:"path"`
+	CreateDate       string   `json:"createDate"`
+	PasswordLastUsed string   `json:"passwordLastUsed"`
+	Groups           []string `json:"groups"`
+	AttachedPolicies []struct {
+		PolicyName string `json:"policyName"`
+		PolicyArn  string `json:"policyArn"`
+	} `json:"attachedPolicies"`
+	MfaDevices []interface{} `json:"mfaDevices"`
+	Tags       []Tag         `json:"tags"`
+}
@@ -0,0 +1,138 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+)
+
+// Policy types of an alert
+const (
+	PolicyTypeConfig     = "config"
+	PolicyTypeAuditEvent = "audit_event"
+	PolicyTypeNetwork    = "network"
+	PolicyTypeAnomaly    = "anomaly"
+	PolicyTypeIAM        = "iam"
+)
+
+type accountIDFormat struct {
+	expression  *regexp.Regexp
+	description string
+}
+
+// accountIDFormats is the account ID format of each cloud type
+var accountIDFormats = map[string]accountIDFormat{
+	"aws":   {regexp.MustCompile(`^[0-9]{12}$`), "a 12 digit AWS account ID"},
+	"azure": {regexp.MustCompile(`^[0-9a-fA-F]{8}-([0-9a-fA-F]{4}-){3}[0-9a-fA-F]{12}$`), "an Azure subscription ID"},
+	"gcp":   {regexp.MustCompile(`^[a-z][a-z0-9-]{4,28}[a-z0-9]$`), "a GCP project ID"},
+}
+
+type requiredField struct {
+	path  string
+	value interface{}
+}
+
+// AlertType return the policy type of the alert
+// Older integrations do not send policyType, audit events are then recognized by resource type
+// and every other alert is a config alert
+func (e *AlertEvent) AlertType() string {
+	if e.PolicyType != "" {
+		return e.PolicyType
+	}
+	if e.ResourceTypeKey() == ResourceTypeAuditEvent {
+		return PolicyTypeAuditEvent
+	}
+	return PolicyTypeConfig
+}
+
+// Validate verify the alert can be processed
+// The validate tags are checked first, then the fields required by the alert type and the account ID format.
+// Every failed field is reported in an errors.ValidationErrors
+func (e *AlertEvent) Validate() error {
+	validationErrors := errors.ValidationErrors{}
+	if err := errors.Validate(e); err != nil {
+		tagErrors, ok := err.(errors.ValidationErrors)
+		if !ok {
+			return err
+		}
+		validationErrors = append(validationErrors, tagErrors...)
+	}
+
+	for _, field := range e.requiredFields() {
+		if isEmptyField(field.value) {
+			validationErrors = append(validationErrors, &errors.FieldError{
+				Path:    field.path,
+				Rule:    "required",
+				Message: fmt.Sprintf("required field %s type of %s is empty", field.path, typeName(field.value)),
+			})
+		}
+	}
+
+	if format, ok := accountIDFormats[e.CloudType]; ok && e.AccountID != "" && !format.expression.MatchString(e.AccountID) {
+		validationErrors = append(validationErrors, &errors.FieldError{
+			Path:    "accountId",
+			Rule:    "format",
+			Message: fmt.Sprintf("field accountId must be %s, got %q", format.description, e.AccountID),
+		})
+	}
+	if e.Resource.AccountID != "" && e.AccountID != "" && e.Resource.AccountID != e.AccountID {
+		validationErrors = append(validationErrors, &errors.FieldError{
+			Path:    "resource.accountId",
+			Rule:    "eqfield",
+			Message: fmt.Sprintf("field resource.accountId must equal accountId %q, got %q", e.AccountID, e.Resource.AccountID),
+		})
+	}
+
+	if len(validationErrors) > 0 {
+		return validationErrors
+	}
+	return nil
+}
+
+// requiredFields return the fields the alert type can not be processed without
+func (e *AlertEvent) requiredFields() []requiredField {
+	fields := []requiredField{{"resourceId", e.ResourceID}}
+	switch e.AlertType() {
+	case PolicyTypeConfig:
+		fields = append(fields,
+			requiredField{"resourceType", e.ResourceType},
+			requiredField{"resourceRegionId", e.ResourceRegionID},
+			requiredField{"resourceConfig", e.ResourceConfig},
+		)
+	case PolicyTypeAuditEvent:
+		fields = append(fields,
+			requiredField{"resourceRegionId", e.ResourceRegionID},
+			requiredField{"resourceConfig", e.ResourceConfig},
+		)
+	case PolicyTypeNetwork:
+		fields = append(fields,
+			requiredField{"resourceType", e.ResourceType},
+			requiredField{"resourceRegionId", e.ResourceRegionID},
+		)
+	case PolicyTypeAnomaly:
+		fields = append(fields, requiredField{"resource.data", e.Resource.Data})
+	case PolicyTypeIAM:
+		fields = append(fields, requiredField{"resourceName", e.ResourceName})
+	}
+	return fields
+}
+
+func isEmptyField(value interface{}) bool {
+	switch typed := value.(type) {
+	case string:
+		return typed == ""
+	case json.RawMessage:
+		return len(typed) == 0 || string(typed) == "null"
+	}
+	return value == nil
+}
+
+// typeName return the declared type name, %T resolve json.RawMessage to its underlying type on newer Go
+func typeName(value interface{}) string {
+	if _, ok := value.(json.RawMessage); ok {
+		return "json.RawMessage"
+	}
+	return fmt.Sprintf("%T", value)
+}
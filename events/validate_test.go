@@ -0,0 +1,193 @@
+package events_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+	"github.com/aws/aws-lambda-go/events/test"
+	"github.com/stretchr/testify/assert"
+)
+
+var goldenAlertTypes = map[string]string{
+	"config":      events.PolicyTypeConfig,
+	"audit-event": events.PolicyTypeAuditEvent,
+	"network":     events.PolicyTypeNetwork,
+	"anomaly":     events.PolicyTypeAnomaly,
+	"iam":         events.PolicyTypeIAM,
+}
+
+func TestGoldenCorpus(t *testing.T) {
+	paths, err := filepath.Glob("./testdata/golden/*.json")
+	assert.NoError(t, err)
+	assert.Len(t, paths, 15)
+
+	for i, path := range paths {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, filepath.Base(path)), func(t *testing.T) {
+			inputJSON := test.ReadJSONFromFile(t, path)
+
+			var inputEvent events.AlertEvent
+			if err := json.Unmarshal(inputJSON, &inputEvent); err != nil {
+				t.Fatalf("could not unmarshal event. details: %v", err)
+			}
+
+			outputJSON, err := json.Marshal(inputEvent)
+			if err != nil {
+				t.Errorf("could not marshal event. details: %v", err)
+			}
+			assert.JSONEq(t, string(inputJSON), string(outputJSON))
+
+			assert.NoError(t, inputEvent.Validate())
+
+			// file names are <cloudType>-<alert type>.json
+			name := strings.TrimSuffix(filepath.Base(path), ".json")
+			parts := strings.SplitN(name, "-", 2)
+			assert.Equal(t, parts[0], inputEvent.CloudType)
+			assert.Equal(t, goldenAlertTypes[parts[1]], inputEvent.AlertType())
+		})
+	}
+}
+
+func TestTestdataEventsAreValid(t *testing.T) {
+	for i, path := range []string{"./testdata/prisma-event.json", "./testdata/vpc-killer-event.json", "./testdata/delete-subnet-event.json"} {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, path), func(t *testing.T) {
+			event := readAlertEvent(t, path)
+			assert.NoError(t, event.Validate())
+		})
+	}
+}
+
+func TestAlertType(t *testing.T) {
+	assert.Equal(t, events.PolicyTypeConfig, (&events.AlertEvent{ResourceType: "Instance"}).AlertType())
+	assert.Equal(t, events.PolicyTypeAuditEvent, (&events.AlertEvent{ResourceType: "Audit Event"}).AlertType())
+	assert.Equal(t, events.PolicyTypeNetwork, (&events.AlertEvent{ResourceType: "Audit Event", PolicyType: "network"}).AlertType())
+}
+
+func TestAlertEventValidate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		path     string
+		modify   func(event *events.AlertEvent)
+		paths    []string
+		expected string
+	}{
+		{
+			name:     "alert ID format",
+			path:     "aws-config.json",
+			modify:   func(event *events.AlertEvent) { event.AlertID = "A-1" },
+			paths:    []string{"alertId"},
+			expected: "field alertId must match ^[PI]-[0-9]+$",
+		},
+		{
+			name:     "unknown cloud type",
+			path:     "aws-config.json",
+			modify:   func(event *events.AlertEvent) { event.CloudType = "ibm" },
+			paths:    []string{"cloudType"},
+			expected: `field cloudType must be one of [aws azure gcp alibaba_cloud oci], got "ibm"`,
+		},
+		{
+			name:     "timestamp in seconds",
+			path:     "aws-config.json",
+			modify:   func(event *events.AlertEvent) { event.AlertTs = 1583337711 },
+			paths:    []string{"alertTs"},
+			expected: "field alertTs must be at least 1000000000000",
+		},
+		{
+			name:     "unknown policy type",
+			path:     "aws-network.json",
+			modify:   func(event *events.AlertEvent) { event.PolicyType = "data" },
+			paths:    []string{"policyType"},
+			expected: `field policyType must be one of [config audit_event network anomaly iam], got "data"`,
+		},
+		{
+			name:     "config alert without resource config",
+			path:     "aws-config.json",
+			modify:   func(event *events.AlertEvent) { event.ResourceConfig = nil },
+			paths:    []string{"resourceConfig"},
+			expected: "required field resourceConfig type of json.RawMessage is empty",
+		},
+		{
+			name:     "audit event with null resource config",
+			path:     "aws-audit-event.json",
+			modify:   func(event *events.AlertEvent) { event.ResourceConfig = json.RawMessage("null") },
+			paths:    []string{"resourceConfig"},
+			expected: "required field resourceConfig type of json.RawMessage is empty",
+		},
+		{
+			name:     "network alert without region",
+			path:     "gcp-network.json",
+			modify:   func(event *events.AlertEvent) { event.ResourceRegionID = "" },
+			paths:    []string{"resourceRegionId"},
+			expected: "required field resourceRegionId type of string is empty",
+		},
+		{
+			name:     "anomaly without resource data",
+			path:     "azure-anomaly.json",
+			modify:   func(event *events.AlertEvent) { event.Resource.Data = nil },
+			paths:    []string{"resource.data"},
+			expected: "required field resource.data type of json.RawMessage is empty",
+		},
+		{
+			name:     "IAM alert without resource name",
+			path:     "gcp-iam.json",
+			modify:   func(event *events.AlertEvent) { event.ResourceName = "" },
+			paths:    []string{"resourceName"},
+			expected: "required field resourceName type of string is empty",
+		},
+		{
+			name: "AWS account ID",
+			path: "aws-iam.json",
+			modify: func(event *events.AlertEvent) {
+				event.AccountID = "12345"
+				event.Resource.AccountID = "12345"
+			},
+			paths:    []string{"accountId"},
+			expected: `field accountId must be a 12 digit AWS account ID, got "12345"`,
+		},
+		{
+			name:     "Azure subscription ID",
+			path:     "azure-config.json",
+			modify:   func(event *events.AlertEvent) { event.AccountID = "123456789012" },
+			paths:    []string{"accountId", "resource.accountId"},
+			expected: `field accountId must be an Azure subscription ID, got "123456789012"; field resource.accountId must equal accountId "123456789012", got "8d3e6b3c-5f44-4a6f-9a36-2f3e1b5a7c21"`,
+		},
+		{
+			name: "GCP project ID",
+			path: "gcp-config.json",
+			modify: func(event *events.AlertEvent) {
+				event.AccountID = "NYC_SBX"
+				event.Resource.AccountID = ""
+			},
+			paths:    []string{"accountId"},
+			expected: `field accountId must be a GCP project ID, got "NYC_SBX"`,
+		},
+		{
+			name: "every failure is reported",
+			path: "aws-config.json",
+			modify: func(event *events.AlertEvent) {
+				event.AlertID = ""
+				event.Severity = "urgent"
+				event.ResourceID = ""
+			},
+			paths:    []string{"alertId", "severity", "resourceId"},
+			expected: `required field alertId type of string is empty; field severity must be one of [critical high medium low informational], got "urgent"; required field resourceId type of string is empty`,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, testCase.name), func(t *testing.T) {
+			event := readAlertEvent(t, "./testdata/golden/"+testCase.path)
+			testCase.modify(&event)
+			err := event.Validate()
+			assert.EqualError(t, err, testCase.expected)
+			validationErrors, ok := err.(errors.ValidationErrors)
+			assert.True(t, ok)
+			assert.Equal(t, testCase.paths, validationErrors.Paths())
+			assert.True(t, errors.Is(err, errors.Validation))
+		})
+	}
+}
@@ -28,10 +28,10 @@ func ListAlerts() {
 
 	fmt.Println("Request for alerts")
 	resp, err := client.ListAlerts(&prisma.ListAlertsInput{
-		map[string]string{
+		Params: map[string]string{
 			"detailed": "false",
 		},
-		prisma.ListAlertsPayload{
+		ListAlertsPayload: prisma.ListAlertsPayload{
 			Filters: prisma.Filters{
 				{
 					Name:     "policy.name",
@@ -0,0 +1,335 @@
+package approval
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+)
+
+const (
+	// TableEnv contain the DynamoDB table of the approvals
+	TableEnv = "APPROVAL_TABLE"
+	// DirEnv contain the local directory of the approvals, it is used when APPROVAL_TABLE is empty
+	DirEnv = "APPROVAL_DIR"
+	// TTLEnv contain how long an approval can be decided, such as 24h
+	TTLEnv = "APPROVAL_TTL"
+	// URLEnv contain the base URL of the approve and reject links, the API of the approver
+	URLEnv = "APPROVAL_URL"
+	// TopicEnv contain the SNS topic the approval requests are published to
+	TopicEnv = "APPROVAL_TOPIC_ARN"
+	// WebhookEnv contain the HTTPS endpoint the approval requests are posted to
+	WebhookEnv = "APPROVAL_WEBHOOK_URL"
+	// DefaultTTL is how long an approval can be decided by default
+	DefaultTTL = 24 * time.Hour
+)
+
+// Status of an approval, a pending approval is decided once
+// A pending approval is closed when its alert is resolved before an approver decide
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+	StatusClosed   = "closed"
+)
+
+// ClosedBy is the approver of a closed approval, Prisma resolved the alert
+const ClosedBy = "Prisma"
+
+// Decisions of the approve and reject links
+const (
+	DecisionApprove = "approve"
+	DecisionReject  = "reject"
+)
+
+// RejectedErrorName is the error a rejected approval fail the waiting state machine task with
+const RejectedErrorName = "RejectedError"
+
+// Approval is the request to run a remediation, it hold the dry-run plan until an approver decide
+type Approval struct {
+	// Token is the single-use secret of the approve and reject links
+	Token       string `json:"token"`
+	AlertID     string `json:"alertId"`
+	AccountID   string `json:"accountId"`
+	Region      string `json:"region"`
+	ResourceID  string `json:"resourceId"`
+	Remediation string `json:"remediation"`
+	// Plan is the dry-run plan shown to the approvers
+	Plan string `json:"plan"`
+	// State is what the approval resume, the state of the state machine with the plan it execute
+	State json.RawMessage `json:"state,omitempty"`
+	// TaskToken is the token of the state machine task that wait for the decision
+	TaskToken string     `json:"taskToken,omitempty"`
+	Status    string     `json:"status"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	Approver  string     `json:"approver,omitempty"`
+	DecidedAt *time.Time `json:"decidedAt,omitempty"`
+}
+
+// Decision is who decided an approval and when, it is added to the state the approval resume
+type Decision struct {
+	Token     string    `json:"token"`
+	Status    string    `json:"status"`
+	Approver  string    `json:"approver"`
+	DecidedAt time.Time `json:"decidedAt"`
+}
+
+// Decision return the decision of the approval
+func (a *Approval) Decision() Decision {
+	decision := Decision{Token: a.Token, Status: a.Status, Approver: a.Approver}
+	if a.DecidedAt != nil {
+		decision.DecidedAt = *a.DecidedAt
+	}
+	return decision
+}
+
+// Output return the state of the approval with its decision in the approval field
+func (a *Approval) Output() ([]byte, error) {
+	fields := map[string]json.RawMessage{}
+	if len(a.State) > 0 {
+		if err := json.Unmarshal(a.State, &fields); err != nil {
+			return nil, errors.Wrap(errors.Permanent, fmt.Errorf("decode state of approval %s: %w", a.Token, err))
+		}
+	}
+	decision, err := json.Marshal(a.Decision())
+	if err != nil {
+		return nil, errors.Wrap(errors.Permanent, err)
+	}
+	fields["approval"] = decision
+	output, err := json.Marshal(fields)
+	if err != nil {
+		return nil, errors.Wrap(errors.Permanent, err)
+	}
+	return output, nil
+}
+
+// Store keep the approvals
+type Store interface {
+	// Put write the approval
+	Put(ctx context.Context, approval Approval) error
+	// Get return the approval of the token, nil when it does not exist
+	Get(ctx context.Context, token string) (*Approval, error)
+	// Decide record the decision of the pending approval if it is not expired at the time of the decision
+	// false is returned when the approval was decided already or expired
+	Decide(ctx context.Context, token string, status string, approver string, decidedAt time.Time) (bool, error)
+	// Pending return the pending approvals of the alert
+	Pending(ctx context.Context, alertID string) ([]Approval, error)
+}
+
+// NewStoreFromEnv create a store on the DynamoDB table in APPROVAL_TABLE, or on the directory in APPROVAL_DIR
+// nil is returned when neither is set, the approvals are disabled
+func NewStoreFromEnv(client dynamodbiface.DynamoDBAPI) Store {
+	if table := os.Getenv(TableEnv); table != "" {
+		return NewDynamoDBStore(table, client)
+	}
+	if dir := os.Getenv(DirEnv); dir != "" {
+		return NewDirStore(dir)
+	}
+	return nil
+}
+
+// Links are the URLs an approver open to decide
+type Links struct {
+	Approve string `json:"approve"`
+	Reject  string `json:"reject"`
+}
+
+// Gate request the approvals and record the decisions
+// A store error is Retryable
+type Gate struct {
+	// Now return the current time, time.Now by default
+	Now func() time.Time
+	// TTL is how long an approval can be decided
+	TTL time.Duration
+	// URL is the base URL of the links
+	URL string
+
+	store    Store
+	notifier Notifier
+}
+
+// NewGate create a gate on the store, the notifier send the approval requests
+func NewGate(store Store, notifier Notifier, baseURL string, ttl time.Duration) *Gate {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Gate{Now: time.Now, TTL: ttl, URL: strings.TrimSuffix(baseURL, "/"), store: store, notifier: notifier}
+}
+
+// NewGateFromEnv create a gate on the store of the environment, it notify APPROVAL_TOPIC_ARN and APPROVAL_WEBHOOK_URL
+// nil is returned when the approvals are disabled
+func NewGateFromEnv(dynamoDB dynamodbiface.DynamoDBAPI, snsClient snsiface.SNSAPI, httpClient HTTPClient) (*Gate, error) {
+	store := NewStoreFromEnv(dynamoDB)
+	if store == nil {
+		return nil, nil
+	}
+	ttl := DefaultTTL
+	if value := os.Getenv(TTLEnv); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("invalid %s %q: must be a positive duration", TTLEnv, value)
+		}
+		ttl = parsed
+	}
+	notifiers := Notifiers{}
+	if topicArn := os.Getenv(TopicEnv); topicArn != "" {
+		notifiers = append(notifiers, NewSNSNotifier(topicArn, snsClient))
+	}
+	if webhookURL := os.Getenv(WebhookEnv); webhookURL != "" {
+		if !strings.HasPrefix(webhookURL, "https://") {
+			return nil, fmt.Errorf("invalid %s %q: must be a https URL", WebhookEnv, webhookURL)
+		}
+		notifiers = append(notifiers, NewWebhookNotifier(webhookURL, httpClient))
+	}
+	return NewGate(store, notifiers, os.Getenv(URLEnv), ttl), nil
+}
+
+// NewToken return a random token of 32 bytes in hexadecimal
+func NewToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// Links return the approve and reject links of the approval
+func (g *Gate) Links(approval *Approval) Links {
+	link := func(decision string) string {
+		return fmt.Sprintf("%s/approvals/%s/%s", g.URL, url.PathEscape(approval.Token), decision)
+	}
+	return Links{Approve: link(DecisionApprove), Reject: link(DecisionReject)}
+}
+
+// Request store the pending approval with a new token and notify the approvers
+func (g *Gate) Request(ctx context.Context, approval *Approval) error {
+	if g.URL == "" {
+		return errors.Wrapf(errors.Permanent, "%s is empty, the approvers cannot be sent the links", URLEnv)
+	}
+	token, err := NewToken()
+	if err != nil {
+		return errors.Wrap(errors.Retryable, err)
+	}
+	now := g.Now().UTC()
+	approval.Token = token
+	approval.Status = StatusPending
+	approval.CreatedAt = now
+	approval.ExpiresAt = now.Add(g.TTL)
+	if err := g.store.Put(ctx, *approval); err != nil {
+		return errors.Wrap(errors.Retryable, fmt.Errorf("write approval of alert %s: %w", approval.AlertID, err))
+	}
+	if g.notifier != nil {
+		if err := g.notifier.Notify(ctx, approval, g.Links(approval)); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Approval of alert %s requested until %s\n", approval.AlertID, approval.ExpiresAt.Format(time.RFC3339))
+	return nil
+}
+
+// Get return the approval of the token
+// A missing approval is NotFound
+func (g *Gate) Get(ctx context.Context, token string) (*Approval, error) {
+	approval, err := g.store.Get(ctx, token)
+	if err != nil {
+		return nil, errors.Wrap(errors.Retryable, fmt.Errorf("read approval: %w", err))
+	}
+	if approval == nil {
+		return nil, errors.Wrapf(errors.NotFound, "approval not found")
+	}
+	return approval, nil
+}
+
+// Decide record the decision of the approver and return the decided approval
+// The approver is required, an approval is decided once and not after it expired
+func (g *Gate) Decide(ctx context.Context, token string, decision string, approver string) (*Approval, error) {
+	var status string
+	switch decision {
+	case DecisionApprove:
+		status = StatusApproved
+	case DecisionReject:
+		status = StatusRejected
+	default:
+		return nil, errors.Wrapf(errors.Validation, "unknown decision %q, must be %s or %s", decision, DecisionApprove, DecisionReject)
+	}
+	if approver == "" {
+		return nil, errors.Wrapf(errors.Forbidden, "the approver of a decision is required")
+	}
+	approval, err := g.Get(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	now := g.Now().UTC()
+	if err := approval.Decidable(now); err != nil {
+		return nil, err
+	}
+	decided, err := g.store.Decide(ctx, token, status, approver, now)
+	if err != nil {
+		return nil, errors.Wrap(errors.Retryable, fmt.Errorf("write decision of approval of alert %s: %w", approval.AlertID, err))
+	}
+	if !decided {
+		// another decision was recorded since the approval was read
+		if approval, err = g.Get(ctx, token); err != nil {
+			return nil, err
+		}
+		if err := approval.Decidable(now); err != nil {
+			return nil, err
+		}
+		return nil, errors.Wrapf(errors.Validation, "approval of alert %s cannot be decided", approval.AlertID)
+	}
+	approval.Status, approval.Approver, approval.DecidedAt = status, approver, &now
+	fmt.Printf("Approval of alert %s %s by %s\n", approval.AlertID, status, approver)
+	return approval, nil
+}
+
+// Close close the pending approvals of the resolved alert and return them, their links do not work anymore
+// The expired approvals are left as they are
+func (g *Gate) Close(ctx context.Context, alertID string) ([]Approval, error) {
+	pending, err := g.store.Pending(ctx, alertID)
+	if err != nil {
+		return nil, errors.Wrap(errors.Retryable, fmt.Errorf("read approvals of alert %s: %w", alertID, err))
+	}
+	now := g.Now().UTC()
+	closed := []Approval{}
+	for _, approval := range pending {
+		decided, err := g.store.Decide(ctx, approval.Token, StatusClosed, ClosedBy, now)
+		if err != nil {
+			return closed, errors.Wrap(errors.Retryable, fmt.Errorf("close approval of alert %s: %w", alertID, err))
+		}
+		if !decided {
+			continue
+		}
+		approval.Status, approval.Approver, approval.DecidedAt = StatusClosed, ClosedBy, &now
+		closed = append(closed, approval)
+	}
+	fmt.Printf("%d approvals of alert %s are closed\n", len(closed), alertID)
+	return closed, nil
+}
+
+// Decidable return a Validation error when the approval was decided or expired at now
+func (a *Approval) Decidable(now time.Time) error {
+	if a.Status != StatusPending {
+		return errors.Wrapf(errors.Validation, "approval of alert %s was %s by %s", a.AlertID, a.Status, a.Approver)
+	}
+	if !now.Before(a.ExpiresAt) {
+		return errors.Wrapf(errors.Validation, "approval of alert %s expired at %s", a.AlertID, a.ExpiresAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// HTTPClient send the requests of the webhook notifier
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
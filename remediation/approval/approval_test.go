@@ -0,0 +1,213 @@
+package approval_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/approval"
+)
+
+// recordingNotifier keep the approval requests
+type recordingNotifier struct {
+	approvals []*approval.Approval
+	links     []approval.Links
+	err       error
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, a *approval.Approval, links approval.Links) error {
+	n.approvals = append(n.approvals, a)
+	n.links = append(n.links, links)
+	return n.err
+}
+
+func newApproval() *approval.Approval {
+	return &approval.Approval{
+		AlertID:     "P-39425",
+		AccountID:   "123456789012",
+		Region:      "us-west-2",
+		ResourceID:  "vpc-1",
+		Remediation: "delete",
+		Plan:        "Plan to delete vpc-1 in 123456789012 us-west-2:\n  1. delete vpc vpc-1\n",
+		State:       json.RawMessage(`{"plan":{"vpcId":"vpc-1"},"done":false}`),
+		TaskToken:   "task-token",
+	}
+}
+
+// withGate return a gate on a temporary directory at the time now
+func withGate(t *testing.T, now *time.Time) (*approval.Gate, *recordingNotifier, func()) {
+	dir, err := ioutil.TempDir("", "approval")
+	assert.NoError(t, err)
+	notifier := &recordingNotifier{}
+	gate := approval.NewGate(approval.NewDirStore(dir), notifier, "https://approvals.example.com/Prod/", time.Hour)
+	gate.Now = func() time.Time { return *now }
+	return gate, notifier, func() { os.RemoveAll(dir) }
+}
+
+func TestGateRequest(t *testing.T) {
+	now := time.Date(2020, 2, 21, 22, 0, 0, 0, time.UTC)
+	gate, notifier, cleanup := withGate(t, &now)
+	defer cleanup()
+	ctx := context.Background()
+
+	requested := newApproval()
+	assert.NoError(t, gate.Request(ctx, requested))
+	assert.Len(t, requested.Token, 64)
+	assert.Equal(t, approval.StatusPending, requested.Status)
+	assert.Equal(t, now.Add(time.Hour), requested.ExpiresAt)
+	assert.Equal(t, []*approval.Approval{requested}, notifier.approvals)
+	assert.Equal(t, approval.Links{
+		Approve: "https://approvals.example.com/Prod/approvals/" + requested.Token + "/approve",
+		Reject:  "https://approvals.example.com/Prod/approvals/" + requested.Token + "/reject",
+	}, notifier.links[0])
+
+	stored, err := gate.Get(ctx, requested.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, requested, stored)
+
+	// a new request of the same alert has a new token
+	again := newApproval()
+	assert.NoError(t, gate.Request(ctx, again))
+	assert.NotEqual(t, requested.Token, again.Token)
+
+	_, err = gate.Get(ctx, "unknown")
+	assert.Equal(t, errors.NotFound, errors.CategoryOf(err))
+
+	gate.URL = ""
+	assert.Equal(t, errors.Permanent, errors.CategoryOf(gate.Request(ctx, newApproval())))
+}
+
+func TestGateDecide(t *testing.T) {
+	testCases := []struct {
+		name     string
+		decision string
+		approver string
+		elapsed  time.Duration
+		status   string
+		category errors.Category
+	}{
+		{name: "approve", decision: approval.DecisionApprove, approver: "jdoe@example.com", status: approval.StatusApproved},
+		{name: "reject", decision: approval.DecisionReject, approver: "jdoe@example.com", status: approval.StatusRejected},
+		{name: "before expiry", decision: approval.DecisionApprove, approver: "jdoe@example.com", elapsed: 59 * time.Minute, status: approval.StatusApproved},
+		{name: "expired", decision: approval.DecisionApprove, approver: "jdoe@example.com", elapsed: time.Hour, category: errors.Validation},
+		{name: "unknown decision", decision: "maybe", approver: "jdoe@example.com", category: errors.Validation},
+		{name: "no approver", decision: approval.DecisionApprove, category: errors.Forbidden},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, tc.name), func(t *testing.T) {
+			now := time.Date(2020, 2, 21, 22, 0, 0, 0, time.UTC)
+			gate, _, cleanup := withGate(t, &now)
+			defer cleanup()
+			ctx := context.Background()
+			requested := newApproval()
+			assert.NoError(t, gate.Request(ctx, requested))
+
+			now = now.Add(tc.elapsed)
+			decided, err := gate.Decide(ctx, requested.Token, tc.decision, tc.approver)
+			if tc.status == "" {
+				assert.Equal(t, tc.category, errors.CategoryOf(err))
+				stored, _ := gate.Get(ctx, requested.Token)
+				assert.Equal(t, approval.StatusPending, stored.Status)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.status, decided.Status)
+			assert.Equal(t, tc.approver, decided.Approver)
+			assert.Equal(t, now, *decided.DecidedAt)
+			stored, _ := gate.Get(ctx, requested.Token)
+			assert.Equal(t, decided, stored)
+
+			// the token is single-use
+			_, err = gate.Decide(ctx, requested.Token, approval.DecisionApprove, "other@example.com")
+			assert.EqualError(t, err, fmt.Sprintf("approval of alert P-39425 was %s by %s", tc.status, tc.approver))
+			stored, _ = gate.Get(ctx, requested.Token)
+			assert.Equal(t, tc.approver, stored.Approver)
+		})
+	}
+}
+
+func TestGateClose(t *testing.T) {
+	now := time.Date(2020, 2, 21, 22, 0, 0, 0, time.UTC)
+	gate, _, cleanup := withGate(t, &now)
+	defer cleanup()
+	ctx := context.Background()
+
+	pending, decided, other := newApproval(), newApproval(), newApproval()
+	other.AlertID = "P-1"
+	for _, requested := range []*approval.Approval{pending, decided, other} {
+		assert.NoError(t, gate.Request(ctx, requested))
+	}
+	_, err := gate.Decide(ctx, decided.Token, approval.DecisionReject, "jdoe@example.com")
+	assert.NoError(t, err)
+
+	now = now.Add(time.Minute)
+	closed, err := gate.Close(ctx, "P-39425")
+	assert.NoError(t, err)
+	assert.Len(t, closed, 1)
+	assert.Equal(t, pending.Token, closed[0].Token)
+	assert.Equal(t, pending.TaskToken, closed[0].TaskToken)
+	assert.Equal(t, approval.StatusClosed, closed[0].Status)
+
+	// the links of a closed approval do not work anymore
+	_, err = gate.Decide(ctx, pending.Token, approval.DecisionApprove, "jdoe@example.com")
+	assert.EqualError(t, err, "approval of alert P-39425 was closed by Prisma")
+	stored, _ := gate.Get(ctx, decided.Token)
+	assert.Equal(t, approval.StatusRejected, stored.Status)
+	stored, _ = gate.Get(ctx, other.Token)
+	assert.Equal(t, approval.StatusPending, stored.Status)
+
+	closed, err = gate.Close(ctx, "P-39425")
+	assert.NoError(t, err)
+	assert.Empty(t, closed)
+}
+
+func TestApprovalOutput(t *testing.T) {
+	decidedAt := time.Date(2020, 2, 21, 22, 30, 0, 0, time.UTC)
+	decided := newApproval()
+	decided.Token, decided.Status, decided.Approver, decided.DecidedAt = "token", approval.StatusApproved, "jdoe@example.com", &decidedAt
+	output, err := decided.Output()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"plan": {"vpcId": "vpc-1"},
+		"done": false,
+		"approval": {"token": "token", "status": "approved", "approver": "jdoe@example.com", "decidedAt": "2020-02-21T22:30:00Z"}
+	}`, string(output))
+
+	decided.State = json.RawMessage(`[]`)
+	_, err = decided.Output()
+	assert.Equal(t, errors.Permanent, errors.CategoryOf(err))
+}
+
+func TestNewGateFromEnv(t *testing.T) {
+	for _, name := range []string{approval.TableEnv, approval.DirEnv, approval.TTLEnv, approval.URLEnv, approval.WebhookEnv} {
+		defer os.Unsetenv(name)
+	}
+	gate, err := approval.NewGateFromEnv(nil, nil, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, gate)
+
+	os.Setenv(approval.TableEnv, "PrismaVPCApprovals")
+	os.Setenv(approval.URLEnv, "https://approvals.example.com/Prod")
+	os.Setenv(approval.TTLEnv, "4h")
+	gate, err = approval.NewGateFromEnv(nil, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 4*time.Hour, gate.TTL)
+	assert.Equal(t, "https://approvals.example.com/Prod", gate.URL)
+
+	os.Setenv(approval.TTLEnv, "-1h")
+	_, err = approval.NewGateFromEnv(nil, nil, nil)
+	assert.EqualError(t, err, `invalid APPROVAL_TTL "-1h": must be a positive duration`)
+
+	os.Setenv(approval.TTLEnv, "")
+	os.Setenv(approval.WebhookEnv, "http://approvals.example.com")
+	_, err = approval.NewGateFromEnv(nil, nil, nil)
+	assert.EqualError(t, err, `invalid APPROVAL_WEBHOOK_URL "http://approvals.example.com": must be a https URL`)
+}
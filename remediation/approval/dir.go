@@ -0,0 +1,109 @@
+package approval
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DirStore is a Store on a local directory, an approval is a JSON file
+type DirStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewDirStore create a store on the directory
+func NewDirStore(dir string) *DirStore {
+	return &DirStore{dir: dir}
+}
+
+// Put write the file of the approval, the directory is created
+func (s *DirStore) Put(ctx context.Context, approval Approval) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write(&approval)
+}
+
+// Get read the file of the approval, nil is returned when it is missing
+func (s *DirStore) Get(ctx context.Context, token string) (*Approval, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read(token)
+}
+
+// Decide write the decision in the file of the pending approval
+func (s *DirStore) Decide(ctx context.Context, token string, status string, approver string, decidedAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	approval, err := s.read(token)
+	if err != nil || approval == nil {
+		return false, err
+	}
+	if approval.Status != StatusPending || !decidedAt.Before(approval.ExpiresAt) {
+		return false, nil
+	}
+	approval.Status, approval.Approver, approval.DecidedAt = status, approver, &decidedAt
+	return true, s.write(approval)
+}
+
+// Pending read the files of the directory and return the pending approvals of the alert
+func (s *DirStore) Pending(ctx context.Context, alertID string) ([]Approval, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	pending := []Approval{}
+	for _, name := range names {
+		token, err := url.PathUnescape(strings.TrimSuffix(filepath.Base(name), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		approval, err := s.read(token)
+		if err != nil {
+			return nil, err
+		}
+		if approval != nil && approval.AlertID == alertID && approval.Status == StatusPending {
+			pending = append(pending, *approval)
+		}
+	}
+	return pending, nil
+}
+
+func (s *DirStore) read(token string) (*Approval, error) {
+	body, err := ioutil.ReadFile(s.name(token))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	approval := &Approval{}
+	if err := json.Unmarshal(body, approval); err != nil {
+		return nil, err
+	}
+	return approval, nil
+}
+
+func (s *DirStore) write(approval *Approval) error {
+	body, err := json.Marshal(approval)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.name(approval.Token), body, 0600)
+}
+
+// name return the file of the approval, the token is escaped so it stay in the directory
+func (s *DirStore) name(token string) string {
+	return filepath.Join(s.dir, url.PathEscape(token)+".json")
+}
@@ -0,0 +1,163 @@
+package approval
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+const (
+	// KeyAttribute is the partition key of the table, the token of the approval
+	KeyAttribute = "token"
+	// ExpiresAttribute is when the approval expire, in epoch seconds
+	ExpiresAttribute = "expiresAt"
+	// AlertAttribute is the alert of the approval, it is the key of AlertIndex
+	AlertAttribute = "alertId"
+	// AlertIndex is the global secondary index of the table on AlertAttribute
+	AlertIndex = "alertId"
+)
+
+// DynamoDBStore is a Store on a DynamoDB table, an approval is an item
+// The decision is kept in its own attributes so it is written with a condition on the status
+type DynamoDBStore struct {
+	table  string
+	client dynamodbiface.DynamoDBAPI
+}
+
+// NewDynamoDBStore create a store on the table
+func NewDynamoDBStore(table string, client dynamodbiface.DynamoDBAPI) *DynamoDBStore {
+	return &DynamoDBStore{table: table, client: client}
+}
+
+// Put write the item of the approval
+func (s *DynamoDBStore) Put(ctx context.Context, approval Approval) error {
+	data, err := json.Marshal(approval)
+	if err != nil {
+		return err
+	}
+	item := map[string]*dynamodb.AttributeValue{
+		KeyAttribute:     {S: aws.String(approval.Token)},
+		ExpiresAttribute: {N: aws.String(strconv.FormatInt(approval.ExpiresAt.Unix(), 10))},
+		"status":         {S: aws.String(approval.Status)},
+		AlertAttribute:   {S: aws.String(approval.AlertID)},
+		"data":           {S: aws.String(string(data))},
+	}
+	_, err = s.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item:      item,
+	})
+	return err
+}
+
+// Get read the item of the approval, the read is consistent so a decision is seen at once
+func (s *DynamoDBStore) Get(ctx context.Context, token string) (*Approval, error) {
+	output, err := s.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.table),
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]*dynamodb.AttributeValue{
+			KeyAttribute: {S: aws.String(token)},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(output.Item) == 0 {
+		return nil, nil
+	}
+	return decodeItem(output.Item)
+}
+
+// Pending query the index of the alert and return its pending approvals
+func (s *DynamoDBStore) Pending(ctx context.Context, alertID string) ([]Approval, error) {
+	pending := []Approval{}
+	var decodeErr error
+	err := s.client.QueryPagesWithContext(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(s.table),
+		IndexName:              aws.String(AlertIndex),
+		KeyConditionExpression: aws.String("#alertId = :alertId"),
+		FilterExpression:       aws.String("#status = :pending"),
+		ExpressionAttributeNames: map[string]*string{
+			"#alertId": aws.String(AlertAttribute),
+			"#status":  aws.String("status"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":alertId": {S: aws.String(alertID)},
+			":pending": {S: aws.String(StatusPending)},
+		},
+	}, func(output *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range output.Items {
+			approval, err := decodeItem(item)
+			if err != nil {
+				decodeErr = err
+				return false
+			}
+			pending = append(pending, *approval)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pending, decodeErr
+}
+
+// decodeItem return the approval of the item with the decision of its attributes
+func decodeItem(item map[string]*dynamodb.AttributeValue) (*Approval, error) {
+	value := func(name string) string {
+		if attribute, ok := item[name]; ok {
+			return aws.StringValue(attribute.S)
+		}
+		return ""
+	}
+	approval := &Approval{}
+	if err := json.Unmarshal([]byte(value("data")), approval); err != nil {
+		return nil, err
+	}
+	approval.Status, approval.Approver = value("status"), value("approver")
+	if decidedAt := value("decidedAt"); decidedAt != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, decidedAt)
+		if err != nil {
+			return nil, err
+		}
+		approval.DecidedAt = &parsed
+	}
+	return approval, nil
+}
+
+// Decide update the decision attributes of the item if it is pending and not expired
+func (s *DynamoDBStore) Decide(ctx context.Context, token string, status string, approver string, decidedAt time.Time) (bool, error) {
+	_, err := s.client.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			KeyAttribute: {S: aws.String(token)},
+		},
+		UpdateExpression:    aws.String("SET #status = :status, #approver = :approver, #decidedAt = :decidedAt"),
+		ConditionExpression: aws.String("#status = :pending AND #expiresAt > :now"),
+		ExpressionAttributeNames: map[string]*string{
+			"#status":    aws.String("status"),
+			"#approver":  aws.String("approver"),
+			"#decidedAt": aws.String("decidedAt"),
+			"#expiresAt": aws.String(ExpiresAttribute),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":status":    {S: aws.String(status)},
+			":approver":  {S: aws.String(approver)},
+			":decidedAt": {S: aws.String(decidedAt.Format(time.RFC3339Nano))},
+			":pending":   {S: aws.String(StatusPending)},
+			":now":       {N: aws.String(strconv.FormatInt(decidedAt.Unix(), 10))},
+		},
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,107 @@
+package approval_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/approval"
+)
+
+// mockDynamoDB keep one item, an update fail its condition when the item is not pending
+type mockDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	item        map[string]*dynamodb.AttributeValue
+	getInput    *dynamodb.GetItemInput
+	updateInput *dynamodb.UpdateItemInput
+	queryInput  *dynamodb.QueryInput
+}
+
+func (m *mockDynamoDB) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	m.item = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (m *mockDynamoDB) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	m.getInput = input
+	if m.item == nil || aws.StringValue(m.item["token"].S) != aws.StringValue(input.Key["token"].S) {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+	return &dynamodb.GetItemOutput{Item: m.item}, nil
+}
+
+func (m *mockDynamoDB) UpdateItemWithContext(ctx aws.Context, input *dynamodb.UpdateItemInput, opts ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	m.updateInput = input
+	if aws.StringValue(m.item["status"].S) != approval.StatusPending {
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+	}
+	for name, attribute := range map[string]string{"status": ":status", "approver": ":approver", "decidedAt": ":decidedAt"} {
+		m.item[name] = input.ExpressionAttributeValues[attribute]
+	}
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+func (m *mockDynamoDB) QueryPagesWithContext(ctx aws.Context, input *dynamodb.QueryInput, fn func(*dynamodb.QueryOutput, bool) bool, opts ...request.Option) error {
+	m.queryInput = input
+	output := &dynamodb.QueryOutput{Items: []map[string]*dynamodb.AttributeValue{}}
+	if m.item != nil && aws.StringValue(m.item["alertId"].S) == aws.StringValue(input.ExpressionAttributeValues[":alertId"].S) &&
+		aws.StringValue(m.item["status"].S) == approval.StatusPending {
+		output.Items = append(output.Items, m.item)
+	}
+	fn(output, true)
+	return nil
+}
+
+func TestDynamoDBStore(t *testing.T) {
+	client := &mockDynamoDB{}
+	store := approval.NewDynamoDBStore("PrismaVPCApprovals", client)
+	ctx := context.Background()
+	requested := newApproval()
+	requested.Token, requested.Status = "token", approval.StatusPending
+	requested.CreatedAt = time.Unix(1582322400, 0).UTC()
+	requested.ExpiresAt = time.Unix(1582326000, 0).UTC()
+	assert.NoError(t, store.Put(ctx, *requested))
+	assert.Equal(t, "token", aws.StringValue(client.item["token"].S))
+	assert.Equal(t, "1582326000", aws.StringValue(client.item["expiresAt"].N))
+	assert.Equal(t, "P-39425", aws.StringValue(client.item["alertId"].S))
+
+	stored, err := store.Get(ctx, "token")
+	assert.NoError(t, err)
+	assert.Equal(t, requested, stored)
+	assert.True(t, aws.BoolValue(client.getInput.ConsistentRead))
+	missing, err := store.Get(ctx, "unknown")
+	assert.NoError(t, err)
+	assert.Nil(t, missing)
+
+	pending, err := store.Pending(ctx, "P-39425")
+	assert.NoError(t, err)
+	assert.Equal(t, []approval.Approval{*requested}, pending)
+	assert.Equal(t, approval.AlertIndex, aws.StringValue(client.queryInput.IndexName))
+	assert.Equal(t, "#status = :pending", aws.StringValue(client.queryInput.FilterExpression))
+
+	decidedAt := time.Unix(1582323000, 0).UTC()
+	decided, err := store.Decide(ctx, "token", approval.StatusApproved, "jdoe@example.com", decidedAt)
+	assert.NoError(t, err)
+	assert.True(t, decided)
+	assert.Equal(t, "#status = :pending AND #expiresAt > :now", aws.StringValue(client.updateInput.ConditionExpression))
+	assert.Equal(t, "1582323000", aws.StringValue(client.updateInput.ExpressionAttributeValues[":now"].N))
+	stored, err = store.Get(ctx, "token")
+	assert.NoError(t, err)
+	assert.Equal(t, approval.StatusApproved, stored.Status)
+	assert.Equal(t, "jdoe@example.com", stored.Approver)
+	assert.Equal(t, decidedAt, *stored.DecidedAt)
+
+	decided, err = store.Decide(ctx, "token", approval.StatusRejected, "other@example.com", decidedAt)
+	assert.NoError(t, err)
+	assert.False(t, decided)
+	pending, err = store.Pending(ctx, "P-39425")
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+}
@@ -0,0 +1,161 @@
+package approval
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+)
+
+// maxSubjectLength is the longest subject SNS accept
+const maxSubjectLength = 100
+
+// Notifier send an approval request to the approvers
+type Notifier interface {
+	Notify(ctx context.Context, approval *Approval, links Links) error
+}
+
+// Notifiers send the approval request with every notifier, the first error is returned
+type Notifiers []Notifier
+
+// Notify send the approval request with every notifier
+func (n Notifiers) Notify(ctx context.Context, approval *Approval, links Links) error {
+	for _, notifier := range n {
+		if err := notifier.Notify(ctx, approval, links); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Request is the approval request sent to the approvers, without the state and the task token
+type Request struct {
+	AlertID     string    `json:"alertId"`
+	AccountID   string    `json:"accountId"`
+	Region      string    `json:"region"`
+	ResourceID  string    `json:"resourceId"`
+	Remediation string    `json:"remediation"`
+	Plan        string    `json:"plan"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	Links       Links     `json:"links"`
+}
+
+// NewRequest return the approval request of the approval
+func NewRequest(approval *Approval, links Links) *Request {
+	return &Request{
+		AlertID:     approval.AlertID,
+		AccountID:   approval.AccountID,
+		Region:      approval.Region,
+		ResourceID:  approval.ResourceID,
+		Remediation: approval.Remediation,
+		Plan:        approval.Plan,
+		ExpiresAt:   approval.ExpiresAt,
+		Links:       links,
+	}
+}
+
+// Subject return the one line summary of the request
+func (r *Request) Subject() string {
+	subject := fmt.Sprintf("Approve the %s of %s for alert %s", r.Remediation, r.ResourceID, r.AlertID)
+	if len(subject) > maxSubjectLength {
+		subject = subject[:maxSubjectLength]
+	}
+	return subject
+}
+
+// Text return the request as plain text, the plan and the links
+func (r *Request) Text() string {
+	return fmt.Sprintf("%s in %s %s.\n\n%s\nApprove: %s\nReject: %s\n\nThe links expire at %s and work once.\n",
+		r.Subject(), r.AccountID, r.Region, r.Plan, r.Links.Approve, r.Links.Reject, r.ExpiresAt.Format(time.RFC3339))
+}
+
+// SNSNotifier publish the approval requests to a topic
+type SNSNotifier struct {
+	TopicArn string
+	client   snsiface.SNSAPI
+}
+
+// NewSNSNotifier create a notifier on the topic
+func NewSNSNotifier(topicArn string, client snsiface.SNSAPI) *SNSNotifier {
+	return &SNSNotifier{TopicArn: topicArn, client: client}
+}
+
+// Notify publish the request as text, with the alert ID as message attribute
+func (n *SNSNotifier) Notify(ctx context.Context, approval *Approval, links Links) error {
+	request := NewRequest(approval, links)
+	output, err := n.client.PublishWithContext(ctx, &sns.PublishInput{
+		TopicArn: aws.String(n.TopicArn),
+		Subject:  aws.String(request.Subject()),
+		Message:  aws.String(request.Text()),
+		MessageAttributes: map[string]*sns.MessageAttributeValue{
+			"alertId": {DataType: aws.String("String"), StringValue: aws.String(approval.AlertID)},
+		},
+	})
+	if err != nil {
+		return errors.Classify(err)
+	}
+	fmt.Printf("Published approval request %s to %s\n", aws.StringValue(output.MessageId), n.TopicArn)
+	return nil
+}
+
+// WebhookNotifier POST the approval requests to a HTTPS endpoint
+type WebhookNotifier struct {
+	URL    string
+	client HTTPClient
+}
+
+// NewWebhookNotifier create a notifier on the endpoint
+func NewWebhookNotifier(url string, client HTTPClient) *WebhookNotifier {
+	return &WebhookNotifier{URL: url, client: client}
+}
+
+// Notify POST the request as JSON
+func (n *WebhookNotifier) Notify(ctx context.Context, approval *Approval, links Links) error {
+	body, err := json.Marshal(NewRequest(approval, links))
+	if err != nil {
+		return errors.Wrap(errors.Permanent, err)
+	}
+	req, err := http.NewRequest(http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(errors.Permanent, err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return errors.Wrap(errors.Retryable, err)
+	}
+	defer resp.Body.Close()
+	// drain the body so the connection is reused
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Classify(&StatusError{StatusCode: resp.StatusCode, URL: n.URL})
+	}
+	fmt.Printf("Posted approval request of alert %s to %s: %d\n", approval.AlertID, n.URL, resp.StatusCode)
+	return nil
+}
+
+// StatusError is a webhook response that is not 2xx
+type StatusError struct {
+	StatusCode int
+	URL        string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webhook %s returned %d", e.URL, e.StatusCode)
+}
+
+// HTTPStatusCode return the status code, errors.CategoryOf classify the error by it
+func (e *StatusError) HTTPStatusCode() int {
+	return e.StatusCode
+}
@@ -0,0 +1,90 @@
+package approval_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/approval"
+)
+
+type mockSNS struct {
+	snsiface.SNSAPI
+	input *sns.PublishInput
+}
+
+func (m *mockSNS) PublishWithContext(ctx aws.Context, input *sns.PublishInput, opts ...request.Option) (*sns.PublishOutput, error) {
+	m.input = input
+	return &sns.PublishOutput{MessageId: aws.String("message-id")}, nil
+}
+
+type mockHTTPClient struct {
+	request    *http.Request
+	statusCode int
+}
+
+func (m *mockHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	m.request = req
+	return &http.Response{StatusCode: m.statusCode, Body: ioutil.NopCloser(bytes.NewReader(nil))}, nil
+}
+
+var links = approval.Links{
+	Approve: "https://approvals.example.com/Prod/approvals/token/approve",
+	Reject:  "https://approvals.example.com/Prod/approvals/token/reject",
+}
+
+func requestedApproval() *approval.Approval {
+	requested := newApproval()
+	requested.Token, requested.Status = "token", approval.StatusPending
+	requested.ExpiresAt = time.Date(2020, 2, 21, 23, 0, 0, 0, time.UTC)
+	return requested
+}
+
+func TestSNSNotifier(t *testing.T) {
+	client := &mockSNS{}
+	notifier := approval.NewSNSNotifier("arn:aws:sns:us-east-1:123456789012:PrismaVPCApprovals", client)
+	assert.NoError(t, notifier.Notify(context.Background(), requestedApproval(), links))
+	assert.Equal(t, "arn:aws:sns:us-east-1:123456789012:PrismaVPCApprovals", aws.StringValue(client.input.TopicArn))
+	assert.Equal(t, "Approve the delete of vpc-1 for alert P-39425", aws.StringValue(client.input.Subject))
+	assert.Equal(t, "P-39425", aws.StringValue(client.input.MessageAttributes["alertId"].StringValue))
+	assert.Equal(t, `Approve the delete of vpc-1 for alert P-39425 in 123456789012 us-west-2.
+
+Plan to delete vpc-1 in 123456789012 us-west-2:
+  1. delete vpc vpc-1
+
+Approve: https://approvals.example.com/Prod/approvals/token/approve
+Reject: https://approvals.example.com/Prod/approvals/token/reject
+
+The links expire at 2020-02-21T23:00:00Z and work once.
+`, aws.StringValue(client.input.Message))
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	client := &mockHTTPClient{statusCode: http.StatusOK}
+	notifier := approval.NewWebhookNotifier("https://chat.example.com/hooks/approvals", client)
+	assert.NoError(t, notifier.Notify(context.Background(), requestedApproval(), links))
+	assert.Equal(t, http.MethodPost, client.request.Method)
+	assert.Equal(t, "application/json", client.request.Header.Get("Content-Type"))
+	body, err := ioutil.ReadAll(client.request.Body)
+	assert.NoError(t, err)
+	sent := approval.Request{}
+	assert.NoError(t, json.Unmarshal(body, &sent))
+	assert.Equal(t, approval.NewRequest(requestedApproval(), links), &sent)
+	// the state and the task token are not sent
+	assert.NotContains(t, string(body), "task-token")
+
+	client.statusCode = http.StatusForbidden
+	err = notifier.Notify(context.Background(), requestedApproval(), links)
+	assert.Equal(t, errors.Forbidden, errors.CategoryOf(err))
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/approval"
+	lambdaEvents "github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/aws/aws-sdk-go/service/sfn/sfniface"
+)
+
+// gate record the decisions
+var gate *approval.Gate
+
+// tasks resume the state machine tasks that wait for a decision
+var tasks sfniface.SFNAPI
+
+// page is a HTML page of the approver, a page with a decision has the form that confirm it
+type page struct {
+	Title    string
+	Message  string
+	Plan     string
+	Decision string
+	Approver string
+}
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+{{if .Plan}}<pre>{{.Plan}}</pre>
+{{end}}{{if .Decision}}<form method="post">
+<label>Approver <input name="approver" value="{{.Approver}}" required{{if .Approver}} readonly{{end}}></label>
+<button type="submit">{{.Decision}}</button>
+</form>
+{{end}}</body>
+</html>
+`))
+
+// handler show the approval of GET /approvals/{token}/{decision} with a form that POST the decision
+// A link opened by a mail scanner decide nothing, the decision is only recorded by the POST.
+// The single-use token of the link authenticate the approver of the form, who enter a name;
+// a signed POST to /signed/approvals/{token}/{decision} is decided by its IAM user
+func handler(ctx context.Context, request lambdaEvents.APIGatewayProxyRequest) (lambdaEvents.APIGatewayProxyResponse, error) {
+	token, decision := request.PathParameters["token"], request.PathParameters["decision"]
+	if decision != approval.DecisionApprove && decision != approval.DecisionReject {
+		return respond(http.StatusNotFound, &page{Title: "Not found", Message: fmt.Sprintf("Unknown decision %q.", decision)})
+	}
+	approver := identity(request)
+	switch request.HTTPMethod {
+	case http.MethodGet:
+		return confirm(ctx, token, decision, approver)
+	case http.MethodPost:
+		if approver == "" {
+			form, err := parseForm(request)
+			if err != nil {
+				return respond(http.StatusBadRequest, &page{Title: "Bad request", Message: err.Error()})
+			}
+			name := strings.TrimSpace(form.Get("approver"))
+			if name == "" {
+				return respondError(errors.Wrapf(errors.Forbidden, "the decision of approval %s require the name of the approver", token))
+			}
+			approver = fmt.Sprintf(linkApprover, name)
+		}
+		return decide(ctx, token, decision, approver)
+	}
+	return respond(http.StatusMethodNotAllowed, &page{Title: "Method not allowed", Message: request.HTTPMethod})
+}
+
+// linkApprover is the approver of a decision authenticated by the token of the link, the name is entered in the form
+const linkApprover = "%s (approval link)"
+
+// identity return the caller authenticated by API Gateway, the email or the principal of the authorizer, or the IAM user
+// An empty identity is a caller of the link, authenticated by its token
+func identity(request lambdaEvents.APIGatewayProxyRequest) string {
+	authorizer := request.RequestContext.Authorizer
+	if claims, ok := authorizer["claims"].(map[string]interface{}); ok {
+		if email, ok := claims["email"].(string); ok && email != "" {
+			return email
+		}
+	}
+	if principalID, ok := authorizer["principalId"].(string); ok && principalID != "" {
+		return principalID
+	}
+	return request.RequestContext.Identity.UserArn
+}
+
+// parseForm return the fields of the urlencoded body
+func parseForm(request lambdaEvents.APIGatewayProxyRequest) (url.Values, error) {
+	body := request.Body
+	if request.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return nil, err
+		}
+		body = string(decoded)
+	}
+	return url.ParseQuery(body)
+}
+
+// confirm show the plan of the pending approval and the form that confirm the decision
+func confirm(ctx context.Context, token string, decision string, approver string) (lambdaEvents.APIGatewayProxyResponse, error) {
+	requested, err := gate.Get(ctx, token)
+	if err != nil {
+		return respondError(err)
+	}
+	if err := requested.Decidable(gate.Now()); err != nil {
+		return respondError(err)
+	}
+	return respond(http.StatusOK, &page{
+		Title:    title(requested, decision),
+		Message:  fmt.Sprintf("Account %s, region %s. The approval expire at %s.", requested.AccountID, requested.Region, requested.ExpiresAt.Format("2006-01-02 15:04 MST")),
+		Plan:     requested.Plan,
+		Decision: strings.Title(decision),
+		Approver: approver,
+	})
+}
+
+// decide record the decision of the approver and resume the state machine task that wait for it
+func decide(ctx context.Context, token string, decision string, approver string) (lambdaEvents.APIGatewayProxyResponse, error) {
+	decided, err := gate.Decide(ctx, token, decision, approver)
+	if err != nil {
+		return respondError(err)
+	}
+	if err := resume(ctx, decided); err != nil {
+		fmt.Printf("Resume approval of alert %s failed: %s\n", decided.AlertID, err.Error())
+		if awsErr, ok := err.(awserr.Error); ok {
+			switch awsErr.Code() {
+			case sfn.ErrCodeTaskTimedOut, sfn.ErrCodeTaskDoesNotExist, sfn.ErrCodeInvalidToken:
+				return respond(http.StatusGone, &page{
+					Title:   title(decided, decision),
+					Message: fmt.Sprintf("The decision is recorded but the remediation of alert %s does not wait for it anymore.", decided.AlertID),
+				})
+			}
+		}
+		return respond(http.StatusBadGateway, &page{
+			Title:   title(decided, decision),
+			Message: fmt.Sprintf("The decision is recorded but the remediation of alert %s cannot be resumed: %s", decided.AlertID, err.Error()),
+		})
+	}
+	return respond(http.StatusOK, &page{
+		Title:   title(decided, decision),
+		Message: fmt.Sprintf("The remediation of alert %s is %s by %s.", decided.AlertID, decided.Status, decided.Approver),
+	})
+}
+
+// resume send the decision to the state machine, an approved task continue with the stored state and a rejected task fail
+func resume(ctx context.Context, decided *approval.Approval) error {
+	if decided.Status == approval.StatusRejected {
+		_, err := tasks.SendTaskFailureWithContext(ctx, &sfn.SendTaskFailureInput{
+			TaskToken: aws.String(decided.TaskToken),
+			Error:     aws.String(approval.RejectedErrorName),
+			Cause:     aws.String(fmt.Sprintf("rejected by %s", decided.Approver)),
+		})
+		return err
+	}
+	output, err := decided.Output()
+	if err != nil {
+		return err
+	}
+	_, err = tasks.SendTaskSuccessWithContext(ctx, &sfn.SendTaskSuccessInput{
+		TaskToken: aws.String(decided.TaskToken),
+		Output:    aws.String(string(output)),
+	})
+	return err
+}
+
+// title return the title of the pages of the approval
+func title(requested *approval.Approval, decision string) string {
+	return fmt.Sprintf("%s the %s of %s for alert %s", strings.Title(decision), requested.Remediation, requested.ResourceID, requested.AlertID)
+}
+
+// respondError return the page of the error, the status is the status of its category
+func respondError(err error) (lambdaEvents.APIGatewayProxyResponse, error) {
+	status := http.StatusInternalServerError
+	switch errors.CategoryOf(err) {
+	case errors.NotFound:
+		status = http.StatusNotFound
+	case errors.Forbidden:
+		status = http.StatusForbidden
+	case errors.Validation:
+		status = http.StatusConflict
+	case errors.Retryable:
+		status = http.StatusServiceUnavailable
+	}
+	fmt.Printf("Approval failed: %d %s\n", status, err.Error())
+	return respond(status, &page{Title: http.StatusText(status), Message: err.Error()})
+}
+
+// respond return the HTML page, it is not cached nor framed and the token is not sent as referrer
+func respond(status int, p *page) (lambdaEvents.APIGatewayProxyResponse, error) {
+	body := &bytes.Buffer{}
+	if err := pageTemplate.Execute(body, p); err != nil {
+		return lambdaEvents.APIGatewayProxyResponse{}, err
+	}
+	return lambdaEvents.APIGatewayProxyResponse{
+		StatusCode: status,
+		Headers: map[string]string{
+			"Content-Type":    "text/html; charset=utf-8",
+			"Cache-Control":   "no-store",
+			"Referrer-Policy": "no-referrer",
+			"X-Frame-Options": "DENY",
+		},
+		Body: body.String(),
+	}, nil
+}
+
+func main() {
+	sess := session.Must(session.NewSession())
+	var err error
+	if gate, err = approval.NewGateFromEnv(dynamodb.New(sess), nil, nil); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	if gate == nil {
+		fmt.Printf("%s is not set\n", approval.TableEnv)
+		os.Exit(1)
+	}
+	tasks = sfn.New(sess)
+	lambda.Start(handler)
+}
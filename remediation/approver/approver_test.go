@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	lambdaEvents "github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/aws/aws-sdk-go/service/sfn/sfniface"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/approval"
+)
+
+type mockSFN struct {
+	sfniface.SFNAPI
+	success *sfn.SendTaskSuccessInput
+	failure *sfn.SendTaskFailureInput
+	err     error
+}
+
+func (m *mockSFN) SendTaskSuccessWithContext(ctx aws.Context, input *sfn.SendTaskSuccessInput, opts ...request.Option) (*sfn.SendTaskSuccessOutput, error) {
+	m.success = input
+	return &sfn.SendTaskSuccessOutput{}, m.err
+}
+
+func (m *mockSFN) SendTaskFailureWithContext(ctx aws.Context, input *sfn.SendTaskFailureInput, opts ...request.Option) (*sfn.SendTaskFailureOutput, error) {
+	m.failure = input
+	return &sfn.SendTaskFailureOutput{}, m.err
+}
+
+// withApproval set the gate on a temporary directory with a pending approval and return its token
+func withApproval(t *testing.T, now *time.Time) (string, *mockSFN, func()) {
+	dir, err := ioutil.TempDir("", "approval")
+	assert.NoError(t, err)
+	gate = approval.NewGate(approval.NewDirStore(dir), nil, "https://approvals.example.com/Prod", time.Hour)
+	gate.Now = func() time.Time { return *now }
+	requested := &approval.Approval{
+		AlertID:     "P-39425",
+		AccountID:   "123456789012",
+		Region:      "us-west-2",
+		ResourceID:  "vpc-1",
+		Remediation: "delete",
+		Plan:        "Plan to delete vpc-1 in 123456789012 us-west-2:\n  1. delete vpc vpc-1\n",
+		State:       []byte(`{"plan":{"vpcId":"vpc-1"},"done":false}`),
+		TaskToken:   "task-token",
+	}
+	assert.NoError(t, gate.Request(context.Background(), requested))
+	client := &mockSFN{}
+	tasks = client
+	return requested.Token, client, func() {
+		gate, tasks = nil, nil
+		os.RemoveAll(dir)
+	}
+}
+
+func decisionRequest(method string, token string, decision string, body string) lambdaEvents.APIGatewayProxyRequest {
+	return lambdaEvents.APIGatewayProxyRequest{
+		HTTPMethod:     method,
+		PathParameters: map[string]string{"token": token, "decision": decision},
+		Body:           body,
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	now := time.Date(2020, 2, 21, 22, 0, 0, 0, time.UTC)
+	token, client, cleanup := withApproval(t, &now)
+	defer cleanup()
+
+	response, err := handler(context.Background(), decisionRequest(http.MethodGet, token, approval.DecisionApprove, ""))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "text/html; charset=utf-8", response.Headers["Content-Type"])
+	assert.Equal(t, "no-referrer", response.Headers["Referrer-Policy"])
+	assert.Contains(t, response.Body, "<title>Approve the delete of vpc-1 for alert P-39425</title>")
+	assert.Contains(t, response.Body, "1. delete vpc vpc-1")
+	assert.Contains(t, response.Body, `<input name="approver" value="" required>`)
+	assert.Contains(t, response.Body, "The approval expire at 2020-02-21 23:00 UTC.")
+
+	// the authenticated approver cannot be changed
+	authenticated := decisionRequest(http.MethodGet, token, approval.DecisionReject, "")
+	authenticated.RequestContext.Authorizer = map[string]interface{}{"claims": map[string]interface{}{"email": "jdoe@example.com"}}
+	response, _ = handler(context.Background(), authenticated)
+	assert.Contains(t, response.Body, `<input name="approver" value="jdoe@example.com" required readonly>`)
+	assert.Contains(t, response.Body, `<button type="submit">Reject</button>`)
+
+	// opening the link decide nothing
+	stored, _ := gate.Get(context.Background(), token)
+	assert.Equal(t, approval.StatusPending, stored.Status)
+	assert.Nil(t, client.success)
+	assert.Nil(t, client.failure)
+
+	response, _ = handler(context.Background(), decisionRequest(http.MethodGet, "unknown", approval.DecisionApprove, ""))
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	response, _ = handler(context.Background(), decisionRequest(http.MethodGet, token, "maybe", ""))
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	response, _ = handler(context.Background(), decisionRequest(http.MethodDelete, token, approval.DecisionApprove, ""))
+	assert.Equal(t, http.StatusMethodNotAllowed, response.StatusCode)
+
+	now = now.Add(time.Hour)
+	response, _ = handler(context.Background(), decisionRequest(http.MethodGet, token, approval.DecisionApprove, ""))
+	assert.Equal(t, http.StatusConflict, response.StatusCode)
+	assert.Contains(t, response.Body, "approval of alert P-39425 expired at 2020-02-21T23:00:00Z")
+}
+
+func TestDecide(t *testing.T) {
+	jdoe := "arn:aws:iam::123456789012:user/jdoe"
+	testCases := []struct {
+		name       string
+		decision   string
+		body       string
+		authorizer map[string]interface{}
+		userArn    string
+		elapsed    time.Duration
+		err        error
+		status     int
+		approver   string
+	}{
+		{name: "approve", decision: approval.DecisionApprove, body: "approver=jdoe%40example.com", status: http.StatusOK, approver: "jdoe@example.com (approval link)"},
+		{name: "reject", decision: approval.DecisionReject, body: "approver=jdoe%40example.com", status: http.StatusOK, approver: "jdoe@example.com (approval link)"},
+		{name: "signed", decision: approval.DecisionApprove, body: "approver=someone-else", userArn: jdoe, status: http.StatusOK, approver: jdoe},
+		{
+			name:       "authorizer claims",
+			decision:   approval.DecisionApprove,
+			authorizer: map[string]interface{}{"claims": map[string]interface{}{"email": "jdoe@example.com"}},
+			status:     http.StatusOK,
+			approver:   "jdoe@example.com",
+		},
+		{
+			name:       "authorizer principal",
+			decision:   approval.DecisionReject,
+			authorizer: map[string]interface{}{"principalId": "jdoe"},
+			status:     http.StatusOK,
+			approver:   "jdoe",
+		},
+		// the token of the link authenticate a named approver
+		{name: "no approver", decision: approval.DecisionApprove, body: "approver=+", status: http.StatusForbidden},
+		{name: "bad form", decision: approval.DecisionApprove, body: "approver=%", status: http.StatusBadRequest},
+		{name: "expired", decision: approval.DecisionApprove, userArn: jdoe, elapsed: time.Hour, status: http.StatusConflict},
+		{
+			name:     "execution stopped",
+			decision: approval.DecisionApprove,
+			userArn:  jdoe,
+			err:      awserr.New(sfn.ErrCodeTaskTimedOut, "Task Timed Out", nil),
+			status:   http.StatusGone,
+			approver: jdoe,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, tc.name), func(t *testing.T) {
+			now := time.Date(2020, 2, 21, 22, 0, 0, 0, time.UTC)
+			token, client, cleanup := withApproval(t, &now)
+			defer cleanup()
+			client.err = tc.err
+			now = now.Add(tc.elapsed)
+
+			req := decisionRequest(http.MethodPost, token, tc.decision, tc.body)
+			req.RequestContext.Authorizer = tc.authorizer
+			req.RequestContext.Identity.UserArn = tc.userArn
+			response, err := handler(context.Background(), req)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.status, response.StatusCode, response.Body)
+
+			stored, _ := gate.Get(context.Background(), token)
+			if tc.approver == "" {
+				assert.Equal(t, approval.StatusPending, stored.Status)
+				assert.Nil(t, client.success)
+				assert.Nil(t, client.failure)
+				return
+			}
+			assert.Equal(t, tc.approver, stored.Approver)
+			assert.Equal(t, now, *stored.DecidedAt)
+			if tc.decision == approval.DecisionApprove {
+				assert.Equal(t, approval.StatusApproved, stored.Status)
+				assert.Equal(t, "task-token", aws.StringValue(client.success.TaskToken))
+				assert.JSONEq(t, fmt.Sprintf(`{
+					"plan": {"vpcId": "vpc-1"},
+					"done": false,
+					"approval": {"token": %q, "status": "approved", "approver": %q, "decidedAt": "2020-02-21T22:00:00Z"}
+				}`, token, tc.approver), aws.StringValue(client.success.Output))
+				assert.Nil(t, client.failure)
+			} else {
+				assert.Equal(t, approval.StatusRejected, stored.Status)
+				assert.Equal(t, "task-token", aws.StringValue(client.failure.TaskToken))
+				assert.Equal(t, "RejectedError", aws.StringValue(client.failure.Error))
+				assert.Equal(t, "rejected by "+tc.approver, aws.StringValue(client.failure.Cause))
+				assert.Nil(t, client.success)
+			}
+
+			// the token is single-use
+			client.success, client.failure = nil, nil
+			again := decisionRequest(http.MethodPost, token, approval.DecisionApprove, "")
+			again.RequestContext.Identity.UserArn = "arn:aws:iam::123456789012:user/other"
+			response, _ = handler(context.Background(), again)
+			assert.Equal(t, http.StatusConflict, response.StatusCode)
+			assert.Nil(t, client.success)
+			assert.Nil(t, client.failure)
+		})
+	}
+}
+
+func TestParseForm(t *testing.T) {
+	req := lambdaEvents.APIGatewayProxyRequest{Body: base64.StdEncoding.EncodeToString([]byte("approver=jdoe")), IsBase64Encoded: true}
+	form, err := parseForm(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "jdoe", form.Get("approver"))
+
+	req.Body = "%"
+	_, err = parseForm(req)
+	assert.Error(t, err)
+}
@@ -0,0 +1,37 @@
+package backup
+
+import (
+	"context"
+	"os"
+
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+const (
+	// BucketEnv contain the S3 bucket of the backup manifests
+	BucketEnv = "BACKUP_BUCKET"
+	// PrefixEnv contain the key prefix of the manifests in the bucket
+	PrefixEnv = "BACKUP_PREFIX"
+	// DirEnv contain the local directory of the manifests, it is used when BACKUP_BUCKET is empty
+	DirEnv = "BACKUP_DIR"
+)
+
+// Store keep the backup manifests
+type Store interface {
+	// Put write the manifest under the key and return its location
+	Put(ctx context.Context, key string, body []byte) (string, error)
+	// Get read the manifest of the key
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// NewStoreFromEnv create a store on the bucket in BACKUP_BUCKET, or on the directory in BACKUP_DIR
+// nil is returned when neither is set, the backups are disabled
+func NewStoreFromEnv(client s3iface.S3API) Store {
+	if bucket := os.Getenv(BucketEnv); bucket != "" {
+		return NewS3Store(bucket, os.Getenv(PrefixEnv), client)
+	}
+	if dir := os.Getenv(DirEnv); dir != "" {
+		return NewDirStore(dir)
+	}
+	return nil
+}
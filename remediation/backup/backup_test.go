@@ -0,0 +1,98 @@
+package backup_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/backup"
+)
+
+type mockS3 struct {
+	s3iface.S3API
+	objects map[string][]byte
+	putErr  error
+}
+
+func (m *mockS3) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	if m.putErr != nil {
+		return nil, m.putErr
+	}
+	body, _ := ioutil.ReadAll(input.Body)
+	m.objects[aws.StringValue(input.Bucket)+"/"+aws.StringValue(input.Key)] = body
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (m *mockS3) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	body, ok := m.objects[aws.StringValue(input.Bucket)+"/"+aws.StringValue(input.Key)]
+	if !ok {
+		return nil, awserr.New(s3.ErrCodeNoSuchKey, "The specified key does not exist.", nil)
+	}
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(body))}, nil
+}
+
+func TestS3Store(t *testing.T) {
+	client := &mockS3{objects: map[string][]byte{}}
+	store := backup.NewS3Store("prisma-backups", "vpckiller", client)
+	ctx := context.Background()
+
+	location, err := store.Put(ctx, "123456789012/us-west-2/vpc-1/P-1.json", []byte(`{"vpcId": "vpc-1"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "s3://prisma-backups/vpckiller/123456789012/us-west-2/vpc-1/P-1.json", location)
+	assert.Equal(t, []byte(`{"vpcId": "vpc-1"}`), client.objects["prisma-backups/vpckiller/123456789012/us-west-2/vpc-1/P-1.json"])
+
+	body, err := store.Get(ctx, "123456789012/us-west-2/vpc-1/P-1.json")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte(`{"vpcId": "vpc-1"}`), body)
+
+	_, err = store.Get(ctx, "123456789012/us-west-2/vpc-2/P-2.json")
+	assert.Equal(t, errors.NotFound, errors.CategoryOf(err))
+
+	client.putErr = awserr.New("AccessDenied", "Access Denied", nil)
+	_, err = store.Put(ctx, "123456789012/us-west-2/vpc-1/P-1.json", []byte(`{}`))
+	assert.Error(t, err)
+}
+
+func TestDirStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store := backup.NewDirStore(dir)
+	ctx := context.Background()
+
+	location, err := store.Put(ctx, "123456789012/us-west-2/vpc-1/P-1.json", []byte(`{"vpcId": "vpc-1"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "123456789012", "us-west-2", "vpc-1", "P-1.json"), location)
+
+	body, err := store.Get(ctx, "123456789012/us-west-2/vpc-1/P-1.json")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte(`{"vpcId": "vpc-1"}`), body)
+
+	_, err = store.Get(ctx, "123456789012/us-west-2/vpc-2/P-2.json")
+	assert.Equal(t, errors.NotFound, errors.CategoryOf(err))
+}
+
+func TestNewStoreFromEnv(t *testing.T) {
+	defer os.Unsetenv(backup.BucketEnv)
+	defer os.Unsetenv(backup.DirEnv)
+
+	assert.Nil(t, backup.NewStoreFromEnv(&mockS3{}))
+
+	os.Setenv(backup.DirEnv, "/tmp/backups")
+	assert.Equal(t, backup.NewDirStore("/tmp/backups"), backup.NewStoreFromEnv(&mockS3{}))
+
+	client := &mockS3{}
+	os.Setenv(backup.BucketEnv, "prisma-backups")
+	assert.Equal(t, backup.NewS3Store("prisma-backups", "", client), backup.NewStoreFromEnv(client))
+}
@@ -0,0 +1,44 @@
+package backup
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+)
+
+// DirStore is a Store on a local directory
+type DirStore struct {
+	dir string
+}
+
+// NewDirStore create a store on the directory, the keys are relative paths
+func NewDirStore(dir string) *DirStore {
+	return &DirStore{dir: dir}
+}
+
+// Put write the manifest file, the parent directories are created
+func (s *DirStore) Put(ctx context.Context, key string, body []byte) (string, error) {
+	name := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return "", errors.Wrap(errors.Permanent, err)
+	}
+	if err := ioutil.WriteFile(name, body, 0600); err != nil {
+		return "", errors.Wrap(errors.Permanent, err)
+	}
+	return name, nil
+}
+
+// Get read the manifest file
+func (s *DirStore) Get(ctx context.Context, key string) ([]byte, error) {
+	body, err := ioutil.ReadFile(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if os.IsNotExist(err) {
+		return nil, errors.Wrapf(errors.NotFound, "backup %s not found", key)
+	}
+	if err != nil {
+		return nil, errors.Wrap(errors.Permanent, err)
+	}
+	return body, nil
+}
@@ -0,0 +1,60 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+)
+
+// S3Store is a Store on a S3 bucket
+type S3Store struct {
+	bucket string
+	prefix string
+	client s3iface.S3API
+}
+
+// NewS3Store create a store on the bucket, the keys are under the prefix
+func NewS3Store(bucket string, prefix string, client s3iface.S3API) *S3Store {
+	return &S3Store{bucket: bucket, prefix: prefix, client: client}
+}
+
+// Put write the manifest as a JSON object encrypted at rest
+func (s *S3Store) Put(ctx context.Context, key string, body []byte) (string, error) {
+	key = path.Join(s.prefix, key)
+	_, err := s.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:               aws.String(s.bucket),
+		Key:                  aws.String(key),
+		Body:                 bytes.NewReader(body),
+		ContentType:          aws.String("application/json"),
+		ServerSideEncryption: aws.String(s3.ServerSideEncryptionAes256),
+	})
+	if err != nil {
+		return "", errors.Classify(err)
+	}
+	return fmt.Sprintf("s3://%s/%s", s.bucket, key), nil
+}
+
+// Get read the object of the key
+func (s *S3Store) Get(ctx context.Context, key string) ([]byte, error) {
+	output, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(path.Join(s.prefix, key)),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+		return nil, errors.Wrapf(errors.NotFound, "backup %s not found", key)
+	}
+	if err != nil {
+		return nil, errors.Classify(err)
+	}
+	defer output.Body.Close()
+	return ioutil.ReadAll(output.Body)
+}
@@ -0,0 +1,134 @@
+package deadletter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+)
+
+// Record is a failed event in the dead-letter queue
+type Record struct {
+	// Source is the function that failed, such as PrismaAlertDispatcher
+	Source string `json:"source"`
+	// Target is the target the event failed on
+	Target    string    `json:"target,omitempty"`
+	Category  string    `json:"category"`
+	Error     string    `json:"error"`
+	Attempts  int       `json:"attempts"`
+	AlertID   string    `json:"alertId,omitempty"`
+	MessageID string    `json:"messageId,omitempty"`
+	FailedAt  time.Time `json:"failedAt"`
+	// Event is the original event
+	Event json.RawMessage `json:"event"`
+}
+
+// NewRecord create the record of an event that failed with err
+// An event that is not JSON is kept as a JSON string
+func NewRecord(source string, target string, err error, attempts int, event []byte) *Record {
+	if !json.Valid(event) {
+		event, _ = json.Marshal(string(event))
+	}
+	return &Record{
+		Source:   source,
+		Target:   target,
+		Category: errors.CategoryOf(err).String(),
+		Error:    err.Error(),
+		Attempts: attempts,
+		AlertID:  alertID(event),
+		FailedAt: time.Now().UTC(),
+		Event:    json.RawMessage(event),
+	}
+}
+
+// Parse decode a dead-letter message
+// A message that is not a Record, such as a message moved by a SQS redrive policy
+// or a failed asynchronous invocation, is the event itself
+func Parse(body []byte) (*Record, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, errors.Wrap(errors.Validation, fmt.Errorf("invalid dead-letter message: %s", err.Error()))
+	}
+	_, hasCategory := fields["category"]
+	_, hasEvent := fields["event"]
+	if !hasCategory || !hasEvent {
+		return &Record{AlertID: alertID(body), Event: json.RawMessage(body)}, nil
+	}
+	record := &Record{}
+	if err := json.Unmarshal(body, record); err != nil {
+		return nil, errors.Wrap(errors.Validation, fmt.Errorf("invalid dead-letter record: %s", err.Error()))
+	}
+	return record, nil
+}
+
+// alertID return the alert ID of an event, if any
+func alertID(event []byte) string {
+	var alert struct {
+		AlertID string `json:"alertId"`
+	}
+	if json.Unmarshal(event, &alert) != nil {
+		return ""
+	}
+	return alert.AlertID
+}
+
+// ReadRecords parse the records of a JSONL file, blank lines are ignored
+func ReadRecords(reader io.Reader) ([]*Record, error) {
+	records := []*Record{}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		body := bytes.TrimSpace(scanner.Bytes())
+		if len(body) == 0 {
+			continue
+		}
+		record, err := Parse(body)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err.Error())
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Filter select the records to replay, an empty field select every record
+type Filter struct {
+	AlertIDs []string
+	Category string
+	Source   string
+	Target   string
+}
+
+// Match return true if the record is selected
+func (f *Filter) Match(record *Record) bool {
+	if len(f.AlertIDs) > 0 && !contains(f.AlertIDs, record.AlertID) {
+		return false
+	}
+	if f.Category != "" && !strings.EqualFold(f.Category, record.Category) {
+		return false
+	}
+	if f.Source != "" && f.Source != record.Source {
+		return false
+	}
+	if f.Target != "" && f.Target != record.Target {
+		return false
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
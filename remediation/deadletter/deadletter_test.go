@@ -0,0 +1,104 @@
+package deadletter_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/deadletter"
+)
+
+const alertBody = `{"alertId": "P-39425", "alertRuleName": "VPCKiller"}`
+
+func TestNewRecord(t *testing.T) {
+	err := errors.Classify(awserr.New("AccessDeniedException", "not authorized", nil))
+	record := deadletter.NewRecord("PrismaAlertDispatcher", "PrismaVPCKiller", err, 3, []byte(alertBody))
+	assert.Equal(t, "PrismaAlertDispatcher", record.Source)
+	assert.Equal(t, "PrismaVPCKiller", record.Target)
+	assert.Equal(t, "Forbidden", record.Category)
+	assert.Equal(t, "AccessDeniedException: not authorized", record.Error)
+	assert.Equal(t, 3, record.Attempts)
+	assert.Equal(t, "P-39425", record.AlertID)
+	assert.False(t, record.FailedAt.IsZero())
+	assert.JSONEq(t, alertBody, string(record.Event))
+
+	record = deadletter.NewRecord("PrismaAlertDispatcher", "", errors.New("invalid message"), 1, []byte(`{"alertId":`))
+	assert.Equal(t, "", record.AlertID)
+	assert.Equal(t, `"{\"alertId\":"`, string(record.Event))
+}
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name     string
+		body     string
+		expected *deadletter.Record
+	}{
+		{
+			name: "record",
+			body: `{"source": "PrismaAlertDispatcher", "target": "PrismaVPCKiller", "category": "Forbidden", "error": "denied", "attempts": 2, "alertId": "P-39425", "event": ` + alertBody + `}`,
+			expected: &deadletter.Record{
+				Source:   "PrismaAlertDispatcher",
+				Target:   "PrismaVPCKiller",
+				Category: "Forbidden",
+				Error:    "denied",
+				Attempts: 2,
+				AlertID:  "P-39425",
+				Event:    []byte(alertBody),
+			},
+		},
+		{
+			name:     "raw event",
+			body:     alertBody,
+			expected: &deadletter.Record{AlertID: "P-39425", Event: []byte(alertBody)},
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, testCase.name), func(t *testing.T) {
+			record, err := deadletter.Parse([]byte(testCase.body))
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expected, record)
+		})
+	}
+
+	_, err := deadletter.Parse([]byte(`[`))
+	assert.True(t, errors.Is(err, errors.Validation))
+}
+
+func TestReadRecords(t *testing.T) {
+	records, err := deadletter.ReadRecords(strings.NewReader(alertBody + "\n\n" + `{"category": "Permanent", "event": {"alertId": "P-1"}}` + "\n"))
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, "P-39425", records[0].AlertID)
+	assert.Equal(t, "Permanent", records[1].Category)
+
+	_, err = deadletter.ReadRecords(strings.NewReader(alertBody + "\nnot json\n"))
+	assert.EqualError(t, err, "line 2: invalid dead-letter message: invalid character 'o' in literal null (expecting 'u')")
+}
+
+func TestFilter(t *testing.T) {
+	record := &deadletter.Record{Source: "PrismaAlertDispatcher", Target: "PrismaVPCKiller", Category: "Forbidden", AlertID: "P-39425"}
+	testCases := []struct {
+		name     string
+		filter   deadletter.Filter
+		expected bool
+	}{
+		{name: "empty", filter: deadletter.Filter{}, expected: true},
+		{name: "alert ID", filter: deadletter.Filter{AlertIDs: []string{"P-1", "P-39425"}}, expected: true},
+		{name: "other alert ID", filter: deadletter.Filter{AlertIDs: []string{"P-1"}}, expected: false},
+		{name: "category", filter: deadletter.Filter{Category: "forbidden"}, expected: true},
+		{name: "other category", filter: deadletter.Filter{Category: "Retryable"}, expected: false},
+		{name: "source", filter: deadletter.Filter{Source: "PrismaVPCKiller"}, expected: false},
+		{name: "target", filter: deadletter.Filter{Target: "PrismaVPCKiller"}, expected: true},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, testCase.name), func(t *testing.T) {
+			assert.Equal(t, testCase.expected, testCase.filter.Match(record))
+		})
+	}
+}
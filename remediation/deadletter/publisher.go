@@ -0,0 +1,111 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+)
+
+// QueueURLEnv contain the URL of the dead-letter queue
+const QueueURLEnv = "DEAD_LETTER_QUEUE_URL"
+
+// Publisher send the failed events of a function to the dead-letter queue
+type Publisher struct {
+	// Source is the function name written in the records
+	Source   string
+	queueURL string
+	client   sqsiface.SQSAPI
+}
+
+// NewPublisher create a publisher on the queue
+// Without a queue URL the records are logged and not published, Publish fail
+func NewPublisher(source string, queueURL string, client sqsiface.SQSAPI) *Publisher {
+	return &Publisher{Source: source, queueURL: queueURL, client: client}
+}
+
+// NewPublisherFromEnv create a publisher on the queue in DEAD_LETTER_QUEUE_URL
+func NewPublisherFromEnv(source string, client sqsiface.SQSAPI) *Publisher {
+	return NewPublisher(source, os.Getenv(QueueURLEnv), client)
+}
+
+// Publish send the record to the dead-letter queue, a publisher without queue log it and return a Permanent error
+func (p *Publisher) Publish(ctx context.Context, record *Record) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(errors.Permanent, err)
+	}
+	if p.queueURL == "" {
+		fmt.Printf("Dead letter: %s\n", string(body))
+		return errors.Wrapf(errors.Permanent, "no dead-letter queue, %s is not set", QueueURLEnv)
+	}
+	attributes := map[string]*sqs.MessageAttributeValue{
+		"attempts": {DataType: aws.String("Number"), StringValue: aws.String(strconv.Itoa(record.Attempts))},
+	}
+	for name, value := range map[string]string{
+		"source":   record.Source,
+		"target":   record.Target,
+		"category": record.Category,
+		"alertId":  record.AlertID,
+	} {
+		if value != "" {
+			attributes[name] = &sqs.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+		}
+	}
+	output, err := p.client.SendMessageWithContext(ctx, &sqs.SendMessageInput{
+		QueueUrl:          aws.String(p.queueURL),
+		MessageBody:       aws.String(string(body)),
+		MessageAttributes: attributes,
+	})
+	if err != nil {
+		return errors.Classify(err)
+	}
+	fmt.Printf("Dead letter %s of alert %s: %s %s\n", aws.StringValue(output.MessageId), record.AlertID, record.Category, record.Error)
+	return nil
+}
+
+// Handle return what a function return for an event that failed with err on the target
+// An acknowledged error return nil, a retryable error is returned so the event is retried,
+// any other error is published to the dead-letter queue and acknowledged.
+// The error is returned when the record cannot be published
+func (p *Publisher) Handle(ctx context.Context, target string, event interface{}, attempts int, err error) error {
+	switch errors.DispositionOf(err) {
+	case errors.Ack:
+		return nil
+	case errors.Retry:
+		return errors.Classify(err)
+	}
+	return p.handle(ctx, target, event, attempts, err)
+}
+
+// HandleAsync return what an asynchronously invoked function return for an event that failed with err on the target
+// A retryable error is returned, Lambda retry the invocation twice and then send the raw event to the function
+// dead-letter queue. Any other error is published and acknowledged, with its category and its error.
+// The error is returned when the record cannot be published
+func (p *Publisher) HandleAsync(ctx context.Context, target string, event interface{}, err error) error {
+	return p.Handle(ctx, target, event, 1, err)
+}
+
+// handle publish the record of the event and acknowledge it
+func (p *Publisher) handle(ctx context.Context, target string, event interface{}, attempts int, err error) error {
+	body, ok := event.([]byte)
+	if !ok {
+		var marshalErr error
+		if body, marshalErr = json.Marshal(event); marshalErr != nil {
+			fmt.Printf("Marshal dead letter failed: %s\n", marshalErr.Error())
+			return errors.Classify(err)
+		}
+	}
+	if publishErr := p.Publish(ctx, NewRecord(p.Source, target, err, attempts, body)); publishErr != nil {
+		fmt.Printf("Publish dead letter failed: %s\n", publishErr.Error())
+		return errors.Classify(err)
+	}
+	return nil
+}
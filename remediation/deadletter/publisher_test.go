@@ -0,0 +1,141 @@
+package deadletter_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/deadletter"
+)
+
+const queueURL = "https://sqs.us-east-1.amazonaws.com/123456789012/PrismaAlertDLQ"
+
+type mockSQS struct {
+	sqsiface.SQSAPI
+	inputs []*sqs.SendMessageInput
+	err    error
+}
+
+func (m *mockSQS) SendMessageWithContext(ctx aws.Context, input *sqs.SendMessageInput, opts ...request.Option) (*sqs.SendMessageOutput, error) {
+	m.inputs = append(m.inputs, input)
+	return &sqs.SendMessageOutput{MessageId: aws.String("message-id")}, m.err
+}
+
+func TestPublish(t *testing.T) {
+	client := &mockSQS{}
+	publisher := deadletter.NewPublisher("PrismaAlertDispatcher", queueURL, client)
+	record := deadletter.NewRecord("PrismaAlertDispatcher", "PrismaVPCKiller", errors.Wrapf(errors.Permanent, "failed"), 2, []byte(alertBody))
+	assert.NoError(t, publisher.Publish(context.Background(), record))
+
+	input := client.inputs[0]
+	assert.Equal(t, queueURL, aws.StringValue(input.QueueUrl))
+	assert.Equal(t, "Permanent", aws.StringValue(input.MessageAttributes["category"].StringValue))
+	assert.Equal(t, "PrismaVPCKiller", aws.StringValue(input.MessageAttributes["target"].StringValue))
+	assert.Equal(t, "2", aws.StringValue(input.MessageAttributes["attempts"].StringValue))
+	assert.Equal(t, "Number", aws.StringValue(input.MessageAttributes["attempts"].DataType))
+	published, err := deadletter.Parse([]byte(aws.StringValue(input.MessageBody)))
+	assert.NoError(t, err)
+	assert.Equal(t, record.Error, published.Error)
+	assert.JSONEq(t, alertBody, string(published.Event))
+
+	// without a queue the record is only logged, it is not published
+	err = deadletter.NewPublisher("PrismaAlertDispatcher", "", nil).Publish(context.Background(), record)
+	assert.Equal(t, errors.Permanent, errors.CategoryOf(err))
+}
+
+func TestHandle(t *testing.T) {
+	event := events.AlertEvent{AlertID: "P-39425", AlertRuleName: "VPCKiller"}
+	testCases := []struct {
+		name      string
+		err       error
+		publishOK bool
+		returned  bool
+		published bool
+	}{
+		{name: "no error", err: nil, publishOK: true},
+		{name: "not found is acknowledged", err: errors.Wrapf(errors.NotFound, "vpc not found"), publishOK: true},
+		{name: "retryable is returned", err: awserr.New("Throttling", "Rate exceeded", nil), publishOK: true, returned: true},
+		{name: "permanent is dead-lettered", err: errors.Wrapf(errors.Validation, "invalid alert"), publishOK: true, published: true},
+		{name: "publish failure return the error", err: errors.Wrapf(errors.Forbidden, "denied"), returned: true, published: true},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, testCase.name), func(t *testing.T) {
+			client := &mockSQS{}
+			if !testCase.publishOK {
+				client.err = awserr.New("AccessDenied", "denied", nil)
+			}
+			publisher := deadletter.NewPublisher("PrismaVPCKiller", queueURL, client)
+			err := publisher.Handle(context.Background(), "PrismaVPCKiller", event, 1, testCase.err)
+			assert.Equal(t, testCase.returned, err != nil)
+			if testCase.returned {
+				assert.Equal(t, errors.CategoryOf(testCase.err), errors.CategoryOf(err))
+			}
+			assert.Equal(t, testCase.published, len(client.inputs) == 1)
+			if testCase.published {
+				record := &deadletter.Record{}
+				assert.NoError(t, json.Unmarshal([]byte(aws.StringValue(client.inputs[0].MessageBody)), record))
+				assert.Equal(t, "PrismaVPCKiller", record.Source)
+				assert.Equal(t, "P-39425", record.AlertID)
+				assert.Equal(t, 1, record.Attempts)
+			}
+		})
+	}
+}
+
+func TestHandleAsync(t *testing.T) {
+	event := events.AlertEvent{AlertID: "P-39425", AlertRuleName: "VPCKiller"}
+	testCases := []struct {
+		name      string
+		err       error
+		publishOK bool
+		returned  bool
+		published bool
+	}{
+		{name: "no error", err: nil, publishOK: true},
+		{name: "not found is acknowledged", err: errors.Wrapf(errors.NotFound, "vpc not found"), publishOK: true},
+		{name: "retryable is returned to Lambda", err: awserr.New("Throttling", "Rate exceeded", nil), publishOK: true, returned: true},
+		{name: "permanent is dead-lettered", err: errors.Wrapf(errors.Validation, "invalid alert"), publishOK: true, published: true},
+		{name: "publish failure return the error", err: errors.Wrapf(errors.Forbidden, "denied"), returned: true, published: true},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, testCase.name), func(t *testing.T) {
+			client := &mockSQS{}
+			if !testCase.publishOK {
+				client.err = awserr.New("AccessDenied", "denied", nil)
+			}
+			publisher := deadletter.NewPublisher("PrismaVPCKiller", queueURL, client)
+			err := publisher.HandleAsync(context.Background(), "PrismaVPCKiller", event, testCase.err)
+			assert.Equal(t, testCase.returned, err != nil)
+			if testCase.returned {
+				assert.Equal(t, errors.CategoryOf(testCase.err), errors.CategoryOf(err))
+			}
+			assert.Equal(t, testCase.published, len(client.inputs) == 1)
+			if testCase.published {
+				record := &deadletter.Record{}
+				assert.NoError(t, json.Unmarshal([]byte(aws.StringValue(client.inputs[0].MessageBody)), record))
+				assert.Equal(t, errors.CategoryOf(testCase.err).String(), record.Category)
+				assert.Equal(t, 1, record.Attempts)
+			}
+		})
+	}
+}
+
+func TestHandleWithoutQueue(t *testing.T) {
+	event := events.AlertEvent{AlertID: "P-39425", AlertRuleName: "VPCKiller"}
+	publisher := deadletter.NewPublisher("PrismaAlertNotification", "", nil)
+	err := publisher.HandleAsync(context.Background(), "PrismaAlertNotification", event, errors.Wrapf(errors.Validation, "invalid alert"))
+	assert.Equal(t, errors.Validation, errors.CategoryOf(err))
+	assert.NoError(t, publisher.HandleAsync(context.Background(), "PrismaAlertNotification", event, errors.Wrapf(errors.NotFound, "vpc not found")))
+}
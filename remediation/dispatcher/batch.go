@@ -0,0 +1,17 @@
+package main
+
+// SQSEventResponse is the partial batch response of a SQS event source mapping with ReportBatchItemFailures
+// Only the listed messages return to the queue, aws-lambda-go define it from v1.28
+type SQSEventResponse struct {
+	BatchItemFailures []SQSBatchItemFailure `json:"batchItemFailures"`
+}
+
+// SQSBatchItemFailure is a message to retry
+type SQSBatchItemFailure struct {
+	ItemIdentifier string `json:"itemIdentifier"`
+}
+
+// retry add the message to the batch item failures
+func (r *SQSEventResponse) retry(messageID string) {
+	r.BatchItemFailures = append(r.BatchItemFailures, SQSBatchItemFailure{ItemIdentifier: messageID})
+}
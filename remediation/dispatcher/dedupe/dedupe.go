@@ -0,0 +1,107 @@
+package dedupe
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/target"
+)
+
+const (
+	// TableEnv contain the DynamoDB table of the dispatched alerts, the memory store is used when it is empty
+	TableEnv = "DEDUPE_TABLE"
+	// TTLEnv contain how long a dispatch is remembered, such as 24h
+	TTLEnv = "DEDUPE_TTL"
+	// DefaultTTL is how long a dispatch is remembered by default
+	DefaultTTL = 24 * time.Hour
+	// DefaultLease is how long a claim wait for its send to be confirmed, longer than the dispatcher timeout
+	DefaultLease = time.Minute
+)
+
+// ErrPending is returned by a Store when the key is claimed by a send not confirmed yet
+var ErrPending = errors.New("dispatch in progress")
+
+// Store remember the dispatched keys
+type Store interface {
+	// Claim record the key as pending until the lease expire
+	// false is returned when the key is confirmed, ErrPending when its lease is not expired
+	Claim(ctx context.Context, key string, lease time.Duration) (bool, error)
+	// Confirm record the key as sent until the TTL expire
+	Confirm(ctx context.Context, key string, ttl time.Duration) error
+	// Release forget the key, so the next dispatch is not a duplicate
+	Release(ctx context.Context, key string) error
+}
+
+// Deduplicator skip the alerts already sent to a target
+type Deduplicator struct {
+	store      Store
+	ttl        time.Duration
+	lease      time.Duration
+	duplicates int64
+}
+
+// New create a Deduplicator on the store
+func New(store Store, ttl time.Duration) *Deduplicator {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Deduplicator{store: store, ttl: ttl, lease: DefaultLease}
+}
+
+// NewFromEnv create a Deduplicator on the DynamoDB table in DEDUPE_TABLE,
+// or on a memory store that only remember the dispatches of a warm Lambda
+func NewFromEnv(client dynamodbiface.DynamoDBAPI) (*Deduplicator, error) {
+	ttl := DefaultTTL
+	if value := os.Getenv(TTLEnv); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("invalid %s %q: must be a positive duration", TTLEnv, value)
+		}
+		ttl = parsed
+	}
+	if table := os.Getenv(TableEnv); table != "" {
+		return New(NewDynamoDBStore(table, client), ttl), nil
+	}
+	return New(NewMemoryStore(), ttl), nil
+}
+
+// Key identify the dispatch of an alert notification to a target
+func Key(alert *events.AlertEvent, targetName string) string {
+	return target.DeduplicationID(alert) + "#" + targetName
+}
+
+// Claim return false when the alert was already sent to the target, the duplicate is counted
+// The claim is a lease until the send is confirmed, so a lost send is not a duplicate once it expire
+// A store error and a send in progress are Retryable
+func (d *Deduplicator) Claim(ctx context.Context, alert *events.AlertEvent, targetName string) (bool, error) {
+	claimed, err := d.store.Claim(ctx, Key(alert, targetName), d.lease)
+	if err != nil {
+		return false, errors.Wrap(errors.Retryable, fmt.Errorf("claim %s: %w", Key(alert, targetName), err))
+	}
+	if !claimed {
+		atomic.AddInt64(&d.duplicates, 1)
+	}
+	return claimed, nil
+}
+
+// Confirm remember the alert sent to the target until the TTL expire
+func (d *Deduplicator) Confirm(ctx context.Context, alert *events.AlertEvent, targetName string) error {
+	return d.store.Confirm(ctx, Key(alert, targetName), d.ttl)
+}
+
+// Release forget the dispatch of a failed send, so the retry is not skipped
+func (d *Deduplicator) Release(ctx context.Context, alert *events.AlertEvent, targetName string) error {
+	return d.store.Release(ctx, Key(alert, targetName))
+}
+
+// Duplicates return the number of duplicates skipped
+func (d *Deduplicator) Duplicates() int64 {
+	return atomic.LoadInt64(&d.duplicates)
+}
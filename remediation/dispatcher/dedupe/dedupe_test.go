@@ -0,0 +1,125 @@
+package dedupe_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/dedupe"
+)
+
+type failingStore struct{}
+
+func (failingStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return false, errors.New("store unavailable")
+}
+
+func (failingStore) Confirm(ctx context.Context, key string, ttl time.Duration) error {
+	return errors.New("store unavailable")
+}
+
+func (failingStore) Release(ctx context.Context, key string) error {
+	return errors.New("store unavailable")
+}
+
+func vpcAlert() *events.AlertEvent {
+	return &events.AlertEvent{AlertID: "P-39425", AlertRuleName: "VPCKiller", AlertTs: 1582322540962}
+}
+
+func TestKey(t *testing.T) {
+	alert := vpcAlert()
+	assert.Equal(t, "P-39425-1582322540962-alert#PrismaVPCKiller", dedupe.Key(alert, "PrismaVPCKiller"))
+
+	alert.AlertStatus = events.AlertStatusResolved
+	assert.Equal(t, "P-39425-1582322540962-resolved#PrismaVPCKiller", dedupe.Key(alert, "PrismaVPCKiller"))
+}
+
+func TestDeduplicator(t *testing.T) {
+	now := time.Date(2020, 2, 21, 22, 0, 0, 0, time.UTC)
+	store := dedupe.NewMemoryStore()
+	store.Now = func() time.Time { return now }
+	deduplicator := dedupe.New(store, time.Hour)
+	ctx := context.Background()
+
+	claimed, err := deduplicator.Claim(ctx, vpcAlert(), "PrismaVPCKiller")
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+
+	// same alert, another target
+	claimed, _ = deduplicator.Claim(ctx, vpcAlert(), "PrismaAlertNotification")
+	assert.True(t, claimed)
+
+	// the send is not confirmed yet
+	claimed, err = deduplicator.Claim(ctx, vpcAlert(), "PrismaVPCKiller")
+	assert.False(t, claimed)
+	assert.EqualError(t, err, "claim P-39425-1582322540962-alert#PrismaVPCKiller: dispatch in progress")
+	assert.True(t, errors.Is(err, errors.Retryable))
+	assert.Equal(t, int64(0), deduplicator.Duplicates())
+
+	assert.NoError(t, deduplicator.Confirm(ctx, vpcAlert(), "PrismaVPCKiller"))
+	claimed, err = deduplicator.Claim(ctx, vpcAlert(), "PrismaVPCKiller")
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+	assert.Equal(t, int64(1), deduplicator.Duplicates())
+
+	// a new notification of the alert
+	resent := vpcAlert()
+	resent.AlertTs++
+	claimed, _ = deduplicator.Claim(ctx, resent, "PrismaVPCKiller")
+	assert.True(t, claimed)
+
+	assert.NoError(t, deduplicator.Release(ctx, vpcAlert(), "PrismaVPCKiller"))
+	claimed, _ = deduplicator.Claim(ctx, vpcAlert(), "PrismaVPCKiller")
+	assert.True(t, claimed)
+
+	// the lease of a lost send expire
+	now = now.Add(dedupe.DefaultLease)
+	claimed, _ = deduplicator.Claim(ctx, vpcAlert(), "PrismaAlertNotification")
+	assert.True(t, claimed)
+	claimed, _ = deduplicator.Claim(ctx, resent, "PrismaVPCKiller")
+	assert.True(t, claimed)
+	assert.NoError(t, deduplicator.Confirm(ctx, resent, "PrismaVPCKiller"))
+
+	now = now.Add(time.Hour)
+	claimed, _ = deduplicator.Claim(ctx, resent, "PrismaVPCKiller")
+	assert.True(t, claimed)
+	assert.Equal(t, int64(1), deduplicator.Duplicates())
+}
+
+func TestDeduplicatorStoreError(t *testing.T) {
+	deduplicator := dedupe.New(failingStore{}, 0)
+	claimed, err := deduplicator.Claim(context.Background(), vpcAlert(), "PrismaVPCKiller")
+	assert.False(t, claimed)
+	assert.EqualError(t, err, "claim P-39425-1582322540962-alert#PrismaVPCKiller: store unavailable")
+	assert.True(t, errors.Is(err, errors.Retryable))
+	assert.Equal(t, int64(0), deduplicator.Duplicates())
+}
+
+func TestNewFromEnv(t *testing.T) {
+	defer os.Unsetenv(dedupe.TableEnv)
+	defer os.Unsetenv(dedupe.TTLEnv)
+
+	deduplicator, err := dedupe.NewFromEnv(nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, deduplicator)
+
+	os.Setenv(dedupe.TableEnv, "PrismaAlertDedupe")
+	os.Setenv(dedupe.TTLEnv, "6h")
+	client := &mockDynamoDB{}
+	deduplicator, err = dedupe.NewFromEnv(client)
+	assert.NoError(t, err)
+	_, err = deduplicator.Claim(context.Background(), vpcAlert(), "PrismaVPCKiller")
+	assert.NoError(t, err)
+	assert.Equal(t, "PrismaAlertDedupe", *client.putInput.TableName)
+
+	for _, ttl := range []string{"6", "-1h"} {
+		os.Setenv(dedupe.TTLEnv, ttl)
+		_, err = dedupe.NewFromEnv(client)
+		assert.EqualError(t, err, `invalid DEDUPE_TTL "`+ttl+`": must be a positive duration`)
+	}
+}
@@ -0,0 +1,105 @@
+package dedupe
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+const (
+	// KeyAttribute is the partition key of the table
+	KeyAttribute = "id"
+	// ExpiresAttribute is the TTL attribute of the table, in epoch seconds
+	ExpiresAttribute = "expiresAt"
+	// SentAttribute is true once the send of the key is confirmed
+	SentAttribute = "sent"
+)
+
+// DynamoDBStore is a Store on a DynamoDB table shared by every Lambda
+type DynamoDBStore struct {
+	// Now return the current time, time.Now by default
+	Now func() time.Time
+
+	table  string
+	client dynamodbiface.DynamoDBAPI
+}
+
+// NewDynamoDBStore create a store on the table
+func NewDynamoDBStore(table string, client dynamodbiface.DynamoDBAPI) *DynamoDBStore {
+	return &DynamoDBStore{Now: time.Now, table: table, client: client}
+}
+
+// Claim put the pending key unless an unexpired item exist, the existing item tell if it is sent
+// DynamoDB delete the expired items late, so the condition check the expiry too
+func (s *DynamoDBStore) Claim(ctx context.Context, key string, lease time.Duration) (bool, error) {
+	now := s.Now()
+	_, err := s.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(s.table),
+		Item:                s.item(key, now.Add(lease), false),
+		ConditionExpression: aws.String("attribute_not_exists(#id) OR #expiresAt <= :now"),
+		ExpressionAttributeNames: map[string]*string{
+			"#id":        aws.String(KeyAttribute),
+			"#expiresAt": aws.String(ExpiresAttribute),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": {N: aws.String(strconv.FormatInt(now.Unix(), 10))},
+		},
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return false, s.sent(ctx, key)
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// sent return ErrPending unless the item of the key is sent
+func (s *DynamoDBStore) sent(ctx context.Context, key string) error {
+	output, err := s.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.table),
+		Key:            map[string]*dynamodb.AttributeValue{KeyAttribute: {S: aws.String(key)}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return err
+	}
+	if sent, ok := output.Item[SentAttribute]; !ok || !aws.BoolValue(sent.BOOL) {
+		return ErrPending
+	}
+	return nil
+}
+
+// Confirm put the sent key
+func (s *DynamoDBStore) Confirm(ctx context.Context, key string, ttl time.Duration) error {
+	_, err := s.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item:      s.item(key, s.Now().Add(ttl), true),
+	})
+	return err
+}
+
+// item return the item of the key
+func (s *DynamoDBStore) item(key string, expires time.Time, sent bool) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		KeyAttribute:     {S: aws.String(key)},
+		ExpiresAttribute: {N: aws.String(strconv.FormatInt(expires.Unix(), 10))},
+		SentAttribute:    {BOOL: aws.Bool(sent)},
+	}
+}
+
+// Release delete the key
+func (s *DynamoDBStore) Release(ctx context.Context, key string) error {
+	_, err := s.client.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			KeyAttribute: {S: aws.String(key)},
+		},
+	})
+	return err
+}
@@ -0,0 +1,85 @@
+package dedupe_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/dedupe"
+)
+
+type mockDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	putInput    *dynamodb.PutItemInput
+	putErr      error
+	deleteInput *dynamodb.DeleteItemInput
+	getInput    *dynamodb.GetItemInput
+	item        map[string]*dynamodb.AttributeValue
+}
+
+func (m *mockDynamoDB) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	m.getInput = input
+	return &dynamodb.GetItemOutput{Item: m.item}, nil
+}
+
+func (m *mockDynamoDB) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	m.putInput = input
+	return &dynamodb.PutItemOutput{}, m.putErr
+}
+
+func (m *mockDynamoDB) DeleteItemWithContext(ctx aws.Context, input *dynamodb.DeleteItemInput, opts ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	m.deleteInput = input
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func TestDynamoDBStore(t *testing.T) {
+	client := &mockDynamoDB{}
+	store := dedupe.NewDynamoDBStore("PrismaAlertDedupe", client)
+	store.Now = func() time.Time { return time.Unix(1582322540, 0) }
+	ctx := context.Background()
+
+	claimed, err := store.Claim(ctx, "P-39425-1582322540962-alert#PrismaVPCKiller", time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+	assert.Equal(t, "PrismaAlertDedupe", aws.StringValue(client.putInput.TableName))
+	assert.Equal(t, "P-39425-1582322540962-alert#PrismaVPCKiller", aws.StringValue(client.putInput.Item["id"].S))
+	assert.Equal(t, "1582326140", aws.StringValue(client.putInput.Item["expiresAt"].N))
+	assert.False(t, aws.BoolValue(client.putInput.Item["sent"].BOOL))
+	assert.Equal(t, "1582322540", aws.StringValue(client.putInput.ExpressionAttributeValues[":now"].N))
+	assert.Equal(t, "attribute_not_exists(#id) OR #expiresAt <= :now", aws.StringValue(client.putInput.ConditionExpression))
+
+	client.putErr = awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+	client.item = client.putInput.Item
+	claimed, err = store.Claim(ctx, "P-39425-1582322540962-alert#PrismaVPCKiller", time.Hour)
+	assert.Equal(t, dedupe.ErrPending, err)
+	assert.False(t, claimed)
+	assert.True(t, aws.BoolValue(client.getInput.ConsistentRead))
+
+	client.putErr = nil
+	assert.NoError(t, store.Confirm(ctx, "P-39425-1582322540962-alert#PrismaVPCKiller", 24*time.Hour))
+	assert.True(t, aws.BoolValue(client.putInput.Item["sent"].BOOL))
+	assert.Equal(t, "1582408940", aws.StringValue(client.putInput.Item["expiresAt"].N))
+	assert.Nil(t, client.putInput.ConditionExpression)
+
+	client.putErr = awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+	client.item = client.putInput.Item
+	claimed, err = store.Claim(ctx, "P-39425-1582322540962-alert#PrismaVPCKiller", time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+
+	client.putErr = awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "Throughput exceeded", nil)
+	claimed, err = store.Claim(ctx, "P-39425-1582322540962-alert#PrismaVPCKiller", time.Hour)
+	assert.Error(t, err)
+	assert.False(t, claimed)
+
+	assert.NoError(t, store.Release(ctx, "P-39425-1582322540962-alert#PrismaVPCKiller"))
+	assert.Equal(t, "PrismaAlertDedupe", aws.StringValue(client.deleteInput.TableName))
+	assert.Equal(t, "P-39425-1582322540962-alert#PrismaVPCKiller", aws.StringValue(client.deleteInput.Key["id"].S))
+}
@@ -0,0 +1,63 @@
+package dedupe
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store of one process
+type MemoryStore struct {
+	// Now return the current time, time.Now by default
+	Now func() time.Time
+
+	mutex sync.Mutex
+	keys  map[string]record
+}
+
+// record is a claimed key, pending until it is sent
+type record struct {
+	expires time.Time
+	sent    bool
+}
+
+// NewMemoryStore create an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{Now: time.Now, keys: map[string]record{}}
+}
+
+// Claim record the key as pending, expired keys are removed
+func (s *MemoryStore) Claim(ctx context.Context, key string, lease time.Duration) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := s.Now()
+	for recorded, record := range s.keys {
+		if !now.Before(record.expires) {
+			delete(s.keys, recorded)
+		}
+	}
+	if record, ok := s.keys[key]; ok {
+		if !record.sent {
+			return false, ErrPending
+		}
+		return false, nil
+	}
+	s.keys[key] = record{expires: now.Add(lease)}
+	return true, nil
+}
+
+// Confirm record the key as sent
+func (s *MemoryStore) Confirm(ctx context.Context, key string, ttl time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.keys[key] = record{expires: s.Now().Add(ttl), sent: true}
+	return nil
+}
+
+// Release forget the key
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.keys, key)
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	lambdaEvents "github.com/aws/aws-lambda-go/events"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+)
+
+// ExplainEnv turn the dispatcher into a dry run when it is true,
+// the routing of every SQS message is logged and nothing is sent
+const ExplainEnv = "EXPLAIN"
+
+// explainMode is read from EXPLAIN at cold start
+var explainMode bool
+
+// ExplainRequest is a direct invocation that explain the routing of an alert payload
+//
+//	{"explain": {"alertId": "P-39425", "alertRuleName": "VPCKiller", ...}}
+type ExplainRequest struct {
+	Explain json.RawMessage `json:"explain"`
+}
+
+// invoke route a direct explain invocation to explain and any other payload to the SQS handler
+func invoke(ctx context.Context, payload json.RawMessage) (interface{}, error) {
+	request := ExplainRequest{}
+	if err := json.Unmarshal(payload, &request); err == nil && len(request.Explain) > 0 {
+		explanation, err := routes.ExplainMessage(request.Explain)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Print(explanation.String())
+		return explanation, nil
+	}
+	sqsEvent := lambdaEvents.SQSEvent{}
+	if err := json.Unmarshal(payload, &sqsEvent); err != nil {
+		return nil, errors.Wrapf(errors.Validation, "invalid SQS event: %s", err.Error())
+	}
+	return handler(ctx, sqsEvent)
+}
+
+// explainMessage log the routing of the message instead of dispatching it
+func explainMessage(message lambdaEvents.SQSMessage) error {
+	explanation, err := routes.ExplainMessage([]byte(message.Body))
+	if err != nil {
+		return err
+	}
+	document, err := json.Marshal(explanation)
+	if err != nil {
+		return errors.Wrap(errors.Permanent, err)
+	}
+	fmt.Printf("Explain message %s: %s\n", message.MessageId, string(document))
+	return nil
+}
+
+// loadExplainMode read EXPLAIN
+func loadExplainMode() error {
+	value := os.Getenv(ExplainEnv)
+	if value == "" {
+		return nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: must be true or false", ExplainEnv, value)
+	}
+	explainMode = enabled
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/router"
+)
+
+func TestInvokeExplain(t *testing.T) {
+	client := &mockLambda{}
+	setup(t, client)
+
+	result, err := invoke(context.Background(), json.RawMessage(`{"explain": `+readEvent(t, "vpc-killer-event.json")+`}`))
+	assert.NoError(t, err)
+	explanation, ok := result.(*router.Explanation)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"PrismaVPCKiller"}, explanation.Targets)
+	assert.Len(t, explanation.Routes, 4)
+	assert.Empty(t, client.invoked)
+
+	_, err = invoke(context.Background(), json.RawMessage(`{"explain": "{"}`))
+	assert.Error(t, err)
+}
+
+func TestInvokeSQSEvent(t *testing.T) {
+	client := &mockLambda{}
+	setup(t, client)
+
+	payload, err := json.Marshal(sqsEvent(readEvent(t, "vpc-killer-event.json")))
+	assert.NoError(t, err)
+	result, err := invoke(context.Background(), payload)
+	assert.NoError(t, err)
+	assert.Equal(t, SQSEventResponse{BatchItemFailures: []SQSBatchItemFailure{}}, result)
+	assert.Equal(t, []string{"PrismaVPCKiller"}, client.invoked)
+}
+
+func TestExplainMode(t *testing.T) {
+	defer os.Unsetenv(ExplainEnv)
+	defer func() { explainMode = false }()
+
+	os.Setenv(ExplainEnv, "yes")
+	assert.EqualError(t, loadExplainMode(), `invalid EXPLAIN "yes": must be true or false`)
+
+	os.Setenv(ExplainEnv, "true")
+	assert.NoError(t, loadExplainMode())
+	assert.True(t, explainMode)
+
+	client := &mockLambda{}
+	queue := setup(t, client)
+	response, err := handler(context.Background(), sqsEvent(readEvent(t, "vpc-killer-event.json"), `{`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, failedIDs(response))
+	assert.Empty(t, client.invoked)
+	assert.Equal(t, []string{}, queue.deadLettered())
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+)
+
+const (
+	// WorkersEnv contain how many targets are sent to at the same time
+	WorkersEnv = "WORKERS"
+	// TargetTimeoutEnv contain the timeout of a target call, such as 10s
+	TargetTimeoutEnv = "TARGET_TIMEOUT"
+	// DefaultWorkers is the number of workers when WORKERS is not set
+	DefaultWorkers = 10
+	// DefaultTargetTimeout is the target timeout when TARGET_TIMEOUT is not set
+	DefaultTargetTimeout = 10 * time.Second
+	// deadlineReserve is kept from the Lambda deadline to report the batch failures and dead letters
+	deadlineReserve = 2 * time.Second
+)
+
+// workers bound the concurrent target calls, targetTimeout bound each call
+var (
+	workers       = newPool(DefaultWorkers)
+	targetTimeout = DefaultTargetTimeout
+)
+
+// pool run at most its capacity of functions at the same time
+type pool chan struct{}
+
+func newPool(size int) pool {
+	return make(pool, size)
+}
+
+// run f when a worker is free
+// A context done before a worker is free is a Retryable error
+func (p pool) run(ctx context.Context, f func() error) error {
+	select {
+	case p <- struct{}{}:
+	case <-ctx.Done():
+		return errors.Wrap(errors.Retryable, ctx.Err())
+	}
+	defer func() { <-p }()
+	return f()
+}
+
+// targetContext return the context of a target call
+// The call time out after the target timeout, or before the Lambda deadline minus a reserve
+func targetContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := targetTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline) - deadlineReserve; remaining < timeout {
+			timeout = remaining
+		}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// loadFanout read WORKERS and TARGET_TIMEOUT
+func loadFanout() error {
+	if value := os.Getenv(WorkersEnv); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 1 {
+			return fmt.Errorf("invalid %s %q: must be a positive integer", WorkersEnv, value)
+		}
+		workers = newPool(size)
+	}
+	if value := os.Getenv(TargetTimeoutEnv); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid %s %q: must be a positive duration", TargetTimeoutEnv, value)
+		}
+		targetTimeout = timeout
+	}
+	return nil
+}
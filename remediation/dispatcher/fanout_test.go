@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+)
+
+func TestHandlerConcurrency(t *testing.T) {
+	client := &mockLambda{delay: 20 * time.Millisecond}
+	setup(t, client, "audit", "notify", "archive")
+	workers = newPool(2)
+
+	bodies := []string{}
+	for _, name := range []string{"aws-config", "aws-iam", "azure-config", "gcp-config"} {
+		bodies = append(bodies, readEvent(t, "golden/"+name+".json"))
+	}
+	response, err := handler(context.Background(), sqsEvent(bodies...))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, failedIDs(response))
+	assert.Len(t, client.invoked, 12)
+	assert.Equal(t, 2, client.peak)
+}
+
+func TestHandlerTargetTimeout(t *testing.T) {
+	client := &mockLambda{delay: time.Second}
+	setup(t, client)
+	targetTimeout = 10 * time.Millisecond
+	vpcKiller := sqsEvent(readEvent(t, "vpc-killer-event.json"))
+
+	start := time.Now()
+	response, err := handler(context.Background(), vpcKiller)
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, []string{"message-0"}, failedIDs(response))
+
+	// the timed out send was released, the retry is not a duplicate
+	client.delay = 0
+	response, err = handler(context.Background(), vpcKiller)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, failedIDs(response))
+	assert.Equal(t, int64(0), deduplicator.Duplicates())
+}
+
+func TestTargetContext(t *testing.T) {
+	targetTimeout = time.Minute
+	defer func() { targetTimeout = DefaultTargetTimeout }()
+
+	ctx, cancel := targetContext(context.Background())
+	deadline, ok := ctx.Deadline()
+	cancel()
+	assert.True(t, ok)
+	assert.InDelta(t, time.Minute.Seconds(), time.Until(deadline).Seconds(), 1)
+
+	// the Lambda deadline minus the reserve is shorter than the target timeout
+	lambdaCtx, lambdaCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer lambdaCancel()
+	ctx, cancel = targetContext(lambdaCtx)
+	deadline, _ = ctx.Deadline()
+	cancel()
+	assert.InDelta(t, 3, time.Until(deadline).Seconds(), 0.5)
+}
+
+func TestPoolRun(t *testing.T) {
+	p := newPool(1)
+	p <- struct{}{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := p.run(ctx, func() error { return nil })
+	assert.True(t, errors.Is(err, errors.Retryable))
+}
+
+func TestLoadFanout(t *testing.T) {
+	defer os.Unsetenv(WorkersEnv)
+	defer os.Unsetenv(TargetTimeoutEnv)
+	defer func() {
+		workers = newPool(DefaultWorkers)
+		targetTimeout = DefaultTargetTimeout
+	}()
+
+	os.Setenv(WorkersEnv, "4")
+	os.Setenv(TargetTimeoutEnv, "5s")
+	assert.NoError(t, loadFanout())
+	assert.Equal(t, 4, cap(workers))
+	assert.Equal(t, 5*time.Second, targetTimeout)
+
+	os.Setenv(WorkersEnv, "0")
+	assert.EqualError(t, loadFanout(), `invalid WORKERS "0": must be a positive integer`)
+
+	os.Setenv(WorkersEnv, "4")
+	os.Setenv(TargetTimeoutEnv, "5")
+	assert.EqualError(t, loadFanout(), `invalid TARGET_TIMEOUT "5": must be a positive duration`)
+}
@@ -2,88 +2,235 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
-
-	"github.com/aws/aws-lambda-go/events"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/deadletter"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/dedupe"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/router"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/target"
+	lambdaEvents "github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
-	invokeLambda "github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
 )
 
-type AlertName string
+// MaxAttemptsEnv contain how many times a message is received before it is dead-lettered
+const MaxAttemptsEnv = "MAX_ATTEMPTS"
 
-// Alert struct only partially parse the alert. Most of information is omitted
-type Alert struct {
-	ResourceID    string    `json:"resourceId"`
-	AlertRuleName AlertName `json:"alertRuleName"`
-	AccountName   string    `json:"accountName"`
-	CloudType     string    `json:"cloudType"`
-	Severity      string    `json:"severity"`
-	PolicyName    string    `json:"policyName"`
-}
+// DefaultMaxAttempts is the number of attempts when MAX_ATTEMPTS is not set
+const DefaultMaxAttempts = 5
 
-const (
-	SuspiciousTrafficAlert AlertName = "Suspicious Traffic Alert"
-	VPCKiller              AlertName = "VPCKiller"
-	ScienceLogic           AlertName = "ScienceLogic"
-	RegionViolation        AlertName = "AWS Region Violation"
+// routes, targets, the deduplicator and the dead-letter publisher are created at cold start
+var (
+	routes       *router.Table
+	targets      *target.Set
+	deduplicator *dedupe.Deduplicator
+	deadLetters  *deadletter.Publisher
+	maxAttempts  = DefaultMaxAttempts
 )
 
-func handler(ctx context.Context, sqsEvent events.SQSEvent) error {
-	for _, message := range sqsEvent.Records {
+// dispatchResult is the outcome of a record
+type dispatchResult struct {
+	target string
+	err    error
+}
 
-		fmt.Printf("The message %s for event source %s \n", message.MessageId, message.EventSource)
+// handler dispatch the records of the batch concurrently and report the records to retry
+// A record that will fail again, or failed MAX_ATTEMPTS times, is published to the dead-letter queue
+func handler(ctx context.Context, sqsEvent lambdaEvents.SQSEvent) (SQSEventResponse, error) {
+	response := SQSEventResponse{BatchItemFailures: []SQSBatchItemFailure{}}
+	if explainMode {
+		for _, message := range sqsEvent.Records {
+			if err := explainMessage(message); err != nil {
+				fmt.Printf("Explain message %s failed: %s\n", message.MessageId, err.Error())
+			}
+		}
+		return response, nil
+	}
 
-		var alert Alert
+	duplicates := deduplicator.Duplicates()
+	results := make([]dispatchResult, len(sqsEvent.Records))
+	var wait sync.WaitGroup
+	for i, message := range sqsEvent.Records {
 
-		if err := json.Unmarshal([]byte(message.Body), &alert); err != nil {
-			return err
-		}
+		fmt.Printf("The message %s for event source %s \n", message.MessageId, message.EventSource)
 
-		switch alert.AlertRuleName {
-		case SuspiciousTrafficAlert:
-			{
-				fmt.Println(alert.AlertRuleName)
-				invokeFunction("PrismaAlertNotification", "Event", []byte(message.Body))
-			}
-		case VPCKiller:
-			{
-				fmt.Println(alert.AlertRuleName)
-				invokeFunction("PrismaVPCKiller", "Event", []byte(message.Body))
-			}
-		case ScienceLogic:
-			{
-				fmt.Println(alert.AlertRuleName)
-				invokeFunction("PrismaScienceLogic", "Event", []byte(message.Body))
-			}
-		case RegionViolation:
-			{
-				fmt.Println(alert.AlertRuleName)
-				invokeFunction("PrismaFalseAlertRemover", "Event", []byte(message.Body))
+		wait.Add(1)
+		go func(i int, message lambdaEvents.SQSMessage) {
+			defer wait.Done()
+			results[i].target, results[i].err = dispatch(ctx, message)
+		}(i, message)
+	}
+	wait.Wait()
+
+	for i, message := range sqsEvent.Records {
+		failedTarget, err := results[i].target, results[i].err
+		attempts := receiveCount(message)
+		disposition := errors.DispositionOf(err)
+		if disposition == errors.Retry && attempts >= maxAttempts {
+			fmt.Printf("Message %s failed %d times\n", message.MessageId, attempts)
+			disposition = errors.DeadLetter
+		}
+		switch disposition {
+		case errors.Retry:
+			fmt.Printf("Retry message %s: %s\n", message.MessageId, err.Error())
+			response.retry(message.MessageId)
+		case errors.DeadLetter:
+			record := deadletter.NewRecord(deadLetters.Source, failedTarget, err, attempts, []byte(message.Body))
+			record.MessageID = message.MessageId
+			if publishErr := deadLetters.Publish(ctx, record); publishErr != nil {
+				fmt.Printf("Publish dead letter of message %s failed: %s\n", message.MessageId, publishErr.Error())
+				response.retry(message.MessageId)
 			}
-		default:
-			fmt.Printf("Unsupported Alert: %s ", alert.AlertRuleName)
 		}
+	}
 
+	if skipped := deduplicator.Duplicates() - duplicates; skipped > 0 {
+		fmt.Printf("Skipped %d duplicate dispatches, %d since cold start\n", skipped, deduplicator.Duplicates())
 	}
+	return response, nil
 
-	return nil
+}
 
+// receiveCount return how many times the message was received
+func receiveCount(message lambdaEvents.SQSMessage) int {
+	count, err := strconv.Atoi(message.Attributes["ApproximateReceiveCount"])
+	if err != nil || count < 1 {
+		return 1
+	}
+	return count
 }
 
-func invokeFunction(functionName string, invocationType string, payload []byte) {
-	sess := session.Must(session.NewSessionWithOptions(session.Options{
-		SharedConfigState: session.SharedConfigEnable,
-	}))
+// dispatch send the message to every target of the alert concurrently
+// Every target is attempted, a Retryable error is returned first so the message is retried.
+// The target of the returned error is returned with it
+func dispatch(ctx context.Context, message lambdaEvents.SQSMessage) (string, error) {
+	alert, err := events.ParseMessage([]byte(message.Body))
+	if err != nil {
+		return "", err
+	}
+
+	if alert.Kind() == events.MessageKindTest {
+		fmt.Printf("Test message %s: %s\n", message.MessageId, alert.Message)
+		return "", nil
+	}
+
+	names := routes.Targets(alert)
+	if len(names) == 0 {
+		if alert.IsResolved() {
+			fmt.Printf("Resolved alert %s: no receiver for %s\n", alert.AlertID, alert.AlertRuleName)
+		} else {
+			fmt.Printf("Unsupported Alert: %s \n", alert.AlertRuleName)
+		}
+		return "", nil
+	}
 
-	client := invokeLambda.New(sess, &aws.Config{Region: aws.String(os.Getenv("REGION"))})
+	errs := make([]error, len(names))
+	var wait sync.WaitGroup
+	for i, name := range names {
+		if alert.IsResolved() {
+			fmt.Printf("Resolved alert %s: %s -> %s\n", alert.AlertID, alert.AlertRuleName, name)
+		} else {
+			fmt.Printf("%s -> %s\n", alert.AlertRuleName, name)
+		}
+		wait.Add(1)
+		go func(i int, name string) {
+			defer wait.Done()
+			errs[i] = workers.run(ctx, func() error {
+				return send(ctx, alert, name, []byte(message.Body))
+			})
+		}(i, name)
+	}
+	wait.Wait()
 
-	client.Invoke(&invokeLambda.InvokeInput{FunctionName: aws.String(functionName), InvocationType: aws.String(invocationType), Payload: payload})
+	var failed error
+	var failedTarget string
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		fmt.Println(err.Error())
+		if failed == nil || (errors.DispositionOf(err) == errors.Retry && errors.DispositionOf(failed) != errors.Retry) {
+			failed = err
+			failedTarget = names[i]
+		}
+	}
+	return failedTarget, failed
+}
+
+// send the alert to the target once, within the target timeout
+// A failed send is released so the retry of the message is not a duplicate,
+// a sent alert is confirmed, an unconfirmed claim expire after its lease
+func send(ctx context.Context, alert *events.AlertEvent, name string, body []byte) error {
+	targetCtx, cancel := targetContext(ctx)
+	defer cancel()
+	claimed, err := deduplicator.Claim(targetCtx, alert, name)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		fmt.Printf("Duplicate alert %s -> %s, skipped\n", alert.AlertID, name)
+		return nil
+	}
+	if err := targets.Get(name).Send(targetCtx, alert, body); err != nil {
+		// the target context may be done, the release use the Lambda context
+		if releaseErr := deduplicator.Release(ctx, alert, name); releaseErr != nil {
+			fmt.Printf("Release alert %s -> %s failed: %s\n", alert.AlertID, name, releaseErr.Error())
+		}
+		return errors.Classify(fmt.Errorf("send alert %s to %s: %w", alert.AlertID, name, err))
+	}
+	// the alert is sent, a failed confirm only let a redelivery send it again after the lease
+	if err := deduplicator.Confirm(ctx, alert, name); err != nil {
+		fmt.Printf("Confirm alert %s -> %s failed: %s\n", alert.AlertID, name, err.Error())
+	}
+	return nil
 }
 
 func main() {
-	lambda.Start(handler)
+	var err error
+	if routes, err = router.LoadFromEnv(); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	if names := routes.FunctionTargets(); len(names) > 0 {
+		fmt.Printf("Targets without spec are Lambda functions: %s\n", strings.Join(names, ", "))
+	}
+
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+	config := &aws.Config{Region: aws.String(os.Getenv("REGION"))}
+	clients := target.NewClients(sess, config)
+	if targets, err = target.NewSet(routes.TargetSpecs(), clients); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	if value := os.Getenv(MaxAttemptsEnv); value != "" {
+		if maxAttempts, err = strconv.Atoi(value); err != nil || maxAttempts < 1 {
+			fmt.Printf("invalid %s %q: must be a positive integer\n", MaxAttemptsEnv, value)
+			os.Exit(1)
+		}
+	}
+	deadLetters = deadletter.NewPublisherFromEnv(lambdacontext.FunctionName, clients.SQS)
+	if deduplicator, err = dedupe.NewFromEnv(dynamodb.New(sess, config)); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	if err = loadFanout(); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	if err = loadExplainMode(); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	lambda.Start(invoke)
 }
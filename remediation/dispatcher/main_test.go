@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	lambdaEvents "github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/deadletter"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/dedupe"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/router"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/target"
+)
+
+type mockLambda struct {
+	lambdaiface.LambdaAPI
+	invoked []string
+	errs    map[string]error
+	// delay every invocation, a delay longer than the context deadline fail the invocation
+	delay time.Duration
+
+	mutex   sync.Mutex
+	running int
+	peak    int
+}
+
+func (m *mockLambda) InvokeWithContext(ctx aws.Context, input *lambda.InvokeInput, opts ...request.Option) (*lambda.InvokeOutput, error) {
+	name := aws.StringValue(input.FunctionName)
+	m.mutex.Lock()
+	m.invoked = append(m.invoked, name)
+	m.running++
+	if m.running > m.peak {
+		m.peak = m.running
+	}
+	err, failed := m.errs[name]
+	m.mutex.Unlock()
+	defer func() {
+		m.mutex.Lock()
+		m.running--
+		m.mutex.Unlock()
+	}()
+
+	select {
+	case <-time.After(m.delay):
+	case <-ctx.Done():
+		return nil, awserr.New(request.CanceledErrorCode, "request context canceled", ctx.Err())
+	}
+	if failed {
+		return nil, err
+	}
+	return &lambda.InvokeOutput{StatusCode: aws.Int64(202)}, nil
+}
+
+type mockSQS struct {
+	sqsiface.SQSAPI
+	records []*deadletter.Record
+}
+
+func (m *mockSQS) SendMessageWithContext(ctx aws.Context, input *sqs.SendMessageInput, opts ...request.Option) (*sqs.SendMessageOutput, error) {
+	record := &deadletter.Record{}
+	if err := json.Unmarshal([]byte(aws.StringValue(input.MessageBody)), record); err != nil {
+		return nil, err
+	}
+	m.records = append(m.records, record)
+	return &sqs.SendMessageOutput{MessageId: aws.String("dead-letter-id")}, nil
+}
+
+// deadLettered describe the dead-letter records as "messageId target category attempts"
+func (m *mockSQS) deadLettered() []string {
+	described := []string{}
+	for _, record := range m.records {
+		described = append(described, fmt.Sprintf("%s %s %s %d", record.MessageID, record.Target, record.Category, record.Attempts))
+	}
+	return described
+}
+
+// setup route the alerts with the default routes and a catch-all route to the given targets
+// The dead-letter queue is returned
+func setup(t *testing.T, client *mockLambda, catchAll ...string) *mockSQS {
+	config := router.DefaultConfig()
+	if len(catchAll) > 0 {
+		config.Routes = append(config.Routes, router.Route{Name: "catch-all", Targets: catchAll})
+	}
+	var err error
+	routes, err = router.NewTable(config)
+	assert.NoError(t, err)
+	targets, err = target.NewSet(routes.TargetSpecs(), &target.Clients{Lambda: client})
+	assert.NoError(t, err)
+	deduplicator = dedupe.New(dedupe.NewMemoryStore(), dedupe.DefaultTTL)
+	queue := &mockSQS{}
+	deadLetters = deadletter.NewPublisher("PrismaAlertDispatcher", "https://sqs.us-east-1.amazonaws.com/123456789012/PrismaAlertDLQ", queue)
+	maxAttempts = DefaultMaxAttempts
+	workers = newPool(DefaultWorkers)
+	targetTimeout = DefaultTargetTimeout
+	return queue
+}
+
+func readEvent(t *testing.T, name string) string {
+	body, err := ioutil.ReadFile("../../events/testdata/" + name)
+	assert.NoError(t, err)
+	return string(body)
+}
+
+func sqsEvent(bodies ...string) lambdaEvents.SQSEvent {
+	sqsEvent := lambdaEvents.SQSEvent{}
+	for i, body := range bodies {
+		sqsEvent.Records = append(sqsEvent.Records, lambdaEvents.SQSMessage{
+			MessageId:   fmt.Sprintf("message-%d", i),
+			EventSource: "aws:sqs",
+			Body:        body,
+		})
+	}
+	return sqsEvent
+}
+
+func failedIDs(response SQSEventResponse) []string {
+	ids := []string{}
+	for _, failure := range response.BatchItemFailures {
+		ids = append(ids, failure.ItemIdentifier)
+	}
+	return ids
+}
+
+func TestHandler(t *testing.T) {
+	vpcKiller := readEvent(t, "vpc-killer-event.json")
+	throttled := awserr.New(lambda.ErrCodeTooManyRequestsException, "Rate exceeded", nil)
+	denied := awserr.New("AccessDeniedException", "not authorized to perform: lambda:InvokeFunction", nil)
+
+	testCases := []struct {
+		name         string
+		bodies       []string
+		errs         map[string]error
+		catchAll     []string
+		invoked      []string
+		failed       []string
+		deadLettered []string
+	}{
+		{
+			name:         "dispatched",
+			bodies:       []string{vpcKiller},
+			invoked:      []string{"PrismaVPCKiller"},
+			failed:       []string{},
+			deadLettered: []string{},
+		},
+		{
+			name:         "invalid messages are dead-lettered and test messages are acknowledged",
+			bodies:       []string{`{"alertId":`, readEvent(t, "test-message.json"), vpcKiller},
+			invoked:      []string{"PrismaVPCKiller"},
+			failed:       []string{},
+			deadLettered: []string{"message-0  Validation 1"},
+		},
+		{
+			name:         "retryable failure",
+			bodies:       []string{vpcKiller, `{`, vpcKiller},
+			errs:         map[string]error{"PrismaVPCKiller": throttled},
+			invoked:      []string{"PrismaVPCKiller", "PrismaVPCKiller"},
+			failed:       []string{"message-0", "message-2"},
+			deadLettered: []string{"message-1  Validation 1"},
+		},
+		{
+			name:         "duplicates are skipped",
+			bodies:       []string{vpcKiller, vpcKiller, readEvent(t, "resolved-event.json")},
+			invoked:      []string{"PrismaVPCKiller", "PrismaVPCKiller"},
+			failed:       []string{},
+			deadLettered: []string{},
+		},
+		{
+			name:         "permanent failure is dead-lettered",
+			bodies:       []string{vpcKiller},
+			errs:         map[string]error{"PrismaVPCKiller": denied},
+			invoked:      []string{"PrismaVPCKiller"},
+			failed:       []string{},
+			deadLettered: []string{"message-0 PrismaVPCKiller Forbidden 1"},
+		},
+		{
+			name:         "every target is attempted",
+			bodies:       []string{readEvent(t, "golden/aws-config.json")},
+			errs:         map[string]error{"audit": denied, "notify": throttled},
+			catchAll:     []string{"audit", "notify", "archive"},
+			invoked:      []string{"audit", "notify", "archive"},
+			failed:       []string{"message-0"},
+			deadLettered: []string{},
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, testCase.name), func(t *testing.T) {
+			client := &mockLambda{errs: testCase.errs}
+			queue := setup(t, client, testCase.catchAll...)
+			response, err := handler(context.Background(), sqsEvent(testCase.bodies...))
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, testCase.invoked, client.invoked)
+			assert.Equal(t, testCase.failed, failedIDs(response))
+			assert.Equal(t, testCase.deadLettered, queue.deadLettered())
+		})
+	}
+}
+
+func TestHandlerRetryIsNotDuplicate(t *testing.T) {
+	client := &mockLambda{errs: map[string]error{"PrismaVPCKiller": awserr.New(lambda.ErrCodeTooManyRequestsException, "Rate exceeded", nil)}}
+	setup(t, client)
+	vpcKiller := sqsEvent(readEvent(t, "vpc-killer-event.json"))
+
+	response, err := handler(context.Background(), vpcKiller)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"message-0"}, failedIDs(response))
+
+	client.errs = nil
+	response, err = handler(context.Background(), vpcKiller)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, failedIDs(response))
+
+	response, err = handler(context.Background(), vpcKiller)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, failedIDs(response))
+	assert.Equal(t, []string{"PrismaVPCKiller", "PrismaVPCKiller"}, client.invoked)
+	assert.Equal(t, int64(1), deduplicator.Duplicates())
+}
+
+func TestHandlerUnconfirmedClaim(t *testing.T) {
+	client := &mockLambda{}
+	setup(t, client)
+	body := readEvent(t, "vpc-killer-event.json")
+	alert, err := events.ParseMessage([]byte(body))
+	assert.NoError(t, err)
+
+	// a dispatch that died between the claim and the send
+	claimed, err := deduplicator.Claim(context.Background(), alert, "PrismaVPCKiller")
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+
+	response, err := handler(context.Background(), sqsEvent(body))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"message-0"}, failedIDs(response))
+	assert.Empty(t, client.invoked)
+	assert.Equal(t, int64(0), deduplicator.Duplicates())
+}
+
+func TestHandlerMaxAttempts(t *testing.T) {
+	client := &mockLambda{errs: map[string]error{"PrismaVPCKiller": awserr.New(lambda.ErrCodeTooManyRequestsException, "Rate exceeded", nil)}}
+	queue := setup(t, client)
+	vpcKiller := sqsEvent(readEvent(t, "vpc-killer-event.json"))
+
+	vpcKiller.Records[0].Attributes = map[string]string{"ApproximateReceiveCount": "4"}
+	response, err := handler(context.Background(), vpcKiller)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"message-0"}, failedIDs(response))
+	assert.Equal(t, []string{}, queue.deadLettered())
+
+	vpcKiller.Records[0].Attributes = map[string]string{"ApproximateReceiveCount": "5"}
+	response, err = handler(context.Background(), vpcKiller)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, failedIDs(response))
+	assert.Equal(t, []string{"message-0 PrismaVPCKiller Retryable 5"}, queue.deadLettered())
+	assert.Equal(t, "P-39425", queue.records[0].AlertID)
+	assert.Equal(t, "PrismaAlertDispatcher", queue.records[0].Source)
+	assert.JSONEq(t, vpcKiller.Records[0].Body, string(queue.records[0].Event))
+}
@@ -0,0 +1,99 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// RoutesEnv contain the routing document as JSON or YAML
+	RoutesEnv = "ROUTES"
+	// RoutesFileEnv contain the path of a JSON or YAML routing file
+	RoutesFileEnv = "ROUTES_FILE"
+)
+
+// DefaultConfig is the routing of the alert rules the dispatcher used to hard-code
+func DefaultConfig() *Config {
+	return &Config{
+		Routes: []Route{
+			{
+				Name:     "suspicious-traffic",
+				Match:    Match{AlertRuleName: []string{"Suspicious Traffic Alert"}},
+				Targets:  []string{"PrismaAlertNotification"},
+				Resolved: true,
+			},
+			{
+				Name:     "vpc-killer",
+				Match:    Match{AlertRuleName: []string{"VPCKiller"}},
+				Targets:  []string{"PrismaVPCKiller"},
+				Resolved: true,
+			},
+			{
+				Name:    "science-logic",
+				Match:   Match{AlertRuleName: []string{"ScienceLogic"}},
+				Targets: []string{"PrismaScienceLogic"},
+			},
+			{
+				Name:     "region-violation",
+				Match:    Match{AlertRuleName: []string{"AWS Region Violation"}},
+				Targets:  []string{"PrismaFalseAlertRemover"},
+				Resolved: true,
+			},
+		},
+	}
+}
+
+// Parse decode a JSON or YAML routing document
+// A document starting with { is JSON, any other document is YAML
+func Parse(document []byte) (*Config, error) {
+	config := &Config{}
+	trimmed := bytes.TrimSpace(document)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(config); err != nil {
+			return nil, fmt.Errorf("invalid routes: %s", err.Error())
+		}
+		return config, nil
+	}
+	if err := yaml.UnmarshalStrict(trimmed, config); err != nil {
+		return nil, fmt.Errorf("invalid routes: %s", err.Error())
+	}
+	return config, nil
+}
+
+// Load decode a routing document and validate it
+func Load(reader io.Reader) (*Table, error) {
+	document, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	config, err := Parse(document)
+	if err != nil {
+		return nil, err
+	}
+	return NewTable(config)
+}
+
+// LoadFromEnv load the routes from ROUTES or the file in ROUTES_FILE
+// DefaultConfig is used when neither is set
+func LoadFromEnv() (*Table, error) {
+	if document := os.Getenv(RoutesEnv); document != "" {
+		return Load(bytes.NewReader([]byte(document)))
+	}
+	if path := os.Getenv(RoutesFileEnv); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return Load(file)
+	}
+	return NewTable(DefaultConfig())
+}
@@ -0,0 +1,135 @@
+package router_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/router"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/target"
+)
+
+const yamlRoutes = `
+routes:
+  - name: vpc-killer
+    match:
+      alertRuleName: [VPCKiller]
+      region: ["regex:^us-(west|east-2)"]
+    targets: [PrismaVPCKiller]
+    resolved: true
+  - name: pci
+    match:
+      policyLabels: ["PCI*"]
+    targets: [PrismaAlertNotification]
+`
+
+const jsonRoutes = `{
+	"routes": [
+		{
+			"name": "vpc-killer",
+			"match": {"alertRuleName": ["VPCKiller"], "region": ["regex:^us-(west|east-2)"]},
+			"targets": ["PrismaVPCKiller"],
+			"resolved": true
+		},
+		{
+			"name": "pci",
+			"match": {"policyLabels": ["PCI*"]},
+			"targets": ["PrismaAlertNotification"]
+		}
+	]
+}`
+
+func TestParse(t *testing.T) {
+	expected := &router.Config{
+		Routes: []router.Route{
+			{
+				Name: "vpc-killer",
+				Match: router.Match{
+					AlertRuleName: []string{"VPCKiller"},
+					Region:        []string{"regex:^us-(west|east-2)"},
+				},
+				Targets:  []string{"PrismaVPCKiller"},
+				Resolved: true,
+			},
+			{
+				Name:    "pci",
+				Match:   router.Match{PolicyLabels: []string{"PCI*"}},
+				Targets: []string{"PrismaAlertNotification"},
+			},
+		},
+	}
+
+	fromYAML, err := router.Parse([]byte(yamlRoutes))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, fromYAML)
+
+	fromJSON, err := router.Parse([]byte(jsonRoutes))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, fromJSON)
+}
+
+func TestParseUnknownField(t *testing.T) {
+	_, err := router.Parse([]byte(`{"routes": [{"name": "vpc", "target": ["PrismaVPCKiller"]}]}`))
+	assert.EqualError(t, err, `invalid routes: json: unknown field "target"`)
+
+	_, err = router.Parse([]byte("routes:\n  - name: vpc\n    target: [PrismaVPCKiller]\n"))
+	assert.EqualError(t, err, "invalid routes: yaml: unmarshal errors:\n  line 3: field target not found in type router.Route")
+}
+
+func TestLoad(t *testing.T) {
+	table, err := router.Load(strings.NewReader(yamlRoutes))
+	assert.NoError(t, err)
+	alert := vpcAlert()
+	assert.Equal(t, []string{"PrismaVPCKiller"}, table.Targets(alert))
+
+	_, err = router.Load(strings.NewReader("routes:\n  - name: vpc\n"))
+	assert.EqualError(t, err, "required field routes[0].targets type of []string is empty")
+}
+
+func TestLoadTargets(t *testing.T) {
+	table, err := router.Load(strings.NewReader(`
+routes:
+  - name: vpc-killer
+    match:
+      alertRuleName: [VPCKiller]
+    targets: [PrismaVPCKiller, audit]
+targets:
+  - name: audit
+    type: sqs
+    queueUrl: https://sqs.us-east-1.amazonaws.com/123456789012/alerts.fifo
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, []target.Spec{
+		{Name: "audit", Type: target.TypeSQS, QueueURL: "https://sqs.us-east-1.amazonaws.com/123456789012/alerts.fifo"},
+	}, table.TargetSpecs())
+	assert.Equal(t, []string{"PrismaVPCKiller", "audit"}, table.Targets(vpcAlert()))
+
+	table, err = router.NewTable(router.DefaultConfig())
+	assert.NoError(t, err)
+	assert.Empty(t, table.TargetSpecs())
+}
+
+func TestLoadFromEnv(t *testing.T) {
+	defer os.Unsetenv(router.RoutesEnv)
+	defer os.Unsetenv(router.RoutesFileEnv)
+
+	table, err := router.LoadFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"PrismaVPCKiller"}, table.Targets(vpcAlert()))
+
+	os.Setenv(router.RoutesFileEnv, "./testdata/routes.yaml")
+	table, err = router.LoadFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"PrismaVPCKiller", "PrismaAlertNotification"}, table.Targets(vpcAlert()))
+
+	os.Setenv(router.RoutesFileEnv, "./testdata/missing.yaml")
+	_, err = router.LoadFromEnv()
+	assert.Error(t, err)
+
+	os.Setenv(router.RoutesEnv, jsonRoutes)
+	table, err = router.LoadFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"PrismaVPCKiller"}, table.Targets(vpcAlert()))
+}
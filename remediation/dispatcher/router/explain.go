@@ -0,0 +1,177 @@
+package router
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+)
+
+// Explanation is how the routing table route an alert
+type Explanation struct {
+	AlertID       string             `json:"alertId,omitempty"`
+	AlertRuleName string             `json:"alertRuleName,omitempty"`
+	Kind          events.MessageKind `json:"kind"`
+	// Errors are the validation errors of the alert, the dispatcher drop an invalid alert
+	Errors []string `json:"errors,omitempty"`
+	// Routes are every route of the table in order
+	Routes []RouteExplanation `json:"routes"`
+	// Targets would receive the alert
+	Targets []string `json:"targets"`
+	// DeadLettered is true when the dispatcher dead-letter the alert instead of routing it
+	DeadLettered bool `json:"deadLettered,omitempty"`
+}
+
+// RouteExplanation is why a route match an alert or not
+type RouteExplanation struct {
+	Name    string `json:"name"`
+	Matched bool   `json:"matched"`
+	// Selected is true when the targets of the route receive the alert
+	// A matching route is not selected after a route without continue
+	Selected   bool                   `json:"selected"`
+	Reason     string                 `json:"reason"`
+	Conditions []ConditionExplanation `json:"conditions,omitempty"`
+	Targets    []string               `json:"targets"`
+}
+
+// ConditionExplanation is the result of a condition of a route
+type ConditionExplanation struct {
+	Field    string   `json:"field"`
+	Patterns []string `json:"patterns"`
+	// Values are the alert values the patterns are matched against
+	Values  []string `json:"values"`
+	Matched bool     `json:"matched"`
+	// Value and Pattern are the first alert value matched and its pattern
+	Value   string `json:"value,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// Explain evaluate every route against the alert, nothing is sent
+// The targets are the targets Targets return
+func (t *Table) Explain(alert *events.AlertEvent) *Explanation {
+	explanation := &Explanation{
+		AlertID:       alert.AlertID,
+		AlertRuleName: alert.AlertRuleName,
+		Kind:          alert.Kind(),
+		Routes:        []RouteExplanation{},
+		Targets:       t.Targets(alert),
+	}
+	var stoppedBy string
+	for i := range t.routes {
+		route := &t.routes[i]
+		routeExplanation := RouteExplanation{
+			Name:    route.route.Name,
+			Matched: true,
+			Targets: route.route.Targets,
+		}
+		failed := []string{}
+		for j := range route.conditions {
+			condition := route.conditions[j].explain(alert)
+			routeExplanation.Conditions = append(routeExplanation.Conditions, condition)
+			if !condition.Matched {
+				routeExplanation.Matched = false
+				failed = append(failed, condition.Field)
+			}
+		}
+		switch {
+		case alert.IsResolved() && !route.route.Resolved:
+			routeExplanation.Matched = false
+			routeExplanation.Reason = "resolved alerts are not routed, the route is not marked resolved"
+		case !routeExplanation.Matched:
+			routeExplanation.Reason = fmt.Sprintf("%s did not match", strings.Join(failed, ", "))
+		case stoppedBy != "":
+			routeExplanation.Reason = fmt.Sprintf("matched, but route %s matched first without continue", stoppedBy)
+		case len(route.conditions) == 0:
+			routeExplanation.Selected = true
+			routeExplanation.Reason = "matched, the route has no conditions"
+		default:
+			routeExplanation.Selected = true
+			routeExplanation.Reason = "matched"
+		}
+		if routeExplanation.Selected && !route.route.Continue {
+			stoppedBy = route.route.Name
+		}
+		explanation.Routes = append(explanation.Routes, routeExplanation)
+	}
+	return explanation
+}
+
+// ExplainMessage explain the routing of a SQS message body
+// An invalid alert is explained with its validation errors and no targets, as the dispatcher dead-letter it,
+// a body that is not an alert is an error. A test message has no routes
+func (t *Table) ExplainMessage(body []byte) (*Explanation, error) {
+	alert, err := events.ParseMessage(body)
+	if alert == nil {
+		return nil, err
+	}
+	// the dispatcher acknowledge test messages without routing them
+	if alert.Kind() == events.MessageKindTest {
+		return &Explanation{Kind: events.MessageKindTest, Routes: []RouteExplanation{}, Targets: []string{}}, nil
+	}
+	explanation := t.Explain(alert)
+	if err != nil {
+		if validationErrors, ok := err.(errors.ValidationErrors); ok {
+			for _, fieldError := range validationErrors {
+				explanation.Errors = append(explanation.Errors, fieldError.Error())
+			}
+		} else {
+			explanation.Errors = append(explanation.Errors, err.Error())
+		}
+		explanation.DeadLettered = true
+		explanation.Targets = []string{}
+		for i := range explanation.Routes {
+			explanation.Routes[i].Selected = false
+		}
+	}
+	return explanation, nil
+}
+
+func (c *condition) explain(alert *events.AlertEvent) ConditionExplanation {
+	explanation := ConditionExplanation{Field: c.name, Patterns: []string{}, Values: []string{}}
+	for _, pattern := range c.patterns {
+		explanation.Patterns = append(explanation.Patterns, pattern.String())
+	}
+	for _, value := range c.values(alert) {
+		if value != "" {
+			explanation.Values = append(explanation.Values, value)
+		}
+	}
+	if value, pattern := c.find(alert); pattern != nil {
+		explanation.Matched = true
+		explanation.Value = value
+		explanation.Pattern = pattern.String()
+	}
+	return explanation
+}
+
+// String format the explanation for a terminal
+func (e *Explanation) String() string {
+	builder := &strings.Builder{}
+	fmt.Fprintf(builder, "Alert %s (%s) %s\n", e.AlertID, e.AlertRuleName, e.Kind)
+	for _, err := range e.Errors {
+		fmt.Fprintf(builder, "  invalid: %s\n", err)
+	}
+	for _, route := range e.Routes {
+		mark := " "
+		if route.Selected {
+			mark = "*"
+		}
+		fmt.Fprintf(builder, "%s route %s: %s\n", mark, route.Name, route.Reason)
+		for _, condition := range route.Conditions {
+			if condition.Matched {
+				fmt.Fprintf(builder, "      %s: %q matched %q\n", condition.Field, condition.Value, condition.Pattern)
+			} else {
+				fmt.Fprintf(builder, "      %s: %q matched none of %q\n", condition.Field, condition.Values, condition.Patterns)
+			}
+		}
+	}
+	if e.DeadLettered {
+		fmt.Fprintln(builder, "  no targets, the invalid alert is dead-lettered")
+	} else if len(e.Targets) == 0 {
+		fmt.Fprintln(builder, "  no targets")
+	} else {
+		fmt.Fprintf(builder, "  targets: %s\n", strings.Join(e.Targets, ", "))
+	}
+	return builder.String()
+}
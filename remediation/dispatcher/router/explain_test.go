@@ -0,0 +1,134 @@
+package router_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/router"
+)
+
+func explainTable(t *testing.T) *router.Table {
+	table, err := router.NewTable(&router.Config{
+		Routes: []router.Route{
+			{Name: "audit", Match: router.Match{CloudType: []string{"aws"}}, Targets: []string{"audit"}, Continue: true},
+			{Name: "vpc", Match: router.Match{AlertRuleName: []string{"VPCKiller"}, Region: []string{"us-west-*"}}, Targets: []string{"PrismaVPCKiller"}, Resolved: true},
+			{Name: "pci", Match: router.Match{PolicyLabels: []string{"PCI*"}}, Targets: []string{"PrismaAlertNotification"}},
+			{Name: "azure", Match: router.Match{CloudType: []string{"azure"}}, Targets: []string{"azure"}},
+			{Name: "catch-all", Targets: []string{"catch-all"}},
+		},
+	})
+	assert.NoError(t, err)
+	return table
+}
+
+func TestExplain(t *testing.T) {
+	table := explainTable(t)
+	alert := vpcAlert()
+	explanation := table.Explain(alert)
+
+	assert.Equal(t, "P-39425", explanation.AlertID)
+	assert.Equal(t, events.MessageKindAlert, explanation.Kind)
+	assert.Equal(t, table.Targets(alert), explanation.Targets)
+	assert.Equal(t, []string{"audit", "PrismaVPCKiller"}, explanation.Targets)
+
+	testCases := []struct {
+		name     string
+		matched  bool
+		selected bool
+		reason   string
+	}{
+		{name: "audit", matched: true, selected: true, reason: "matched"},
+		{name: "vpc", matched: true, selected: true, reason: "matched"},
+		{name: "pci", matched: true, selected: false, reason: "matched, but route vpc matched first without continue"},
+		{name: "azure", matched: false, selected: false, reason: "cloudType did not match"},
+		{name: "catch-all", matched: true, selected: false, reason: "matched, but route vpc matched first without continue"},
+	}
+	assert.Len(t, explanation.Routes, len(testCases))
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, testCase.name), func(t *testing.T) {
+			route := explanation.Routes[i]
+			assert.Equal(t, testCase.name, route.Name)
+			assert.Equal(t, testCase.matched, route.Matched)
+			assert.Equal(t, testCase.selected, route.Selected)
+			assert.Equal(t, testCase.reason, route.Reason)
+		})
+	}
+
+	assert.Equal(t, []router.ConditionExplanation{
+		{Field: "alertRuleName", Patterns: []string{"VPCKiller"}, Values: []string{"VPCKiller"}, Matched: true, Value: "VPCKiller", Pattern: "VPCKiller"},
+		{Field: "region", Patterns: []string{"us-west-*"}, Values: []string{"us-west-2"}, Matched: true, Value: "us-west-2", Pattern: "us-west-*"},
+	}, explanation.Routes[1].Conditions)
+	assert.Equal(t, []router.ConditionExplanation{
+		{Field: "cloudType", Patterns: []string{"azure"}, Values: []string{"aws"}},
+	}, explanation.Routes[3].Conditions)
+}
+
+func TestExplainResolved(t *testing.T) {
+	table := explainTable(t)
+	alert := vpcAlert()
+	alert.AlertStatus = events.AlertStatusResolved
+	explanation := table.Explain(alert)
+
+	assert.Equal(t, []string{"PrismaVPCKiller"}, explanation.Targets)
+	assert.False(t, explanation.Routes[0].Matched)
+	assert.Equal(t, "resolved alerts are not routed, the route is not marked resolved", explanation.Routes[0].Reason)
+	assert.True(t, explanation.Routes[1].Selected)
+}
+
+func TestExplainMessage(t *testing.T) {
+	table := explainTable(t)
+
+	body, err := ioutil.ReadFile("../../../events/testdata/vpc-killer-event.json")
+	assert.NoError(t, err)
+	explanation, err := table.ExplainMessage(body)
+	assert.NoError(t, err)
+	assert.Empty(t, explanation.Errors)
+	assert.False(t, explanation.DeadLettered)
+	assert.Equal(t, "P-39425", explanation.AlertID)
+
+	invalid := vpcAlert()
+	invalid.PolicyID = ""
+	body, err = json.Marshal(invalid)
+	assert.NoError(t, err)
+	explanation, err = table.ExplainMessage(body)
+	assert.NoError(t, err)
+	assert.Contains(t, explanation.Errors, "required field policyId type of string is empty")
+	assert.True(t, explanation.DeadLettered)
+	assert.Empty(t, explanation.Targets)
+	assert.True(t, explanation.Routes[0].Matched)
+	assert.False(t, explanation.Routes[0].Selected)
+	assert.Contains(t, explanation.String(), "  no targets, the invalid alert is dead-lettered\n")
+
+	body, err = ioutil.ReadFile("../../../events/testdata/test-message.json")
+	assert.NoError(t, err)
+	explanation, err = table.ExplainMessage(body)
+	assert.NoError(t, err)
+	assert.Equal(t, events.MessageKindTest, explanation.Kind)
+	assert.Empty(t, explanation.Routes)
+	assert.Empty(t, explanation.Targets)
+
+	_, err = table.ExplainMessage([]byte(`{`))
+	assert.EqualError(t, err, "invalid message: unexpected end of JSON input")
+}
+
+func TestExplanationString(t *testing.T) {
+	explanation := explainTable(t).Explain(vpcAlert())
+	assert.Equal(t, `Alert P-39425 (VPCKiller) alert
+* route audit: matched
+      cloudType: "aws" matched "aws"
+* route vpc: matched
+      alertRuleName: "VPCKiller" matched "VPCKiller"
+      region: "us-west-2" matched "us-west-*"
+  route pci: matched, but route vpc matched first without continue
+      policyLabels: "PCI DSS" matched "PCI*"
+  route azure: cloudType did not match
+      cloudType: ["aws"] matched none of ["azure"]
+  route catch-all: matched, but route vpc matched first without continue
+  targets: audit, PrismaVPCKiller
+`, explanation.String())
+}
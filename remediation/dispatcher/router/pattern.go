@@ -0,0 +1,83 @@
+package router
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RegexPrefix mark a pattern as a regular expression, such as regex:^P-[0-9]+$
+const RegexPrefix = "regex:"
+
+// globCharacters turn a pattern into a glob
+const globCharacters = "*?["
+
+// Pattern match a value exactly, by glob or by regular expression
+type Pattern struct {
+	raw        string
+	expression *regexp.Regexp
+}
+
+// ParsePattern parse a pattern
+// A pattern starting with regex: is a regular expression, a pattern with *, ? or [ is a glob,
+// any other pattern is an exact match
+func ParsePattern(pattern string) (*Pattern, error) {
+	if strings.HasPrefix(pattern, RegexPrefix) {
+		expression, err := regexp.Compile(strings.TrimPrefix(pattern, RegexPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %s", pattern, err.Error())
+		}
+		return &Pattern{raw: pattern, expression: expression}, nil
+	}
+	if strings.ContainsAny(pattern, globCharacters) {
+		expression, err := globToRegexp(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %s", pattern, err.Error())
+		}
+		return &Pattern{raw: pattern, expression: expression}, nil
+	}
+	return &Pattern{raw: pattern}, nil
+}
+
+// Match return true if the value match the pattern
+func (p *Pattern) Match(value string) bool {
+	if p.expression != nil {
+		return p.expression.MatchString(value)
+	}
+	return p.raw == value
+}
+
+func (p *Pattern) String() string {
+	return p.raw
+}
+
+// globToRegexp convert a glob to an anchored regular expression
+// * match any sequence, ? match a single character and [...] a character class.
+// Unlike path.Match, * also match /, policy names often contain it
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	var builder strings.Builder
+	builder.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch glob[i] {
+		case '*':
+			builder.WriteString(".*")
+		case '?':
+			builder.WriteString(".")
+		case '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("missing closing ]")
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			builder.WriteString("[" + class + "]")
+			i += end
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(glob[i])))
+		}
+	}
+	builder.WriteString("$")
+	return regexp.Compile(builder.String())
+}
@@ -0,0 +1,50 @@
+package router_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/router"
+)
+
+func TestPatternMatch(t *testing.T) {
+	testCases := []struct {
+		pattern  string
+		value    string
+		expected bool
+	}{
+		{pattern: "VPCKiller", value: "VPCKiller", expected: true},
+		{pattern: "VPCKiller", value: "VPCKiller2", expected: false},
+		{pattern: "VPCKiller", value: "vpckiller", expected: false},
+		{pattern: "AWS *", value: "AWS Region Violation", expected: true},
+		{pattern: "AWS *", value: "Azure Region Violation", expected: false},
+		{pattern: "*/admin", value: "policy/with/admin", expected: true},
+		{pattern: "us-east-?", value: "us-east-2", expected: true},
+		{pattern: "us-east-?", value: "us-east-10", expected: false},
+		{pattern: "us-[ew]*", value: "us-west-1", expected: true},
+		{pattern: "us-[!ew]*", value: "us-west-1", expected: false},
+		{pattern: "a.b*", value: "axb", expected: false},
+		{pattern: "regex:^P-[0-9]+$", value: "P-123", expected: true},
+		{pattern: "regex:(?i)^high$", value: "HIGH", expected: true},
+		{pattern: "regex:critical|high", value: "medium", expected: false},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, testCase.pattern), func(t *testing.T) {
+			pattern, err := router.ParsePattern(testCase.pattern)
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expected, pattern.Match(testCase.value))
+			assert.Equal(t, testCase.pattern, pattern.String())
+		})
+	}
+}
+
+func TestParsePatternErrors(t *testing.T) {
+	_, err := router.ParsePattern("regex:[a")
+	assert.EqualError(t, err, "invalid regex \"regex:[a\": error parsing regexp: missing closing ]: `[a`")
+
+	_, err = router.ParsePattern("us-[east")
+	assert.EqualError(t, err, `invalid glob "us-[east": missing closing ]`)
+}
@@ -0,0 +1,320 @@
+package router
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/target"
+)
+
+// Match is the conditions of a route
+// Every condition must match, a condition match when any of its patterns match.
+// An empty condition match every alert
+type Match struct {
+	AlertRuleName []string `json:"alertRuleName,omitempty" yaml:"alertRuleName,omitempty"`
+	PolicyID      []string `json:"policyId,omitempty" yaml:"policyId,omitempty"`
+	PolicyName    []string `json:"policyName,omitempty" yaml:"policyName,omitempty"`
+	Severity      []string `json:"severity,omitempty" yaml:"severity,omitempty"`
+	CloudType     []string `json:"cloudType,omitempty" yaml:"cloudType,omitempty"`
+	// Account match the account ID or the account name
+	Account []string `json:"account,omitempty" yaml:"account,omitempty"`
+	Region  []string `json:"region,omitempty" yaml:"region,omitempty"`
+	// ResourceType match the resource type as sent, such as "Virtual Network", or normalized, such as VIRTUAL_NETWORK
+	ResourceType []string `json:"resourceType,omitempty" yaml:"resourceType,omitempty"`
+	// PolicyLabels match when any label of the alert match
+	PolicyLabels []string `json:"policyLabels,omitempty" yaml:"policyLabels,omitempty"`
+}
+
+// Route send the alerts matched by Match to the targets
+type Route struct {
+	Name    string   `json:"name" yaml:"name" validate:"required"`
+	Match   Match    `json:"match" yaml:"match"`
+	Targets []string `json:"targets" yaml:"targets" validate:"required"`
+	// Resolved also send the resolved notifications of the matched alerts, the targets must recognize them
+	Resolved bool `json:"resolved,omitempty" yaml:"resolved,omitempty"`
+	// Continue keep matching the next routes, by default the first matching route win
+	Continue bool `json:"continue,omitempty" yaml:"continue,omitempty"`
+}
+
+// Config is the routing document
+type Config struct {
+	Routes []Route `json:"routes" yaml:"routes" validate:"required"`
+	// Targets describe the targets routes refer to, a target without spec is a Lambda function
+	Targets []target.Spec `json:"targets,omitempty" yaml:"targets,omitempty"`
+}
+
+// condition is a compiled Match condition
+type condition struct {
+	name     string
+	patterns []*Pattern
+	values   func(alert *events.AlertEvent) []string
+}
+
+type compiledRoute struct {
+	route      Route
+	conditions []condition
+}
+
+// Table is a validated routing table
+type Table struct {
+	routes      []compiledRoute
+	targetSpecs []target.Spec
+}
+
+// NewTable validate the config and compile its patterns
+// Every invalid field is reported in an errors.ValidationErrors
+func NewTable(config *Config) (*Table, error) {
+	validationErrors := errors.ValidationErrors{}
+	if err := errors.Validate(config); err != nil {
+		tagErrors, ok := err.(errors.ValidationErrors)
+		if !ok {
+			return nil, err
+		}
+		validationErrors = append(validationErrors, tagErrors...)
+	}
+
+	if err := target.ValidateSpecs(config.Targets); err != nil {
+		specErrors, ok := err.(errors.ValidationErrors)
+		if !ok {
+			return nil, err
+		}
+		validationErrors = append(validationErrors, specErrors...)
+	}
+
+	table := &Table{targetSpecs: config.Targets}
+	names := map[string]bool{}
+	for i, route := range config.Routes {
+		path := fmt.Sprintf("routes[%d]", i)
+		if route.Name != "" && names[route.Name] {
+			validationErrors = append(validationErrors, &errors.FieldError{
+				Path:    path + ".name",
+				Rule:    "unique",
+				Message: fmt.Sprintf("field %s.name must be unique, got %q", path, route.Name),
+			})
+		}
+		names[route.Name] = true
+		for j, name := range route.Targets {
+			targetPath := fmt.Sprintf("%s.targets[%d]", path, j)
+			if name == "" {
+				validationErrors = append(validationErrors, &errors.FieldError{
+					Path:    targetPath,
+					Rule:    "required",
+					Message: fmt.Sprintf("required field %s type of string is empty", targetPath),
+				})
+				continue
+			}
+			if rule, message := checkTarget(name, config.Targets); rule != "" {
+				validationErrors = append(validationErrors, &errors.FieldError{
+					Path:    targetPath,
+					Rule:    rule,
+					Message: fmt.Sprintf("field %s %s", targetPath, message),
+				})
+			}
+		}
+
+		compiled := compiledRoute{route: route}
+		for _, field := range matchFields(&route.Match) {
+			if len(field.patterns) == 0 {
+				continue
+			}
+			condition := condition{name: field.name, values: field.values}
+			for j, raw := range field.patterns {
+				pattern, err := ParsePattern(raw)
+				if err != nil {
+					patternPath := fmt.Sprintf("%s.match.%s[%d]", path, field.name, j)
+					validationErrors = append(validationErrors, &errors.FieldError{
+						Path:    patternPath,
+						Rule:    "pattern",
+						Message: fmt.Sprintf("field %s: %s", patternPath, err.Error()),
+					})
+					continue
+				}
+				condition.patterns = append(condition.patterns, pattern)
+			}
+			compiled.conditions = append(compiled.conditions, condition)
+		}
+		table.routes = append(table.routes, compiled)
+	}
+
+	if len(validationErrors) > 0 {
+		return nil, validationErrors
+	}
+	return table, nil
+}
+
+type matchField struct {
+	name     string
+	patterns []string
+	values   func(alert *events.AlertEvent) []string
+}
+
+// matchFields map each condition of a Match to the alert values it is matched against
+func matchFields(match *Match) []matchField {
+	return []matchField{
+		{"alertRuleName", match.AlertRuleName, func(alert *events.AlertEvent) []string { return []string{alert.AlertRuleName} }},
+		{"policyId", match.PolicyID, func(alert *events.AlertEvent) []string { return []string{alert.PolicyID} }},
+		{"policyName", match.PolicyName, func(alert *events.AlertEvent) []string { return []string{alert.PolicyName} }},
+		{"severity", match.Severity, func(alert *events.AlertEvent) []string { return []string{alert.Severity} }},
+		{"cloudType", match.CloudType, func(alert *events.AlertEvent) []string { return []string{alert.CloudType} }},
+		{"account", match.Account, func(alert *events.AlertEvent) []string { return []string{alert.AccountID, alert.AccountName} }},
+		{"region", match.Region, func(alert *events.AlertEvent) []string { return []string{alert.ResourceRegionID} }},
+		{"resourceType", match.ResourceType, func(alert *events.AlertEvent) []string {
+			return []string{alert.ResourceType, alert.Resource.ResourceType, alert.ResourceTypeKey()}
+		}},
+		{"policyLabels", match.PolicyLabels, func(alert *events.AlertEvent) []string { return alert.PolicyLabels }},
+	}
+}
+
+func (c *condition) match(alert *events.AlertEvent) bool {
+	_, pattern := c.find(alert)
+	return pattern != nil
+}
+
+// find return the first alert value matched by a pattern and the pattern
+func (c *condition) find(alert *events.AlertEvent) (string, *Pattern) {
+	for _, value := range c.values(alert) {
+		if value == "" {
+			continue
+		}
+		for _, pattern := range c.patterns {
+			if pattern.Match(value) {
+				return value, pattern
+			}
+		}
+	}
+	return "", nil
+}
+
+func (r *compiledRoute) match(alert *events.AlertEvent) bool {
+	if alert.IsResolved() && !r.route.Resolved {
+		return false
+	}
+	for i := range r.conditions {
+		if !r.conditions[i].match(alert) {
+			return false
+		}
+	}
+	return true
+}
+
+// Routes return the routes of the alert in order
+// The first matching route win, unless it is marked continue
+func (t *Table) Routes(alert *events.AlertEvent) []Route {
+	routes := []Route{}
+	for i := range t.routes {
+		if !t.routes[i].match(alert) {
+			continue
+		}
+		routes = append(routes, t.routes[i].route)
+		if !t.routes[i].route.Continue {
+			break
+		}
+	}
+	return routes
+}
+
+// Targets return the targets of the matching routes without duplicates
+func (t *Table) Targets(alert *events.AlertEvent) []string {
+	targets := []string{}
+	seen := map[string]bool{}
+	for _, route := range t.Routes(alert) {
+		for _, target := range route.Targets {
+			if !seen[target] {
+				seen[target] = true
+				targets = append(targets, target)
+			}
+		}
+	}
+	return targets
+}
+
+// TargetSpecs return the target specs of the routing document
+func (t *Table) TargetSpecs() []target.Spec {
+	return t.targetSpecs
+}
+
+// FunctionTargets return the route targets without spec, they are Lambda functions
+func (t *Table) FunctionTargets() []string {
+	specs := map[string]bool{}
+	for _, spec := range t.targetSpecs {
+		specs[spec.Name] = true
+	}
+	seen := map[string]bool{}
+	functions := []string{}
+	for i := range t.routes {
+		for _, name := range t.routes[i].route.Targets {
+			if !specs[name] && !seen[name] {
+				seen[name] = true
+				functions = append(functions, name)
+			}
+		}
+	}
+	sort.Strings(functions)
+	return functions
+}
+
+// functionName match a Lambda function name or ARN, with an optional version or alias
+var functionName = regexp.MustCompile(`^(arn:aws[a-zA-Z-]*:lambda:[a-z0-9-]+:\d{12}:function:)?[a-zA-Z0-9_-]{1,64}(:[a-zA-Z0-9_$-]{1,128})?$`)
+
+// checkTarget return the rule and the message of a route target that is neither a target spec nor a Lambda function
+// A name close to the name of a spec is a typo of the spec, not a Lambda function
+func checkTarget(name string, specs []target.Spec) (string, string) {
+	for _, spec := range specs {
+		if spec.Name == name {
+			return "", ""
+		}
+	}
+	for _, spec := range specs {
+		if similar(name, spec.Name) {
+			return "target", fmt.Sprintf("is not a target, got %q: did you mean %q", name, spec.Name)
+		}
+	}
+	if !functionName.MatchString(name) {
+		return "target", fmt.Sprintf("must be a target or a Lambda function name, got %q", name)
+	}
+	return "", ""
+}
+
+// similar return true when a differ from b by its case, or by up to 2 characters when b is long enough to tell
+func similar(a string, b string) bool {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	if a == b {
+		return true
+	}
+	return len(b) >= 5 && distance(a, b) <= 2
+}
+
+// distance return the Levenshtein distance of a and b
+func distance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+func min(values ...int) int {
+	minimum := values[0]
+	for _, value := range values[1:] {
+		if value < minimum {
+			minimum = value
+		}
+	}
+	return minimum
+}
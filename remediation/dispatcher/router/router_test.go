@@ -0,0 +1,217 @@
+package router_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/router"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/target"
+)
+
+func vpcAlert() *events.AlertEvent {
+	return &events.AlertEvent{
+		AlertID:          "P-39425",
+		AlertRuleName:    "VPCKiller",
+		PolicyID:         "123-5a19-4afb-b75e-123",
+		PolicyName:       "AWS VPC in a restricted region",
+		Severity:         "high",
+		CloudType:        "aws",
+		AccountID:        "123456789012",
+		AccountName:      "sbx",
+		ResourceRegionID: "us-west-2",
+		ResourceType:     "Virtual Network",
+		Resource:         events.AlertResource{ResourceType: "VIRTUAL_NETWORK"},
+		PolicyLabels:     []string{"Network", "PCI DSS"},
+	}
+}
+
+func TestDefaultConfig(t *testing.T) {
+	table, err := router.NewTable(router.DefaultConfig())
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		alertRuleName string
+		status        string
+		expected      []string
+	}{
+		{alertRuleName: "Suspicious Traffic Alert", expected: []string{"PrismaAlertNotification"}},
+		{alertRuleName: "VPCKiller", expected: []string{"PrismaVPCKiller"}},
+		{alertRuleName: "ScienceLogic", expected: []string{"PrismaScienceLogic"}},
+		{alertRuleName: "AWS Region Violation", expected: []string{"PrismaFalseAlertRemover"}},
+		{alertRuleName: "High Alert Notifications", expected: []string{}},
+		{alertRuleName: "VPCKiller", status: "resolved", expected: []string{"PrismaVPCKiller"}},
+		{alertRuleName: "ScienceLogic", status: "resolved", expected: []string{}},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s %s", i, testCase.alertRuleName, testCase.status), func(t *testing.T) {
+			alert := vpcAlert()
+			alert.AlertRuleName = testCase.alertRuleName
+			alert.AlertStatus = testCase.status
+			assert.Equal(t, testCase.expected, table.Targets(alert))
+		})
+	}
+}
+
+func TestRouteMatch(t *testing.T) {
+	testCases := []struct {
+		name     string
+		match    router.Match
+		expected bool
+	}{
+		{name: "empty match", match: router.Match{}, expected: true},
+		{name: "alert rule name", match: router.Match{AlertRuleName: []string{"VPCKiller"}}, expected: true},
+		{name: "policy ID", match: router.Match{PolicyID: []string{"123-*"}}, expected: true},
+		{name: "policy name", match: router.Match{PolicyName: []string{"regex:restricted region$"}}, expected: true},
+		{name: "severity", match: router.Match{Severity: []string{"critical", "high"}}, expected: true},
+		{name: "other severity", match: router.Match{Severity: []string{"low"}}, expected: false},
+		{name: "cloud type", match: router.Match{CloudType: []string{"azure", "gcp"}}, expected: false},
+		{name: "account ID", match: router.Match{Account: []string{"123456789012"}}, expected: true},
+		{name: "account name", match: router.Match{Account: []string{"sbx"}}, expected: true},
+		{name: "region", match: router.Match{Region: []string{"us-west-*"}}, expected: true},
+		{name: "resource type display name", match: router.Match{ResourceType: []string{"Virtual Network"}}, expected: true},
+		{name: "normalized resource type", match: router.Match{ResourceType: []string{"VIRTUAL_NETWORK"}}, expected: true},
+		{name: "policy label", match: router.Match{PolicyLabels: []string{"PCI*"}}, expected: true},
+		{name: "missing policy label", match: router.Match{PolicyLabels: []string{"CIS"}}, expected: false},
+		{
+			name: "every condition must match",
+			match: router.Match{
+				AlertRuleName: []string{"VPCKiller"},
+				Region:        []string{"us-east-1"},
+			},
+			expected: false,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, testCase.name), func(t *testing.T) {
+			table, err := router.NewTable(&router.Config{
+				Routes: []router.Route{{Name: "route", Match: testCase.match, Targets: []string{"target"}}},
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expected, len(table.Routes(vpcAlert())) == 1)
+		})
+	}
+}
+
+func TestRoutesOrder(t *testing.T) {
+	table, err := router.NewTable(&router.Config{
+		Routes: []router.Route{
+			{Name: "audit", Match: router.Match{CloudType: []string{"aws"}}, Targets: []string{"audit", "notify"}, Continue: true},
+			{Name: "vpc", Match: router.Match{AlertRuleName: []string{"VPCKiller"}}, Targets: []string{"vpckiller", "notify"}},
+			{Name: "catch-all", Targets: []string{"never"}},
+		},
+	})
+	assert.NoError(t, err)
+
+	routes := table.Routes(vpcAlert())
+	assert.Len(t, routes, 2)
+	assert.Equal(t, "audit", routes[0].Name)
+	assert.Equal(t, "vpc", routes[1].Name)
+	assert.Equal(t, []string{"audit", "notify", "vpckiller"}, table.Targets(vpcAlert()))
+
+	azure := vpcAlert()
+	azure.CloudType = "azure"
+	azure.AlertRuleName = "Other"
+	assert.Equal(t, []string{"never"}, table.Targets(azure))
+}
+
+func TestFunctionTargets(t *testing.T) {
+	table, err := router.NewTable(&router.Config{
+		Routes: []router.Route{
+			{Name: "vpc", Targets: []string{"PrismaVPCKiller", "audit"}},
+			{Name: "pci", Targets: []string{"PrismaAlertNotification", "arn:aws:lambda:us-east-1:123456789012:function:PrismaVPCKiller:live", "PrismaVPCKiller"}},
+		},
+		Targets: []target.Spec{{Name: "audit", Type: target.TypeSNS, TopicArn: "arn:aws:sns:us-east-1:123456789012:audit"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"PrismaAlertNotification", "PrismaVPCKiller", "arn:aws:lambda:us-east-1:123456789012:function:PrismaVPCKiller:live"}, table.FunctionTargets())
+}
+
+func TestNewTableErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   *router.Config
+		paths    []string
+		expected string
+	}{
+		{
+			name:     "no routes",
+			config:   &router.Config{},
+			paths:    []string{"routes"},
+			expected: "required field routes type of []router.Route is empty",
+		},
+		{
+			name: "missing name and targets",
+			config: &router.Config{Routes: []router.Route{
+				{Match: router.Match{Severity: []string{"high"}}},
+			}},
+			paths:    []string{"routes[0].name", "routes[0].targets"},
+			expected: "required field routes[0].name type of string is empty; required field routes[0].targets type of []string is empty",
+		},
+		{
+			name: "duplicate name and empty target",
+			config: &router.Config{Routes: []router.Route{
+				{Name: "vpc", Targets: []string{"PrismaVPCKiller"}},
+				{Name: "vpc", Targets: []string{""}},
+			}},
+			paths:    []string{"routes[1].name", "routes[1].targets[0]"},
+			expected: `field routes[1].name must be unique, got "vpc"; required field routes[1].targets[0] type of string is empty`,
+		},
+		{
+			name: "invalid patterns",
+			config: &router.Config{Routes: []router.Route{
+				{Name: "vpc", Targets: []string{"PrismaVPCKiller"}, Match: router.Match{
+					PolicyID: []string{"ok", "regex:(a"},
+					Region:   []string{"us-[east"},
+				}},
+			}},
+			paths:    []string{"routes[0].match.policyId[1]", "routes[0].match.region[0]"},
+			expected: "field routes[0].match.policyId[1]: invalid regex \"regex:(a\": error parsing regexp: missing closing ): `(a`; field routes[0].match.region[0]: invalid glob \"us-[east\": missing closing ]",
+		},
+		{
+			name: "invalid target spec",
+			config: &router.Config{
+				Routes:  []router.Route{{Name: "vpc", Targets: []string{"queue"}}},
+				Targets: []target.Spec{{Name: "queue", Type: target.TypeSQS}},
+			},
+			paths:    []string{"targets[0].queueUrl"},
+			expected: "required field targets[0].queueUrl of a sqs target is empty",
+		},
+		{
+			name: "typo of a target spec",
+			config: &router.Config{
+				Routes: []router.Route{{Name: "pci", Targets: []string{"PrismaAlertNotification", "adit", "SOC"}}},
+				Targets: []target.Spec{
+					{Name: "audit", Type: target.TypeSQS, QueueURL: "https://sqs.us-east-1.amazonaws.com/123456789012/audit"},
+					{Name: "soc", Type: target.TypeWebhook, URL: "https://soc.example.com/prisma"},
+				},
+			},
+			paths:    []string{"routes[0].targets[1]", "routes[0].targets[2]"},
+			expected: `field routes[0].targets[1] is not a target, got "adit": did you mean "audit"; field routes[0].targets[2] is not a target, got "SOC": did you mean "soc"`,
+		},
+		{
+			name: "invalid function name",
+			config: &router.Config{Routes: []router.Route{
+				{Name: "vpc", Targets: []string{"Prisma VPC Killer"}},
+			}},
+			paths:    []string{"routes[0].targets[0]"},
+			expected: `field routes[0].targets[0] must be a target or a Lambda function name, got "Prisma VPC Killer"`,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, testCase.name), func(t *testing.T) {
+			table, err := router.NewTable(testCase.config)
+			assert.Nil(t, table)
+			assert.EqualError(t, err, testCase.expected)
+			validationErrors, ok := err.(errors.ValidationErrors)
+			assert.True(t, ok)
+			assert.Equal(t, testCase.paths, validationErrors.Paths())
+		})
+	}
+}
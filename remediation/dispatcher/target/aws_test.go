@@ -0,0 +1,240 @@
+package target_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/aws/aws-sdk-go/service/sfn/sfniface"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/target"
+)
+
+type mockLambda struct {
+	lambdaiface.LambdaAPI
+	input  *lambda.InvokeInput
+	output *lambda.InvokeOutput
+	err    error
+}
+
+func (m *mockLambda) InvokeWithContext(ctx aws.Context, input *lambda.InvokeInput, opts ...request.Option) (*lambda.InvokeOutput, error) {
+	m.input = input
+	if m.output == nil {
+		m.output = &lambda.InvokeOutput{StatusCode: aws.Int64(202)}
+	}
+	return m.output, m.err
+}
+
+type mockSQS struct {
+	sqsiface.SQSAPI
+	input *sqs.SendMessageInput
+	err   error
+}
+
+func (m *mockSQS) SendMessageWithContext(ctx aws.Context, input *sqs.SendMessageInput, opts ...request.Option) (*sqs.SendMessageOutput, error) {
+	m.input = input
+	return &sqs.SendMessageOutput{MessageId: aws.String("message-id")}, m.err
+}
+
+type mockSNS struct {
+	snsiface.SNSAPI
+	input *sns.PublishInput
+}
+
+func (m *mockSNS) PublishWithContext(ctx aws.Context, input *sns.PublishInput, opts ...request.Option) (*sns.PublishOutput, error) {
+	m.input = input
+	return &sns.PublishOutput{MessageId: aws.String("message-id")}, nil
+}
+
+type mockEventBridge struct {
+	eventbridgeiface.EventBridgeAPI
+	input  *eventbridge.PutEventsInput
+	output *eventbridge.PutEventsOutput
+}
+
+func (m *mockEventBridge) PutEventsWithContext(ctx aws.Context, input *eventbridge.PutEventsInput, opts ...request.Option) (*eventbridge.PutEventsOutput, error) {
+	m.input = input
+	return m.output, nil
+}
+
+type mockSFN struct {
+	sfniface.SFNAPI
+	input *sfn.StartExecutionInput
+	err   error
+}
+
+func (m *mockSFN) StartExecutionWithContext(ctx aws.Context, input *sfn.StartExecutionInput, opts ...request.Option) (*sfn.StartExecutionOutput, error) {
+	m.input = input
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &sfn.StartExecutionOutput{ExecutionArn: aws.String("arn:aws:states:us-east-1:123456789012:execution:remediation:" + aws.StringValue(input.Name))}, nil
+}
+
+var body = []byte(`{"alertId": "P-39425"}`)
+
+func TestLambdaSend(t *testing.T) {
+	client := &mockLambda{}
+	err := target.NewLambda("vpc", "PrismaVPCKiller", "", client).Send(context.Background(), vpcAlert(), body)
+	assert.NoError(t, err)
+	assert.Equal(t, "PrismaVPCKiller", aws.StringValue(client.input.FunctionName))
+	assert.Equal(t, "Event", aws.StringValue(client.input.InvocationType))
+	assert.Equal(t, body, client.input.Payload)
+
+	client = &mockLambda{output: &lambda.InvokeOutput{FunctionError: aws.String("Unhandled"), Payload: []byte(`{"errorMessage": "boom"}`)}}
+	err = target.NewLambda("vpc", "PrismaVPCKiller", "RequestResponse", client).Send(context.Background(), vpcAlert(), body)
+	assert.EqualError(t, err, `function PrismaVPCKiller failed: Unhandled {"errorMessage": "boom"}`)
+	assert.True(t, errors.Is(err, errors.Permanent))
+
+	client = &mockLambda{err: awserr.New(lambda.ErrCodeTooManyRequestsException, "Rate exceeded", nil)}
+	err = target.NewLambda("vpc", "", "", client).Send(context.Background(), vpcAlert(), body)
+	assert.True(t, errors.Is(err, errors.Retryable))
+	assert.Equal(t, "vpc", aws.StringValue(client.input.FunctionName))
+}
+
+func TestLambdaParameters(t *testing.T) {
+	client := &mockLambda{}
+	lambdaTarget := target.NewLambda("vpc-quarantine", "PrismaVPCKiller", "", client)
+	lambdaTarget.Parameters = map[string]string{"remediation": "quarantine", "alertId": "P-1"}
+	err := lambdaTarget.Send(context.Background(), vpcAlert(), []byte(`{"alertId": "P-39425", "resourceId": "vpc-1"}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"alertId": "P-1", "resourceId": "vpc-1", "remediation": "quarantine"}`, string(client.input.Payload))
+
+	client = &mockLambda{}
+	lambdaTarget = target.NewLambda("vpc-quarantine", "PrismaVPCKiller", "", client)
+	lambdaTarget.Parameters = map[string]string{"remediation": "quarantine"}
+	err = lambdaTarget.Send(context.Background(), vpcAlert(), []byte(`[]`))
+	assert.True(t, errors.Is(err, errors.Validation))
+	assert.Nil(t, client.input)
+}
+
+func TestSQSSend(t *testing.T) {
+	testCases := []struct {
+		name     string
+		queueURL string
+		fifo     bool
+	}{
+		{name: "standard queue", queueURL: "https://sqs.us-east-1.amazonaws.com/123456789012/alerts"},
+		{name: "fifo queue", queueURL: "https://sqs.us-east-1.amazonaws.com/123456789012/alerts.fifo", fifo: true},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, testCase.name), func(t *testing.T) {
+			client := &mockSQS{}
+			err := target.NewSQS("queue", testCase.queueURL, client).Send(context.Background(), vpcAlert(), body)
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.queueURL, aws.StringValue(client.input.QueueUrl))
+			assert.Equal(t, string(body), aws.StringValue(client.input.MessageBody))
+			assert.Equal(t, "VPCKiller", aws.StringValue(client.input.MessageAttributes["alertRuleName"].StringValue))
+			assert.Equal(t, "alert", aws.StringValue(client.input.MessageAttributes["kind"].StringValue))
+			if testCase.fifo {
+				assert.Equal(t, "123456789012", aws.StringValue(client.input.MessageGroupId))
+				assert.Equal(t, "P-39425-1582322540962-alert", aws.StringValue(client.input.MessageDeduplicationId))
+			} else {
+				assert.Nil(t, client.input.MessageGroupId)
+				assert.Nil(t, client.input.MessageDeduplicationId)
+			}
+		})
+	}
+}
+
+func TestMessageGroupID(t *testing.T) {
+	alert := vpcAlert()
+	assert.Equal(t, "123456789012", target.MessageGroupID(alert))
+	alert.AccountID = ""
+	assert.Equal(t, "P-39425", target.MessageGroupID(alert))
+	assert.Equal(t, "test", target.MessageGroupID(&events.AlertEvent{}))
+}
+
+func TestSNSSend(t *testing.T) {
+	client := &mockSNS{}
+	topicArn := "arn:aws:sns:us-east-1:123456789012:alerts"
+	err := target.NewSNS("notify", topicArn, client).Send(context.Background(), vpcAlert(), body)
+	assert.NoError(t, err)
+	assert.Equal(t, topicArn, aws.StringValue(client.input.TopicArn))
+	assert.Equal(t, string(body), aws.StringValue(client.input.Message))
+	assert.Equal(t, "high", aws.StringValue(client.input.MessageAttributes["severity"].StringValue))
+	assert.Equal(t, "String", aws.StringValue(client.input.MessageAttributes["severity"].DataType))
+}
+
+func TestEventBridgeSend(t *testing.T) {
+	client := &mockEventBridge{output: &eventbridge.PutEventsOutput{
+		FailedEntryCount: aws.Int64(0),
+		Entries:          []*eventbridge.PutEventsResultEntry{{EventId: aws.String("event-id")}},
+	}}
+	err := target.NewEventBridge("bus", "", "", "", client).Send(context.Background(), vpcAlert(), body)
+	assert.NoError(t, err)
+	entry := client.input.Entries[0]
+	assert.Nil(t, entry.EventBusName)
+	assert.Equal(t, target.DefaultSource, aws.StringValue(entry.Source))
+	assert.Equal(t, target.DefaultDetailType, aws.StringValue(entry.DetailType))
+	assert.Equal(t, string(body), aws.StringValue(entry.Detail))
+
+	client = &mockEventBridge{output: &eventbridge.PutEventsOutput{
+		FailedEntryCount: aws.Int64(1),
+		Entries:          []*eventbridge.PutEventsResultEntry{{ErrorCode: aws.String("ThrottlingException"), ErrorMessage: aws.String("Rate exceeded")}},
+	}}
+	err = target.NewEventBridge("bus", "remediation", "custom", "Alert", client).Send(context.Background(), vpcAlert(), body)
+	assert.EqualError(t, err, "put event of alert P-39425 failed: ThrottlingException Rate exceeded")
+	assert.True(t, errors.Is(err, errors.Retryable))
+	assert.Equal(t, "remediation", aws.StringValue(client.input.Entries[0].EventBusName))
+	assert.Equal(t, "custom", aws.StringValue(client.input.Entries[0].Source))
+}
+
+func TestStepFunctionsSend(t *testing.T) {
+	stateMachineArn := "arn:aws:states:us-east-1:123456789012:stateMachine:remediation"
+	client := &mockSFN{}
+	err := target.NewStepFunctions("remediation", stateMachineArn, client).Send(context.Background(), vpcAlert(), body)
+	assert.NoError(t, err)
+	assert.Equal(t, stateMachineArn, aws.StringValue(client.input.StateMachineArn))
+	assert.Equal(t, "P-39425-1582322540962-alert", aws.StringValue(client.input.Name))
+	assert.Equal(t, string(body), aws.StringValue(client.input.Input))
+
+	client = &mockSFN{err: awserr.New(sfn.ErrCodeExecutionAlreadyExists, "Execution Already Exists", nil)}
+	err = target.NewStepFunctions("remediation", stateMachineArn, client).Send(context.Background(), vpcAlert(), body)
+	assert.NoError(t, err)
+
+	client = &mockSFN{err: awserr.New(sfn.ErrCodeStateMachineDoesNotExist, "State Machine Does Not Exist", nil)}
+	err = target.NewStepFunctions("remediation", stateMachineArn, client).Send(context.Background(), vpcAlert(), body)
+	assert.Error(t, err)
+}
+
+func TestStepFunctionsParameters(t *testing.T) {
+	client := &mockSFN{}
+	stepFunctionsTarget := target.NewStepFunctions("vpc-approval", "arn:aws:states:us-east-1:123456789012:stateMachine:PrismaVPCKiller", client)
+	stepFunctionsTarget.Parameters = map[string]string{"approval": "required"}
+	err := stepFunctionsTarget.Send(context.Background(), vpcAlert(), []byte(`{"alertId": "P-39425", "resourceId": "vpc-1"}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"alertId": "P-39425", "resourceId": "vpc-1", "approval": "required"}`, aws.StringValue(client.input.Input))
+
+	client = &mockSFN{}
+	stepFunctionsTarget = target.NewStepFunctions("vpc-approval", "arn:aws:states:us-east-1:123456789012:stateMachine:PrismaVPCKiller", client)
+	stepFunctionsTarget.Parameters = map[string]string{"approval": "required"}
+	err = stepFunctionsTarget.Send(context.Background(), vpcAlert(), []byte(`[]`))
+	assert.True(t, errors.Is(err, errors.Validation))
+	assert.Nil(t, client.input)
+}
+
+func TestExecutionName(t *testing.T) {
+	alert := vpcAlert()
+	alert.AlertID = "P-39425/é"
+	assert.Equal(t, "P-39425__-1582322540962-alert", target.ExecutionName(alert))
+
+	alert.AlertID = fmt.Sprintf("P-%0100d", 1)
+	assert.Len(t, target.ExecutionName(alert), 80)
+}
@@ -0,0 +1,80 @@
+package target
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+)
+
+const (
+	// DefaultSource is the source of the events put on EventBridge
+	DefaultSource = "prisma-cloud-remediation"
+	// DefaultDetailType is the detail type of the events put on EventBridge
+	DefaultDetailType = "Prisma Cloud Alert"
+)
+
+// EventBridge put the alert on an event bus
+type EventBridge struct {
+	name         string
+	EventBusName string
+	Source       string
+	DetailType   string
+	client       eventbridgeiface.EventBridgeAPI
+}
+
+// NewEventBridge create an EventBridge target, the source and detail type have defaults
+func NewEventBridge(name string, eventBusName string, source string, detailType string, client eventbridgeiface.EventBridgeAPI) *EventBridge {
+	if source == "" {
+		source = DefaultSource
+	}
+	if detailType == "" {
+		detailType = DefaultDetailType
+	}
+	return &EventBridge{
+		name:         name,
+		EventBusName: eventBusName,
+		Source:       source,
+		DetailType:   detailType,
+		client:       client,
+	}
+}
+
+// Name return the target name
+func (b *EventBridge) Name() string {
+	return b.name
+}
+
+// Send put an event with the message body as detail
+func (b *EventBridge) Send(ctx context.Context, alert *events.AlertEvent, body []byte) error {
+	entry := &eventbridge.PutEventsRequestEntry{
+		Source:     aws.String(b.Source),
+		DetailType: aws.String(b.DetailType),
+		Detail:     aws.String(string(body)),
+	}
+	if b.EventBusName != "" {
+		entry.EventBusName = aws.String(b.EventBusName)
+	}
+	output, err := b.client.PutEventsWithContext(ctx, &eventbridge.PutEventsInput{
+		Entries: []*eventbridge.PutEventsRequestEntry{entry},
+	})
+	if err != nil {
+		return errors.Classify(err)
+	}
+	// PutEvents succeed even when the entry is rejected
+	if aws.Int64Value(output.FailedEntryCount) > 0 {
+		for _, result := range output.Entries {
+			if result.ErrorCode != nil {
+				return errors.Wrapf(errors.Retryable, "put event of alert %s failed: %s %s", alert.AlertID, aws.StringValue(result.ErrorCode), aws.StringValue(result.ErrorMessage))
+			}
+		}
+		return errors.Wrapf(errors.Retryable, "put event of alert %s failed", alert.AlertID)
+	}
+	fmt.Printf("Put event %s on %s\n", aws.StringValue(output.Entries[0].EventId), aws.StringValue(entry.EventBusName))
+	return nil
+}
@@ -0,0 +1,70 @@
+package target
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+)
+
+// InvocationTypeEvent invoke the function asynchronously
+const InvocationTypeEvent = "Event"
+
+// Lambda invoke a Lambda function with the alert
+type Lambda struct {
+	name           string
+	FunctionName   string
+	InvocationType string
+	// Parameters are added to the alert payload, they replace the alert fields of the same name
+	Parameters map[string]string
+	client     lambdaiface.LambdaAPI
+}
+
+// NewLambda create a Lambda target, the function name default to the target name
+// and the invocation type to Event
+func NewLambda(name string, functionName string, invocationType string, client lambdaiface.LambdaAPI) *Lambda {
+	if functionName == "" {
+		functionName = name
+	}
+	if invocationType == "" {
+		invocationType = InvocationTypeEvent
+	}
+	return &Lambda{
+		name:           name,
+		FunctionName:   functionName,
+		InvocationType: invocationType,
+		client:         client,
+	}
+}
+
+// Name return the target name
+func (l *Lambda) Name() string {
+	return l.name
+}
+
+// Send invoke the function with the message body
+// A function error of a RequestResponse invocation is returned as a Permanent error
+func (l *Lambda) Send(ctx context.Context, alert *events.AlertEvent, body []byte) error {
+	payload, err := withParameters(l.name, body, l.Parameters)
+	if err != nil {
+		return err
+	}
+	output, err := l.client.InvokeWithContext(ctx, &lambda.InvokeInput{
+		FunctionName:   aws.String(l.FunctionName),
+		InvocationType: aws.String(l.InvocationType),
+		Payload:        payload,
+	})
+	if err != nil {
+		return errors.Classify(err)
+	}
+	if output.FunctionError != nil {
+		return errors.Wrapf(errors.Permanent, "function %s failed: %s %s", l.FunctionName, aws.StringValue(output.FunctionError), string(output.Payload))
+	}
+	fmt.Printf("Invoked %s: %d\n", l.FunctionName, aws.Int64Value(output.StatusCode))
+	return nil
+}
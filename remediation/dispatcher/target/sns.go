@@ -0,0 +1,51 @@
+package target
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+)
+
+// SNS publish the alert to a topic
+type SNS struct {
+	name     string
+	TopicArn string
+	client   snsiface.SNSAPI
+}
+
+// NewSNS create a SNS target
+func NewSNS(name string, topicArn string, client snsiface.SNSAPI) *SNS {
+	return &SNS{name: name, TopicArn: topicArn, client: client}
+}
+
+// Name return the target name
+func (t *SNS) Name() string {
+	return t.name
+}
+
+// Send publish the message body with the alert fields as message attributes
+func (t *SNS) Send(ctx context.Context, alert *events.AlertEvent, body []byte) error {
+	input := &sns.PublishInput{
+		TopicArn:          aws.String(t.TopicArn),
+		Message:           aws.String(string(body)),
+		MessageAttributes: map[string]*sns.MessageAttributeValue{},
+	}
+	for name, value := range messageAttributes(alert) {
+		input.MessageAttributes[name] = &sns.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(value),
+		}
+	}
+	output, err := t.client.PublishWithContext(ctx, input)
+	if err != nil {
+		return errors.Classify(err)
+	}
+	fmt.Printf("Published message %s to %s\n", aws.StringValue(output.MessageId), t.TopicArn)
+	return nil
+}
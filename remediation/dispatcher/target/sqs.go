@@ -0,0 +1,77 @@
+package target
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+)
+
+// fifoSuffix end the URL of a FIFO queue
+const fifoSuffix = ".fifo"
+
+// SQS forward the alert to a queue
+type SQS struct {
+	name     string
+	QueueURL string
+	client   sqsiface.SQSAPI
+}
+
+// NewSQS create a SQS target
+func NewSQS(name string, queueURL string, client sqsiface.SQSAPI) *SQS {
+	return &SQS{name: name, QueueURL: queueURL, client: client}
+}
+
+// Name return the target name
+func (q *SQS) Name() string {
+	return q.name
+}
+
+// Send the message body with the alert fields as message attributes
+// A FIFO queue group messages by account, or by alert without account, and deduplicate them by alert
+func (q *SQS) Send(ctx context.Context, alert *events.AlertEvent, body []byte) error {
+	input := &sqs.SendMessageInput{
+		QueueUrl:          aws.String(q.QueueURL),
+		MessageBody:       aws.String(string(body)),
+		MessageAttributes: map[string]*sqs.MessageAttributeValue{},
+	}
+	for name, value := range messageAttributes(alert) {
+		input.MessageAttributes[name] = &sqs.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(value),
+		}
+	}
+	if strings.HasSuffix(q.QueueURL, fifoSuffix) {
+		input.MessageGroupId = aws.String(MessageGroupID(alert))
+		input.MessageDeduplicationId = aws.String(DeduplicationID(alert))
+	}
+	output, err := q.client.SendMessageWithContext(ctx, input)
+	if err != nil {
+		return errors.Classify(err)
+	}
+	fmt.Printf("Sent message %s to %s\n", aws.StringValue(output.MessageId), q.QueueURL)
+	return nil
+}
+
+// DeduplicationID identify an alert notification, Prisma send the same alert again with a new alertTs
+func DeduplicationID(alert *events.AlertEvent) string {
+	return fmt.Sprintf("%s-%d-%s", alert.AlertID, alert.AlertTs, alert.Kind())
+}
+
+// MessageGroupID return the FIFO message group of the alert, its account
+// An alert without account, such as a test message, is grouped by alert ID, then by kind
+func MessageGroupID(alert *events.AlertEvent) string {
+	if alert.AccountID != "" {
+		return alert.AccountID
+	}
+	if alert.AlertID != "" {
+		return alert.AlertID
+	}
+	return string(alert.Kind())
+}
@@ -0,0 +1,73 @@
+package target
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/aws/aws-sdk-go/service/sfn/sfniface"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+)
+
+// maxExecutionNameLength is the longest execution name Step Functions accept
+const maxExecutionNameLength = 80
+
+var invalidExecutionNameCharacters = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// StepFunctions start a state machine execution with the alert as input
+type StepFunctions struct {
+	name            string
+	StateMachineArn string
+	// Parameters are added to the input, they replace the alert fields of the same name
+	Parameters map[string]string
+	client     sfniface.SFNAPI
+}
+
+// NewStepFunctions create a Step Functions target
+func NewStepFunctions(name string, stateMachineArn string, client sfniface.SFNAPI) *StepFunctions {
+	return &StepFunctions{name: name, StateMachineArn: stateMachineArn, client: client}
+}
+
+// Name return the target name
+func (s *StepFunctions) Name() string {
+	return s.name
+}
+
+// Send start an execution named after the alert
+// A redelivered alert find the execution already started, it is not an error
+func (s *StepFunctions) Send(ctx context.Context, alert *events.AlertEvent, body []byte) error {
+	input, err := withParameters(s.name, body, s.Parameters)
+	if err != nil {
+		return err
+	}
+	name := ExecutionName(alert)
+	output, err := s.client.StartExecutionWithContext(ctx, &sfn.StartExecutionInput{
+		StateMachineArn: aws.String(s.StateMachineArn),
+		Name:            aws.String(name),
+		Input:           aws.String(string(input)),
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == sfn.ErrCodeExecutionAlreadyExists {
+			fmt.Printf("Execution %s already started\n", name)
+			return nil
+		}
+		return errors.Classify(err)
+	}
+	fmt.Printf("Started execution %s\n", aws.StringValue(output.ExecutionArn))
+	return nil
+}
+
+// ExecutionName return the execution name of the alert
+// Only letters, digits, - and _ are allowed, up to 80 characters
+func ExecutionName(alert *events.AlertEvent) string {
+	name := invalidExecutionNameCharacters.ReplaceAllString(DeduplicationID(alert), "_")
+	if len(name) > maxExecutionNameLength {
+		name = name[:maxExecutionNameLength]
+	}
+	return name
+}
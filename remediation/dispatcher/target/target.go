@@ -0,0 +1,256 @@
+package target
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/aws/aws-sdk-go/service/sfn/sfniface"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+)
+
+// Target types
+const (
+	TypeLambda        = "lambda"
+	TypeSQS           = "sqs"
+	TypeSNS           = "sns"
+	TypeEventBridge   = "eventbridge"
+	TypeStepFunctions = "stepfunctions"
+	TypeWebhook       = "webhook"
+)
+
+// DefaultWebhookTimeout is the timeout of the default webhook HTTP client
+const DefaultWebhookTimeout = 10 * time.Second
+
+// Target receive the alerts of a route
+type Target interface {
+	// Name is the name routes use to select the target
+	Name() string
+	// Send deliver the SQS message body of the alert
+	Send(ctx context.Context, alert *events.AlertEvent, body []byte) error
+}
+
+// Spec describe a target in the routing document
+// Only the fields of the target type are used
+type Spec struct {
+	Name string `json:"name" yaml:"name" validate:"required"`
+	Type string `json:"type" yaml:"type" validate:"required,oneof=lambda sqs sns eventbridge stepfunctions webhook"`
+	// FunctionName of a lambda target, the target name is used when it is empty
+	FunctionName string `json:"functionName,omitempty" yaml:"functionName,omitempty"`
+	// InvocationType of a lambda target, Event by default
+	InvocationType string `json:"invocationType,omitempty" yaml:"invocationType,omitempty" validate:"omitempty,oneof=Event RequestResponse"`
+	// Parameters of a lambda or a stepfunctions target are added to the payload, such as the remediation of the VPC killer
+	Parameters map[string]string `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	QueueURL   string            `json:"queueUrl,omitempty" yaml:"queueUrl,omitempty"`
+	TopicArn   string            `json:"topicArn,omitempty" yaml:"topicArn,omitempty"`
+	// EventBusName of an eventbridge target, the default bus when it is empty
+	EventBusName    string            `json:"eventBusName,omitempty" yaml:"eventBusName,omitempty"`
+	Source          string            `json:"source,omitempty" yaml:"source,omitempty"`
+	DetailType      string            `json:"detailType,omitempty" yaml:"detailType,omitempty"`
+	StateMachineArn string            `json:"stateMachineArn,omitempty" yaml:"stateMachineArn,omitempty"`
+	URL             string            `json:"url,omitempty" yaml:"url,omitempty"`
+	Headers         map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+}
+
+// ValidateSpecs verify the target specs of a routing document
+// Paths are reported as targets[i].field
+func ValidateSpecs(specs []Spec) error {
+	validationErrors := errors.ValidationErrors{}
+	document := &struct {
+		Targets []Spec `json:"targets"`
+	}{Targets: specs}
+	if err := errors.Validate(document); err != nil {
+		tagErrors, ok := err.(errors.ValidationErrors)
+		if !ok {
+			return err
+		}
+		validationErrors = append(validationErrors, tagErrors...)
+	}
+
+	names := map[string]bool{}
+	for i := range specs {
+		spec := &specs[i]
+		path := fmt.Sprintf("targets[%d]", i)
+		if spec.Name != "" && names[spec.Name] {
+			validationErrors = append(validationErrors, &errors.FieldError{
+				Path:    path + ".name",
+				Rule:    "unique",
+				Message: fmt.Sprintf("field %s.name must be unique, got %q", path, spec.Name),
+			})
+		}
+		names[spec.Name] = true
+
+		field, value := spec.destination()
+		if field != "" && value == "" {
+			validationErrors = append(validationErrors, &errors.FieldError{
+				Path:    path + "." + field,
+				Rule:    "required",
+				Message: fmt.Sprintf("required field %s.%s of a %s target is empty", path, field, spec.Type),
+			})
+		}
+		if spec.Type == TypeWebhook && spec.URL != "" && !strings.HasPrefix(spec.URL, "https://") {
+			validationErrors = append(validationErrors, &errors.FieldError{
+				Path:    path + ".url",
+				Rule:    "https",
+				Message: fmt.Sprintf("field %s.url must be a https URL, got %q", path, spec.URL),
+			})
+		}
+	}
+	if len(validationErrors) > 0 {
+		return validationErrors
+	}
+	return nil
+}
+
+// destination return the field that address the target and its value
+func (s *Spec) destination() (string, string) {
+	switch s.Type {
+	case TypeSQS:
+		return "queueUrl", s.QueueURL
+	case TypeSNS:
+		return "topicArn", s.TopicArn
+	case TypeStepFunctions:
+		return "stateMachineArn", s.StateMachineArn
+	case TypeWebhook:
+		return "url", s.URL
+	}
+	return "", ""
+}
+
+// withParameters return the message body with the parameters of the target
+func withParameters(name string, body []byte, parameters map[string]string) ([]byte, error) {
+	if len(parameters) == 0 {
+		return body, nil
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, errors.Wrapf(errors.Validation, "add the parameters of target %s: %s", name, err.Error())
+	}
+	for name, value := range parameters {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, errors.Wrap(errors.Permanent, err)
+		}
+		fields[name] = encoded
+	}
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return nil, errors.Wrap(errors.Permanent, err)
+	}
+	return payload, nil
+}
+
+// HTTPClient send the requests of webhook targets
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Clients are the service clients shared by the targets
+type Clients struct {
+	Lambda      lambdaiface.LambdaAPI
+	SQS         sqsiface.SQSAPI
+	SNS         snsiface.SNSAPI
+	EventBridge eventbridgeiface.EventBridgeAPI
+	SFN         sfniface.SFNAPI
+	HTTP        HTTPClient
+}
+
+// NewClients create the service clients from one session
+func NewClients(sess *session.Session, config *aws.Config) *Clients {
+	return &Clients{
+		Lambda:      lambda.New(sess, config),
+		SQS:         sqs.New(sess, config),
+		SNS:         sns.New(sess, config),
+		EventBridge: eventbridge.New(sess, config),
+		SFN:         sfn.New(sess, config),
+		HTTP:        &http.Client{Timeout: DefaultWebhookTimeout},
+	}
+}
+
+// New create the target of the spec
+func New(spec Spec, clients *Clients) (Target, error) {
+	switch spec.Type {
+	case TypeLambda:
+		lambdaTarget := NewLambda(spec.Name, spec.FunctionName, spec.InvocationType, clients.Lambda)
+		lambdaTarget.Parameters = spec.Parameters
+		return lambdaTarget, nil
+	case TypeSQS:
+		return NewSQS(spec.Name, spec.QueueURL, clients.SQS), nil
+	case TypeSNS:
+		return NewSNS(spec.Name, spec.TopicArn, clients.SNS), nil
+	case TypeEventBridge:
+		return NewEventBridge(spec.Name, spec.EventBusName, spec.Source, spec.DetailType, clients.EventBridge), nil
+	case TypeStepFunctions:
+		stepFunctionsTarget := NewStepFunctions(spec.Name, spec.StateMachineArn, clients.SFN)
+		stepFunctionsTarget.Parameters = spec.Parameters
+		return stepFunctionsTarget, nil
+	case TypeWebhook:
+		return NewWebhook(spec.Name, spec.URL, spec.Headers, clients.HTTP), nil
+	}
+	return nil, fmt.Errorf("unknown target type %q of target %s", spec.Type, spec.Name)
+}
+
+// Set is the targets of a routing document by name
+// A name without spec is the name of a Lambda function, like the routes of the original dispatcher
+type Set struct {
+	targets map[string]Target
+	clients *Clients
+}
+
+// NewSet create the targets of the specs
+func NewSet(specs []Spec, clients *Clients) (*Set, error) {
+	if err := ValidateSpecs(specs); err != nil {
+		return nil, err
+	}
+	set := &Set{targets: map[string]Target{}, clients: clients}
+	for _, spec := range specs {
+		target, err := New(spec, clients)
+		if err != nil {
+			return nil, err
+		}
+		set.targets[spec.Name] = target
+	}
+	return set, nil
+}
+
+// Get return the target by name, a Lambda target is returned for a name without spec,
+// the route targets are validated against the specs when the routes load
+func (s *Set) Get(name string) Target {
+	if target, ok := s.targets[name]; ok {
+		return target
+	}
+	return NewLambda(name, name, "", s.clients.Lambda)
+}
+
+// messageAttributes are the alert fields sent as SQS and SNS message attributes, for filter policies
+func messageAttributes(alert *events.AlertEvent) map[string]string {
+	attributes := map[string]string{}
+	for name, value := range map[string]string{
+		"alertId":       alert.AlertID,
+		"alertRuleName": alert.AlertRuleName,
+		"severity":      alert.Severity,
+		"cloudType":     alert.CloudType,
+		"kind":          string(alert.Kind()),
+	} {
+		if value != "" {
+			attributes[name] = value
+		}
+	}
+	return attributes
+}
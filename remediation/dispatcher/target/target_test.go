@@ -0,0 +1,112 @@
+package target_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/target"
+)
+
+func vpcAlert() *events.AlertEvent {
+	return &events.AlertEvent{
+		AlertID:       "P-39425",
+		AlertRuleName: "VPCKiller",
+		PolicyID:      "123-5a19-4afb-b75e-123",
+		Severity:      "high",
+		CloudType:     "aws",
+		AccountID:     "123456789012",
+		AlertTs:       1582322540962,
+	}
+}
+
+func TestValidateSpecs(t *testing.T) {
+	assert.NoError(t, target.ValidateSpecs(nil))
+	assert.NoError(t, target.ValidateSpecs([]target.Spec{
+		{Name: "PrismaVPCKiller", Type: target.TypeLambda},
+		{Name: "queue", Type: target.TypeSQS, QueueURL: "https://sqs.us-east-1.amazonaws.com/123456789012/alerts"},
+		{Name: "bus", Type: target.TypeEventBridge},
+		{Name: "hook", Type: target.TypeWebhook, URL: "https://example.com/alerts"},
+	}))
+
+	testCases := []struct {
+		name     string
+		specs    []target.Spec
+		paths    []string
+		expected string
+	}{
+		{
+			name:     "missing name and type",
+			specs:    []target.Spec{{}},
+			paths:    []string{"targets[0].name", "targets[0].type"},
+			expected: "required field targets[0].name type of string is empty; required field targets[0].type type of string is empty",
+		},
+		{
+			name:     "unknown type",
+			specs:    []target.Spec{{Name: "ftp", Type: "ftp"}},
+			paths:    []string{"targets[0].type"},
+			expected: "field targets[0].type must be one of [lambda sqs sns eventbridge stepfunctions webhook], got \"ftp\"",
+		},
+		{
+			name: "duplicate name and missing destination",
+			specs: []target.Spec{
+				{Name: "notify", Type: target.TypeSNS, TopicArn: "arn:aws:sns:us-east-1:123456789012:alerts"},
+				{Name: "notify", Type: target.TypeSNS},
+			},
+			paths:    []string{"targets[1].name", "targets[1].topicArn"},
+			expected: `field targets[1].name must be unique, got "notify"; required field targets[1].topicArn of a sns target is empty`,
+		},
+		{
+			name:     "plain http webhook",
+			specs:    []target.Spec{{Name: "hook", Type: target.TypeWebhook, URL: "http://example.com"}},
+			paths:    []string{"targets[0].url"},
+			expected: `field targets[0].url must be a https URL, got "http://example.com"`,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, testCase.name), func(t *testing.T) {
+			err := target.ValidateSpecs(testCase.specs)
+			validationErrors, ok := err.(errors.ValidationErrors)
+			assert.True(t, ok)
+			assert.Equal(t, testCase.paths, validationErrors.Paths())
+			assert.EqualError(t, err, testCase.expected)
+		})
+	}
+}
+
+func TestNewSet(t *testing.T) {
+	clients := &target.Clients{Lambda: &mockLambda{}, SQS: &mockSQS{}, SFN: &mockSFN{}}
+	set, err := target.NewSet([]target.Spec{
+		{Name: "queue", Type: target.TypeSQS, QueueURL: "https://sqs.us-east-1.amazonaws.com/123456789012/alerts"},
+		{Name: "vpc", Type: target.TypeLambda, FunctionName: "PrismaVPCKiller"},
+		{
+			Name:            "vpc-approval",
+			Type:            target.TypeStepFunctions,
+			StateMachineArn: "arn:aws:states:us-east-1:123456789012:stateMachine:PrismaVPCKiller",
+			Parameters:      map[string]string{"approval": "required"},
+		},
+	}, clients)
+	assert.NoError(t, err)
+
+	assert.IsType(t, &target.SQS{}, set.Get("queue"))
+	vpc, ok := set.Get("vpc").(*target.Lambda)
+	assert.True(t, ok)
+	assert.Equal(t, "PrismaVPCKiller", vpc.FunctionName)
+	approval, ok := set.Get("vpc-approval").(*target.StepFunctions)
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"approval": "required"}, approval.Parameters)
+
+	// a name without spec is a Lambda function
+	fallback, ok := set.Get("PrismaAlertNotification").(*target.Lambda)
+	assert.True(t, ok)
+	assert.Equal(t, "PrismaAlertNotification", fallback.Name())
+	assert.Equal(t, "PrismaAlertNotification", fallback.FunctionName)
+	assert.Equal(t, target.InvocationTypeEvent, fallback.InvocationType)
+
+	_, err = target.NewSet([]target.Spec{{Name: "queue", Type: target.TypeSQS}}, clients)
+	assert.EqualError(t, err, "required field targets[0].queueUrl of a sqs target is empty")
+}
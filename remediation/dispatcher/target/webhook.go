@@ -0,0 +1,71 @@
+package target
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+)
+
+// StatusError is a webhook response that is not 2xx
+type StatusError struct {
+	StatusCode int
+	URL        string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webhook %s returned %d", e.URL, e.StatusCode)
+}
+
+// HTTPStatusCode return the status code, errors.CategoryOf classify the error by it
+func (e *StatusError) HTTPStatusCode() int {
+	return e.StatusCode
+}
+
+// Webhook POST the alert to a HTTPS endpoint
+type Webhook struct {
+	name    string
+	URL     string
+	Headers map[string]string
+	client  HTTPClient
+}
+
+// NewWebhook create a webhook target
+func NewWebhook(name string, url string, headers map[string]string, client HTTPClient) *Webhook {
+	return &Webhook{name: name, URL: url, Headers: headers, client: client}
+}
+
+// Name return the target name
+func (w *Webhook) Name() string {
+	return w.name
+}
+
+// Send POST the message body as JSON
+func (w *Webhook) Send(ctx context.Context, alert *events.AlertEvent, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(errors.Permanent, err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range w.Headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return errors.Wrap(errors.Retryable, err)
+	}
+	defer resp.Body.Close()
+	// drain the body so the connection is reused
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Classify(&StatusError{StatusCode: resp.StatusCode, URL: w.URL})
+	}
+	fmt.Printf("Posted alert %s to %s: %d\n", alert.AlertID, w.URL, resp.StatusCode)
+	return nil
+}
@@ -0,0 +1,63 @@
+package target_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/dispatcher/target"
+)
+
+type mockHTTPClient struct {
+	request    *http.Request
+	statusCode int
+	err        error
+}
+
+func (m *mockHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	m.request = req
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &http.Response{StatusCode: m.statusCode, Body: ioutil.NopCloser(bytes.NewReader(nil))}, nil
+}
+
+func TestWebhookSend(t *testing.T) {
+	url := "https://example.com/alerts"
+	client := &mockHTTPClient{statusCode: http.StatusAccepted}
+	webhook := target.NewWebhook("hook", url, map[string]string{"Authorization": "Bearer token"}, client)
+	assert.NoError(t, webhook.Send(context.Background(), vpcAlert(), body))
+	assert.Equal(t, http.MethodPost, client.request.Method)
+	assert.Equal(t, url, client.request.URL.String())
+	assert.Equal(t, "application/json", client.request.Header.Get("Content-Type"))
+	assert.Equal(t, "Bearer token", client.request.Header.Get("Authorization"))
+	sent, err := ioutil.ReadAll(client.request.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, body, sent)
+
+	testCases := []struct {
+		statusCode int
+		category   errors.Category
+	}{
+		{statusCode: http.StatusTooManyRequests, category: errors.Retryable},
+		{statusCode: http.StatusBadGateway, category: errors.Retryable},
+		{statusCode: http.StatusBadRequest, category: errors.Validation},
+		{statusCode: http.StatusForbidden, category: errors.Forbidden},
+	}
+	for _, testCase := range testCases {
+		client.statusCode = testCase.statusCode
+		err := webhook.Send(context.Background(), vpcAlert(), body)
+		statusError, ok := err.(*errors.Error).Err.(*target.StatusError)
+		assert.True(t, ok)
+		assert.Equal(t, testCase.statusCode, statusError.StatusCode)
+		assert.Equal(t, testCase.category, errors.CategoryOf(err), http.StatusText(testCase.statusCode))
+	}
+
+	client.err = context.DeadlineExceeded
+	assert.True(t, errors.Is(webhook.Send(context.Background(), vpcAlert(), body), errors.Retryable))
+}
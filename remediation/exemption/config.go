@@ -0,0 +1,70 @@
+package exemption
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+)
+
+const (
+	// ExemptionsEnv contain the allowlists as JSON or YAML
+	ExemptionsEnv = "EXEMPTIONS"
+	// ExemptionsFileEnv contain the path of a JSON or YAML allowlists file
+	ExemptionsFileEnv = "EXEMPTIONS_FILE"
+)
+
+// Config is the allowlists of the accounts, regions and VPCs the destructive remediations never modify
+type Config struct {
+	Accounts []string `json:"accounts" yaml:"accounts"`
+	Regions  []string `json:"regions" yaml:"regions"`
+	Vpcs     []string `json:"vpcs" yaml:"vpcs"`
+}
+
+// DefaultConfig spare Virginia, where the remediations used to only print a message
+func DefaultConfig() *Config {
+	return &Config{Regions: []string{"us-east-1"}}
+}
+
+// Parse decode a JSON or YAML allowlists document
+// A document starting with { is JSON, any other document is YAML
+func Parse(document []byte) (*Config, error) {
+	config := &Config{}
+	trimmed := bytes.TrimSpace(document)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(config); err != nil {
+			return nil, errors.Wrapf(errors.Validation, "invalid exemptions: %s", err.Error())
+		}
+		return config, nil
+	}
+	if err := yaml.UnmarshalStrict(trimmed, config); err != nil {
+		return nil, errors.Wrapf(errors.Validation, "invalid exemptions: %s", err.Error())
+	}
+	return config, nil
+}
+
+// LoadFromEnv create an engine on the allowlists of EXEMPTIONS or the file in EXEMPTIONS_FILE
+// DefaultConfig is used when neither is set
+func LoadFromEnv() (*Engine, error) {
+	document := []byte(os.Getenv(ExemptionsEnv))
+	if path := os.Getenv(ExemptionsFileEnv); len(document) == 0 && path != "" {
+		var err error
+		if document, err = ioutil.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if len(document) == 0 {
+		return New(DefaultConfig()), nil
+	}
+	config, err := Parse(document)
+	if err != nil {
+		return nil, err
+	}
+	return New(config), nil
+}
@@ -0,0 +1,53 @@
+package exemption_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/exemption"
+)
+
+func TestParse(t *testing.T) {
+	expected := &exemption.Config{
+		Accounts: []string{"111111111111"},
+		Regions:  []string{"us-east-1", "eu-west-1"},
+		Vpcs:     []string{"vpc-shared"},
+	}
+
+	config, err := exemption.Parse([]byte(`
+accounts: ["111111111111"]
+regions: [us-east-1, eu-west-1]
+vpcs: [vpc-shared]
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, config)
+
+	config, err = exemption.Parse([]byte(`{"accounts": ["111111111111"], "regions": ["us-east-1", "eu-west-1"], "vpcs": ["vpc-shared"]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, config)
+
+	_, err = exemption.Parse([]byte(`{"account": ["111111111111"]}`))
+	assert.Equal(t, errors.Validation, errors.CategoryOf(err))
+	_, err = exemption.Parse([]byte(`region: us-east-1`))
+	assert.Equal(t, errors.Validation, errors.CategoryOf(err))
+}
+
+func TestLoadFromEnv(t *testing.T) {
+	defer os.Unsetenv(exemption.ExemptionsEnv)
+
+	engine, err := exemption.LoadFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, exemption.New(exemption.DefaultConfig()), engine)
+
+	os.Setenv(exemption.ExemptionsEnv, `{"vpcs": ["vpc-shared"]}`)
+	engine, err = exemption.LoadFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, exemption.New(&exemption.Config{Vpcs: []string{"vpc-shared"}}), engine)
+
+	os.Setenv(exemption.ExemptionsEnv, `{"vpcs": "vpc-shared"}`)
+	_, err = exemption.LoadFromEnv()
+	assert.Error(t, err)
+}
@@ -0,0 +1,130 @@
+package exemption
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+)
+
+const (
+	// TagKey is the tag that protect a resource from the destructive remediations
+	TagKey = "prisma-remediation"
+	// TagValue is the value of TagKey of a protected resource
+	TagValue = "exempt"
+)
+
+// Exemption is a resource spared by a remediation and the reason it is spared
+type Exemption struct {
+	ResourceID string `json:"resourceId"`
+	Reason     string `json:"reason"`
+}
+
+func (e *Exemption) String() string {
+	return fmt.Sprintf("%s is exempt: %s", e.ResourceID, e.Reason)
+}
+
+// Engine decide which resources the destructive remediations spare
+type Engine struct {
+	config *Config
+}
+
+// New create an engine on the allowlists of the config
+func New(config *Config) *Engine {
+	return &Engine{config: config}
+}
+
+// Event return the exemption of the resource of the alert, or nil when the resource can be remediated
+// The account, the region and the resource are checked against the allowlists,
+// then the tags of resourceConfig and resource.resourceTags
+func (e *Engine) Event(event *events.AlertEvent) *Exemption {
+	resourceID := event.ResourceID
+	switch {
+	case contains(e.config.Accounts, event.AccountID):
+		return skip(resourceID, "account %s is allowlisted", event.AccountID)
+	case contains(e.config.Regions, event.ResourceRegionID):
+		return skip(resourceID, "region %s is allowlisted", event.ResourceRegionID)
+	case contains(e.config.Vpcs, resourceID):
+		return skip(resourceID, "VPC %s is allowlisted", resourceID)
+	}
+	if isExempt(configTags(event)) {
+		return skip(resourceID, "resourceConfig is tagged %s=%s", TagKey, TagValue)
+	}
+	if isExempt(resourceTags(event)) {
+		return skip(resourceID, "resource.resourceTags is tagged %s=%s", TagKey, TagValue)
+	}
+	return nil
+}
+
+// Vpc return the exemption of a VPC in the allowlist
+func (e *Engine) Vpc(vpcID string) *Exemption {
+	if contains(e.config.Vpcs, vpcID) {
+		return skip(vpcID, "VPC %s is allowlisted", vpcID)
+	}
+	return nil
+}
+
+// Tags return the exemption of a resource by its live tags
+// resourceID is the spared resource, resourceType and ID are the resource that carry the tags
+func (e *Engine) Tags(resourceID string, resourceType string, id string, tags map[string]string) *Exemption {
+	if isExempt(tags) {
+		return skip(resourceID, "%s %s is tagged %s=%s", resourceType, id, TagKey, TagValue)
+	}
+	return nil
+}
+
+// EC2Tags return the EC2 tags as a map
+func EC2Tags(tags []*ec2.Tag) map[string]string {
+	result := map[string]string{}
+	for _, tag := range tags {
+		result[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return result
+}
+
+// skip log the exemption and return it
+func skip(resourceID string, format string, args ...interface{}) *Exemption {
+	exemption := &Exemption{ResourceID: resourceID, Reason: fmt.Sprintf(format, args...)}
+	fmt.Printf("Skip %s\n", exemption.String())
+	return exemption
+}
+
+func isExempt(tags map[string]string) bool {
+	return tags[TagKey] == TagValue
+}
+
+// configTags return the tags of resourceConfig, any resource config with a tags list
+func configTags(event *events.AlertEvent) map[string]string {
+	config := struct {
+		Tags []events.Tag `json:"tags"`
+	}{}
+	tags := map[string]string{}
+	if err := json.Unmarshal(event.ResourceConfig, &config); err != nil {
+		return tags
+	}
+	for _, tag := range config.Tags {
+		tags[tag.Key] = tag.Value
+	}
+	return tags
+}
+
+// resourceTags return resource.resourceTags, a map of the tag keys to their values
+func resourceTags(event *events.AlertEvent) map[string]string {
+	tags := map[string]string{}
+	if len(event.Resource.ResourceTags) > 0 {
+		json.Unmarshal(event.Resource.ResourceTags, &tags)
+	}
+	return tags
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
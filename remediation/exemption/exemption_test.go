@@ -0,0 +1,89 @@
+package exemption_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/exemption"
+)
+
+func TestEvent(t *testing.T) {
+	testCases := []struct {
+		accountID      string
+		region         string
+		resourceID     string
+		resourceConfig string
+		resourceTags   string
+		expected       string
+	}{
+		{accountID: "123456789012", region: "us-west-2", resourceID: "vpc-1"},
+		{accountID: "111111111111", region: "us-west-2", resourceID: "vpc-1", expected: "account 111111111111 is allowlisted"},
+		{accountID: "123456789012", region: "us-east-1", resourceID: "vpc-1", expected: "region us-east-1 is allowlisted"},
+		{accountID: "123456789012", region: "us-west-2", resourceID: "vpc-shared", expected: "VPC vpc-shared is allowlisted"},
+		{
+			accountID:      "123456789012",
+			region:         "us-west-2",
+			resourceID:     "vpc-1",
+			resourceConfig: `{"vpcId": "vpc-1", "tags": [{"key": "prisma-remediation", "value": "exempt"}]}`,
+			expected:       "resourceConfig is tagged prisma-remediation=exempt",
+		},
+		{
+			accountID:      "123456789012",
+			region:         "us-west-2",
+			resourceID:     "vpc-1",
+			resourceConfig: `{"vpcId": "vpc-1", "tags": [{"key": "prisma-remediation", "value": "remediate"}]}`,
+		},
+		{
+			accountID:    "123456789012",
+			region:       "us-west-2",
+			resourceID:   "vpc-1",
+			resourceTags: `{"Name": "shared", "prisma-remediation": "exempt"}`,
+			expected:     "resource.resourceTags is tagged prisma-remediation=exempt",
+		},
+	}
+
+	engine := exemption.New(&exemption.Config{
+		Accounts: []string{"111111111111"},
+		Regions:  []string{"us-east-1"},
+		Vpcs:     []string{"vpc-shared"},
+	})
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, tc.expected), func(t *testing.T) {
+			event := &events.AlertEvent{
+				AccountID:        tc.accountID,
+				ResourceRegionID: tc.region,
+				ResourceID:       tc.resourceID,
+				ResourceConfig:   json.RawMessage(tc.resourceConfig),
+			}
+			event.Resource.ResourceTags = json.RawMessage(tc.resourceTags)
+			actual := engine.Event(event)
+			if tc.expected == "" {
+				assert.Nil(t, actual)
+				return
+			}
+			assert.Equal(t, &exemption.Exemption{ResourceID: tc.resourceID, Reason: tc.expected}, actual)
+		})
+	}
+}
+
+func TestTags(t *testing.T) {
+	engine := exemption.New(&exemption.Config{Vpcs: []string{"vpc-shared"}})
+
+	tags := exemption.EC2Tags([]*ec2.Tag{
+		{Key: aws.String("Name"), Value: aws.String("web")},
+		{Key: aws.String(exemption.TagKey), Value: aws.String(exemption.TagValue)},
+	})
+	assert.Equal(t, map[string]string{"Name": "web", "prisma-remediation": "exempt"}, tags)
+	assert.Equal(t, "vpc-1 is exempt: instance i-1 is tagged prisma-remediation=exempt",
+		engine.Tags("vpc-1", "instance", "i-1", tags).String())
+	assert.Nil(t, engine.Tags("vpc-1", "instance", "i-2", map[string]string{"Name": "web"}))
+
+	assert.Equal(t, "VPC vpc-shared is allowlisted", engine.Vpc("vpc-shared").Reason)
+	assert.Nil(t, engine.Vpc("vpc-1"))
+}
@@ -3,54 +3,90 @@ package main
 import (
 	"context"
 	"fmt"
+	"os"
 
 	"github.com/CityOfNewYork/prisma-cloud-remediation/api"
 	"github.com/CityOfNewYork/prisma-cloud-remediation/api/prisma"
 	"github.com/CityOfNewYork/prisma-cloud-remediation/api/prisma/alert"
 	"github.com/CityOfNewYork/prisma-cloud-remediation/api/prisma/prismaiface"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
 	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/deadletter"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/sqs"
 )
 
-func login(svc *secretsmanager.SecretsManager) (prismaiface.PrismaAPI, error) {
-	prismaClient := api.CreatePrismaClient("api3")
-	client, err := api.LoginPrismaWithAWSSecret("Prisma", "FalseAlertDismisser", svc, prismaClient)
+// registry cache the logged in clients across warm invocations
+var registry *api.Registry
+
+// deadLetters publish the alerts that cannot be remediated
+var deadLetters *deadletter.Publisher
+
+// defaultProfile is used when PRISMA_TENANTS and PRISMA_TENANTS_FILE are not set
+var defaultProfile = api.Profile{
+	Name:         api.DefaultProfileName,
+	Tenant:       "api3",
+	SecretName:   "Prisma",
+	CustomerName: "FalseAlertDismisser",
+}
+
+func newRegistry(sess *session.Session) (*api.Registry, error) {
+	profiles, err := api.LoadProfilesFromEnv(defaultProfile)
 	if err != nil {
 		return nil, err
 	}
-	return client, nil
+	return api.NewRegistry(profiles, secretsmanager.New(sess))
 }
 
-func handler(contxt context.Context, event events.Alert) error {
+func handler(contxt context.Context, event events.AlertEvent) error {
+	if event.IsResolved() {
+		fmt.Printf("Alert %s is resolved, nothing to remediate\n", event.AlertID)
+		return nil
+	}
+	if err := event.Validate(); err != nil {
+		fmt.Printf("Invalid alert: %s\n", err.Error())
+		return deadLetters.HandleAsync(contxt, lambdacontext.FunctionName, event, err)
+	}
 	if !alert.FalseAWSRegionViolationAlert(&event) {
 		fmt.Println("This is not a false alert.")
 		return nil
 	}
-	sess := session.Must(session.NewSession(&aws.Config{
-		Region: aws.String("us-east-1"),
-	}))
-	prismaClient, err := login(secretsmanager.New(sess))
-	if err != nil {
+	fmt.Printf("Dimiss AlertID: %s\n", event.AlertID)
+	loggedIn := false
+	dismissErr := registry.Do(event.Tenant, func(prismaClient prismaiface.PrismaAPI) error {
+		loggedIn = true
+		return api.DismissAlert(&prisma.DismissAlertInput{
+			Alerts:        []string{event.AlertID},
+			DismissalNote: "Non Virginia Region",
+		}, prismaClient)
+	})
+	if dismissErr != nil && !loggedIn {
 		fmt.Println("Login failed")
-		fmt.Println(err.Error())
-		return err
+		fmt.Println(dismissErr.Error())
+		// a login failure is never acknowledged, even when the secret is not found
+		return errors.Classify(dismissErr)
 	}
-	fmt.Printf("Dimiss AlertID: %s\n", event.AlertID)
-	dismissErr := api.DismissAlert(&prisma.DismissAlertInput{
-		Alerts:        []string{event.AlertID},
-		DismissalNote: "Non Virginia Region",
-	}, prismaClient)
 	if dismissErr != nil {
-		fmt.Printf("Dismiss alert failed: \n%s\n", dismissErr.Error())
-		return dismissErr
+		fmt.Printf("Dismiss alert failed: %s\n%s\n", errors.CategoryOf(dismissErr), dismissErr.Error())
+		return deadLetters.HandleAsync(contxt, lambdacontext.FunctionName, event, dismissErr)
 	}
 	fmt.Printf("Aleret %s is dismissed\n", event.AlertID)
 	return nil
 }
 
 func main() {
+	var err error
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region: aws.String("us-east-1"),
+	}))
+	deadLetters = deadletter.NewPublisherFromEnv(lambdacontext.FunctionName, sqs.New(sess))
+	if registry, err = newRegistry(sess); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
 	lambda.Start(handler)
 }
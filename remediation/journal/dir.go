@@ -0,0 +1,78 @@
+package journal
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// DirStore is a Store on a local directory, a journal is a JSON file of its entries
+type DirStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewDirStore create a store on the directory
+func NewDirStore(dir string) *DirStore {
+	return &DirStore{dir: dir}
+}
+
+// Put replace the entry in the file of the journal, the directory is created
+func (s *DirStore) Put(ctx context.Context, entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := s.read(entry.ID)
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for i := range entries {
+		if entries[i].Seq == entry.Seq {
+			entries[i], replaced = entry, true
+		}
+	}
+	if !replaced {
+		entries = append(entries, entry)
+		sort.Slice(entries, func(a, b int) bool { return entries[a].Seq < entries[b].Seq })
+	}
+	body, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.name(entry.ID), body, 0600)
+}
+
+// Entries read the file of the journal, a missing file is an empty journal
+func (s *DirStore) Entries(ctx context.Context, id string) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read(id)
+}
+
+func (s *DirStore) read(id string) ([]Entry, error) {
+	body, err := ioutil.ReadFile(s.name(id))
+	if os.IsNotExist(err) {
+		return []Entry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	if err := json.Unmarshal(body, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// name return the file of the journal, the ID is escaped so it stay in the directory
+func (s *DirStore) name(id string) string {
+	return filepath.Join(s.dir, url.PathEscape(id)+".json")
+}
@@ -0,0 +1,120 @@
+package journal
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+const (
+	// KeyAttribute is the partition key of the table, the ID of the journal
+	KeyAttribute = "id"
+	// SeqAttribute is the sort key of the table, the sequence of the entry
+	SeqAttribute = "seq"
+)
+
+// DynamoDBStore is a Store on a DynamoDB table, an entry is an item
+type DynamoDBStore struct {
+	table  string
+	client dynamodbiface.DynamoDBAPI
+}
+
+// NewDynamoDBStore create a store on the table
+func NewDynamoDBStore(table string, client dynamodbiface.DynamoDBAPI) *DynamoDBStore {
+	return &DynamoDBStore{table: table, client: client}
+}
+
+// Put write the item of the entry
+func (s *DynamoDBStore) Put(ctx context.Context, entry Entry) error {
+	item := map[string]*dynamodb.AttributeValue{
+		KeyAttribute: {S: aws.String(entry.ID)},
+		SeqAttribute: {N: aws.String(strconv.Itoa(entry.Seq))},
+		"status":     {S: aws.String(entry.Status)},
+		"time":       {S: aws.String(entry.Time.Format(time.RFC3339Nano))},
+	}
+	for name, value := range map[string]string{
+		"operation":  entry.Operation,
+		"resourceId": entry.ResourceID,
+		"error":      entry.Error,
+		"data":       string(entry.Data),
+	} {
+		if value != "" {
+			item[name] = &dynamodb.AttributeValue{S: aws.String(value)}
+		}
+	}
+	_, err := s.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item:      item,
+	})
+	return err
+}
+
+// Entries query the items of the journal, the reads are consistent so a resumed remediation see the last result
+func (s *DynamoDBStore) Entries(ctx context.Context, id string) ([]Entry, error) {
+	entries := []Entry{}
+	var parseErr error
+	err := s.client.QueryPagesWithContext(ctx, &dynamodb.QueryInput{
+		TableName:                aws.String(s.table),
+		ConsistentRead:           aws.Bool(true),
+		KeyConditionExpression:   aws.String("#id = :id"),
+		ExpressionAttributeNames: map[string]*string{"#id": aws.String(KeyAttribute)},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":id": {S: aws.String(id)},
+		},
+	}, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			entry, err := parseEntry(item)
+			if err != nil {
+				parseErr = err
+				return false
+			}
+			entries = append(entries, entry)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, parseErr
+}
+
+func parseEntry(item map[string]*dynamodb.AttributeValue) (Entry, error) {
+	value := func(name string) string {
+		if attribute, ok := item[name]; ok {
+			return aws.StringValue(attribute.S)
+		}
+		return ""
+	}
+	var seq int
+	var err error
+	if attribute, ok := item[SeqAttribute]; ok {
+		seq, err = strconv.Atoi(aws.StringValue(attribute.N))
+	} else {
+		err = fmt.Errorf("item without %s", SeqAttribute)
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+	entryTime, err := time.Parse(time.RFC3339Nano, value("time"))
+	if err != nil {
+		return Entry{}, err
+	}
+	entry := Entry{
+		ID:         value(KeyAttribute),
+		Seq:        seq,
+		Operation:  value("operation"),
+		ResourceID: value("resourceId"),
+		Status:     value("status"),
+		Error:      value("error"),
+		Time:       entryTime,
+	}
+	if data := value("data"); data != "" {
+		entry.Data = []byte(data)
+	}
+	return entry, nil
+}
@@ -0,0 +1,60 @@
+package journal_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/journal"
+)
+
+// mockDynamoDB keep the put items, the query return them in two pages
+type mockDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	items      []map[string]*dynamodb.AttributeValue
+	queryInput *dynamodb.QueryInput
+}
+
+func (m *mockDynamoDB) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	m.items = append(m.items, input.Item)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (m *mockDynamoDB) QueryPagesWithContext(ctx aws.Context, input *dynamodb.QueryInput, fn func(*dynamodb.QueryOutput, bool) bool, opts ...request.Option) error {
+	m.queryInput = input
+	half := len(m.items) / 2
+	if fn(&dynamodb.QueryOutput{Items: m.items[:half]}, false) {
+		fn(&dynamodb.QueryOutput{Items: m.items[half:]}, true)
+	}
+	return nil
+}
+
+func TestDynamoDBStore(t *testing.T) {
+	client := &mockDynamoDB{}
+	store := journal.NewDynamoDBStore("PrismaVPCJournal", client)
+	ctx := context.Background()
+	entries := []journal.Entry{
+		{ID: "P-1/vpc-1", Seq: 0, Status: journal.StatusPlanned, Data: []byte(`{"vpcId":"vpc-1"}`), Time: time.Unix(1582322540, 0).UTC()},
+		{ID: "P-1/vpc-1", Seq: 1, Operation: "security-group/revoke", ResourceID: "sg-1", Status: journal.StatusDone, Data: []byte(`[]`), Time: time.Unix(1582322541, 0).UTC()},
+		{ID: "P-1/vpc-1", Seq: 2, Operation: "vpc/delete", ResourceID: "vpc-1", Status: journal.StatusFailed, Error: "DependencyViolation", Time: time.Unix(1582322542, 0).UTC()},
+	}
+	for _, entry := range entries {
+		assert.NoError(t, store.Put(ctx, entry))
+	}
+	assert.Equal(t, "1", aws.StringValue(client.items[1]["seq"].N))
+	assert.Equal(t, "P-1/vpc-1", aws.StringValue(client.items[1]["id"].S))
+	assert.NotContains(t, client.items[1], "error")
+
+	actual, err := store.Entries(ctx, "P-1/vpc-1")
+	assert.NoError(t, err)
+	assert.Equal(t, entries, actual)
+	assert.Equal(t, "PrismaVPCJournal", aws.StringValue(client.queryInput.TableName))
+	assert.True(t, aws.BoolValue(client.queryInput.ConsistentRead))
+	assert.Equal(t, "P-1/vpc-1", aws.StringValue(client.queryInput.ExpressionAttributeValues[":id"].S))
+}
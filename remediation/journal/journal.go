@@ -0,0 +1,235 @@
+package journal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+)
+
+const (
+	// TableEnv contain the DynamoDB table of the journals
+	TableEnv = "JOURNAL_TABLE"
+	// DirEnv contain the local directory of the journals, it is used when JOURNAL_TABLE is empty
+	DirEnv = "JOURNAL_DIR"
+)
+
+// Status of an entry, a step is started before it runs, then done or failed
+// A done step that was compensated is undone. The plan of a journal that is over, such as the plan
+// of a resolved alert, is closed. The entries of a plan replaced by a new plan are archived
+const (
+	StatusPlanned  = "planned"
+	StatusStarted  = "started"
+	StatusDone     = "done"
+	StatusFailed   = "failed"
+	StatusUndone   = "undone"
+	StatusClosed   = "closed"
+	StatusArchived = "archived"
+)
+
+// Entry record the plan or a step of a remediation
+// The entry 0 hold the plan, the steps are numbered from 1
+type Entry struct {
+	ID         string `json:"id"`
+	Seq        int    `json:"seq"`
+	Operation  string `json:"operation,omitempty"`
+	ResourceID string `json:"resourceId,omitempty"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	// Data is the plan of the entry 0, or what the undo of a step need
+	Data json.RawMessage `json:"data,omitempty"`
+	Time time.Time       `json:"time"`
+}
+
+// Store keep the entries of the journals
+type Store interface {
+	// Put write the entry, it replace the entry with the same ID and sequence
+	Put(ctx context.Context, entry Entry) error
+	// Entries return the entries of the journal ordered by sequence
+	Entries(ctx context.Context, id string) ([]Entry, error)
+}
+
+// NewStoreFromEnv create a store on the DynamoDB table in JOURNAL_TABLE, or on the directory in JOURNAL_DIR
+// nil is returned when neither is set, the journal is disabled
+func NewStoreFromEnv(client dynamodbiface.DynamoDBAPI) Store {
+	if table := os.Getenv(TableEnv); table != "" {
+		return NewDynamoDBStore(table, client)
+	}
+	if dir := os.Getenv(DirEnv); dir != "" {
+		return NewDirStore(dir)
+	}
+	return nil
+}
+
+// Journal is the journal of a remediation, it record the intent of each step before it runs and its result after
+// A store error is Retryable
+type Journal struct {
+	// Now return the current time, time.Now by default
+	Now func() time.Time
+
+	id      string
+	store   Store
+	entries map[int]Entry
+}
+
+// Open read the journal of the ID, a new journal has no entries
+func Open(ctx context.Context, store Store, id string) (*Journal, error) {
+	entries, err := store.Entries(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(errors.Retryable, fmt.Errorf("read journal %s: %w", id, err))
+	}
+	j := &Journal{Now: time.Now, id: id, store: store, entries: map[int]Entry{}}
+	for _, entry := range entries {
+		j.entries[entry.Seq] = entry
+	}
+	return j, nil
+}
+
+// ID return the ID of the journal
+func (j *Journal) ID() string {
+	return j.id
+}
+
+// Plan decode the recorded plan into plan, false is returned when no plan is recorded
+func (j *Journal) Plan(plan interface{}) (bool, error) {
+	entry, ok := j.entries[0]
+	if !ok || entry.Status == StatusArchived {
+		return false, nil
+	}
+	if err := json.Unmarshal(entry.Data, plan); err != nil {
+		return false, errors.Wrap(errors.Permanent, fmt.Errorf("decode plan of journal %s: %w", j.id, err))
+	}
+	return true, nil
+}
+
+// Record write the plan, it is written again when the executed steps change it
+func (j *Journal) Record(ctx context.Context, plan interface{}) error {
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return errors.Wrap(errors.Permanent, err)
+	}
+	return j.put(ctx, Entry{Seq: 0, Status: StatusPlanned, Data: data})
+}
+
+// Done return true when the step completed, an undone step is run again
+func (j *Journal) Done(seq int) bool {
+	return j.entries[seq].Status == StatusDone
+}
+
+// Started return true when the step was started but its result is not recorded,
+// the function stopped while the step was running
+func (j *Journal) Started(seq int) bool {
+	return j.entries[seq].Status == StatusStarted
+}
+
+// Start record the intent of the step, undo is what its compensation need
+// A nil undo keep the undo recorded by the previous start of the step
+func (j *Journal) Start(ctx context.Context, seq int, operation string, resourceID string, undo interface{}) error {
+	entry := Entry{Seq: seq, Operation: operation, ResourceID: resourceID, Status: StatusStarted, Data: j.entries[seq].Data}
+	if undo != nil {
+		data, err := json.Marshal(undo)
+		if err != nil {
+			return errors.Wrap(errors.Permanent, err)
+		}
+		entry.Data = data
+	}
+	return j.put(ctx, entry)
+}
+
+// Finish record the result of the started step
+func (j *Journal) Finish(ctx context.Context, seq int, err error) error {
+	entry := j.entries[seq]
+	entry.Status, entry.Error = StatusDone, ""
+	if err != nil {
+		entry.Status, entry.Error = StatusFailed, err.Error()
+	}
+	return j.put(ctx, entry)
+}
+
+// Undone record the compensation of the step
+func (j *Journal) Undone(ctx context.Context, seq int) error {
+	entry := j.entries[seq]
+	entry.Status = StatusUndone
+	return j.put(ctx, entry)
+}
+
+// Close record that the remediation is over, a closed journal is not resumed
+// A journal without plan has nothing to close
+func (j *Journal) Close(ctx context.Context) error {
+	entry, ok := j.entries[0]
+	if !ok || entry.Status == StatusArchived || entry.Status == StatusClosed {
+		return nil
+	}
+	entry.Status = StatusClosed
+	return j.put(ctx, entry)
+}
+
+// Closed return true when the remediation is over
+func (j *Journal) Closed() bool {
+	return j.entries[0].Status == StatusClosed
+}
+
+// Restart archive the entries of the journal, the next plan start from an empty journal
+// The entries are copied to the journal ArchiveID, a new plan overwrite them under the ID of the journal
+func (j *Journal) Restart(ctx context.Context) error {
+	seqs := make([]int, 0, len(j.entries))
+	for seq := range j.entries {
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+	archiveID := j.ArchiveID()
+	for _, seq := range seqs {
+		entry := j.entries[seq]
+		if entry.Status == StatusArchived {
+			continue
+		}
+		archived := entry
+		archived.ID = archiveID
+		if err := j.store.Put(ctx, archived); err != nil {
+			return errors.Wrap(errors.Retryable, fmt.Errorf("archive journal %s step %d: %w", j.id, entry.Seq, err))
+		}
+		entry.Status = StatusArchived
+		if err := j.put(ctx, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ArchiveID return the ID the entries of the current plan are archived under,
+// it is the ID of the journal and the time the plan was recorded
+func (j *Journal) ArchiveID() string {
+	recorded := j.entries[0].Time
+	if recorded.IsZero() {
+		recorded = j.Now()
+	}
+	return fmt.Sprintf("%s/archive/%s", j.id, recorded.UTC().Format(time.RFC3339Nano))
+}
+
+// Completed return the done steps in reverse order, the order of their compensation
+func (j *Journal) Completed() []Entry {
+	var completed []Entry
+	for seq, entry := range j.entries {
+		if seq > 0 && entry.Status == StatusDone {
+			completed = append(completed, entry)
+		}
+	}
+	sort.Slice(completed, func(a, b int) bool { return completed[a].Seq > completed[b].Seq })
+	return completed
+}
+
+func (j *Journal) put(ctx context.Context, entry Entry) error {
+	entry.ID = j.id
+	entry.Time = j.Now().UTC()
+	if err := j.store.Put(ctx, entry); err != nil {
+		return errors.Wrap(errors.Retryable, fmt.Errorf("write journal %s step %d: %w", j.id, entry.Seq, err))
+	}
+	j.entries[entry.Seq] = entry
+	return nil
+}
@@ -0,0 +1,195 @@
+package journal_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/journal"
+)
+
+type failingStore struct {
+	journal.Store
+}
+
+func (s *failingStore) Put(ctx context.Context, entry journal.Entry) error {
+	return fmt.Errorf("throughput exceeded")
+}
+
+type plan struct {
+	VpcID string   `json:"vpcId"`
+	Steps []string `json:"steps"`
+}
+
+func TestJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store := journal.NewDirStore(dir)
+	ctx := context.Background()
+
+	j, err := journal.Open(ctx, store, "P-1/vpc-1")
+	assert.NoError(t, err)
+	j.Now = func() time.Time { return time.Unix(1582322540, 0) }
+	recorded := &plan{}
+	ok, err := j.Plan(recorded)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, j.Record(ctx, &plan{VpcID: "vpc-1", Steps: []string{"detach igw-1", "delete igw-1", "delete vpc-1"}}))
+	assert.NoError(t, j.Start(ctx, 1, "internet-gateway/detach", "igw-1", map[string]string{"vpcId": "vpc-1"}))
+	assert.NoError(t, j.Finish(ctx, 1, nil))
+	assert.NoError(t, j.Start(ctx, 2, "internet-gateway/delete", "igw-1", nil))
+	assert.NoError(t, j.Finish(ctx, 2, fmt.Errorf("DependencyViolation")))
+	assert.NoError(t, j.Start(ctx, 3, "vpc/delete", "vpc-1", nil))
+
+	// the function stopped during the step 3, the next run resume from the journal
+	resumed, err := journal.Open(ctx, store, "P-1/vpc-1")
+	assert.NoError(t, err)
+	ok, err = resumed.Plan(recorded)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, &plan{VpcID: "vpc-1", Steps: []string{"detach igw-1", "delete igw-1", "delete vpc-1"}}, recorded)
+	assert.True(t, resumed.Done(1))
+	assert.False(t, resumed.Done(2))
+	assert.False(t, resumed.Started(2))
+	assert.True(t, resumed.Started(3))
+
+	assert.NoError(t, resumed.Finish(ctx, 2, nil))
+	completed := resumed.Completed()
+	assert.Len(t, completed, 2)
+	assert.Equal(t, []int{2, 1}, []int{completed[0].Seq, completed[1].Seq})
+	assert.Equal(t, "P-1/vpc-1", completed[1].ID)
+	assert.Equal(t, "igw-1", completed[1].ResourceID)
+	assert.JSONEq(t, `{"vpcId": "vpc-1"}`, string(completed[1].Data))
+	assert.Equal(t, time.Unix(1582322540, 0).UTC(), completed[1].Time)
+
+	assert.NoError(t, resumed.Undone(ctx, 1))
+	assert.False(t, resumed.Done(1))
+	assert.Len(t, resumed.Completed(), 1)
+
+	// the undo recorded by the first start is kept
+	assert.NoError(t, resumed.Start(ctx, 1, "internet-gateway/detach", "igw-1", nil))
+	assert.NoError(t, resumed.Finish(ctx, 1, nil))
+	assert.JSONEq(t, `{"vpcId": "vpc-1"}`, string(resumed.Completed()[1].Data))
+
+	other, err := journal.Open(ctx, store, "P-2/vpc-1")
+	assert.NoError(t, err)
+	assert.Empty(t, other.Completed())
+}
+
+func TestJournalCloseRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store := journal.NewDirStore(dir)
+	ctx := context.Background()
+
+	j, err := journal.Open(ctx, store, "P-1/vpc-1")
+	assert.NoError(t, err)
+	// a journal without plan has nothing to close
+	assert.NoError(t, j.Close(ctx))
+	assert.False(t, j.Closed())
+
+	assert.NoError(t, j.Record(ctx, &plan{VpcID: "vpc-1", Steps: []string{"delete vpc-1"}}))
+	assert.NoError(t, j.Start(ctx, 1, "vpc/delete", "vpc-1", nil))
+	assert.NoError(t, j.Finish(ctx, 1, nil))
+	assert.NoError(t, j.Close(ctx))
+
+	closed, err := journal.Open(ctx, store, "P-1/vpc-1")
+	assert.NoError(t, err)
+	assert.True(t, closed.Closed())
+	recorded := &plan{}
+	ok, err := closed.Plan(recorded)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	archiveID := closed.ArchiveID()
+	assert.NoError(t, closed.Restart(ctx))
+	restarted, err := journal.Open(ctx, store, "P-1/vpc-1")
+	assert.NoError(t, err)
+	assert.False(t, restarted.Closed())
+	assert.False(t, restarted.Done(1))
+	assert.Empty(t, restarted.Completed())
+	ok, err = restarted.Plan(recorded)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	entries, err := store.Entries(ctx, "P-1/vpc-1")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	for _, entry := range entries {
+		assert.Equal(t, journal.StatusArchived, entry.Status)
+	}
+
+	// the new plan overwrite the entries of the journal, the archive keep the closed plan and its steps
+	assert.NoError(t, restarted.Record(ctx, &plan{VpcID: "vpc-1", Steps: []string{"quarantine vpc-1"}}))
+	assert.NoError(t, restarted.Start(ctx, 1, "vpc/quarantine", "vpc-1", nil))
+	archive, err := journal.Open(ctx, store, archiveID)
+	assert.NoError(t, err)
+	assert.True(t, archive.Closed())
+	assert.True(t, archive.Done(1))
+	ok, err = archive.Plan(recorded)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"delete vpc-1"}, recorded.Steps)
+	assert.NotEqual(t, archiveID, restarted.ArchiveID())
+}
+
+func TestJournalStoreFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	j, err := journal.Open(context.Background(), &failingStore{Store: journal.NewDirStore(dir)}, "P-1/vpc-1")
+	assert.NoError(t, err)
+
+	err = j.Start(context.Background(), 1, "vpc/delete", "vpc-1", nil)
+	assert.Equal(t, errors.Retryable, errors.CategoryOf(err))
+	assert.Contains(t, err.Error(), "write journal P-1/vpc-1 step 1")
+	assert.False(t, j.Started(1))
+}
+
+func TestNewStoreFromEnv(t *testing.T) {
+	testCases := []struct {
+		name     string
+		env      map[string]string
+		expected interface{}
+	}{
+		{
+			name: "disabled",
+		},
+		{
+			name:     "table",
+			env:      map[string]string{journal.TableEnv: "PrismaVPCJournal", journal.DirEnv: "/tmp"},
+			expected: &journal.DynamoDBStore{},
+		},
+		{
+			name:     "directory",
+			env:      map[string]string{journal.DirEnv: "/tmp"},
+			expected: &journal.DirStore{},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, tc.name), func(t *testing.T) {
+			for _, name := range []string{journal.TableEnv, journal.DirEnv} {
+				os.Unsetenv(name)
+			}
+			for name, value := range tc.env {
+				os.Setenv(name, value)
+				defer os.Unsetenv(name)
+			}
+			store := journal.NewStoreFromEnv(&mockDynamoDB{})
+			if tc.expected == nil {
+				assert.Nil(t, store)
+			} else {
+				assert.IsType(t, tc.expected, store)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 
 	"github.com/CityOfNewYork/prisma-cloud-remediation/api"
 	"github.com/CityOfNewYork/prisma-cloud-remediation/api/prisma/prismaiface"
@@ -20,26 +21,36 @@ const (
 	externalID = "FrenchEllaReturns"
 )
 
-func login(svc *secretsmanager.SecretsManager) (prismaiface.PrismaAPI, error) {
-	prismaClient := api.CreatePrismaClient("api3")
-	client, err := api.LoginPrismaWithAWSSecret("Prisma", "OnBoarding", svc, prismaClient)
+// registry cache the logged in clients across warm invocations
+var registry *api.Registry
+
+// defaultProfile is used when PRISMA_TENANTS and PRISMA_TENANTS_FILE are not set
+var defaultProfile = api.Profile{
+	Name:         api.DefaultProfileName,
+	Tenant:       tenant,
+	SecretName:   "Prisma",
+	CustomerName: "OnBoarding",
+}
+
+func newRegistry() (*api.Registry, error) {
+	profiles, err := api.LoadProfilesFromEnv(defaultProfile)
 	if err != nil {
 		return nil, err
 	}
-	return client, nil
-}
-
-func handler(ctx context.Context, event events.OnBoardEvent) error {
 	sess := session.Must(session.NewSession(&aws.Config{
 		Region: aws.String("us-east-1"),
 	}))
-	prismaClient, err := login(secretsmanager.New(sess))
-	if err != nil {
-		fmt.Println("Login failed")
-		fmt.Println(err.Error())
-		return err
-	}
+	return api.NewRegistry(profiles, secretsmanager.New(sess))
+}
+
+func handler(ctx context.Context, event events.OnBoardEvent) error {
+	return registry.Do(event.Tenant, func(prismaClient prismaiface.PrismaAPI) error {
+		return onboard(event, prismaClient)
+	})
+}
 
+// onboard register the account of the event if it is not registered
+func onboard(event events.OnBoardEvent, prismaClient prismaiface.PrismaAPI) error {
 	accountGroups, accoutGroupsErr := prismaClient.ListAccountGroups()
 	if accoutGroupsErr != nil {
 		fmt.Println(accoutGroupsErr.Error())
@@ -49,8 +60,8 @@ func handler(ctx context.Context, event events.OnBoardEvent) error {
 
 	switch event.CloudType {
 	case "aws":
-		if accountID := looUpAccount(event.AWS.Name, prismaClient); accountID != "" {
-			return nil
+		if registered, err := looUpAccount(event.AWS.Name, prismaClient); err != nil || registered {
+			return err
 		}
 		event.AWS.GroupIds = accountGroupIDs
 		payload, err := json.Marshal(event.AWS)
@@ -64,8 +75,8 @@ func handler(ctx context.Context, event events.OnBoardEvent) error {
 		}
 
 	case "azure":
-		if accountID := looUpAccount(event.Azure.CloudAccount.Name, prismaClient); accountID != "" {
-			return nil
+		if registered, err := looUpAccount(event.Azure.CloudAccount.Name, prismaClient); err != nil || registered {
+			return err
 		}
 		event.Azure.CloudAccount.GroupIds = accountGroupIDs
 		payload, err := json.Marshal(event.Azure)
@@ -79,8 +90,8 @@ func handler(ctx context.Context, event events.OnBoardEvent) error {
 		}
 
 	case "gcp":
-		if accountID := looUpAccount(event.GCP.CloudAccount.Name, prismaClient); accountID != "" {
-			return nil
+		if registered, err := looUpAccount(event.GCP.CloudAccount.Name, prismaClient); err != nil || registered {
+			return err
 		}
 		event.GCP.CloudAccount.GroupIds = accountGroupIDs
 		payload, err := json.Marshal(event.GCP)
@@ -94,25 +105,32 @@ func handler(ctx context.Context, event events.OnBoardEvent) error {
 		}
 
 	default:
-		fmt.Println("Not support cloud type: %s\n", event.CloudType)
+		fmt.Printf("Not support cloud type: %s\n", event.CloudType)
 	}
 	return nil
 }
 
-func looUpAccount(name string, prismaClient prismaiface.PrismaAPI) string {
-	accountID, accountIDErr := api.LookUpAccountName(name, prismaClient)
-	if accountIDErr != nil {
-		fmt.Println(accountIDErr.Error())
-		return ""
+// looUpAccount return true if the account is registered, the lookup errors are returned
+func looUpAccount(name string, prismaClient prismaiface.PrismaAPI) (bool, error) {
+	resp, err := prismaClient.ListAccountNames()
+	if err != nil {
+		fmt.Println(err.Error())
+		return false, err
 	}
-
-	if accountID != "" {
-		fmt.Println("Account exist")
-		return accountID
+	for _, account := range *resp {
+		if account.Name == name {
+			fmt.Println("Account exist")
+			return true, nil
+		}
 	}
-	return ""
+	return false, nil
 }
 
 func main() {
+	var err error
+	if registry, err = newRegistry(); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
 	lambda.Start(handler)
 }
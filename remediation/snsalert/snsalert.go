@@ -5,26 +5,21 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/deadletter"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
 )
 
-type AlertName string
+// deadLetters publish the alerts that cannot be sent
+var deadLetters *deadletter.Publisher
 
-// Alert struct only partially parse the alert. Most of information is omitted
-type Alert struct {
-	ResourceID    string      `json:"resourceId"`
-	AlertRuleName AlertName   `json:"alertRuleName"`
-	AccountName   string      `json:"accountName"`
-	CloudType     string      `json:"cloudType"`
-	Severity      string      `json:"severity"`
-	PolicyName    string      `json:"policyName"`
-	Resource      interface{} `json:"resource"`
-}
-
-func handler(ctx context.Context, event Alert) error {
+func handler(ctx context.Context, event events.AlertEvent) error {
 
 	fmt.Println("Event received.")
 
@@ -36,11 +31,15 @@ func handler(ctx context.Context, event Alert) error {
 	Policy: %s
 	ResourceID: %s`, event.AlertRuleName, event.ResourceID, event.CloudType, event.AccountName, event.PolicyName, event.ResourceID)
 
+	if event.IsResolved() {
+		message = fmt.Sprintf("\n\tResolved Alert: %s%s", event.AlertID, message)
+	}
+
 	fmt.Printf("Alert: %s\n", message)
 	err := sendSNS(message)
 	if err != nil {
 		fmt.Println(err.Error())
-		return err
+		return deadLetters.HandleAsync(ctx, lambdacontext.FunctionName, event, err)
 	}
 	fmt.Println("Notification sent.")
 	return nil
@@ -60,11 +59,15 @@ func sendSNS(message string) error {
 	})
 	if err != nil {
 		fmt.Println(err.Error())
-		return err
+		return errors.Classify(err)
 	}
 	return nil
 }
 
 func main() {
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+	deadLetters = deadletter.NewPublisherFromEnv(lambdacontext.FunctionName, sqs.New(sess, &aws.Config{Region: aws.String(os.Getenv("REGION"))}))
 	lambda.Start(handler)
 }
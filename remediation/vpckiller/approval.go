@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/approval"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/aws/aws-sdk-go/service/sfn/sfniface"
+)
+
+// ApprovalRequired is the approval of a request that wait for an approver, a dispatcher route set it with a parameter
+const ApprovalRequired = "required"
+
+// gate request the approvals, it is nil when APPROVAL_TABLE and APPROVAL_DIR are not set
+var gate *approval.Gate
+
+// tasks stop the state machine tasks that wait for the approval of a resolved alert
+var tasks sfniface.SFNAPI
+
+// approvalRequired return true when the request wait for an approver
+func (r *Request) approvalRequired() (bool, error) {
+	switch r.Approval {
+	case "":
+		return false, nil
+	case ApprovalRequired:
+		if gate == nil {
+			return false, errors.Wrapf(errors.Permanent, "alert %s require an approval but %s is not set", r.AlertID, approval.TableEnv)
+		}
+		return true, nil
+	}
+	return false, errors.Wrapf(errors.Validation, "invalid approval %q: must be %s", r.Approval, ApprovalRequired)
+}
+
+// approvePhase store the state with its plan and notify the approvers
+// The state machine wait for the approver, who resume it with the stored state and the decision
+func approvePhase(ctx context.Context, state *State, taskToken string) error {
+	if taskToken == "" {
+		return errors.Wrapf(errors.Validation, "phase %s without task token", PhaseApprove)
+	}
+	plan := state.Plan
+	if plan == nil {
+		return errors.Wrapf(errors.Validation, "phase %s without plan", PhaseApprove)
+	}
+	if gate == nil {
+		return errors.Wrapf(errors.Permanent, "phase %s without %s", PhaseApprove, approval.TableEnv)
+	}
+	body, err := json.Marshal(state)
+	if err != nil {
+		return errors.Wrap(errors.Permanent, err)
+	}
+	return gate.Request(ctx, &approval.Approval{
+		AlertID:     plan.AlertID,
+		AccountID:   plan.AccountID,
+		Region:      plan.Region,
+		ResourceID:  plan.VpcID,
+		Remediation: plan.Remediation,
+		Plan:        plan.String(),
+		State:       body,
+		TaskToken:   taskToken,
+	})
+}
+
+// closeApprovals close the pending approvals of the resolved alert
+// The state machine tasks that wait for them fail with RejectedError, the executions end without changes
+func closeApprovals(ctx context.Context, alertID string) error {
+	if gate == nil {
+		return nil
+	}
+	closed, err := gate.Close(ctx, alertID)
+	if err != nil {
+		return err
+	}
+	for _, pending := range closed {
+		if pending.TaskToken == "" || tasks == nil {
+			continue
+		}
+		_, err := tasks.SendTaskFailureWithContext(ctx, &sfn.SendTaskFailureInput{
+			TaskToken: aws.String(pending.TaskToken),
+			Error:     aws.String(approval.RejectedErrorName),
+			Cause:     aws.String(fmt.Sprintf("alert %s is resolved", alertID)),
+		})
+		if awsErr, ok := err.(awserr.Error); ok {
+			switch awsErr.Code() {
+			case sfn.ErrCodeTaskTimedOut, sfn.ErrCodeTaskDoesNotExist, sfn.ErrCodeInvalidToken:
+				// the execution does not wait anymore
+				continue
+			}
+		}
+		if err != nil {
+			return errors.Classify(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/aws/aws-sdk-go/service/sfn/sfniface"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/approval"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/deadletter"
+)
+
+// recordingNotifier keep the approval requests
+type recordingNotifier struct {
+	approvals []*approval.Approval
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, a *approval.Approval, links approval.Links) error {
+	n.approvals = append(n.approvals, a)
+	return nil
+}
+
+// mockSFN record the task failures
+type mockSFN struct {
+	sfniface.SFNAPI
+	failures []*sfn.SendTaskFailureInput
+}
+
+func (m *mockSFN) SendTaskFailureWithContext(ctx aws.Context, input *sfn.SendTaskFailureInput, opts ...request.Option) (*sfn.SendTaskFailureOutput, error) {
+	m.failures = append(m.failures, input)
+	return &sfn.SendTaskFailureOutput{}, nil
+}
+
+// withGate set the gate on a temporary directory for the test
+func withGate(t *testing.T) (*recordingNotifier, func()) {
+	dir, err := ioutil.TempDir("", "approval")
+	assert.NoError(t, err)
+	notifier := &recordingNotifier{}
+	gate = approval.NewGate(approval.NewDirStore(dir), notifier, "https://approvals.example.com/Prod", time.Hour)
+	return notifier, func() {
+		gate = nil
+		os.RemoveAll(dir)
+	}
+}
+
+func TestStateMachineApproval(t *testing.T) {
+	approved := []string{"Request", "Verify", "Remediate", "Approve", "Backup", "Detach", "Terminate", "Wait", "Clean", "Delete", stateSucceeded}
+	testCases := []struct {
+		name      string
+		decision  string
+		terminal  string
+		visited   []string
+		mutations []string
+		category  string
+	}{
+		{
+			name:      "approved",
+			decision:  approval.DecisionApprove,
+			terminal:  stateSucceeded,
+			visited:   approved,
+			mutations: twoTierTeardown,
+		},
+		{
+			name:     "rejected",
+			decision: approval.DecisionReject,
+			terminal: stateRejected,
+			visited:  []string{"Request", "Verify", "Remediate", "Approve", stateRejected},
+		},
+		{
+			name:     "expired",
+			terminal: stateFailed,
+			visited:  []string{"Request", "Verify", "Remediate", "Approve", "Fail", stateFailed},
+			category: "Retryable",
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, tc.name), func(t *testing.T) {
+			ec2svc := newTwoTierVpc()
+			defer withJournal(t, ec2svc)()
+			notifier, cleanup := withGate(t)
+			defer cleanup()
+			sqsvc := &mockSQS{}
+			deadLetters = deadletter.NewPublisher("PrismaVPCKiller", "queue-url", sqsvc)
+			defer func() { deadLetters = nil }()
+
+			e := &execution{}
+			if tc.decision != "" {
+				// the approver decide, then send the stored state back with the task token
+				e.callback = func(taskToken string) ([]byte, error) {
+					assert.Empty(t, ec2svc.mutations())
+					requested := notifier.approvals[0]
+					assert.Equal(t, taskToken, requested.TaskToken)
+					decided, err := gate.Decide(context.Background(), requested.Token, tc.decision, "jdoe@example.com")
+					assert.NoError(t, err)
+					if decided.Status == approval.StatusRejected {
+						return nil, &taskError{name: approval.RejectedErrorName, cause: "rejected by jdoe@example.com"}
+					}
+					return decided.Output()
+				}
+			}
+			terminal, output := e.run(t, definition("arn"), stateMachineInput(t, map[string]interface{}{"approval": ApprovalRequired}))
+			assert.Equal(t, tc.terminal, terminal)
+			assert.Equal(t, tc.visited, e.visited)
+			assert.Equal(t, tc.mutations, ec2svc.mutations())
+
+			assert.Len(t, notifier.approvals, 1)
+			requested := notifier.approvals[0]
+			assert.Equal(t, "P-39425", requested.AlertID)
+			assert.Equal(t, "vpc-1", requested.ResourceID)
+			assert.Contains(t, requested.Plan, "delete vpc vpc-1")
+			assert.Equal(t, float64(3600), output["approvalTimeout"])
+			if tc.decision == approval.DecisionApprove {
+				assert.Equal(t, "jdoe@example.com", output["approval"].(map[string]interface{})["approver"])
+			}
+
+			if tc.category == "" {
+				assert.Empty(t, sqsvc.inputs)
+				return
+			}
+			record := deadletter.Record{}
+			assert.NoError(t, json.Unmarshal([]byte(aws.StringValue(sqsvc.inputs[0].MessageBody)), &record))
+			assert.Equal(t, tc.category, record.Category)
+		})
+	}
+}
+
+func TestStateMachineResolvedApproval(t *testing.T) {
+	ec2svc := newTwoTierVpc()
+	defer withJournal(t, ec2svc)()
+	notifier, cleanup := withGate(t)
+	defer cleanup()
+	sfnsvc := &mockSFN{}
+	tasks = sfnsvc
+	defer func() { tasks = nil }()
+	ctx := context.Background()
+
+	// the alert is resolved while the state machine wait for an approver
+	e := &execution{}
+	e.callback = func(taskToken string) ([]byte, error) {
+		resolved := Request{}
+		assert.NoError(t, json.Unmarshal(stateMachineInput(t, map[string]interface{}{"alertStatus": "resolved"}), &resolved))
+		_, err := handleAlert(ctx, resolved)
+		assert.NoError(t, err)
+		assert.Len(t, sfnsvc.failures, 1)
+		failure := sfnsvc.failures[0]
+		assert.Equal(t, taskToken, aws.StringValue(failure.TaskToken))
+		return nil, &taskError{name: aws.StringValue(failure.Error), cause: aws.StringValue(failure.Cause)}
+	}
+	terminal, _ := e.run(t, definition("arn"), stateMachineInput(t, map[string]interface{}{"approval": ApprovalRequired}))
+	assert.Equal(t, stateRejected, terminal)
+	assert.Empty(t, ec2svc.mutations())
+
+	_, err := gate.Decide(ctx, notifier.approvals[0].Token, approval.DecisionApprove, "jdoe@example.com")
+	assert.EqualError(t, err, "approval of alert P-39425 was closed by Prisma")
+	record, err := openJournal("P-39425", RemediationDelete, "vpc-1")
+	assert.NoError(t, err)
+	assert.True(t, record.Closed())
+
+	// the alert is opened again, it is planned again
+	e = &execution{}
+	terminal, _ = e.run(t, definition("arn"), stateMachineInput(t, nil))
+	assert.Equal(t, stateSucceeded, terminal)
+	assert.Equal(t, twoTierTeardown, ec2svc.mutations())
+}
+
+func TestApprovalRequired(t *testing.T) {
+	testCases := []struct {
+		name     string
+		approval string
+		gate     bool
+		expected bool
+		category errors.Category
+	}{
+		{name: "not required", gate: true},
+		{name: "not required without gate"},
+		{name: "required", approval: ApprovalRequired, gate: true, expected: true},
+		{name: "required without gate", approval: ApprovalRequired, category: errors.Permanent},
+		{name: "invalid", approval: "yes", gate: true, category: errors.Validation},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, tc.name), func(t *testing.T) {
+			if tc.gate {
+				_, cleanup := withGate(t)
+				defer cleanup()
+			}
+			request := Request{Approval: tc.approval}
+			required, err := request.approvalRequired()
+			assert.Equal(t, tc.expected, required)
+			if tc.category == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, tc.category, errors.CategoryOf(err))
+		})
+	}
+}
+
+func TestHandleAlertApproval(t *testing.T) {
+	ec2svc := newTwoTierVpc()
+	defer withJournal(t, ec2svc)()
+	_, cleanup := withGate(t)
+	defer cleanup()
+	deadLetters = deadletter.NewPublisher("PrismaVPCKiller", "", nil)
+	defer func() { deadLetters = nil }()
+
+	// the function cannot wait for the approver
+	request := Request{}
+	assert.NoError(t, json.Unmarshal(stateMachineInput(t, map[string]interface{}{"approval": ApprovalRequired}), &request))
+	_, err := handleAlert(context.Background(), request)
+	assert.Equal(t, errors.Validation, errors.CategoryOf(err))
+	assert.Empty(t, ec2svc.calls)
+
+	// the plan mode does not need an approval
+	request.Mode = ModePlan
+	plan, err := handleAlert(context.Background(), request)
+	assert.NoError(t, err)
+	assert.Len(t, plan.Steps, len(twoTierTeardown))
+	assert.Empty(t, ec2svc.mutations())
+}
+
+func TestHandleBatchApproval(t *testing.T) {
+	ec2svc := newTwoTierVpc()
+	defer withJournal(t, ec2svc)()
+	_, cleanup := withGate(t)
+	defer cleanup()
+
+	// a batch cannot wait for the approver
+	batch := &Batch{Targets: []BatchTarget{{AccountID: "123456789012", Regions: []string{"us-west-2"}}}}
+	report, err := handleBatch(context.Background(), Request{Approval: ApprovalRequired, Batch: batch})
+	assert.Nil(t, report)
+	assert.Equal(t, errors.Validation, errors.CategoryOf(err))
+	assert.Empty(t, ec2svc.calls)
+
+	// the plan mode does not need an approval
+	report, err = handleBatch(context.Background(), Request{Approval: ApprovalRequired, Mode: ModePlan, Batch: batch})
+	assert.NoError(t, err)
+	assert.NotNil(t, report)
+	assert.Empty(t, ec2svc.mutations())
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/backup"
+)
+
+// TagBackupSource is the tag of a snapshot or an image with the volume or the instance it back up
+const TagBackupSource = "prisma-backup-source"
+
+// backups keep the manifests of the deleted VPCs, the backup phase is skipped when it is nil
+var backups backup.Store
+
+// Backup is a snapshot, an image or a manifest created before the VPC is deleted
+type Backup struct {
+	ResourceType string `json:"resourceType"`
+	// ResourceID is the volume, the instance or the VPC that is backed up
+	ResourceID string `json:"resourceId"`
+	// BackupID is the snapshot ID, the image ID or the location of the manifest
+	BackupID string `json:"backupId"`
+}
+
+// manifest is the network configuration of a VPC saved before it is deleted
+type manifest struct {
+	AlertID        string               `json:"alertId"`
+	AccountID      string               `json:"accountId"`
+	Region         string               `json:"region"`
+	VpcID          string               `json:"vpcId"`
+	CreatedAt      time.Time            `json:"createdAt"`
+	Backups        []Backup             `json:"backups"`
+	SecurityGroups []*ec2.SecurityGroup `json:"securityGroups"`
+	RouteTables    []*ec2.RouteTable    `json:"routeTables"`
+	NetworkACLs    []*ec2.NetworkAcl    `json:"networkAcls"`
+}
+
+// backupPlan put the backup steps before the steps of the teardown
+// The volumes are snapshotted and the EBS-backed instances imaged, the manifest is written once they complete
+func (inv *inventory) backupPlan(teardown *Plan) *Plan {
+	plan := &Plan{VpcID: teardown.VpcID}
+	var volumes, images []string
+	seen := map[string]bool{}
+	for _, instance := range inv.instances {
+		for _, mapping := range instance.BlockDeviceMappings {
+			if mapping.Ebs != nil && mapping.Ebs.VolumeId != nil && !seen[*mapping.Ebs.VolumeId] {
+				seen[*mapping.Ebs.VolumeId] = true
+				volumes = append(volumes, *mapping.Ebs.VolumeId)
+			}
+		}
+		if aws.StringValue(instance.RootDeviceType) == ec2.DeviceTypeEbs {
+			images = append(images, *instance.InstanceId)
+		}
+	}
+	for _, volumeID := range volumes {
+		plan.add(ResourceSnapshot, volumeID, ActionCreate)
+	}
+	for _, instanceID := range images {
+		plan.add(ResourceImage, instanceID, ActionCreate)
+	}
+	for _, volumeID := range volumes {
+		plan.add(ResourceSnapshot, volumeID, ActionWait)
+	}
+	for _, instanceID := range images {
+		plan.add(ResourceImage, instanceID, ActionWait)
+	}
+	plan.add(ResourceManifest, teardown.VpcID, ActionCreate, plan.resourceIDs()...)
+	plan.Steps = append(plan.Steps, teardown.Steps...)
+	return plan
+}
+
+// backedUp record the backup of the resource in the plan
+func (p *Plan) backedUp(resourceType string, resourceID string, backupID string) {
+	fmt.Printf("Backed up %s %s to %s\n", resourceType, resourceID, backupID)
+	p.Backups = append(p.Backups, Backup{ResourceType: resourceType, ResourceID: resourceID, BackupID: backupID})
+}
+
+// backup return the backup ID of the resource
+func (p *Plan) backup(resourceType string, resourceID string) (string, error) {
+	for _, backup := range p.Backups {
+		if backup.ResourceType == resourceType && backup.ResourceID == resourceID {
+			return backup.BackupID, nil
+		}
+	}
+	return "", errors.Wrapf(errors.Validation, "no %s of %s in the plan", resourceType, resourceID)
+}
+
+// backupTags return the tags of a snapshot or an image of the resource
+func backupTags(plan *Plan, resourceID string) []*ec2.Tag {
+	return []*ec2.Tag{
+		{Key: aws.String(TagAlertID), Value: aws.String(plan.AlertID)},
+		{Key: aws.String(TagBackupSource), Value: aws.String(resourceID)},
+	}
+}
+
+func (c *Client) createSnapshot(plan *Plan, step Step) error {
+	output, err := c.ec2svc.CreateSnapshot(&ec2.CreateSnapshotInput{
+		VolumeId:    aws.String(step.ResourceID),
+		Description: aws.String(fmt.Sprintf("Backup of %s before the remediation of alert %s", step.ResourceID, plan.AlertID)),
+		TagSpecifications: []*ec2.TagSpecification{
+			{ResourceType: aws.String(ec2.ResourceTypeSnapshot), Tags: backupTags(plan, step.ResourceID)},
+		},
+	})
+	if err != nil {
+		return awsErrorHandler(err)
+	}
+	plan.backedUp(ResourceSnapshot, step.ResourceID, *output.SnapshotId)
+	return nil
+}
+
+func (c *Client) waitUntilSnapshotCompleted(plan *Plan, step Step) error {
+	snapshotID, err := plan.backup(ResourceSnapshot, step.ResourceID)
+	if err != nil {
+		return err
+	}
+	output, err := c.ec2svc.DescribeSnapshots(&ec2.DescribeSnapshotsInput{
+		SnapshotIds: []*string{aws.String(snapshotID)},
+	})
+	if err != nil {
+		return backupPending("snapshot "+snapshotID, awsErrorHandler(err))
+	}
+	if len(output.Snapshots) == 0 {
+		return pending("snapshot " + snapshotID)
+	}
+	for _, snapshot := range output.Snapshots {
+		switch aws.StringValue(snapshot.State) {
+		case ec2.SnapshotStateCompleted:
+		case ec2.SnapshotStateError:
+			return errors.Wrapf(errors.Permanent, "snapshot %s of %s failed: %s",
+				snapshotID, step.ResourceID, aws.StringValue(snapshot.StateMessage))
+		default:
+			return pending("snapshot " + snapshotID)
+		}
+	}
+	return nil
+}
+
+// backupPending return a pending error for a backup that is not found yet, a new backup may not be described at once
+func backupPending(description string, err error) error {
+	if errors.CategoryOf(err) == errors.NotFound {
+		return pending(description)
+	}
+	return err
+}
+
+// createImage image the instance without reboot, CreateImage cannot tag so the image is tagged after
+func (c *Client) createImage(plan *Plan, step Step) error {
+	output, err := c.ec2svc.CreateImage(&ec2.CreateImageInput{
+		InstanceId:  aws.String(step.ResourceID),
+		Name:        aws.String(fmt.Sprintf("prisma-backup-%s-%s", step.ResourceID, now().UTC().Format("20060102T150405Z"))),
+		Description: aws.String(fmt.Sprintf("Backup of %s before the remediation of alert %s", step.ResourceID, plan.AlertID)),
+		NoReboot:    aws.Bool(true),
+	})
+	if err != nil {
+		return awsErrorHandler(err)
+	}
+	plan.backedUp(ResourceImage, step.ResourceID, *output.ImageId)
+	return c.tag(output.ImageId, backupTags(plan, step.ResourceID))
+}
+
+// waitUntilImageAvailable tag the snapshots the image created once the image is available,
+// the step is pending until then
+func (c *Client) waitUntilImageAvailable(plan *Plan, step Step) error {
+	imageID, err := plan.backup(ResourceImage, step.ResourceID)
+	if err != nil {
+		return err
+	}
+	output, err := c.ec2svc.DescribeImages(&ec2.DescribeImagesInput{ImageIds: []*string{aws.String(imageID)}})
+	if err != nil {
+		return backupPending("image "+imageID, awsErrorHandler(err))
+	}
+	if len(output.Images) == 0 {
+		return pending("image " + imageID)
+	}
+	for _, image := range output.Images {
+		switch aws.StringValue(image.State) {
+		case ec2.ImageStateAvailable:
+		case ec2.ImageStatePending:
+			return pending("image " + imageID)
+		default:
+			return errors.Wrapf(errors.Permanent, "image %s of %s is %s", imageID, step.ResourceID, aws.StringValue(image.State))
+		}
+	}
+	for _, image := range output.Images {
+		for _, mapping := range image.BlockDeviceMappings {
+			if mapping.Ebs != nil && mapping.Ebs.SnapshotId != nil {
+				if err := c.tag(mapping.Ebs.SnapshotId, backupTags(plan, step.ResourceID)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// createManifest write the security groups, the route tables and the network ACLs of the VPC to the backup store
+func (c *Client) createManifest(plan *Plan, step Step) error {
+	if backups == nil {
+		return errors.Wrapf(errors.Validation, "no backup store for the manifest of %s", step.ResourceID)
+	}
+	m := &manifest{
+		AlertID:   plan.AlertID,
+		AccountID: plan.AccountID,
+		Region:    plan.Region,
+		VpcID:     step.ResourceID,
+		CreatedAt: now().UTC(),
+		Backups:   plan.Backups,
+	}
+	filter := vpcFilter("vpc-id", step.ResourceID)
+	err := c.ec2svc.DescribeSecurityGroupsPages(&ec2.DescribeSecurityGroupsInput{Filters: filter},
+		func(page *ec2.DescribeSecurityGroupsOutput, lastPage bool) bool {
+			m.SecurityGroups = append(m.SecurityGroups, page.SecurityGroups...)
+			return true
+		})
+	if err != nil {
+		return awsErrorHandler(err)
+	}
+	err = c.ec2svc.DescribeRouteTablesPages(&ec2.DescribeRouteTablesInput{Filters: filter},
+		func(page *ec2.DescribeRouteTablesOutput, lastPage bool) bool {
+			m.RouteTables = append(m.RouteTables, page.RouteTables...)
+			return true
+		})
+	if err != nil {
+		return awsErrorHandler(err)
+	}
+	err = c.ec2svc.DescribeNetworkAclsPages(&ec2.DescribeNetworkAclsInput{Filters: filter},
+		func(page *ec2.DescribeNetworkAclsOutput, lastPage bool) bool {
+			m.NetworkACLs = append(m.NetworkACLs, page.NetworkAcls...)
+			return true
+		})
+	if err != nil {
+		return awsErrorHandler(err)
+	}
+	body, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return errors.Wrap(errors.Permanent, err)
+	}
+	key := path.Join(plan.AccountID, plan.Region, step.ResourceID, plan.AlertID+".json")
+	location, err := backups.Put(context.Background(), key, body)
+	if err != nil {
+		return err
+	}
+	plan.backedUp(ResourceManifest, step.ResourceID, location)
+	return nil
+}
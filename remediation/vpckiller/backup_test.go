@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/backup"
+)
+
+func (m *mockEC2) CreateSnapshot(input *ec2.CreateSnapshotInput) (*ec2.Snapshot, error) {
+	var tags []string
+	for _, tag := range input.TagSpecifications[0].Tags {
+		tags = append(tags, aws.StringValue(tag.Key)+"="+aws.StringValue(tag.Value))
+	}
+	snapshotID := "snap-" + *input.VolumeId
+	return &ec2.Snapshot{SnapshotId: aws.String(snapshotID)}, m.call("CreateSnapshot", *input.VolumeId+" "+tags[0]+","+tags[1])
+}
+
+func (m *mockEC2) DescribeSnapshots(input *ec2.DescribeSnapshotsInput) (*ec2.DescribeSnapshotsOutput, error) {
+	id := *input.SnapshotIds[0]
+	state := ec2.SnapshotStateCompleted
+	if m.pending[id] {
+		state = ec2.SnapshotStatePending
+	}
+	return &ec2.DescribeSnapshotsOutput{Snapshots: []*ec2.Snapshot{{SnapshotId: aws.String(id), State: aws.String(state)}}},
+		m.call("DescribeSnapshots", id)
+}
+
+func (m *mockEC2) CreateImage(input *ec2.CreateImageInput) (*ec2.CreateImageOutput, error) {
+	return &ec2.CreateImageOutput{ImageId: aws.String("ami-" + *input.InstanceId)}, m.call("CreateImage", *input.InstanceId+" "+*input.Name)
+}
+
+func (m *mockEC2) DescribeImages(input *ec2.DescribeImagesInput) (*ec2.DescribeImagesOutput, error) {
+	id := *input.ImageIds[0]
+	state := ec2.ImageStateAvailable
+	if m.pending[id] {
+		state = ec2.ImageStatePending
+	}
+	if err := m.call("DescribeImages", id); err != nil {
+		return nil, err
+	}
+	return &ec2.DescribeImagesOutput{Images: []*ec2.Image{{
+		ImageId: input.ImageIds[0],
+		State:   aws.String(state),
+		BlockDeviceMappings: []*ec2.BlockDeviceMapping{
+			{DeviceName: aws.String("/dev/xvda"), Ebs: &ec2.EbsBlockDevice{SnapshotId: aws.String("snap-" + *input.ImageIds[0])}},
+			{DeviceName: aws.String("/dev/sdb"), VirtualName: aws.String("ephemeral0")},
+		},
+	}}}, nil
+}
+
+func newBackedUpVpc() *mockEC2 {
+	ec2svc := newMockVpc()
+	ec2svc.instances[0].RootDeviceType = aws.String(ec2.DeviceTypeEbs)
+	ec2svc.instances[0].BlockDeviceMappings = []*ec2.InstanceBlockDeviceMapping{
+		{DeviceName: aws.String("/dev/xvda"), Ebs: &ec2.EbsInstanceBlockDevice{VolumeId: aws.String("vol-1")}},
+		{DeviceName: aws.String("/dev/sdf"), Ebs: &ec2.EbsInstanceBlockDevice{VolumeId: aws.String("vol-2")}},
+	}
+	ec2svc.instances[1].RootDeviceType = aws.String(ec2.DeviceTypeInstanceStore)
+	ec2svc.instances[1].BlockDeviceMappings = []*ec2.InstanceBlockDeviceMapping{
+		{DeviceName: aws.String("/dev/sdf"), Ebs: &ec2.EbsInstanceBlockDevice{VolumeId: aws.String("vol-3")}},
+	}
+	return ec2svc
+}
+
+func TestBackupPlan(t *testing.T) {
+	defer func() { backups = nil }()
+	backups = backup.NewDirStore(os.TempDir())
+
+	plan, err := newClient(newBackedUpVpc()).plan(RemediationDelete, "vpc-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"create snapshot vol-1",
+		"create snapshot vol-2",
+		"create snapshot vol-3",
+		"create image i-1",
+		"wait snapshot vol-1",
+		"wait snapshot vol-2",
+		"wait snapshot vol-3",
+		"wait image i-1",
+		"create manifest vpc-1 after vol-1, vol-2, vol-3, i-1",
+		"terminate instance i-1",
+	}, stepLines(plan)[:10])
+
+	plan, err = newClient(newBackedUpVpc()).plan(RemediationQuarantine, "vpc-1")
+	assert.NoError(t, err)
+	for _, step := range plan.Steps {
+		assert.NotEqual(t, ResourceSnapshot, step.ResourceType)
+	}
+
+	backups = nil
+	plan, err = newClient(newBackedUpVpc()).plan(RemediationDelete, "vpc-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "terminate instance i-1", stepLines(plan)[0])
+}
+
+func TestBackup(t *testing.T) {
+	defer func() { backups, now = nil, time.Now }()
+	now = func() time.Time { return time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC) }
+	dir, err := ioutil.TempDir("", "backup")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	backups = backup.NewDirStore(dir)
+
+	ec2svc := newBackedUpVpc()
+	client := newClient(ec2svc)
+	plan, err := client.plan(RemediationDelete, "vpc-1")
+	assert.NoError(t, err)
+	plan.AlertID, plan.AccountID, plan.Region = "P-1", "123456789012", "us-west-2"
+	assert.NoError(t, client.execute(context.Background(), plan))
+	assert.Equal(t, []string{
+		"CreateSnapshot vol-1 prisma-remediation-alert-id=P-1,prisma-backup-source=vol-1",
+		"CreateSnapshot vol-2 prisma-remediation-alert-id=P-1,prisma-backup-source=vol-2",
+		"CreateSnapshot vol-3 prisma-remediation-alert-id=P-1,prisma-backup-source=vol-3",
+		"CreateImage i-1 prisma-backup-i-1-20200301T120000Z",
+		"CreateTags ami-i-1 prisma-backup-source=i-1",
+		"DescribeSnapshots snap-vol-1",
+		"DescribeSnapshots snap-vol-2",
+		"DescribeSnapshots snap-vol-3",
+		"DescribeImages ami-i-1",
+		"CreateTags snap-ami-i-1 prisma-backup-source=i-1",
+		"TerminateInstances i-1",
+	}, ec2svc.mutations()[:11])
+
+	location := dir + "/123456789012/us-west-2/vpc-1/P-1.json"
+	expected := []Backup{
+		{ResourceType: ResourceSnapshot, ResourceID: "vol-1", BackupID: "snap-vol-1"},
+		{ResourceType: ResourceSnapshot, ResourceID: "vol-2", BackupID: "snap-vol-2"},
+		{ResourceType: ResourceSnapshot, ResourceID: "vol-3", BackupID: "snap-vol-3"},
+		{ResourceType: ResourceImage, ResourceID: "i-1", BackupID: "ami-i-1"},
+	}
+	assert.Equal(t, append(expected, Backup{ResourceType: ResourceManifest, ResourceID: "vpc-1", BackupID: location}), plan.Backups)
+
+	body, err := backups.Get(context.Background(), "123456789012/us-west-2/vpc-1/P-1.json")
+	assert.NoError(t, err)
+	m := &manifest{}
+	assert.NoError(t, json.Unmarshal(body, m))
+	assert.Equal(t, "P-1", m.AlertID)
+	assert.Equal(t, "2020-03-01T12:00:00Z", m.CreatedAt.Format(time.RFC3339))
+	assert.Equal(t, expected, m.Backups)
+	// the manifest keep the resources the teardown deleted
+	fixture := newBackedUpVpc()
+	assert.Equal(t, fixture.groups, m.SecurityGroups)
+	assert.Equal(t, fixture.routeTables, m.RouteTables)
+	assert.Equal(t, fixture.acls, m.NetworkACLs)
+}
+
+func TestBackupFailure(t *testing.T) {
+	defer func() { backups = nil }()
+	backups = backup.NewDirStore(os.TempDir())
+
+	ec2svc := newBackedUpVpc()
+	ec2svc.pending = map[string]bool{"ami-i-1": true}
+	client := newClient(ec2svc)
+	plan, err := client.plan(RemediationDelete, "vpc-1")
+	assert.NoError(t, err)
+	// the image is still pending at the deadline, the remediation is retried
+	defer func(interval time.Duration) { waitInterval = interval }(waitInterval)
+	waitInterval = time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, errors.Retryable, errors.CategoryOf(client.execute(ctx, plan)))
+	mutations := ec2svc.mutations()
+	assert.Equal(t, "DescribeImages ami-i-1", mutations[len(mutations)-1])
+	assert.Contains(t, mutations[:len(mutations)-1], "DescribeImages ami-i-1")
+	assert.NotContains(t, mutations, "TerminateInstances i-1")
+
+	ec2svc.failures["DescribeImages ami-i-1"] = awserr.New("InvalidAMIID.NotFound", "not found yet", nil)
+	assert.Equal(t, errors.Retryable, errors.CategoryOf(client.waitUntilImageAvailable(plan, Step{ResourceID: "i-1"})))
+
+	assert.Equal(t, errors.Validation, errors.CategoryOf(client.waitUntilSnapshotCompleted(&Plan{}, Step{ResourceID: "vol-1"})))
+}
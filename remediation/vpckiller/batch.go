@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+)
+
+const (
+	// AccountLimitEnv contain how many VPCs of an account a batch remediate at the same time
+	AccountLimitEnv = "VPCKILLER_ACCOUNT_LIMIT"
+	// DefaultAccountLimit is the account limit when VPCKILLER_ACCOUNT_LIMIT is not set
+	DefaultAccountLimit = 2
+)
+
+// Status of a VPC in the report of a batch
+const (
+	StatusPlanned    = "planned"
+	StatusRemediated = "remediated"
+	StatusExempt     = "exempt"
+	StatusNotFound   = "not-found"
+	StatusFailed     = "failed"
+)
+
+// accountLimit is read from VPCKILLER_ACCOUNT_LIMIT at cold start
+var accountLimit = DefaultAccountLimit
+
+// Batch is a sweep of the VPCs of several accounts and regions
+//
+//	{"mode": "plan", "batch": {"targets": [{"accountId": "123456789012", "regions": ["us-west-2", "eu-west-1"]}]}}
+type Batch struct {
+	Targets []BatchTarget `json:"targets" validate:"required"`
+	// AccountLimit override VPCKILLER_ACCOUNT_LIMIT
+	AccountLimit int `json:"accountLimit,omitempty" validate:"omitempty,min=1"`
+}
+
+// BatchTarget is the VPCs of an account in the regions, the default VPCs when VpcIDs is empty
+type BatchTarget struct {
+	AccountID string   `json:"accountId" validate:"required,regexp=^[0-9]{12}$"`
+	Regions   []string `json:"regions" validate:"required"`
+	VpcIDs    []string `json:"vpcIds,omitempty"`
+}
+
+// Report is the result of every VPC of a batch, sorted by account, region and VPC
+type Report struct {
+	Mode    string         `json:"mode"`
+	Results []Result       `json:"results"`
+	Summary map[string]int `json:"summary"`
+}
+
+// Result is the outcome of the remediation of a VPC
+type Result struct {
+	AccountID   string `json:"accountId"`
+	Region      string `json:"region"`
+	VpcID       string `json:"vpcId,omitempty"`
+	Remediation string `json:"remediation,omitempty"`
+	Status      string `json:"status"`
+	Category    string `json:"category,omitempty"`
+	Error       string `json:"error,omitempty"`
+	Plan        *Plan  `json:"plan,omitempty"`
+}
+
+// sweep remediate the VPCs of a batch
+type sweep struct {
+	request *Request
+	mode    string
+	alertID string
+	limit   int
+	// find return the VPCs of the account in the region that exist, the default VPCs when vpcIDs is empty
+	find func(accountID string, region string, vpcIDs []string) ([]string, error)
+	// remediate plan and execute the remediation of a VPC
+	remediate func(ctx context.Context, event events.AlertEvent, remediation string, mode string) (*Plan, error)
+
+	mutex   sync.Mutex
+	claimed map[string]bool
+	results []Result
+}
+
+// handleBatch validate the batch and remediate its VPCs, the failures are in the report
+func handleBatch(ctx context.Context, request Request) (*Report, error) {
+	mode, err := request.mode()
+	if err != nil {
+		return nil, err
+	}
+	if err := errors.Validate(request.Batch); err != nil {
+		return nil, err
+	}
+	// the function cannot wait for an approver, a batch that require one is only planned
+	if approvalRequired, err := request.approvalRequired(); err != nil {
+		return nil, err
+	} else if approvalRequired && mode == ModeExecute {
+		return nil, errors.Wrapf(errors.Validation, "a batch cannot wait for an approval, run it in %s mode", ModePlan)
+	}
+	alertID := request.AlertID
+	if alertID == "" {
+		alertID = fmt.Sprintf("batch-%d", now().Unix())
+	}
+	limit := accountLimit
+	if request.Batch.AccountLimit > 0 {
+		limit = request.Batch.AccountLimit
+	}
+	s := &sweep{
+		request:   &request,
+		mode:      mode,
+		alertID:   alertID,
+		limit:     limit,
+		find:      findVpcs,
+		remediate: remediateVpc,
+	}
+	report := s.run(ctx)
+	fmt.Printf("VPC Killer batch %s: %v\n", alertID, report.Summary)
+	return report, nil
+}
+
+// run sweep the regions of the targets at the same time, an account has at most limit VPCs in progress
+func (s *sweep) run(ctx context.Context) *Report {
+	s.claimed = map[string]bool{}
+	limits := map[string]pool{}
+	for _, target := range s.request.Batch.Targets {
+		if _, ok := limits[target.AccountID]; !ok {
+			limits[target.AccountID] = newPool(s.limit)
+		}
+	}
+	var wg sync.WaitGroup
+	for _, target := range s.request.Batch.Targets {
+		for _, region := range target.Regions {
+			wg.Add(1)
+			go func(target BatchTarget, region string) {
+				defer wg.Done()
+				s.sweepRegion(ctx, target, region, limits[target.AccountID])
+			}(target, region)
+		}
+	}
+	wg.Wait()
+
+	sort.Slice(s.results, func(i, j int) bool {
+		a, b := s.results[i], s.results[j]
+		if a.AccountID != b.AccountID {
+			return a.AccountID < b.AccountID
+		}
+		if a.Region != b.Region {
+			return a.Region < b.Region
+		}
+		return a.VpcID < b.VpcID
+	})
+	report := &Report{Mode: s.mode, Results: s.results, Summary: map[string]int{}}
+	for _, result := range s.results {
+		report.Summary[result.Status]++
+	}
+	return report
+}
+
+// sweepRegion remediate the VPCs of the target in the region
+func (s *sweep) sweepRegion(ctx context.Context, target BatchTarget, region string, limit pool) {
+	request := Request{Remediation: s.request.Remediation}
+	request.AccountID = target.AccountID
+	remediation, err := request.remediation()
+	if err != nil {
+		s.record(Result{AccountID: target.AccountID, Region: region}, nil, err)
+		return
+	}
+	vpcIDs, err := s.find(target.AccountID, region, target.VpcIDs)
+	if err != nil {
+		s.record(Result{AccountID: target.AccountID, Region: region, Remediation: remediation}, nil, err)
+		return
+	}
+	for _, vpcID := range target.VpcIDs {
+		if !containsID(vpcIDs, vpcID) && s.claim(target.AccountID, region, vpcID) {
+			s.record(Result{AccountID: target.AccountID, Region: region, VpcID: vpcID, Remediation: remediation, Status: StatusNotFound}, nil, nil)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, vpcID := range vpcIDs {
+		if !s.claim(target.AccountID, region, vpcID) {
+			continue
+		}
+		wg.Add(1)
+		go func(vpcID string) {
+			defer wg.Done()
+			result := Result{AccountID: target.AccountID, Region: region, VpcID: vpcID, Remediation: remediation}
+			event := events.AlertEvent{AlertID: s.alertID, AccountID: target.AccountID, ResourceRegionID: region, ResourceID: vpcID}
+			var plan *Plan
+			err := limit.run(ctx, func() error {
+				var err error
+				plan, err = s.remediate(ctx, event, remediation, s.mode)
+				return err
+			})
+			s.record(result, plan, err)
+		}(vpcID)
+	}
+	wg.Wait()
+}
+
+// claim return false when the VPC is already in the batch, a VPC can be listed by several targets
+func (s *sweep) claim(accountID string, region string, vpcID string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := accountID + "/" + region + "/" + vpcID
+	if s.claimed[key] {
+		return false
+	}
+	s.claimed[key] = true
+	return true
+}
+
+// record add the result with the status of the plan or of the error
+func (s *sweep) record(result Result, plan *Plan, err error) {
+	result.Plan = plan
+	switch {
+	case err != nil && errors.Is(err, errors.NotFound):
+		result.Status = StatusNotFound
+		result.Error = err.Error()
+	case err != nil:
+		result.Status = StatusFailed
+		result.Category = errors.CategoryOf(err).String()
+		result.Error = err.Error()
+	case result.Status != "":
+	case plan.Exemption != nil:
+		result.Status = StatusExempt
+	case plan.Mode == ModePlan:
+		result.Status = StatusPlanned
+	default:
+		result.Status = StatusRemediated
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.results = append(s.results, result)
+}
+
+// pool run at most its capacity of functions at the same time
+type pool chan struct{}
+
+func newPool(size int) pool {
+	return make(pool, size)
+}
+
+// run f when a worker is free
+// A context done before a worker is free is a Retryable error
+func (p pool) run(ctx context.Context, f func() error) error {
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(errors.Retryable, err)
+	}
+	select {
+	case p <- struct{}{}:
+	case <-ctx.Done():
+		return errors.Wrap(errors.Retryable, ctx.Err())
+	}
+	defer func() { <-p }()
+	return f()
+}
+
+// findVpcs return the VPCs of the account in the region, a region without the VPCs return none
+func findVpcs(accountID string, region string, vpcIDs []string) ([]string, error) {
+	client, err := clients.Client(region, accountID)
+	if err != nil {
+		return nil, err
+	}
+	var vpcs []*ec2.Vpc
+	if len(vpcIDs) == 0 {
+		vpcs, err = client.defaultVpcs()
+	} else {
+		vpcs, err = client.verifyVpcs(aws.StringSlice(vpcIDs))
+	}
+	if errors.Is(err, errors.NotFound) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	found := []string{}
+	for _, vpc := range vpcs {
+		found = append(found, aws.StringValue(vpc.VpcId))
+	}
+	return found, nil
+}
+
+func containsID(ids []string, id string) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+// loadAccountLimit read VPCKILLER_ACCOUNT_LIMIT
+func loadAccountLimit() error {
+	if value := os.Getenv(AccountLimitEnv); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return fmt.Errorf("invalid %s %q: must be a positive integer", AccountLimitEnv, value)
+		}
+		accountLimit = limit
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/exemption"
+)
+
+// mockSweep find the VPCs of a fixed inventory and record how many VPCs of an account run at the same time
+type mockSweep struct {
+	vpcs     map[string][]string
+	failures map[string]error
+	mutex    sync.Mutex
+	running  map[string]int
+	peak     map[string]int
+}
+
+func (m *mockSweep) find(accountID string, region string, vpcIDs []string) ([]string, error) {
+	if err := m.failures[accountID+"/"+region]; err != nil {
+		return nil, err
+	}
+	if len(vpcIDs) == 0 {
+		return []string{"vpc-default"}, nil
+	}
+	var found []string
+	for _, vpcID := range vpcIDs {
+		if containsID(m.vpcs[accountID+"/"+region], vpcID) {
+			found = append(found, vpcID)
+		}
+	}
+	return found, nil
+}
+
+func (m *mockSweep) remediate(ctx context.Context, event events.AlertEvent, remediation string, mode string) (*Plan, error) {
+	m.mutex.Lock()
+	m.running[event.AccountID]++
+	if m.running[event.AccountID] > m.peak[event.AccountID] {
+		m.peak[event.AccountID] = m.running[event.AccountID]
+	}
+	m.mutex.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	m.mutex.Lock()
+	m.running[event.AccountID]--
+	m.mutex.Unlock()
+
+	if err := m.failures[event.ResourceID]; err != nil {
+		return nil, err
+	}
+	plan := &Plan{AlertID: event.AlertID, VpcID: event.ResourceID, Mode: mode, Remediation: remediation, Steps: []Step{}}
+	if event.ResourceID == "vpc-shared" {
+		plan.Exemption = &exemption.Exemption{ResourceID: "vpc-shared", Reason: "VPC vpc-shared is allowlisted"}
+	}
+	return plan, nil
+}
+
+func TestSweep(t *testing.T) {
+	defer func() { accountRemediations = map[string]string{} }()
+	accountRemediations = map[string]string{"222222222222": RemediationQuarantine}
+
+	mock := &mockSweep{
+		vpcs: map[string][]string{
+			"111111111111/us-west-2": {"vpc-1", "vpc-2", "vpc-3", "vpc-4", "vpc-shared"},
+			"111111111111/eu-west-1": {"vpc-5", "vpc-6"},
+		},
+		failures: map[string]error{
+			"vpc-2":                   errors.Wrapf(errors.Retryable, "DependencyViolation"),
+			"vpc-3":                   errors.Wrapf(errors.NotFound, "Vpcs not found"),
+			"222222222222/ap-south-1": errors.Wrapf(errors.Forbidden, "AccessDenied"),
+		},
+		running: map[string]int{},
+		peak:    map[string]int{},
+	}
+	request := &Request{Batch: &Batch{Targets: []BatchTarget{
+		{AccountID: "111111111111", Regions: []string{"us-west-2"}, VpcIDs: []string{"vpc-1", "vpc-2", "vpc-3", "vpc-4", "vpc-shared", "vpc-missing"}},
+		{AccountID: "111111111111", Regions: []string{"us-west-2"}, VpcIDs: []string{"vpc-1", "vpc-missing"}},
+		{AccountID: "111111111111", Regions: []string{"eu-west-1"}, VpcIDs: []string{"vpc-5", "vpc-6"}},
+		{AccountID: "222222222222", Regions: []string{"us-east-2", "ap-south-1"}},
+	}}}
+	s := &sweep{request: request, mode: ModeExecute, alertID: "batch-1", limit: 2, find: mock.find, remediate: mock.remediate}
+	report := s.run(context.Background())
+
+	var actual []string
+	for _, result := range report.Results {
+		actual = append(actual, fmt.Sprintf("%s %s %s %s %s %s", result.AccountID, result.Region, result.VpcID, result.Remediation, result.Status, result.Category))
+	}
+	assert.Equal(t, []string{
+		"111111111111 eu-west-1 vpc-5 delete remediated ",
+		"111111111111 eu-west-1 vpc-6 delete remediated ",
+		"111111111111 us-west-2 vpc-1 delete remediated ",
+		"111111111111 us-west-2 vpc-2 delete failed Retryable",
+		"111111111111 us-west-2 vpc-3 delete not-found ",
+		"111111111111 us-west-2 vpc-4 delete remediated ",
+		"111111111111 us-west-2 vpc-missing delete not-found ",
+		"111111111111 us-west-2 vpc-shared delete exempt ",
+		"222222222222 ap-south-1  quarantine failed Forbidden",
+		"222222222222 us-east-2 vpc-default quarantine remediated ",
+	}, actual)
+	assert.Equal(t, map[string]int{StatusRemediated: 5, StatusFailed: 2, StatusNotFound: 2, StatusExempt: 1}, report.Summary)
+	assert.Equal(t, "batch-1", report.Results[0].Plan.AlertID)
+	assert.Equal(t, 2, mock.peak["111111111111"])
+	assert.Equal(t, 1, mock.peak["222222222222"])
+}
+
+func TestSweepDeadline(t *testing.T) {
+	mock := &mockSweep{vpcs: map[string][]string{"111111111111/us-west-2": {"vpc-1", "vpc-2"}}, running: map[string]int{}, peak: map[string]int{}}
+	request := &Request{Batch: &Batch{Targets: []BatchTarget{
+		{AccountID: "111111111111", Regions: []string{"us-west-2"}, VpcIDs: []string{"vpc-1", "vpc-2"}},
+	}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s := &sweep{request: request, mode: ModePlan, limit: 1, find: mock.find, remediate: mock.remediate}
+	report := s.run(ctx)
+	for _, result := range report.Results {
+		assert.Equal(t, StatusFailed, result.Status)
+		assert.Equal(t, errors.Retryable.String(), result.Category)
+	}
+	assert.Equal(t, 0, mock.peak["111111111111"])
+}
+
+func TestHandleBatch(t *testing.T) {
+	testCases := []struct {
+		batch    *Batch
+		mode     string
+		expected []string
+	}{
+		{batch: &Batch{}, expected: []string{"batch.targets"}},
+		{
+			batch:    &Batch{Targets: []BatchTarget{{AccountID: "1111", Regions: []string{"us-west-2"}}, {AccountID: "111111111111"}}},
+			expected: []string{"batch.targets[0].accountId", "batch.targets[1].regions"},
+		},
+		{
+			batch:    &Batch{Targets: []BatchTarget{{AccountID: "111111111111", Regions: []string{"us-west-2"}}}, AccountLimit: -1},
+			expected: []string{"batch.accountLimit"},
+		},
+		{batch: &Batch{Targets: []BatchTarget{{AccountID: "111111111111", Regions: []string{"us-west-2"}}}}, mode: "sweep"},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %v", i, tc.expected), func(t *testing.T) {
+			report, err := handler(context.Background(), Request{Mode: tc.mode, Batch: tc.batch})
+			assert.Nil(t, report.(*Report))
+			assert.Equal(t, errors.Validation, errors.CategoryOf(err))
+			if tc.expected != nil {
+				paths := []string{}
+				for _, path := range err.(errors.ValidationErrors).Paths() {
+					paths = append(paths, "batch."+path)
+				}
+				assert.Equal(t, tc.expected, paths)
+			}
+		})
+	}
+}
+
+func TestLoadAccountLimit(t *testing.T) {
+	defer os.Unsetenv(AccountLimitEnv)
+	defer func() { accountLimit = DefaultAccountLimit }()
+
+	os.Setenv(AccountLimitEnv, "4")
+	assert.NoError(t, loadAccountLimit())
+	assert.Equal(t, 4, accountLimit)
+
+	os.Setenv(AccountLimitEnv, "0")
+	assert.Error(t, loadAccountLimit())
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elb/elbiface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	lambdaService "github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+)
+
+// Client contain the clients of the services that can have resources in a VPC
+// The clients are interfaces, so the tests replace them with fakes
+type Client struct {
+	ec2svc    ec2iface.EC2API
+	elbsvc    elbiface.ELBAPI
+	elbv2svc  elbv2iface.ELBV2API
+	lambdasvc lambdaiface.LambdaAPI
+	rdssvc    rdsiface.RDSAPI
+}
+
+// Role is the assume role that used to access other accounts with the ExternalID
+const (
+	Role       = "Prisma_VPC_Term_Role"
+	ExternalID = "PrismaVPCKiller"
+)
+
+// ClientFactory create the client of an account in a region
+type ClientFactory interface {
+	Client(region string, accountID string) (*Client, error)
+}
+
+// clients create the clients of the remediated accounts, it is set at cold start
+var clients ClientFactory
+
+// AssumeRoleFactory create the clients with the credentials of the role of the account
+type AssumeRoleFactory struct {
+	Role       string
+	ExternalID string
+	// Session is the session of the function the role is assumed from
+	Session *session.Session
+	// Credentials return the credentials of the role, the STS AssumeRole credentials by default
+	Credentials func(provider client.ConfigProvider, roleARN string, externalID string) *credentials.Credentials
+}
+
+// NewAssumeRoleFactory create a factory that assume the role with the external ID from the session
+func NewAssumeRoleFactory(sess *session.Session, role string, externalID string) *AssumeRoleFactory {
+	return &AssumeRoleFactory{Role: role, ExternalID: externalID, Session: sess, Credentials: assumeRoleCredentials}
+}
+
+// Client create the service clients of the account in the region
+// The role is assumed on the first call of a client and the credentials are refreshed before they expire
+func (f *AssumeRoleFactory) Client(region string, accountID string) (*Client, error) {
+	roleARN := fmt.Sprintf("arn:aws:iam::%v:role/%v", accountID, f.Role)
+	config := &aws.Config{
+		Region:      aws.String(region),
+		Credentials: f.Credentials(f.Session, roleARN, f.ExternalID),
+	}
+	return newServices(f.Session, config), nil
+}
+
+func assumeRoleCredentials(provider client.ConfigProvider, roleARN string, externalID string) *credentials.Credentials {
+	return stscreds.NewCredentials(provider, roleARN, func(assumeRole *stscreds.AssumeRoleProvider) {
+		assumeRole.ExternalID = aws.String(externalID)
+	})
+}
+
+// newServices create the service clients of the config
+func newServices(provider client.ConfigProvider, config *aws.Config) *Client {
+	return &Client{
+		ec2svc:    ec2.New(provider, config),
+		elbsvc:    elb.New(provider, config),
+		elbv2svc:  elbv2.New(provider, config),
+		lambdasvc: lambdaService.New(provider, config),
+		rdssvc:    rds.New(provider, config),
+	}
+}
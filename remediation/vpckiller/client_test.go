@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssumeRoleFactory(t *testing.T) {
+	sess := session.Must(session.NewSession(&aws.Config{Region: aws.String("us-east-1")}))
+	factory := NewAssumeRoleFactory(sess, Role, ExternalID)
+	var roleARNs, externalIDs []string
+	factory.Credentials = func(provider client.ConfigProvider, roleARN string, externalID string) *credentials.Credentials {
+		roleARNs = append(roleARNs, roleARN)
+		externalIDs = append(externalIDs, externalID)
+		return credentials.NewStaticCredentials("id", "secret", "")
+	}
+
+	client, err := factory.Client("us-west-2", "123456789012")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"arn:aws:iam::123456789012:role/Prisma_VPC_Term_Role"}, roleARNs)
+	assert.Equal(t, []string{"PrismaVPCKiller"}, externalIDs)
+
+	ec2svc := client.ec2svc.(*ec2.EC2)
+	assert.Equal(t, "us-west-2", aws.StringValue(ec2svc.Config.Region))
+	value, err := ec2svc.Config.Credentials.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, "id", value.AccessKeyID)
+}
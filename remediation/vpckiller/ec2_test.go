@@ -0,0 +1,846 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+)
+
+// mockEC2 is an in-memory EC2 of VPCs and their resources
+// The describe calls apply the filters, the mutating calls modify the resources and enforce the dependency
+// constraints of EC2. Every call is recorded, a call listed in failures, such as "DeleteSubnet subnet-1",
+// fail with the injected error. The resources listed in pending, such as a shutting down instance, stay pending
+type mockEC2 struct {
+	ec2iface.EC2API
+	vpcs        []*ec2.Vpc
+	gateways    []*ec2.InternetGateway
+	instances   []*ec2.Instance
+	natGateways []*ec2.NatGateway
+	interfaces  []*ec2.NetworkInterface
+	addresses   []*ec2.Address
+	groups      []*ec2.SecurityGroup
+	subnets     []*ec2.Subnet
+	routeTables []*ec2.RouteTable
+	acls        []*ec2.NetworkAcl
+	failures    map[string]error
+	pending     map[string]bool
+	calls       []string
+}
+
+func newMockEC2() *mockEC2 {
+	return &mockEC2{failures: map[string]error{}}
+}
+
+// addVpc add a VPC with its default security group
+func (m *mockEC2) addVpc(vpcID string, isDefault bool) *mockEC2 {
+	m.vpcs = append(m.vpcs, &ec2.Vpc{VpcId: aws.String(vpcID), IsDefault: aws.Bool(isDefault), State: aws.String(ec2.VpcStateAvailable)})
+	return m.addGroup("sg-"+vpcID, vpcID, "default")
+}
+
+func (m *mockEC2) addSubnet(subnetID string, vpcID string) *mockEC2 {
+	m.subnets = append(m.subnets, &ec2.Subnet{SubnetId: aws.String(subnetID), VpcId: aws.String(vpcID)})
+	return m
+}
+
+// addGateway add an internet gateway attached to the VPC
+func (m *mockEC2) addGateway(gatewayID string, vpcID string) *mockEC2 {
+	m.gateways = append(m.gateways, &ec2.InternetGateway{
+		InternetGatewayId: aws.String(gatewayID),
+		Attachments:       []*ec2.InternetGatewayAttachment{{VpcId: aws.String(vpcID), State: aws.String("available")}},
+	})
+	return m
+}
+
+// addGroup add a security group, its ingress rules reference the referenced groups
+func (m *mockEC2) addGroup(groupID string, vpcID string, name string, referenced ...string) *mockEC2 {
+	group := &ec2.SecurityGroup{GroupId: aws.String(groupID), VpcId: aws.String(vpcID), GroupName: aws.String(name)}
+	for _, referencedID := range referenced {
+		group.IpPermissions = append(group.IpPermissions, &ec2.IpPermission{
+			IpProtocol:       aws.String("tcp"),
+			FromPort:         aws.Int64(443),
+			ToPort:           aws.Int64(443),
+			UserIdGroupPairs: []*ec2.UserIdGroupPair{{GroupId: aws.String(referencedID)}},
+		})
+	}
+	m.groups = append(m.groups, group)
+	return m
+}
+
+// addInstance add a running instance in the subnet, an instance with a public IP map it on the internet gateway
+func (m *mockEC2) addInstance(instanceID string, subnetID string, publicIP bool, groupIDs ...string) *mockEC2 {
+	instance := &ec2.Instance{
+		InstanceId: aws.String(instanceID),
+		SubnetId:   aws.String(subnetID),
+		VpcId:      m.findSubnet(subnetID).VpcId,
+		State:      &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)},
+	}
+	if publicIP {
+		instance.PublicIpAddress = aws.String("203.0.113.10")
+	}
+	for _, groupID := range groupIDs {
+		instance.SecurityGroups = append(instance.SecurityGroups, &ec2.GroupIdentifier{GroupId: aws.String(groupID)})
+	}
+	m.instances = append(m.instances, instance)
+	return m
+}
+
+// call record the call and return its injected failure
+func (m *mockEC2) call(name string, id string) error {
+	call := name + " " + id
+	m.calls = append(m.calls, call)
+	return m.failures[call]
+}
+
+// mutations return the recorded calls of the steps, the calls that modify a resource and the describes of the waits
+// The describes of the inventory have no resource ID
+func (m *mockEC2) mutations() []string {
+	var mutations []string
+	for _, call := range m.calls {
+		if !strings.HasPrefix(call, "Describe") || !strings.HasSuffix(call, " ") {
+			mutations = append(mutations, call)
+		}
+	}
+	return mutations
+}
+
+// resources return the IDs of the resources left in the VPC, sorted
+func (m *mockEC2) resources(vpcID string) []string {
+	var ids []string
+	for _, vpc := range m.vpcs {
+		if *vpc.VpcId == vpcID {
+			ids = append(ids, vpcID)
+		}
+	}
+	for _, subnet := range m.subnets {
+		if *subnet.VpcId == vpcID {
+			ids = append(ids, *subnet.SubnetId)
+		}
+	}
+	for _, gateway := range m.gateways {
+		if attached(gateway, vpcID) {
+			ids = append(ids, *gateway.InternetGatewayId)
+		}
+	}
+	for _, instance := range m.instances {
+		if *instance.VpcId == vpcID && alive(instance) {
+			ids = append(ids, *instance.InstanceId)
+		}
+	}
+	for _, natGateway := range m.natGateways {
+		if *natGateway.VpcId == vpcID && *natGateway.State != ec2.NatGatewayStateDeleted {
+			ids = append(ids, *natGateway.NatGatewayId)
+		}
+	}
+	for _, networkInterface := range m.interfaces {
+		if *networkInterface.VpcId == vpcID {
+			ids = append(ids, *networkInterface.NetworkInterfaceId)
+		}
+	}
+	for _, group := range m.groups {
+		if *group.VpcId == vpcID {
+			ids = append(ids, *group.GroupId)
+		}
+	}
+	for _, table := range m.routeTables {
+		if *table.VpcId == vpcID {
+			ids = append(ids, *table.RouteTableId)
+		}
+	}
+	for _, acl := range m.acls {
+		if *acl.VpcId == vpcID {
+			ids = append(ids, *acl.NetworkAclId)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (m *mockEC2) findSubnet(subnetID string) *ec2.Subnet {
+	for _, subnet := range m.subnets {
+		if *subnet.SubnetId == subnetID {
+			return subnet
+		}
+	}
+	return nil
+}
+
+func (m *mockEC2) findInstance(instanceID string) *ec2.Instance {
+	for _, instance := range m.instances {
+		if *instance.InstanceId == instanceID {
+			return instance
+		}
+	}
+	return nil
+}
+
+func (m *mockEC2) findGateway(gatewayID string) *ec2.InternetGateway {
+	for _, gateway := range m.gateways {
+		if *gateway.InternetGatewayId == gatewayID {
+			return gateway
+		}
+	}
+	return nil
+}
+
+func (m *mockEC2) findGroup(groupID string) *ec2.SecurityGroup {
+	for _, group := range m.groups {
+		if *group.GroupId == groupID {
+			return group
+		}
+	}
+	return nil
+}
+
+func alive(instance *ec2.Instance) bool {
+	return *instance.State.Name != ec2.InstanceStateNameTerminated
+}
+
+func attached(gateway *ec2.InternetGateway, vpcID string) bool {
+	for _, attachment := range gateway.Attachments {
+		if *attachment.VpcId == vpcID {
+			return true
+		}
+	}
+	return false
+}
+
+func dependencyViolation(message string) error {
+	return awserr.New("DependencyViolation", message, nil)
+}
+
+func notFound(code string, id string) error {
+	return awserr.New(code+".NotFound", "The ID '"+id+"' does not exist", nil)
+}
+
+// matches return true when the resource match every filter, values are the values of the resource by filter name
+// The tag:<key> and tag-key filters match the tags of the resource
+func matches(filters []*ec2.Filter, values map[string][]string, tags []*ec2.Tag) bool {
+	for _, filter := range filters {
+		name := aws.StringValue(filter.Name)
+		resourceValues := values[name]
+		switch {
+		case strings.HasPrefix(name, "tag:"):
+			resourceValues = nil
+			for _, tag := range tags {
+				if aws.StringValue(tag.Key) == strings.TrimPrefix(name, "tag:") {
+					resourceValues = append(resourceValues, aws.StringValue(tag.Value))
+				}
+			}
+		case name == "tag-key":
+			resourceValues = nil
+			for _, tag := range tags {
+				resourceValues = append(resourceValues, aws.StringValue(tag.Key))
+			}
+		}
+		matched := false
+		for _, value := range aws.StringValueSlice(filter.Values) {
+			matched = matched || containsID(resourceValues, value)
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// selected return true when the resource is one of the IDs, every resource is selected without IDs
+func selected(values []*string, id string) bool {
+	return len(values) == 0 || containsID(aws.StringValueSlice(values), id)
+}
+
+func (m *mockEC2) DescribeVpcs(input *ec2.DescribeVpcsInput) (*ec2.DescribeVpcsOutput, error) {
+	if err := m.call("DescribeVpcs", ""); err != nil {
+		return nil, err
+	}
+	output := &ec2.DescribeVpcsOutput{}
+	for _, vpc := range m.vpcs {
+		values := map[string][]string{
+			"vpc-id":     {*vpc.VpcId},
+			"is-default": {strconv.FormatBool(aws.BoolValue(vpc.IsDefault))},
+		}
+		if selected(input.VpcIds, *vpc.VpcId) && matches(input.Filters, values, vpc.Tags) {
+			output.Vpcs = append(output.Vpcs, vpc)
+		}
+	}
+	return output, nil
+}
+
+func (m *mockEC2) DescribeInstancesPages(input *ec2.DescribeInstancesInput, fn func(*ec2.DescribeInstancesOutput, bool) bool) error {
+	if err := m.call("DescribeInstances", ""); err != nil {
+		return err
+	}
+	reservation := &ec2.Reservation{}
+	for _, instance := range m.instances {
+		values := map[string][]string{"vpc-id": {aws.StringValue(instance.VpcId)}}
+		if selected(input.InstanceIds, *instance.InstanceId) && matches(input.Filters, values, instance.Tags) {
+			reservation.Instances = append(reservation.Instances, instance)
+		}
+	}
+	fn(&ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{reservation}}, true)
+	return nil
+}
+
+func (m *mockEC2) DescribeNatGatewaysPages(input *ec2.DescribeNatGatewaysInput, fn func(*ec2.DescribeNatGatewaysOutput, bool) bool) error {
+	output, _ := m.DescribeNatGateways(input)
+	fn(output, true)
+	return nil
+}
+
+func (m *mockEC2) DescribeNatGateways(input *ec2.DescribeNatGatewaysInput) (*ec2.DescribeNatGatewaysOutput, error) {
+	output := &ec2.DescribeNatGatewaysOutput{}
+	for _, natGateway := range m.natGateways {
+		values := map[string][]string{"vpc-id": {*natGateway.VpcId}}
+		if selected(input.NatGatewayIds, *natGateway.NatGatewayId) && matches(input.Filter, values, natGateway.Tags) {
+			output.NatGateways = append(output.NatGateways, natGateway)
+		}
+	}
+	return output, nil
+}
+
+func (m *mockEC2) DescribeInternetGatewaysPages(input *ec2.DescribeInternetGatewaysInput, fn func(*ec2.DescribeInternetGatewaysOutput, bool) bool) error {
+	output := &ec2.DescribeInternetGatewaysOutput{}
+	for _, gateway := range m.gateways {
+		values := map[string][]string{}
+		for _, attachment := range gateway.Attachments {
+			values["attachment.vpc-id"] = append(values["attachment.vpc-id"], *attachment.VpcId)
+		}
+		if selected(input.InternetGatewayIds, *gateway.InternetGatewayId) && matches(input.Filters, values, gateway.Tags) {
+			output.InternetGateways = append(output.InternetGateways, gateway)
+		}
+	}
+	fn(output, true)
+	return nil
+}
+
+func (m *mockEC2) DescribeNetworkInterfacesPages(input *ec2.DescribeNetworkInterfacesInput, fn func(*ec2.DescribeNetworkInterfacesOutput, bool) bool) error {
+	output, _ := m.DescribeNetworkInterfaces(input)
+	fn(output, true)
+	return nil
+}
+
+func (m *mockEC2) DescribeNetworkInterfaces(input *ec2.DescribeNetworkInterfacesInput) (*ec2.DescribeNetworkInterfacesOutput, error) {
+	output := &ec2.DescribeNetworkInterfacesOutput{}
+	for _, networkInterface := range m.interfaces {
+		values := map[string][]string{"vpc-id": {*networkInterface.VpcId}}
+		if selected(input.NetworkInterfaceIds, *networkInterface.NetworkInterfaceId) && matches(input.Filters, values, networkInterface.TagSet) {
+			output.NetworkInterfaces = append(output.NetworkInterfaces, networkInterface)
+		}
+	}
+	return output, nil
+}
+
+func (m *mockEC2) DescribeAddresses(input *ec2.DescribeAddressesInput) (*ec2.DescribeAddressesOutput, error) {
+	output := &ec2.DescribeAddressesOutput{}
+	for _, address := range m.addresses {
+		values := map[string][]string{"network-interface-id": {aws.StringValue(address.NetworkInterfaceId)}}
+		if selected(input.AllocationIds, *address.AllocationId) && matches(input.Filters, values, address.Tags) {
+			output.Addresses = append(output.Addresses, address)
+		}
+	}
+	return output, nil
+}
+
+func (m *mockEC2) DescribeSecurityGroupsPages(input *ec2.DescribeSecurityGroupsInput, fn func(*ec2.DescribeSecurityGroupsOutput, bool) bool) error {
+	output, err := m.DescribeSecurityGroups(input)
+	if err != nil {
+		return err
+	}
+	fn(output, true)
+	return nil
+}
+
+// DescribeSecurityGroups fail when a group of the IDs does not exist
+func (m *mockEC2) DescribeSecurityGroups(input *ec2.DescribeSecurityGroupsInput) (*ec2.DescribeSecurityGroupsOutput, error) {
+	for _, id := range aws.StringValueSlice(input.GroupIds) {
+		if m.findGroup(id) == nil {
+			return nil, notFound("InvalidGroup", id)
+		}
+	}
+	output := &ec2.DescribeSecurityGroupsOutput{}
+	for _, group := range m.groups {
+		values := map[string][]string{"vpc-id": {*group.VpcId}, "group-name": {*group.GroupName}}
+		if selected(input.GroupIds, *group.GroupId) && matches(input.Filters, values, group.Tags) {
+			output.SecurityGroups = append(output.SecurityGroups, group)
+		}
+	}
+	return output, nil
+}
+
+func (m *mockEC2) DescribeSubnetsPages(input *ec2.DescribeSubnetsInput, fn func(*ec2.DescribeSubnetsOutput, bool) bool) error {
+	output, _ := m.DescribeSubnets(input)
+	fn(output, true)
+	return nil
+}
+
+func (m *mockEC2) DescribeSubnets(input *ec2.DescribeSubnetsInput) (*ec2.DescribeSubnetsOutput, error) {
+	output := &ec2.DescribeSubnetsOutput{}
+	for _, subnet := range m.subnets {
+		values := map[string][]string{"vpc-id": {*subnet.VpcId}}
+		if selected(input.SubnetIds, *subnet.SubnetId) && matches(input.Filters, values, subnet.Tags) {
+			output.Subnets = append(output.Subnets, subnet)
+		}
+	}
+	return output, nil
+}
+
+func (m *mockEC2) DescribeRouteTablesPages(input *ec2.DescribeRouteTablesInput, fn func(*ec2.DescribeRouteTablesOutput, bool) bool) error {
+	output, _ := m.DescribeRouteTables(input)
+	fn(output, true)
+	return nil
+}
+
+func (m *mockEC2) DescribeRouteTables(input *ec2.DescribeRouteTablesInput) (*ec2.DescribeRouteTablesOutput, error) {
+	output := &ec2.DescribeRouteTablesOutput{}
+	for _, table := range m.routeTables {
+		values := map[string][]string{"vpc-id": {*table.VpcId}}
+		if selected(input.RouteTableIds, *table.RouteTableId) && matches(input.Filters, values, table.Tags) {
+			output.RouteTables = append(output.RouteTables, table)
+		}
+	}
+	return output, nil
+}
+
+func (m *mockEC2) DescribeNetworkAclsPages(input *ec2.DescribeNetworkAclsInput, fn func(*ec2.DescribeNetworkAclsOutput, bool) bool) error {
+	output, _ := m.DescribeNetworkAcls(input)
+	fn(output, true)
+	return nil
+}
+
+func (m *mockEC2) DescribeNetworkAcls(input *ec2.DescribeNetworkAclsInput) (*ec2.DescribeNetworkAclsOutput, error) {
+	output := &ec2.DescribeNetworkAclsOutput{}
+	for _, acl := range m.acls {
+		values := map[string][]string{"vpc-id": {*acl.VpcId}}
+		for _, association := range acl.Associations {
+			values["association.subnet-id"] = append(values["association.subnet-id"], *association.SubnetId)
+		}
+		if selected(input.NetworkAclIds, *acl.NetworkAclId) && matches(input.Filters, values, acl.Tags) {
+			output.NetworkAcls = append(output.NetworkAcls, acl)
+		}
+	}
+	return output, nil
+}
+
+// The mock has none of the other resources of a VPC
+
+func (m *mockEC2) DescribeVpcEndpointsPages(input *ec2.DescribeVpcEndpointsInput, fn func(*ec2.DescribeVpcEndpointsOutput, bool) bool) error {
+	fn(&ec2.DescribeVpcEndpointsOutput{}, true)
+	return nil
+}
+
+func (m *mockEC2) DescribeTransitGatewayVpcAttachmentsPages(input *ec2.DescribeTransitGatewayVpcAttachmentsInput, fn func(*ec2.DescribeTransitGatewayVpcAttachmentsOutput, bool) bool) error {
+	fn(&ec2.DescribeTransitGatewayVpcAttachmentsOutput{}, true)
+	return nil
+}
+
+func (m *mockEC2) DescribeVpcPeeringConnectionsPages(input *ec2.DescribeVpcPeeringConnectionsInput, fn func(*ec2.DescribeVpcPeeringConnectionsOutput, bool) bool) error {
+	fn(&ec2.DescribeVpcPeeringConnectionsOutput{}, true)
+	return nil
+}
+
+func (m *mockEC2) DescribeVpnGateways(input *ec2.DescribeVpnGatewaysInput) (*ec2.DescribeVpnGatewaysOutput, error) {
+	return &ec2.DescribeVpnGatewaysOutput{}, nil
+}
+
+func (m *mockEC2) DescribeEgressOnlyInternetGatewaysPages(input *ec2.DescribeEgressOnlyInternetGatewaysInput, fn func(*ec2.DescribeEgressOnlyInternetGatewaysOutput, bool) bool) error {
+	fn(&ec2.DescribeEgressOnlyInternetGatewaysOutput{}, true)
+	return nil
+}
+
+// TerminateInstances start the shutdown, DescribeInstances complete it
+func (m *mockEC2) TerminateInstances(input *ec2.TerminateInstancesInput) (*ec2.TerminateInstancesOutput, error) {
+	id := *input.InstanceIds[0]
+	if err := m.call("TerminateInstances", id); err != nil {
+		return nil, err
+	}
+	instance := m.findInstance(id)
+	if instance == nil {
+		return nil, notFound("InvalidInstanceID", id)
+	}
+	if alive(instance) {
+		instance.State.Name = aws.String(ec2.InstanceStateNameShuttingDown)
+	}
+	return &ec2.TerminateInstancesOutput{}, nil
+}
+
+// DescribeInstances complete the shutdown of the instance unless it is pending, a terminated instance lose its
+// network interfaces
+func (m *mockEC2) DescribeInstances(input *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+	id := *input.InstanceIds[0]
+	if err := m.call("DescribeInstances", id); err != nil {
+		return nil, err
+	}
+	instance := m.findInstance(id)
+	if instance == nil {
+		return nil, notFound("InvalidInstanceID", id)
+	}
+	if *instance.State.Name == ec2.InstanceStateNameShuttingDown && !m.pending[id] {
+		instance.State.Name = aws.String(ec2.InstanceStateNameTerminated)
+		var kept []*ec2.NetworkInterface
+		for _, networkInterface := range m.interfaces {
+			if attachment := networkInterface.Attachment; attachment == nil || aws.StringValue(attachment.InstanceId) != id {
+				kept = append(kept, networkInterface)
+			}
+		}
+		m.interfaces = kept
+	}
+	return &ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{Instances: []*ec2.Instance{instance}}}}, nil
+}
+
+// DeleteNatGateway delete the NAT gateway, its network interfaces and their Elastic IP associations
+func (m *mockEC2) DeleteNatGateway(input *ec2.DeleteNatGatewayInput) (*ec2.DeleteNatGatewayOutput, error) {
+	id := *input.NatGatewayId
+	if err := m.call("DeleteNatGateway", id); err != nil {
+		return nil, err
+	}
+	for _, natGateway := range m.natGateways {
+		if *natGateway.NatGatewayId != id {
+			continue
+		}
+		natGateway.State = aws.String(ec2.NatGatewayStateDeleted)
+		for _, natAddress := range natGateway.NatGatewayAddresses {
+			m.deleteInterface(*natAddress.NetworkInterfaceId)
+		}
+		return &ec2.DeleteNatGatewayOutput{}, nil
+	}
+	return nil, notFound("NatGateway", id)
+}
+
+// deleteInterface delete the network interface and disassociate its Elastic IPs
+func (m *mockEC2) deleteInterface(id string) bool {
+	for _, address := range m.addresses {
+		if aws.StringValue(address.NetworkInterfaceId) == id {
+			address.AssociationId, address.NetworkInterfaceId = nil, nil
+		}
+	}
+	for i, networkInterface := range m.interfaces {
+		if *networkInterface.NetworkInterfaceId == id {
+			m.interfaces = append(m.interfaces[:i], m.interfaces[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (m *mockEC2) DisassociateAddress(input *ec2.DisassociateAddressInput) (*ec2.DisassociateAddressOutput, error) {
+	id := *input.AssociationId
+	if err := m.call("DisassociateAddress", id); err != nil {
+		return nil, err
+	}
+	for _, address := range m.addresses {
+		if aws.StringValue(address.AssociationId) == id {
+			address.AssociationId, address.NetworkInterfaceId = nil, nil
+			return &ec2.DisassociateAddressOutput{}, nil
+		}
+	}
+	return nil, notFound("InvalidAssociationID", id)
+}
+
+// ReleaseAddress fail while the Elastic IP is associated
+func (m *mockEC2) ReleaseAddress(input *ec2.ReleaseAddressInput) (*ec2.ReleaseAddressOutput, error) {
+	id := *input.AllocationId
+	if err := m.call("ReleaseAddress", id); err != nil {
+		return nil, err
+	}
+	for i, address := range m.addresses {
+		if *address.AllocationId != id {
+			continue
+		}
+		if address.AssociationId != nil {
+			return nil, awserr.New("InvalidIPAddress.InUse", "Address "+id+" is in use", nil)
+		}
+		m.addresses = append(m.addresses[:i], m.addresses[i+1:]...)
+		return &ec2.ReleaseAddressOutput{}, nil
+	}
+	return nil, notFound("InvalidAllocationID", id)
+}
+
+// DetachInternetGateway fail while an instance of the VPC has a public IP
+func (m *mockEC2) DetachInternetGateway(input *ec2.DetachInternetGatewayInput) (*ec2.DetachInternetGatewayOutput, error) {
+	id := *input.InternetGatewayId
+	if err := m.call("DetachInternetGateway", id); err != nil {
+		return nil, err
+	}
+	gateway := m.findGateway(id)
+	if gateway == nil {
+		return nil, notFound("InvalidInternetGatewayID", id)
+	}
+	if !attached(gateway, *input.VpcId) {
+		return nil, awserr.New("Gateway.NotAttached", id+" is not attached to "+*input.VpcId, nil)
+	}
+	for _, instance := range m.instances {
+		if aws.StringValue(instance.VpcId) == *input.VpcId && alive(instance) && instance.PublicIpAddress != nil {
+			return nil, dependencyViolation("Network " + *input.VpcId + " has some mapped public address(es)")
+		}
+	}
+	gateway.Attachments = nil
+	return &ec2.DetachInternetGatewayOutput{}, nil
+}
+
+func (m *mockEC2) DeleteInternetGateway(input *ec2.DeleteInternetGatewayInput) (*ec2.DeleteInternetGatewayOutput, error) {
+	id := *input.InternetGatewayId
+	if err := m.call("DeleteInternetGateway", id); err != nil {
+		return nil, err
+	}
+	for i, gateway := range m.gateways {
+		if *gateway.InternetGatewayId != id {
+			continue
+		}
+		if len(gateway.Attachments) > 0 {
+			return nil, dependencyViolation("The internetGateway '" + id + "' has dependencies and cannot be deleted")
+		}
+		m.gateways = append(m.gateways[:i], m.gateways[i+1:]...)
+		return &ec2.DeleteInternetGatewayOutput{}, nil
+	}
+	return nil, notFound("InvalidInternetGatewayID", id)
+}
+
+func (m *mockEC2) AttachInternetGateway(input *ec2.AttachInternetGatewayInput) (*ec2.AttachInternetGatewayOutput, error) {
+	id := *input.InternetGatewayId
+	if err := m.call("AttachInternetGateway", id); err != nil {
+		return nil, err
+	}
+	gateway := m.findGateway(id)
+	if gateway == nil {
+		return nil, notFound("InvalidInternetGatewayID", id)
+	}
+	if len(gateway.Attachments) > 0 {
+		return nil, awserr.New("Resource.AlreadyAssociated", id+" is already attached", nil)
+	}
+	gateway.Attachments = []*ec2.InternetGatewayAttachment{{VpcId: input.VpcId, State: aws.String("available")}}
+	return &ec2.AttachInternetGatewayOutput{}, nil
+}
+
+func (m *mockEC2) DeleteNetworkInterface(input *ec2.DeleteNetworkInterfaceInput) (*ec2.DeleteNetworkInterfaceOutput, error) {
+	id := *input.NetworkInterfaceId
+	if err := m.call("DeleteNetworkInterface", id); err != nil {
+		return nil, err
+	}
+	if !m.deleteInterface(id) {
+		return nil, notFound("InvalidNetworkInterfaceID", id)
+	}
+	return &ec2.DeleteNetworkInterfaceOutput{}, nil
+}
+
+// RevokeSecurityGroupIngress remove the group references of the permissions
+func (m *mockEC2) RevokeSecurityGroupIngress(input *ec2.RevokeSecurityGroupIngressInput) (*ec2.RevokeSecurityGroupIngressOutput, error) {
+	id := *input.GroupId
+	if err := m.call("RevokeSecurityGroupIngress", id); err != nil {
+		return nil, err
+	}
+	group := m.findGroup(id)
+	if group == nil {
+		return nil, notFound("InvalidGroup", id)
+	}
+	var revoked []string
+	for _, permission := range input.IpPermissions {
+		for _, pair := range permission.UserIdGroupPairs {
+			revoked = append(revoked, *pair.GroupId)
+		}
+	}
+	var kept []*ec2.IpPermission
+	for _, permission := range group.IpPermissions {
+		var pairs []*ec2.UserIdGroupPair
+		for _, pair := range permission.UserIdGroupPairs {
+			if !containsID(revoked, *pair.GroupId) {
+				pairs = append(pairs, pair)
+			}
+		}
+		if len(pairs) > 0 || len(permission.UserIdGroupPairs) == 0 {
+			permission.UserIdGroupPairs = pairs
+			kept = append(kept, permission)
+		}
+	}
+	group.IpPermissions = kept
+	return &ec2.RevokeSecurityGroupIngressOutput{}, nil
+}
+
+func (m *mockEC2) AuthorizeSecurityGroupIngress(input *ec2.AuthorizeSecurityGroupIngressInput) (*ec2.AuthorizeSecurityGroupIngressOutput, error) {
+	id := *input.GroupId
+	if err := m.call("AuthorizeSecurityGroupIngress", id); err != nil {
+		return nil, err
+	}
+	group := m.findGroup(id)
+	if group == nil {
+		return nil, notFound("InvalidGroup", id)
+	}
+	group.IpPermissions = append(group.IpPermissions, input.IpPermissions...)
+	return &ec2.AuthorizeSecurityGroupIngressOutput{}, nil
+}
+
+// DeleteSecurityGroup fail for the default group, a group used by an instance, or referenced by another group
+func (m *mockEC2) DeleteSecurityGroup(input *ec2.DeleteSecurityGroupInput) (*ec2.DeleteSecurityGroupOutput, error) {
+	id := *input.GroupId
+	if err := m.call("DeleteSecurityGroup", id); err != nil {
+		return nil, err
+	}
+	group := m.findGroup(id)
+	if group == nil {
+		return nil, notFound("InvalidGroup", id)
+	}
+	if *group.GroupName == "default" {
+		return nil, awserr.New("CannotDelete", "the specified group: \""+id+"\" name: \"default\" cannot be deleted by a user", nil)
+	}
+	for _, instance := range m.instances {
+		for _, used := range instance.SecurityGroups {
+			if *used.GroupId == id && alive(instance) {
+				return nil, dependencyViolation("resource " + id + " has a dependent object")
+			}
+		}
+	}
+	for _, other := range m.groups {
+		if other != group && referencesGroup(other, id) {
+			return nil, dependencyViolation("resource " + id + " has a dependent object")
+		}
+	}
+	for i := range m.groups {
+		if m.groups[i] == group {
+			m.groups = append(m.groups[:i], m.groups[i+1:]...)
+			break
+		}
+	}
+	return &ec2.DeleteSecurityGroupOutput{}, nil
+}
+
+// DeleteSubnet fail while an instance run in the subnet, the associations of the subnet are removed
+func (m *mockEC2) DeleteSubnet(input *ec2.DeleteSubnetInput) (*ec2.DeleteSubnetOutput, error) {
+	id := *input.SubnetId
+	if err := m.call("DeleteSubnet", id); err != nil {
+		return nil, err
+	}
+	if m.findSubnet(id) == nil {
+		return nil, notFound("InvalidSubnetID", id)
+	}
+	for _, instance := range m.instances {
+		if *instance.SubnetId == id && alive(instance) {
+			return nil, dependencyViolation("The subnet '" + id + "' has dependencies and cannot be deleted")
+		}
+	}
+	for i, subnet := range m.subnets {
+		if *subnet.SubnetId == id {
+			m.subnets = append(m.subnets[:i], m.subnets[i+1:]...)
+			break
+		}
+	}
+	for _, table := range m.routeTables {
+		var kept []*ec2.RouteTableAssociation
+		for _, association := range table.Associations {
+			if aws.StringValue(association.SubnetId) != id {
+				kept = append(kept, association)
+			}
+		}
+		table.Associations = kept
+	}
+	for _, acl := range m.acls {
+		var kept []*ec2.NetworkAclAssociation
+		for _, association := range acl.Associations {
+			if aws.StringValue(association.SubnetId) != id {
+				kept = append(kept, association)
+			}
+		}
+		acl.Associations = kept
+	}
+	return &ec2.DeleteSubnetOutput{}, nil
+}
+
+func (m *mockEC2) DeleteRouteTable(input *ec2.DeleteRouteTableInput) (*ec2.DeleteRouteTableOutput, error) {
+	id := *input.RouteTableId
+	if err := m.call("DeleteRouteTable", id); err != nil {
+		return nil, err
+	}
+	for i, table := range m.routeTables {
+		if *table.RouteTableId == id {
+			m.routeTables = append(m.routeTables[:i], m.routeTables[i+1:]...)
+			return &ec2.DeleteRouteTableOutput{}, nil
+		}
+	}
+	return nil, notFound("InvalidRouteTableID", id)
+}
+
+func (m *mockEC2) DeleteNetworkAcl(input *ec2.DeleteNetworkAclInput) (*ec2.DeleteNetworkAclOutput, error) {
+	id := *input.NetworkAclId
+	if err := m.call("DeleteNetworkAcl", id); err != nil {
+		return nil, err
+	}
+	for i, acl := range m.acls {
+		if *acl.NetworkAclId == id {
+			m.acls = append(m.acls[:i], m.acls[i+1:]...)
+			return &ec2.DeleteNetworkAclOutput{}, nil
+		}
+	}
+	return nil, notFound("InvalidNetworkAclID", id)
+}
+
+// DeleteVpc fail while the VPC has a subnet, an attached internet gateway or a custom security group
+// The default security group, route tables and network ACLs are deleted with the VPC
+func (m *mockEC2) DeleteVpc(input *ec2.DeleteVpcInput) (*ec2.DeleteVpcOutput, error) {
+	id := *input.VpcId
+	if err := m.call("DeleteVpc", id); err != nil {
+		return nil, err
+	}
+	index := -1
+	for i, vpc := range m.vpcs {
+		if *vpc.VpcId == id {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil, notFound("InvalidVpcID", id)
+	}
+	violation := dependencyViolation("The vpc '" + id + "' has dependencies and cannot be deleted")
+	for _, subnet := range m.subnets {
+		if *subnet.VpcId == id {
+			return nil, violation
+		}
+	}
+	for _, gateway := range m.gateways {
+		if attached(gateway, id) {
+			return nil, violation
+		}
+	}
+	var groups []*ec2.SecurityGroup
+	for _, group := range m.groups {
+		if *group.VpcId == id && *group.GroupName != "default" {
+			return nil, violation
+		}
+		if *group.VpcId != id {
+			groups = append(groups, group)
+		}
+	}
+	var tables []*ec2.RouteTable
+	for _, table := range m.routeTables {
+		if *table.VpcId != id {
+			tables = append(tables, table)
+		}
+	}
+	var acls []*ec2.NetworkAcl
+	for _, acl := range m.acls {
+		if *acl.VpcId != id {
+			acls = append(acls, acl)
+		}
+	}
+	m.groups, m.routeTables, m.acls = groups, tables, acls
+	m.vpcs = append(m.vpcs[:index], m.vpcs[index+1:]...)
+	return &ec2.DeleteVpcOutput{}, nil
+}
+
+// mockFactory return the client of the mock EC2 of the region
+type mockFactory struct {
+	regions map[string]*mockEC2
+	err     error
+}
+
+func (f *mockFactory) Client(region string, accountID string) (*Client, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	ec2svc, ok := f.regions[region]
+	if !ok {
+		ec2svc = newMockEC2()
+	}
+	return newClient(ec2svc), nil
+}
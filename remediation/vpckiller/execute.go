@@ -0,0 +1,524 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+// actions run a step by its resource type and action
+var actions = map[string]func(c *Client, plan *Plan, step Step) error{
+	ResourceLambdaFunction + "/" + ActionDetach:            (*Client).detachLambdaFunction,
+	ResourceLoadBalancer + "/" + ActionDelete:              (*Client).deleteLoadBalancer,
+	ResourceLoadBalancer + "/" + ActionWait:                (*Client).waitUntilLoadBalancerDeleted,
+	ResourceClassicLoadBalancer + "/" + ActionDelete:       (*Client).deleteClassicLoadBalancer,
+	ResourceVpcEndpoint + "/" + ActionDelete:               (*Client).deleteVpcEndpoint,
+	ResourceVpcEndpoint + "/" + ActionWait:                 (*Client).waitUntilVpcEndpointDeleted,
+	ResourceTransitGatewayAttachment + "/" + ActionDelete:  (*Client).deleteTransitGatewayAttachment,
+	ResourceTransitGatewayAttachment + "/" + ActionWait:    (*Client).waitUntilTransitGatewayAttachmentDeleted,
+	ResourcePeeringConnection + "/" + ActionDelete:         (*Client).deletePeeringConnection,
+	ResourcePeeringConnection + "/" + ActionWait:           (*Client).waitUntilPeeringConnectionDeleted,
+	ResourceVpnGateway + "/" + ActionDetach:                (*Client).detachVpnGateway,
+	ResourceVpnGateway + "/" + ActionWait:                  (*Client).waitUntilVpnGatewayDetached,
+	ResourceInstance + "/" + ActionTerminate:               (*Client).terminateInstance,
+	ResourceInstance + "/" + ActionWait:                    (*Client).waitUntilInstanceTerminated,
+	ResourceNatGateway + "/" + ActionDelete:                (*Client).deleteNatGateway,
+	ResourceNatGateway + "/" + ActionWait:                  (*Client).waitUntilNatGatewayDeleted,
+	ResourceElasticIP + "/" + ActionDisassociate:           (*Client).disassociateAddress,
+	ResourceElasticIP + "/" + ActionRelease:                (*Client).releaseAddress,
+	ResourceInternetGateway + "/" + ActionDetach:           (*Client).detachInternetGateway,
+	ResourceInternetGateway + "/" + ActionDelete:           (*Client).deleteInternetGateway,
+	ResourceEgressOnlyInternetGateway + "/" + ActionDelete: (*Client).deleteEgressOnlyInternetGateway,
+	ResourceNetworkInterface + "/" + ActionDetach:          (*Client).detachNetworkInterface,
+	ResourceNetworkInterface + "/" + ActionWait:            (*Client).waitUntilNetworkInterfaceDeleted,
+	ResourceNetworkInterface + "/" + ActionDelete:          (*Client).deleteNetworkInterface,
+	ResourceSecurityGroup + "/" + ActionRevoke:             (*Client).revokeSecurityGroupReferences,
+	ResourceSecurityGroup + "/" + ActionDelete:             (*Client).deleteSecurityGroup,
+	ResourceDBSubnetGroup + "/" + ActionDelete:             (*Client).deleteDBSubnetGroup,
+	ResourceSubnet + "/" + ActionDelete:                    (*Client).deleteSubnet,
+	ResourceRouteTable + "/" + ActionDisassociate:          (*Client).disassociateRouteTable,
+	ResourceRouteTable + "/" + ActionDelete:                (*Client).deleteRouteTable,
+	ResourceNetworkACL + "/" + ActionDelete:                (*Client).deleteNetworkACL,
+	ResourceVpc + "/" + ActionDelete:                       (*Client).deleteVpc,
+
+	ResourceQuarantineSecurityGroup + "/" + ActionCreate: (*Client).createQuarantineGroup,
+	ResourceQuarantineSecurityGroup + "/" + ActionDelete: (*Client).deleteSecurityGroup,
+	ResourceQuarantineNetworkACL + "/" + ActionCreate:    (*Client).createQuarantineACL,
+	ResourceQuarantineNetworkACL + "/" + ActionDelete:    (*Client).deleteNetworkACL,
+	ResourceNetworkInterface + "/" + ActionIsolate:       (*Client).isolateNetworkInterface,
+	ResourceSubnet + "/" + ActionIsolate:                 (*Client).isolateSubnet,
+	ResourceElasticIP + "/" + ActionIsolate:              (*Client).isolateAddress,
+	ResourceInternetGateway + "/" + ActionIsolate:        (*Client).isolateInternetGateway,
+	ResourceVpc + "/" + ActionIsolate:                    (*Client).isolateVpc,
+
+	ResourceInternetGateway + "/" + ActionRestore:  (*Client).restoreInternetGateway,
+	ResourceElasticIP + "/" + ActionRestore:        (*Client).restoreAddress,
+	ResourceSubnet + "/" + ActionRestore:           (*Client).restoreSubnet,
+	ResourceNetworkInterface + "/" + ActionRestore: (*Client).restoreNetworkInterface,
+	ResourceVpc + "/" + ActionRestore:              (*Client).restoreVpc,
+
+	ResourceSnapshot + "/" + ActionCreate: (*Client).createSnapshot,
+	ResourceSnapshot + "/" + ActionWait:   (*Client).waitUntilSnapshotCompleted,
+	ResourceImage + "/" + ActionCreate:    (*Client).createImage,
+	ResourceImage + "/" + ActionWait:      (*Client).waitUntilImageAvailable,
+	ResourceManifest + "/" + ActionCreate: (*Client).createManifest,
+}
+
+// execute run the steps of the plan in order and stop at the first failure
+// A step whose resource is pending is run again every waitInterval until the deadline of ctx,
+// the phases of the state machine run the steps once and the state machine retry them
+func (c *Client) execute(ctx context.Context, plan *Plan) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(waitTimeout)
+	}
+	for i, step := range plan.Steps {
+		for {
+			err := c.journaled(plan, i+1, step)
+			if err == nil {
+				break
+			}
+			if !isPending(err) || !sleep(ctx, deadline, waitInterval) {
+				return err
+			}
+		}
+	}
+	fmt.Printf("%d steps of %s are done\n", len(plan.Steps), plan.VpcID)
+	return nil
+}
+
+// run a step of the plan
+func (c *Client) run(plan *Plan, step Step) error {
+	action, ok := actions[step.operation()]
+	if !ok {
+		return errors.Wrapf(errors.Validation, "unknown step: %s %s", step.Action, step.ResourceType)
+	}
+	fmt.Printf("%s %s %s\n", step.Action, step.ResourceType, step.ResourceID)
+	return action(c, plan, step)
+}
+
+func (c *Client) detachLambdaFunction(plan *Plan, step Step) error {
+	_, err := c.lambdasvc.UpdateFunctionConfiguration(&lambda.UpdateFunctionConfigurationInput{
+		FunctionName: aws.String(step.ResourceID),
+		VpcConfig: &lambda.VpcConfig{
+			SubnetIds:        []*string{},
+			SecurityGroupIds: []*string{},
+		},
+	})
+	return awsErrorHandler(err)
+}
+
+func (c *Client) deleteLoadBalancer(plan *Plan, step Step) error {
+	_, err := c.elbv2svc.DeleteLoadBalancer(&elbv2.DeleteLoadBalancerInput{
+		LoadBalancerArn: aws.String(step.ResourceID),
+	})
+	return awsErrorHandler(err)
+}
+
+func (c *Client) waitUntilLoadBalancerDeleted(plan *Plan, step Step) error {
+	return poll("load balancer "+step.ResourceID, func() (bool, error) {
+		output, err := c.elbv2svc.DescribeLoadBalancers(&elbv2.DescribeLoadBalancersInput{
+			LoadBalancerArns: []*string{aws.String(step.ResourceID)},
+		})
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == elbv2.ErrCodeLoadBalancerNotFoundException {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return len(output.LoadBalancers) == 0, nil
+	})
+}
+
+func (c *Client) deleteClassicLoadBalancer(plan *Plan, step Step) error {
+	_, err := c.elbsvc.DeleteLoadBalancer(&elb.DeleteLoadBalancerInput{
+		LoadBalancerName: aws.String(step.ResourceID),
+	})
+	return awsErrorHandler(err)
+}
+
+func (c *Client) deleteVpcEndpoint(plan *Plan, step Step) error {
+	output, err := c.ec2svc.DeleteVpcEndpoints(&ec2.DeleteVpcEndpointsInput{
+		VpcEndpointIds: []*string{aws.String(step.ResourceID)},
+	})
+	if err != nil {
+		return awsErrorHandler(err)
+	}
+	for _, item := range output.Unsuccessful {
+		if item.Error != nil {
+			return errors.Wrapf(errors.Permanent, "delete VPC endpoint %s: %s %s",
+				step.ResourceID, aws.StringValue(item.Error.Code), aws.StringValue(item.Error.Message))
+		}
+	}
+	return nil
+}
+
+func (c *Client) waitUntilVpcEndpointDeleted(plan *Plan, step Step) error {
+	return poll("VPC endpoint "+step.ResourceID, func() (bool, error) {
+		output, err := c.ec2svc.DescribeVpcEndpoints(&ec2.DescribeVpcEndpointsInput{
+			VpcEndpointIds: []*string{aws.String(step.ResourceID)},
+		})
+		if err != nil {
+			return false, err
+		}
+		for _, endpoint := range output.VpcEndpoints {
+			if !isGone(endpoint.State, ec2.StateDeleted) {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+}
+
+func (c *Client) deleteTransitGatewayAttachment(plan *Plan, step Step) error {
+	_, err := c.ec2svc.DeleteTransitGatewayVpcAttachment(&ec2.DeleteTransitGatewayVpcAttachmentInput{
+		TransitGatewayAttachmentId: aws.String(step.ResourceID),
+	})
+	return awsErrorHandler(err)
+}
+
+func (c *Client) waitUntilTransitGatewayAttachmentDeleted(plan *Plan, step Step) error {
+	return poll("transit gateway attachment "+step.ResourceID, func() (bool, error) {
+		output, err := c.ec2svc.DescribeTransitGatewayVpcAttachments(&ec2.DescribeTransitGatewayVpcAttachmentsInput{
+			TransitGatewayAttachmentIds: []*string{aws.String(step.ResourceID)},
+		})
+		if err != nil {
+			return false, err
+		}
+		for _, attachment := range output.TransitGatewayVpcAttachments {
+			if !isGone(attachment.State, ec2.TransitGatewayAttachmentStateDeleted) {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+}
+
+func (c *Client) deletePeeringConnection(plan *Plan, step Step) error {
+	_, err := c.ec2svc.DeleteVpcPeeringConnection(&ec2.DeleteVpcPeeringConnectionInput{
+		VpcPeeringConnectionId: aws.String(step.ResourceID),
+	})
+	return awsErrorHandler(err)
+}
+
+func (c *Client) waitUntilPeeringConnectionDeleted(plan *Plan, step Step) error {
+	return poll("VPC peering connection "+step.ResourceID, func() (bool, error) {
+		output, err := c.ec2svc.DescribeVpcPeeringConnections(&ec2.DescribeVpcPeeringConnectionsInput{
+			VpcPeeringConnectionIds: []*string{aws.String(step.ResourceID)},
+		})
+		if err != nil {
+			return false, err
+		}
+		for _, connection := range output.VpcPeeringConnections {
+			if connection.Status == nil {
+				continue
+			}
+			if !isGone(connection.Status.Code,
+				ec2.VpcPeeringConnectionStateReasonCodeDeleted,
+				ec2.VpcPeeringConnectionStateReasonCodeRejected,
+				ec2.VpcPeeringConnectionStateReasonCodeFailed,
+				ec2.VpcPeeringConnectionStateReasonCodeExpired) {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+}
+
+func (c *Client) detachVpnGateway(plan *Plan, step Step) error {
+	_, err := c.ec2svc.DetachVpnGateway(&ec2.DetachVpnGatewayInput{
+		VpnGatewayId: aws.String(step.ResourceID),
+		VpcId:        aws.String(plan.VpcID),
+	})
+	return awsErrorHandler(err)
+}
+
+func (c *Client) waitUntilVpnGatewayDetached(plan *Plan, step Step) error {
+	return poll("VPN gateway "+step.ResourceID, func() (bool, error) {
+		output, err := c.ec2svc.DescribeVpnGateways(&ec2.DescribeVpnGatewaysInput{
+			VpnGatewayIds: []*string{aws.String(step.ResourceID)},
+		})
+		if err != nil {
+			return false, err
+		}
+		for _, gateway := range output.VpnGateways {
+			for _, attachment := range gateway.VpcAttachments {
+				if aws.StringValue(attachment.VpcId) == plan.VpcID && !isGone(attachment.State, ec2.AttachmentStatusDetached) {
+					return false, nil
+				}
+			}
+		}
+		return true, nil
+	})
+}
+
+func (c *Client) terminateInstance(plan *Plan, step Step) error {
+	_, err := c.ec2svc.TerminateInstances(&ec2.TerminateInstancesInput{
+		InstanceIds: []*string{aws.String(step.ResourceID)},
+	})
+	return awsErrorHandler(err)
+}
+
+func (c *Client) waitUntilInstanceTerminated(plan *Plan, step Step) error {
+	return poll("instance "+step.ResourceID, func() (bool, error) {
+		output, err := c.ec2svc.DescribeInstances(&ec2.DescribeInstancesInput{
+			InstanceIds: []*string{aws.String(step.ResourceID)},
+		})
+		if err != nil {
+			return false, err
+		}
+		for _, reservation := range output.Reservations {
+			for _, instance := range reservation.Instances {
+				if instance.State != nil && !isGone(instance.State.Name, ec2.InstanceStateNameTerminated) {
+					return false, nil
+				}
+			}
+		}
+		return true, nil
+	})
+}
+
+func (c *Client) deleteNatGateway(plan *Plan, step Step) error {
+	_, err := c.ec2svc.DeleteNatGateway(&ec2.DeleteNatGatewayInput{
+		NatGatewayId: aws.String(step.ResourceID),
+	})
+	return awsErrorHandler(err)
+}
+
+func (c *Client) waitUntilNatGatewayDeleted(plan *Plan, step Step) error {
+	return poll("NAT gateway "+step.ResourceID, func() (bool, error) {
+		output, err := c.ec2svc.DescribeNatGateways(&ec2.DescribeNatGatewaysInput{
+			NatGatewayIds: []*string{aws.String(step.ResourceID)},
+		})
+		if err != nil {
+			return false, err
+		}
+		for _, gateway := range output.NatGateways {
+			if !isGone(gateway.State, ec2.NatGatewayStateDeleted, ec2.NatGatewayStateFailed) {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+}
+
+// disassociateAddress disassociate the Elastic IP from its current association
+func (c *Client) disassociateAddress(plan *Plan, step Step) error {
+	output, err := c.ec2svc.DescribeAddresses(&ec2.DescribeAddressesInput{
+		AllocationIds: []*string{aws.String(step.ResourceID)},
+	})
+	if err != nil {
+		return awsErrorHandler(err)
+	}
+	for _, address := range output.Addresses {
+		if address.AssociationId == nil {
+			continue
+		}
+		if _, err := c.ec2svc.DisassociateAddress(&ec2.DisassociateAddressInput{
+			AssociationId: address.AssociationId,
+		}); err != nil {
+			return awsErrorHandler(err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) releaseAddress(plan *Plan, step Step) error {
+	_, err := c.ec2svc.ReleaseAddress(&ec2.ReleaseAddressInput{
+		AllocationId: aws.String(step.ResourceID),
+	})
+	return awsErrorHandler(err)
+}
+
+func (c *Client) detachInternetGateway(plan *Plan, step Step) error {
+	_, err := c.ec2svc.DetachInternetGateway(&ec2.DetachInternetGatewayInput{
+		InternetGatewayId: aws.String(step.ResourceID),
+		VpcId:             aws.String(plan.VpcID),
+	})
+	return awsErrorHandler(err)
+}
+
+func (c *Client) deleteInternetGateway(plan *Plan, step Step) error {
+	_, err := c.ec2svc.DeleteInternetGateway(&ec2.DeleteInternetGatewayInput{
+		InternetGatewayId: aws.String(step.ResourceID),
+	})
+	return awsErrorHandler(err)
+}
+
+func (c *Client) deleteEgressOnlyInternetGateway(plan *Plan, step Step) error {
+	_, err := c.ec2svc.DeleteEgressOnlyInternetGateway(&ec2.DeleteEgressOnlyInternetGatewayInput{
+		EgressOnlyInternetGatewayId: aws.String(step.ResourceID),
+	})
+	return awsErrorHandler(err)
+}
+
+// detachNetworkInterface detach the network interface, the step is pending until the interface is available
+// The rerun of a pending step does not detach the interface again while it is detaching
+func (c *Client) detachNetworkInterface(plan *Plan, step Step) error {
+	return poll("network interface "+step.ResourceID, func() (bool, error) {
+		output, err := c.ec2svc.DescribeNetworkInterfaces(&ec2.DescribeNetworkInterfacesInput{
+			NetworkInterfaceIds: []*string{aws.String(step.ResourceID)},
+		})
+		if err != nil {
+			return false, err
+		}
+		available := true
+		for _, networkInterface := range output.NetworkInterfaces {
+			if aws.StringValue(networkInterface.Status) != ec2.NetworkInterfaceStatusAvailable {
+				available = false
+			}
+			attachment := networkInterface.Attachment
+			if attachment == nil || attachment.AttachmentId == nil ||
+				isGone(attachment.Status, ec2.AttachmentStatusDetaching, ec2.AttachmentStatusDetached) {
+				continue
+			}
+			if _, err := c.ec2svc.DetachNetworkInterface(&ec2.DetachNetworkInterfaceInput{
+				AttachmentId: attachment.AttachmentId,
+				Force:        aws.Bool(true),
+			}); err != nil {
+				return false, err
+			}
+		}
+		return available, nil
+	})
+}
+
+func (c *Client) waitUntilNetworkInterfaceDeleted(plan *Plan, step Step) error {
+	return poll("network interface "+step.ResourceID, func() (bool, error) {
+		output, err := c.ec2svc.DescribeNetworkInterfaces(&ec2.DescribeNetworkInterfacesInput{
+			NetworkInterfaceIds: []*string{aws.String(step.ResourceID)},
+		})
+		if err != nil {
+			return false, err
+		}
+		return len(output.NetworkInterfaces) == 0, nil
+	})
+}
+
+func (c *Client) deleteNetworkInterface(plan *Plan, step Step) error {
+	_, err := c.ec2svc.DeleteNetworkInterface(&ec2.DeleteNetworkInterfaceInput{
+		NetworkInterfaceId: aws.String(step.ResourceID),
+	})
+	return awsErrorHandler(err)
+}
+
+// revokeSecurityGroupReferences revoke the rules of the security group that reference security groups,
+// a security group cannot be deleted while a rule reference it
+func (c *Client) revokeSecurityGroupReferences(plan *Plan, step Step) error {
+	output, err := c.ec2svc.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{
+		GroupIds: []*string{aws.String(step.ResourceID)},
+	})
+	if err != nil {
+		return awsErrorHandler(err)
+	}
+	for _, group := range output.SecurityGroups {
+		if ingress := groupReferences(group.IpPermissions); len(ingress) > 0 {
+			if _, err := c.ec2svc.RevokeSecurityGroupIngress(&ec2.RevokeSecurityGroupIngressInput{
+				GroupId:       group.GroupId,
+				IpPermissions: ingress,
+			}); err != nil {
+				return awsErrorHandler(err)
+			}
+		}
+		if egress := groupReferences(group.IpPermissionsEgress); len(egress) > 0 {
+			if _, err := c.ec2svc.RevokeSecurityGroupEgress(&ec2.RevokeSecurityGroupEgressInput{
+				GroupId:       group.GroupId,
+				IpPermissions: egress,
+			}); err != nil {
+				return awsErrorHandler(err)
+			}
+		}
+	}
+	return nil
+}
+
+// groupReferences return the security group part of the permissions
+func groupReferences(permissions []*ec2.IpPermission) []*ec2.IpPermission {
+	var references []*ec2.IpPermission
+	for _, permission := range permissions {
+		if len(permission.UserIdGroupPairs) == 0 {
+			continue
+		}
+		references = append(references, &ec2.IpPermission{
+			IpProtocol:       permission.IpProtocol,
+			FromPort:         permission.FromPort,
+			ToPort:           permission.ToPort,
+			UserIdGroupPairs: permission.UserIdGroupPairs,
+		})
+	}
+	return references
+}
+
+func (c *Client) deleteSecurityGroup(plan *Plan, step Step) error {
+	_, err := c.ec2svc.DeleteSecurityGroup(&ec2.DeleteSecurityGroupInput{
+		GroupId: aws.String(step.ResourceID),
+	})
+	return awsErrorHandler(err)
+}
+
+func (c *Client) deleteDBSubnetGroup(plan *Plan, step Step) error {
+	_, err := c.rdssvc.DeleteDBSubnetGroup(&rds.DeleteDBSubnetGroupInput{
+		DBSubnetGroupName: aws.String(step.ResourceID),
+	})
+	return awsErrorHandler(err)
+}
+
+func (c *Client) deleteSubnet(plan *Plan, step Step) error {
+	_, err := c.ec2svc.DeleteSubnet(&ec2.DeleteSubnetInput{
+		SubnetId: aws.String(step.ResourceID),
+	})
+	return awsErrorHandler(err)
+}
+
+// disassociateRouteTable remove the remaining associations of the route table
+func (c *Client) disassociateRouteTable(plan *Plan, step Step) error {
+	output, err := c.ec2svc.DescribeRouteTables(&ec2.DescribeRouteTablesInput{
+		RouteTableIds: []*string{aws.String(step.ResourceID)},
+	})
+	if err != nil {
+		return awsErrorHandler(err)
+	}
+	for _, table := range output.RouteTables {
+		for _, association := range table.Associations {
+			if aws.BoolValue(association.Main) {
+				continue
+			}
+			if _, err := c.ec2svc.DisassociateRouteTable(&ec2.DisassociateRouteTableInput{
+				AssociationId: association.RouteTableAssociationId,
+			}); err != nil {
+				return awsErrorHandler(err)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Client) deleteRouteTable(plan *Plan, step Step) error {
+	_, err := c.ec2svc.DeleteRouteTable(&ec2.DeleteRouteTableInput{
+		RouteTableId: aws.String(step.ResourceID),
+	})
+	return awsErrorHandler(err)
+}
+
+func (c *Client) deleteNetworkACL(plan *Plan, step Step) error {
+	_, err := c.ec2svc.DeleteNetworkAcl(&ec2.DeleteNetworkAclInput{
+		NetworkAclId: aws.String(step.ResourceID),
+	})
+	return awsErrorHandler(err)
+}
+
+func (c *Client) deleteVpc(plan *Plan, step Step) error {
+	_, err := c.ec2svc.DeleteVpc(&ec2.DeleteVpcInput{
+		VpcId: aws.String(step.ResourceID),
+	})
+	return awsErrorHandler(err)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/exemption"
+)
+
+// exemptions spare the VPCs of the allowlists and the VPCs with a protected resource
+var exemptions = exemption.New(exemption.DefaultConfig())
+
+// taggedResource is a resource of the VPC and its live tags
+type taggedResource struct {
+	resourceType string
+	id           *string
+	tags         []*ec2.Tag
+}
+
+// exemption re-check the live tags of the VPC and of its resources
+// A VPC with a protected resource cannot be deleted, so the whole VPC is spared
+func (inv *inventory) exemption(vpc *ec2.Vpc) *exemption.Exemption {
+	resources := []taggedResource{{ResourceVpc, vpc.VpcId, vpc.Tags}}
+	for _, instance := range inv.instances {
+		resources = append(resources, taggedResource{ResourceInstance, instance.InstanceId, instance.Tags})
+	}
+	for _, natGateway := range inv.natGateways {
+		resources = append(resources, taggedResource{ResourceNatGateway, natGateway.NatGatewayId, natGateway.Tags})
+	}
+	for _, address := range inv.addresses {
+		resources = append(resources, taggedResource{ResourceElasticIP, address.AllocationId, address.Tags})
+	}
+	for _, gateway := range inv.internetGateways {
+		resources = append(resources, taggedResource{ResourceInternetGateway, gateway.InternetGatewayId, gateway.Tags})
+	}
+	for _, networkInterface := range inv.interfaces {
+		resources = append(resources, taggedResource{ResourceNetworkInterface, networkInterface.NetworkInterfaceId, networkInterface.TagSet})
+	}
+	for _, group := range inv.groups {
+		resources = append(resources, taggedResource{ResourceSecurityGroup, group.GroupId, group.Tags})
+	}
+	for _, subnet := range inv.subnets {
+		resources = append(resources, taggedResource{ResourceSubnet, subnet.SubnetId, subnet.Tags})
+	}
+	for _, resource := range resources {
+		tags := exemption.EC2Tags(resource.tags)
+		if exempt := exemptions.Tags(*vpc.VpcId, resource.resourceType, aws.StringValue(resource.id), tags); exempt != nil {
+			return exempt
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/exemption"
+)
+
+func TestPlanExemption(t *testing.T) {
+	exempt := &ec2.Tag{Key: aws.String(exemption.TagKey), Value: aws.String(exemption.TagValue)}
+	testCases := []struct {
+		remediation string
+		tag         func(m *mockEC2)
+		expected    string
+	}{
+		{remediation: RemediationDelete, tag: func(m *mockEC2) {}},
+		{
+			remediation: RemediationDelete,
+			tag:         func(m *mockEC2) { m.vpcs[0].Tags = []*ec2.Tag{exempt} },
+			expected:    "vpc vpc-1 is tagged prisma-remediation=exempt",
+		},
+		{
+			remediation: RemediationQuarantine,
+			tag:         func(m *mockEC2) { m.instances[1].Tags = []*ec2.Tag{exempt} },
+			expected:    "instance i-2 is tagged prisma-remediation=exempt",
+		},
+		{
+			remediation: RemediationDelete,
+			tag:         func(m *mockEC2) { m.subnets[1].Tags = []*ec2.Tag{exempt} },
+			expected:    "subnet subnet-2 is tagged prisma-remediation=exempt",
+		},
+		{remediation: RemediationRestore, tag: func(m *mockEC2) { m.vpcs[0].Tags = []*ec2.Tag{exempt} }},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s %s", i, tc.remediation, tc.expected), func(t *testing.T) {
+			ec2svc := newMockVpc()
+			tc.tag(ec2svc)
+			plan, err := newClient(ec2svc).plan(tc.remediation, "vpc-1")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.remediation, plan.Remediation)
+			if tc.expected == "" {
+				assert.Nil(t, plan.Exemption)
+				return
+			}
+			assert.Equal(t, &exemption.Exemption{ResourceID: "vpc-1", Reason: tc.expected}, plan.Exemption)
+			assert.Empty(t, plan.Steps)
+		})
+	}
+}
+
+func TestRemediateVpcExemption(t *testing.T) {
+	event := events.AlertEvent{AlertID: "P-1", AccountID: "123456789012", ResourceRegionID: "us-east-1", ResourceID: "vpc-1"}
+	plan, err := remediateVpc(context.Background(), event, RemediationDelete, ModeExecute)
+	assert.NoError(t, err)
+	assert.Equal(t, &Plan{
+		AlertID:     "P-1",
+		AccountID:   "123456789012",
+		Region:      "us-east-1",
+		VpcID:       "vpc-1",
+		Mode:        ModeExecute,
+		Remediation: RemediationDelete,
+		Exemption:   &exemption.Exemption{ResourceID: "vpc-1", Reason: "region us-east-1 is allowlisted"},
+		Steps:       []Step{},
+	}, plan)
+	assert.Contains(t, plan.String(), "skipped, vpc-1 is exempt: region us-east-1 is allowlisted")
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+// inventory is the resources of a VPC that must be removed before the VPC is deleted
+type inventory struct {
+	functions            []*lambda.FunctionConfiguration
+	loadBalancers        []*elbv2.LoadBalancer
+	classicLoadBalancers []*elb.LoadBalancerDescription
+	endpoints            []*ec2.VpcEndpoint
+	attachments          []*ec2.TransitGatewayVpcAttachment
+	peerings             []*ec2.VpcPeeringConnection
+	vpnGateways          []*ec2.VpnGateway
+	instances            []*ec2.Instance
+	natGateways          []*ec2.NatGateway
+	addresses            []*ec2.Address
+	internetGateways     []*ec2.InternetGateway
+	egressOnlyGateways   []*ec2.EgressOnlyInternetGateway
+	interfaces           []*ec2.NetworkInterface
+	groups               []*ec2.SecurityGroup
+	dbSubnetGroups       []*rds.DBSubnetGroup
+	subnets              []*ec2.Subnet
+	routeTables          []*ec2.RouteTable
+	acls                 []*ec2.NetworkAcl
+}
+
+// vpcFilter return the filter of the resources in the VPC
+func vpcFilter(name string, vpcID string) []*ec2.Filter {
+	return []*ec2.Filter{
+		{
+			Name:   aws.String(name),
+			Values: []*string{aws.String(vpcID)},
+		},
+	}
+}
+
+// describe list the resources of the VPC, nothing is modified
+func (c *Client) describe(vpcID string) (*inventory, error) {
+	inv := &inventory{}
+	describers := []struct {
+		name     string
+		describe func(vpcID string) error
+	}{
+		{"Lambda functions", func(vpcID string) error {
+			return c.lambdasvc.ListFunctionsPages(&lambda.ListFunctionsInput{},
+				func(page *lambda.ListFunctionsOutput, lastPage bool) bool {
+					for _, function := range page.Functions {
+						if function.VpcConfig != nil && aws.StringValue(function.VpcConfig.VpcId) == vpcID {
+							inv.functions = append(inv.functions, function)
+						}
+					}
+					return true
+				})
+		}},
+		{"load balancers", func(vpcID string) error {
+			return c.elbv2svc.DescribeLoadBalancersPages(&elbv2.DescribeLoadBalancersInput{},
+				func(page *elbv2.DescribeLoadBalancersOutput, lastPage bool) bool {
+					for _, loadBalancer := range page.LoadBalancers {
+						if aws.StringValue(loadBalancer.VpcId) == vpcID {
+							inv.loadBalancers = append(inv.loadBalancers, loadBalancer)
+						}
+					}
+					return true
+				})
+		}},
+		{"classic load balancers", func(vpcID string) error {
+			return c.elbsvc.DescribeLoadBalancersPages(&elb.DescribeLoadBalancersInput{},
+				func(page *elb.DescribeLoadBalancersOutput, lastPage bool) bool {
+					for _, loadBalancer := range page.LoadBalancerDescriptions {
+						if aws.StringValue(loadBalancer.VPCId) == vpcID {
+							inv.classicLoadBalancers = append(inv.classicLoadBalancers, loadBalancer)
+						}
+					}
+					return true
+				})
+		}},
+		{"VPC endpoints", func(vpcID string) error {
+			return c.ec2svc.DescribeVpcEndpointsPages(&ec2.DescribeVpcEndpointsInput{Filters: vpcFilter("vpc-id", vpcID)},
+				func(page *ec2.DescribeVpcEndpointsOutput, lastPage bool) bool {
+					for _, endpoint := range page.VpcEndpoints {
+						if !isGone(endpoint.State, ec2.StateDeleted) {
+							inv.endpoints = append(inv.endpoints, endpoint)
+						}
+					}
+					return true
+				})
+		}},
+		{"transit gateway attachments", func(vpcID string) error {
+			return c.ec2svc.DescribeTransitGatewayVpcAttachmentsPages(&ec2.DescribeTransitGatewayVpcAttachmentsInput{Filters: vpcFilter("vpc-id", vpcID)},
+				func(page *ec2.DescribeTransitGatewayVpcAttachmentsOutput, lastPage bool) bool {
+					for _, attachment := range page.TransitGatewayVpcAttachments {
+						if !isGone(attachment.State, ec2.TransitGatewayAttachmentStateDeleted, ec2.TransitGatewayAttachmentStateFailed, ec2.TransitGatewayAttachmentStateRejected) {
+							inv.attachments = append(inv.attachments, attachment)
+						}
+					}
+					return true
+				})
+		}},
+		{"VPC peering connections", func(vpcID string) error {
+			seen := map[string]bool{}
+			for _, side := range []string{"requester-vpc-info.vpc-id", "accepter-vpc-info.vpc-id"} {
+				err := c.ec2svc.DescribeVpcPeeringConnectionsPages(&ec2.DescribeVpcPeeringConnectionsInput{Filters: vpcFilter(side, vpcID)},
+					func(page *ec2.DescribeVpcPeeringConnectionsOutput, lastPage bool) bool {
+						for _, peering := range page.VpcPeeringConnections {
+							if peering.Status != nil && isGone(peering.Status.Code, ec2.VpcPeeringConnectionStateReasonCodeDeleted,
+								ec2.VpcPeeringConnectionStateReasonCodeDeleting, ec2.VpcPeeringConnectionStateReasonCodeRejected,
+								ec2.VpcPeeringConnectionStateReasonCodeFailed, ec2.VpcPeeringConnectionStateReasonCodeExpired) {
+								continue
+							}
+							if !seen[*peering.VpcPeeringConnectionId] {
+								seen[*peering.VpcPeeringConnectionId] = true
+								inv.peerings = append(inv.peerings, peering)
+							}
+						}
+						return true
+					})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{"VPN gateways", func(vpcID string) error {
+			output, err := c.ec2svc.DescribeVpnGateways(&ec2.DescribeVpnGatewaysInput{Filters: vpcFilter("attachment.vpc-id", vpcID)})
+			if err != nil {
+				return err
+			}
+			for _, gateway := range output.VpnGateways {
+				for _, attachment := range gateway.VpcAttachments {
+					if aws.StringValue(attachment.VpcId) == vpcID && !isGone(attachment.State, ec2.AttachmentStatusDetached) {
+						inv.vpnGateways = append(inv.vpnGateways, gateway)
+						break
+					}
+				}
+			}
+			return nil
+		}},
+		{"instances", func(vpcID string) error {
+			return c.ec2svc.DescribeInstancesPages(&ec2.DescribeInstancesInput{Filters: vpcFilter("vpc-id", vpcID)},
+				func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
+					for _, reservation := range page.Reservations {
+						for _, instance := range reservation.Instances {
+							if instance.State == nil || !isGone(instance.State.Name, ec2.InstanceStateNameTerminated) {
+								inv.instances = append(inv.instances, instance)
+							}
+						}
+					}
+					return true
+				})
+		}},
+		{"NAT gateways", func(vpcID string) error {
+			return c.ec2svc.DescribeNatGatewaysPages(&ec2.DescribeNatGatewaysInput{Filter: vpcFilter("vpc-id", vpcID)},
+				func(page *ec2.DescribeNatGatewaysOutput, lastPage bool) bool {
+					for _, gateway := range page.NatGateways {
+						if !isGone(gateway.State, ec2.NatGatewayStateDeleted, ec2.NatGatewayStateFailed) {
+							inv.natGateways = append(inv.natGateways, gateway)
+						}
+					}
+					return true
+				})
+		}},
+		{"internet gateways", func(vpcID string) error {
+			return c.ec2svc.DescribeInternetGatewaysPages(&ec2.DescribeInternetGatewaysInput{Filters: vpcFilter("attachment.vpc-id", vpcID)},
+				func(page *ec2.DescribeInternetGatewaysOutput, lastPage bool) bool {
+					inv.internetGateways = append(inv.internetGateways, page.InternetGateways...)
+					return true
+				})
+		}},
+		{"egress-only internet gateways", func(vpcID string) error {
+			return c.ec2svc.DescribeEgressOnlyInternetGatewaysPages(&ec2.DescribeEgressOnlyInternetGatewaysInput{},
+				func(page *ec2.DescribeEgressOnlyInternetGatewaysOutput, lastPage bool) bool {
+					for _, gateway := range page.EgressOnlyInternetGateways {
+						for _, attachment := range gateway.Attachments {
+							if aws.StringValue(attachment.VpcId) == vpcID {
+								inv.egressOnlyGateways = append(inv.egressOnlyGateways, gateway)
+								break
+							}
+						}
+					}
+					return true
+				})
+		}},
+		{"network interfaces", func(vpcID string) error {
+			return c.ec2svc.DescribeNetworkInterfacesPages(&ec2.DescribeNetworkInterfacesInput{Filters: vpcFilter("vpc-id", vpcID)},
+				func(page *ec2.DescribeNetworkInterfacesOutput, lastPage bool) bool {
+					inv.interfaces = append(inv.interfaces, page.NetworkInterfaces...)
+					return true
+				})
+		}},
+		{"Elastic IPs", func(vpcID string) error {
+			// addresses do not have a VPC, they are found by the network interfaces of the VPC
+			if len(inv.interfaces) == 0 {
+				return nil
+			}
+			var interfaceIDs []*string
+			for _, networkInterface := range inv.interfaces {
+				interfaceIDs = append(interfaceIDs, networkInterface.NetworkInterfaceId)
+			}
+			output, err := c.ec2svc.DescribeAddresses(&ec2.DescribeAddressesInput{
+				Filters: []*ec2.Filter{{Name: aws.String("network-interface-id"), Values: interfaceIDs}},
+			})
+			if err != nil {
+				return err
+			}
+			inv.addresses = output.Addresses
+			return nil
+		}},
+		{"security groups", func(vpcID string) error {
+			return c.ec2svc.DescribeSecurityGroupsPages(&ec2.DescribeSecurityGroupsInput{Filters: vpcFilter("vpc-id", vpcID)},
+				func(page *ec2.DescribeSecurityGroupsOutput, lastPage bool) bool {
+					inv.groups = append(inv.groups, page.SecurityGroups...)
+					return true
+				})
+		}},
+		{"DB subnet groups", func(vpcID string) error {
+			return c.rdssvc.DescribeDBSubnetGroupsPages(&rds.DescribeDBSubnetGroupsInput{},
+				func(page *rds.DescribeDBSubnetGroupsOutput, lastPage bool) bool {
+					for _, group := range page.DBSubnetGroups {
+						if aws.StringValue(group.VpcId) == vpcID {
+							inv.dbSubnetGroups = append(inv.dbSubnetGroups, group)
+						}
+					}
+					return true
+				})
+		}},
+		{"subnets", func(vpcID string) error {
+			return c.ec2svc.DescribeSubnetsPages(&ec2.DescribeSubnetsInput{Filters: vpcFilter("vpc-id", vpcID)},
+				func(page *ec2.DescribeSubnetsOutput, lastPage bool) bool {
+					inv.subnets = append(inv.subnets, page.Subnets...)
+					return true
+				})
+		}},
+		{"route tables", func(vpcID string) error {
+			return c.ec2svc.DescribeRouteTablesPages(&ec2.DescribeRouteTablesInput{Filters: vpcFilter("vpc-id", vpcID)},
+				func(page *ec2.DescribeRouteTablesOutput, lastPage bool) bool {
+					inv.routeTables = append(inv.routeTables, page.RouteTables...)
+					return true
+				})
+		}},
+		{"network ACLs", func(vpcID string) error {
+			return c.ec2svc.DescribeNetworkAclsPages(&ec2.DescribeNetworkAclsInput{Filters: vpcFilter("vpc-id", vpcID)},
+				func(page *ec2.DescribeNetworkAclsOutput, lastPage bool) bool {
+					inv.acls = append(inv.acls, page.NetworkAcls...)
+					return true
+				})
+		}},
+	}
+
+	for _, describer := range describers {
+		fmt.Printf("Searching %s\n", describer.name)
+		if err := describer.describe(vpcID); err != nil {
+			return nil, awsErrorHandler(err)
+		}
+	}
+	return inv, nil
+}
+
+// isGone return true when the state is one of the states of a removed resource
+func isGone(state *string, goneStates ...string) bool {
+	for _, gone := range goneStates {
+		if aws.StringValue(state) == gone {
+			return true
+		}
+	}
+	return false
+}
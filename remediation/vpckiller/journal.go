@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/journal"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// ActionUndo prefix the actions of a rollback plan, such as undo-detach
+const ActionUndo = "undo"
+
+// journals record the executed steps, it is set at cold start, nil disable the journal
+var journals journal.Store
+
+// compensation undo a done step
+type compensation struct {
+	// capture return what the undo need, it runs before the step
+	capture func(c *Client, plan *Plan, step Step) (interface{}, error)
+	undo    func(c *Client, plan *Plan, step Step, data json.RawMessage) error
+}
+
+// compensations undo the steps that detach, disassociate or revoke
+// A deleted resource cannot be undone, its backup is the way back
+var compensations = map[string]compensation{
+	ResourceInternetGateway + "/" + ActionDetach:  {undo: (*Client).attachInternetGateway},
+	ResourceElasticIP + "/" + ActionDisassociate:  {capture: (*Client).captureAddressAssociation, undo: (*Client).associateAddress},
+	ResourceSecurityGroup + "/" + ActionRevoke:    {capture: (*Client).captureGroupReferences, undo: (*Client).authorizeGroupReferences},
+	ResourceRouteTable + "/" + ActionDisassociate: {capture: (*Client).captureRouteTableAssociations, undo: (*Client).associateRouteTable},
+}
+
+// journalID return the ID of the journal of the remediation of the VPC, the VPCs of a batch share its alert ID
+// A restore use the alert ID of the quarantine it undo, each remediation of the alert has its journal
+func journalID(alertID string, remediation string, vpcID string) string {
+	return alertID + "/" + remediation + "/" + vpcID
+}
+
+// openJournal read the journal of the remediation of the VPC, nil is returned when the journal is disabled
+func openJournal(alertID string, remediation string, vpcID string) (*journal.Journal, error) {
+	if journals == nil {
+		return nil, nil
+	}
+	return journal.Open(context.Background(), journals, journalID(alertID, remediation, vpcID))
+}
+
+// resume return the plan recorded in the journal, a rerun of an alert continue from its last done step
+// Without a recorded plan, when the journal is closed or when every step of its plan is done, the remediation is planned
+func (c *Client) resume(record *journal.Journal, remediation string, vpcID string) (*Plan, error) {
+	if record != nil {
+		recorded := &Plan{}
+		ok, err := record.Plan(recorded)
+		if err != nil {
+			return nil, err
+		}
+		if ok && (record.Closed() || completed(record, recorded)) {
+			// the alert was resolved then opened again, or the VPC is remediated again, such as a second restore
+			fmt.Printf("Journal %s is over, plan again\n", record.ID())
+			if err := record.Restart(context.Background()); err != nil {
+				return nil, err
+			}
+			ok = false
+		}
+		if ok {
+			fmt.Printf("Resume the plan of journal %s\n", record.ID())
+			recorded.journal = record
+			return recorded, nil
+		}
+	}
+	plan, err := c.plan(remediation, vpcID)
+	if err != nil {
+		return nil, err
+	}
+	plan.journal = record
+	return plan, nil
+}
+
+// completed return true when every step of the recorded plan is done
+func completed(record *journal.Journal, plan *Plan) bool {
+	for i := range plan.Steps {
+		if !record.Done(i + 1) {
+			return false
+		}
+	}
+	return true
+}
+
+// journaled run the step, its intent and its result are recorded in the journal of the plan
+// A done step is skipped, a step interrupted by the end of the function is run again
+func (c *Client) journaled(plan *Plan, seq int, step Step) error {
+	record := plan.journal
+	if record == nil {
+		return c.run(plan, step)
+	}
+	if record.Done(seq) {
+		fmt.Printf("%s %s %s is done\n", step.Action, step.ResourceType, step.ResourceID)
+		return nil
+	}
+	ctx := context.Background()
+	interrupted := record.Started(seq)
+	var undo interface{}
+	// the resource of an interrupted step may be changed already, its recorded undo is kept
+	if compensation, ok := compensations[step.operation()]; ok && compensation.capture != nil && !interrupted {
+		var err error
+		if undo, err = compensation.capture(c, plan, step); err != nil {
+			return err
+		}
+	}
+	if err := record.Start(ctx, seq, step.operation(), step.ResourceID, undo); err != nil {
+		return err
+	}
+	backups := len(plan.Backups)
+	err := c.run(plan, step)
+	if interrupted && errors.Is(err, errors.NotFound) {
+		fmt.Printf("%s %s %s was done before the interruption\n", step.Action, step.ResourceType, step.ResourceID)
+		err = nil
+	}
+	// the backups of the steps are recorded with the plan, the resumed steps need them
+	if err == nil && len(plan.Backups) != backups {
+		if err := record.Record(ctx, plan); err != nil {
+			return err
+		}
+	}
+	if recordErr := record.Finish(ctx, seq, err); recordErr != nil && err == nil {
+		return recordErr
+	}
+	return err
+}
+
+// rollbackVpc undo the done steps of the journal of the alert in reverse order,
+// the steps without compensation are skipped
+func rollbackVpc(event events.AlertEvent, mode string) (*Plan, error) {
+	if journals == nil {
+		return nil, errors.Wrapf(errors.Validation, "rollback need a journal, %s or %s is not set", journal.TableEnv, journal.DirEnv)
+	}
+	record, recorded, err := rollbackJournal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{
+		AlertID:     recorded.AlertID,
+		AccountID:   recorded.AccountID,
+		Region:      recorded.Region,
+		VpcID:       recorded.VpcID,
+		Mode:        mode,
+		Remediation: RemediationRollback,
+		Steps:       []Step{},
+	}
+	var undos []journal.Entry
+	for _, entry := range record.Completed() {
+		if entry.Seq > len(recorded.Steps) {
+			continue
+		}
+		step := recorded.Steps[entry.Seq-1]
+		if _, ok := compensations[step.operation()]; !ok {
+			fmt.Printf("%s %s %s cannot be undone\n", step.Action, step.ResourceType, step.ResourceID)
+			continue
+		}
+		plan.add(step.ResourceType, step.ResourceID, ActionUndo+"-"+step.Action)
+		undos = append(undos, entry)
+	}
+	fmt.Print(plan.String())
+	if mode == ModePlan {
+		return plan, nil
+	}
+
+	client, err := clients.Client(plan.Region, plan.AccountID)
+	if err != nil {
+		return plan, err
+	}
+	for _, entry := range undos {
+		step := recorded.Steps[entry.Seq-1]
+		fmt.Printf("%s-%s %s %s\n", ActionUndo, step.Action, step.ResourceType, step.ResourceID)
+		err := compensations[step.operation()].undo(client, recorded, step, entry.Data)
+		if errors.Is(err, errors.NotFound) {
+			// a later step deleted the resource
+			fmt.Printf("%s %s is gone, %s cannot be undone\n", step.ResourceType, step.ResourceID, step.Action)
+			continue
+		}
+		if err != nil {
+			return plan, err
+		}
+		if err := record.Undone(context.Background(), entry.Seq); err != nil {
+			return plan, err
+		}
+	}
+	fmt.Printf("%d steps of %s are undone\n", len(undos), plan.VpcID)
+	return plan, nil
+}
+
+// rollbackJournal return the journal of the delete or the quarantine of the alert and its recorded plan
+// An alert with both a delete and a quarantine journal cannot be rolled back
+func rollbackJournal(event events.AlertEvent) (*journal.Journal, *Plan, error) {
+	var found *journal.Journal
+	var recorded *Plan
+	for _, remediation := range []string{RemediationDelete, RemediationQuarantine} {
+		record, err := openJournal(event.AlertID, remediation, event.ResourceID)
+		if err != nil {
+			return nil, nil, err
+		}
+		plan := &Plan{}
+		ok, err := record.Plan(plan)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			continue
+		}
+		if found != nil {
+			return nil, nil, errors.Wrapf(errors.Validation, "alert %s has the journals %s and %s, the rollback cannot tell which one to undo",
+				event.AlertID, found.ID(), record.ID())
+		}
+		found, recorded = record, plan
+	}
+	if found == nil {
+		return nil, nil, errors.Wrapf(errors.NotFound, "journal of alert %s on %s not found", event.AlertID, event.ResourceID)
+	}
+	return found, recorded, nil
+}
+
+func (c *Client) attachInternetGateway(plan *Plan, step Step, data json.RawMessage) error {
+	_, err := c.ec2svc.AttachInternetGateway(&ec2.AttachInternetGatewayInput{
+		InternetGatewayId: aws.String(step.ResourceID),
+		VpcId:             aws.String(plan.VpcID),
+	})
+	return awsErrorHandler(err)
+}
+
+// addressAssociation is the network interface an Elastic IP was associated with
+type addressAssociation struct {
+	NetworkInterfaceID string `json:"networkInterfaceId"`
+	PrivateIPAddress   string `json:"privateIpAddress,omitempty"`
+}
+
+func (c *Client) captureAddressAssociation(plan *Plan, step Step) (interface{}, error) {
+	output, err := c.ec2svc.DescribeAddresses(&ec2.DescribeAddressesInput{
+		AllocationIds: []*string{aws.String(step.ResourceID)},
+	})
+	if err != nil {
+		return nil, awsErrorHandler(err)
+	}
+	for _, address := range output.Addresses {
+		if address.NetworkInterfaceId != nil {
+			return &addressAssociation{
+				NetworkInterfaceID: *address.NetworkInterfaceId,
+				PrivateIPAddress:   aws.StringValue(address.PrivateIpAddress),
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *Client) associateAddress(plan *Plan, step Step, data json.RawMessage) error {
+	association := &addressAssociation{}
+	if err := json.Unmarshal(data, association); err != nil || association.NetworkInterfaceID == "" {
+		return nil
+	}
+	input := &ec2.AssociateAddressInput{
+		AllocationId:       aws.String(step.ResourceID),
+		NetworkInterfaceId: aws.String(association.NetworkInterfaceID),
+	}
+	if association.PrivateIPAddress != "" {
+		input.PrivateIpAddress = aws.String(association.PrivateIPAddress)
+	}
+	_, err := c.ec2svc.AssociateAddress(input)
+	return awsErrorHandler(err)
+}
+
+// groupRules is the rules of a security group that reference security groups
+type groupRules struct {
+	Ingress []*ec2.IpPermission `json:"ingress,omitempty"`
+	Egress  []*ec2.IpPermission `json:"egress,omitempty"`
+}
+
+func (c *Client) captureGroupReferences(plan *Plan, step Step) (interface{}, error) {
+	output, err := c.ec2svc.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{
+		GroupIds: []*string{aws.String(step.ResourceID)},
+	})
+	if err != nil {
+		return nil, awsErrorHandler(err)
+	}
+	rules := &groupRules{}
+	for _, group := range output.SecurityGroups {
+		rules.Ingress = append(rules.Ingress, groupReferences(group.IpPermissions)...)
+		rules.Egress = append(rules.Egress, groupReferences(group.IpPermissionsEgress)...)
+	}
+	return rules, nil
+}
+
+func (c *Client) authorizeGroupReferences(plan *Plan, step Step, data json.RawMessage) error {
+	rules := &groupRules{}
+	if err := json.Unmarshal(data, rules); err != nil {
+		return errors.Wrap(errors.Permanent, err)
+	}
+	if len(rules.Ingress) > 0 {
+		if _, err := c.ec2svc.AuthorizeSecurityGroupIngress(&ec2.AuthorizeSecurityGroupIngressInput{
+			GroupId:       aws.String(step.ResourceID),
+			IpPermissions: rules.Ingress,
+		}); err != nil {
+			return awsErrorHandler(err)
+		}
+	}
+	if len(rules.Egress) > 0 {
+		if _, err := c.ec2svc.AuthorizeSecurityGroupEgress(&ec2.AuthorizeSecurityGroupEgressInput{
+			GroupId:       aws.String(step.ResourceID),
+			IpPermissions: rules.Egress,
+		}); err != nil {
+			return awsErrorHandler(err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) captureRouteTableAssociations(plan *Plan, step Step) (interface{}, error) {
+	output, err := c.ec2svc.DescribeRouteTables(&ec2.DescribeRouteTablesInput{
+		RouteTableIds: []*string{aws.String(step.ResourceID)},
+	})
+	if err != nil {
+		return nil, awsErrorHandler(err)
+	}
+	subnetIDs := []string{}
+	for _, table := range output.RouteTables {
+		for _, association := range table.Associations {
+			if !aws.BoolValue(association.Main) && association.SubnetId != nil {
+				subnetIDs = append(subnetIDs, *association.SubnetId)
+			}
+		}
+	}
+	return subnetIDs, nil
+}
+
+func (c *Client) associateRouteTable(plan *Plan, step Step, data json.RawMessage) error {
+	var subnetIDs []string
+	if err := json.Unmarshal(data, &subnetIDs); err != nil {
+		return errors.Wrap(errors.Permanent, err)
+	}
+	for _, subnetID := range subnetIDs {
+		if _, err := c.ec2svc.AssociateRouteTable(&ec2.AssociateRouteTableInput{
+			RouteTableId: aws.String(step.ResourceID),
+			SubnetId:     aws.String(subnetID),
+		}); err != nil {
+			return awsErrorHandler(err)
+		}
+	}
+	return nil
+}
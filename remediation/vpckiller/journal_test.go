@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/journal"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/assert"
+)
+
+// interruptedStore lose the result of a step, like a function that stop while the step run
+type interruptedStore struct {
+	journal.Store
+	seq int
+}
+
+func (s *interruptedStore) Put(ctx context.Context, entry journal.Entry) error {
+	if entry.Seq == s.seq && entry.Status == journal.StatusDone {
+		return fmt.Errorf("task timed out")
+	}
+	return s.Store.Put(ctx, entry)
+}
+
+// withJournal set the journal and the clients of the mock EC2 in us-west-2 for the test
+func withJournal(t *testing.T, ec2svc *mockEC2) func() {
+	dir, err := ioutil.TempDir("", "journal")
+	assert.NoError(t, err)
+	journals = journal.NewDirStore(dir)
+	clients = &mockFactory{regions: map[string]*mockEC2{"us-west-2": ec2svc}}
+	return func() {
+		journals, clients = nil, nil
+		os.RemoveAll(dir)
+	}
+}
+
+var journalEvent = events.AlertEvent{AlertID: "P-1", AccountID: "123456789012", ResourceRegionID: "us-west-2", ResourceID: "vpc-1"}
+
+func TestJournalResume(t *testing.T) {
+	testCases := []struct {
+		name    string
+		failure string
+	}{
+		{name: "first step", failure: "TerminateInstances i-1"},
+		{name: "wait", failure: "DescribeInstances i-2"},
+		{name: "security group", failure: "DeleteSecurityGroup sg-db"},
+		{name: "last step", failure: "DeleteVpc vpc-1"},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, tc.name), func(t *testing.T) {
+			ec2svc := newTwoTierVpc()
+			defer withJournal(t, ec2svc)()
+			ec2svc.failures[tc.failure] = awserr.New("DependencyViolation", "has dependencies", nil)
+			_, err := remediateVpc(context.Background(), journalEvent, RemediationDelete, ModeExecute)
+			assert.Equal(t, errors.Retryable, errors.CategoryOf(err))
+			failed := len(ec2svc.mutations()) - 1
+			assert.Equal(t, tc.failure, twoTierTeardown[failed])
+
+			// the rerun of the alert start from the failed step
+			delete(ec2svc.failures, tc.failure)
+			ec2svc.calls = nil
+			plan, err := remediateVpc(context.Background(), journalEvent, RemediationDelete, ModeExecute)
+			assert.NoError(t, err)
+			assert.Len(t, plan.Steps, len(twoTierTeardown))
+			assert.Equal(t, twoTierTeardown[failed:], ec2svc.mutations())
+			assert.Empty(t, ec2svc.resources("vpc-1"))
+
+			// a remediated alert delivered again is planned again, its VPC is gone
+			ec2svc.calls = nil
+			_, err = remediateVpc(context.Background(), journalEvent, RemediationDelete, ModeExecute)
+			assert.Equal(t, errors.NotFound, errors.CategoryOf(err))
+			assert.Empty(t, ec2svc.mutations())
+		})
+	}
+}
+
+func TestJournalInterrupted(t *testing.T) {
+	ec2svc := newTwoTierVpc()
+	defer withJournal(t, ec2svc)()
+	// the subnet-1 is deleted, but the function stop before its result is recorded
+	journals = &interruptedStore{Store: journals, seq: 11}
+	_, err := remediateVpc(context.Background(), journalEvent, RemediationDelete, ModeExecute)
+	assert.Equal(t, errors.Retryable, errors.CategoryOf(err))
+	assert.Equal(t, twoTierTeardown[:11], ec2svc.mutations())
+
+	journals = journals.(*interruptedStore).Store
+	ec2svc.calls = nil
+	_, err = remediateVpc(context.Background(), journalEvent, RemediationDelete, ModeExecute)
+	assert.NoError(t, err)
+	assert.Equal(t, twoTierTeardown[10:], ec2svc.mutations())
+	assert.Empty(t, ec2svc.resources("vpc-1"))
+}
+
+func TestJournalRemediation(t *testing.T) {
+	ec2svc := newMockVpc()
+	defer withJournal(t, ec2svc)()
+	_, err := remediateVpc(context.Background(), journalEvent, RemediationQuarantine, ModeExecute)
+	assert.NoError(t, err)
+	quarantine := ec2svc.mutations()
+
+	// the restore use the alert ID of the quarantine, the remediations have their journals
+	ec2svc.calls = nil
+	plan, err := remediateVpc(context.Background(), journalEvent, RemediationRestore, ModeExecute)
+	assert.NoError(t, err)
+	assert.Equal(t, "P-1/restore/vpc-1", plan.journal.ID())
+	restore := ec2svc.mutations()
+	assert.NotEmpty(t, restore)
+
+	// the done plans are planned again, the VPC is quarantined and restored a second time
+	ec2svc.calls = nil
+	_, err = remediateVpc(context.Background(), journalEvent, RemediationQuarantine, ModeExecute)
+	assert.NoError(t, err)
+	assert.Equal(t, quarantine, ec2svc.mutations())
+
+	ec2svc.calls = nil
+	_, err = remediateVpc(context.Background(), journalEvent, RemediationRestore, ModeExecute)
+	assert.NoError(t, err)
+	assert.Equal(t, restore, ec2svc.mutations())
+}
+
+func TestRollback(t *testing.T) {
+	ec2svc := newTwoTierVpc()
+	defer withJournal(t, ec2svc)()
+	ec2svc.failures["DeleteSecurityGroup sg-db"] = awserr.New("DependencyViolation", "has dependencies", nil)
+	_, err := remediateVpc(context.Background(), journalEvent, RemediationDelete, ModeExecute)
+	assert.Error(t, err)
+	delete(ec2svc.failures, "DeleteSecurityGroup sg-db")
+
+	event := events.AlertEvent{AlertID: "P-1", ResourceID: "vpc-1"}
+	plan, err := remediateVpc(context.Background(), event, RemediationRollback, ModePlan)
+	assert.NoError(t, err)
+	assert.Equal(t, "123456789012", plan.AccountID)
+	assert.Equal(t, "us-west-2", plan.Region)
+	assert.Equal(t, []string{
+		"undo-revoke security-group sg-web",
+		"undo-revoke security-group sg-db",
+		"undo-detach internet-gateway igw-1",
+	}, stepLines(plan))
+
+	ec2svc.calls = nil
+	_, err = remediateVpc(context.Background(), event, RemediationRollback, ModeExecute)
+	assert.NoError(t, err)
+	// the internet gateway was deleted after its detach, it cannot be attached again
+	assert.Equal(t, []string{
+		"AuthorizeSecurityGroupIngress sg-web",
+		"AuthorizeSecurityGroupIngress sg-db",
+		"AttachInternetGateway igw-1",
+	}, ec2svc.mutations())
+	assert.True(t, referencesGroup(ec2svc.findGroup("sg-web"), "sg-db"))
+	assert.True(t, referencesGroup(ec2svc.findGroup("sg-db"), "sg-web"))
+
+	// the undone steps are done again by the next run
+	ec2svc.calls = nil
+	_, err = remediateVpc(context.Background(), journalEvent, RemediationDelete, ModeExecute)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"RevokeSecurityGroupIngress sg-db",
+		"RevokeSecurityGroupIngress sg-web",
+		"DeleteSecurityGroup sg-db",
+		"DeleteSecurityGroup sg-web",
+		"DeleteSubnet subnet-1",
+		"DeleteSubnet subnet-2",
+		"DeleteVpc vpc-1",
+	}, ec2svc.mutations())
+	assert.Empty(t, ec2svc.resources("vpc-1"))
+}
+
+func TestRollbackFailure(t *testing.T) {
+	event := events.AlertEvent{AlertID: "P-1", ResourceID: "vpc-1"}
+	_, err := remediateVpc(context.Background(), event, RemediationRollback, ModeExecute)
+	assert.Equal(t, errors.Validation, errors.CategoryOf(err))
+
+	defer withJournal(t, newTwoTierVpc())()
+	_, err = remediateVpc(context.Background(), event, RemediationRollback, ModeExecute)
+	assert.Equal(t, errors.NotFound, errors.CategoryOf(err))
+}
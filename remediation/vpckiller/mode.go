@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+)
+
+// ModeEnv select the mode of the invocations that do not set one, execute by default
+const ModeEnv = "VPCKILLER_MODE"
+
+// Modes of the VPC killer, plan return the deletion plan without deleting anything
+const (
+	ModePlan    = "plan"
+	ModeExecute = "execute"
+)
+
+// defaultMode is read from VPCKILLER_MODE at cold start
+var defaultMode = ModeExecute
+
+// Request is the alert with the mode and the remediation of the invocation
+//
+//	{"mode": "plan", "remediation": "quarantine", "alertId": "P-39425", "resourceId": "vpc-0a1b2c3d", ...}
+//
+// A request with a batch remediate the VPCs of the batch instead of the VPC of the alert,
+// a request with a phase run the phase of the state machine on its state
+type Request struct {
+	events.AlertEvent
+	Mode        string `json:"mode,omitempty"`
+	Remediation string `json:"remediation,omitempty"`
+	// Approval is required to make the state machine wait for an approver before the plan is executed
+	Approval string `json:"approval,omitempty"`
+	Batch    *Batch `json:"batch,omitempty"`
+	Phase    string `json:"phase,omitempty"`
+	State    *State `json:"state,omitempty"`
+	// TaskToken is the token of the approve phase, the approver resume the state machine with it
+	TaskToken string `json:"taskToken,omitempty"`
+}
+
+// mode return the mode of the request, or the default mode when it is empty
+func (r *Request) mode() (string, error) {
+	switch r.Mode {
+	case "":
+		return defaultMode, nil
+	case ModePlan, ModeExecute:
+		return r.Mode, nil
+	}
+	return "", errors.Wrapf(errors.Validation, "invalid mode %q: must be %s or %s", r.Mode, ModePlan, ModeExecute)
+}
+
+// loadMode read VPCKILLER_MODE
+func loadMode() error {
+	switch value := os.Getenv(ModeEnv); value {
+	case "":
+	case ModePlan, ModeExecute:
+		defaultMode = value
+	default:
+		return fmt.Errorf("invalid %s %q: must be %s or %s", ModeEnv, value, ModePlan, ModeExecute)
+	}
+	return nil
+}
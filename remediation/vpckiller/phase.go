@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/approval"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/deadletter"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+)
+
+// Phases of a remediation run by the state machine, verify plan the remediation, approve request its approval,
+// the other phases run the steps of the plan they own and fail record the failure
+const (
+	PhaseVerify    = "verify"
+	PhaseApprove   = "approve"
+	PhaseBackup    = "backup"
+	PhaseDetach    = "detach"
+	PhaseTerminate = "terminate"
+	PhaseWait      = "wait"
+	PhaseClean     = "clean"
+	PhaseDelete    = "delete"
+	PhaseFail      = "fail"
+)
+
+// phases are the phases that run steps, in their order
+var phases = []string{PhaseBackup, PhaseDetach, PhaseTerminate, PhaseWait, PhaseClean, PhaseDelete}
+
+// State is the document a phase take and return, the state machine pass it from a phase to the next
+//
+//	{"request": {"alertId": "P-39425", "resourceId": "vpc-0a1b2c3d", ...}, "plan": {...}, "done": false}
+type State struct {
+	// Request is the input of the state machine, the alert with its mode and remediation
+	Request Request `json:"request"`
+	Plan    *Plan   `json:"plan,omitempty"`
+	// Done is true when nothing is left to run, such as the plan mode or an exempt VPC
+	Done bool `json:"done"`
+	// ApprovalTimeout is how long the state machine wait for an approver, it is set when the request require an approval
+	ApprovalTimeout int `json:"approvalTimeout,omitempty"`
+	// Approval is the decision of the approver
+	Approval *approval.Decision `json:"approval,omitempty"`
+	// Error is the error caught by the state machine, it is set for the fail phase
+	Error *StateError `json:"error,omitempty"`
+}
+
+// StateError is the error and the cause the state machine catch
+type StateError struct {
+	Error string `json:"Error"`
+	Cause string `json:"Cause"`
+}
+
+// The errors of the phases are named after their category, the state machine retry and catch them by name
+type (
+	RetryableError  struct{ error }
+	PermanentError  struct{ error }
+	NotFoundError   struct{ error }
+	ForbiddenError  struct{ error }
+	ValidationError struct{ error }
+)
+
+func (e *RetryableError) Unwrap() error  { return e.error }
+func (e *PermanentError) Unwrap() error  { return e.error }
+func (e *NotFoundError) Unwrap() error   { return e.error }
+func (e *ForbiddenError) Unwrap() error  { return e.error }
+func (e *ValidationError) Unwrap() error { return e.error }
+
+// phaseError name the error after its category
+func phaseError(err error) error {
+	switch errors.CategoryOf(err) {
+	case errors.Retryable:
+		return &RetryableError{err}
+	case errors.NotFound:
+		return &NotFoundError{err}
+	case errors.Forbidden:
+		return &ForbiddenError{err}
+	case errors.Validation:
+		return &ValidationError{err}
+	}
+	return &PermanentError{err}
+}
+
+// errorCategories is the category of the error names the state machine catch
+// The errors of Step Functions and of Lambda, such as States.Timeout, are Retryable
+var errorCategories = map[string]errors.Category{
+	"RetryableError":  errors.Retryable,
+	"PermanentError":  errors.Permanent,
+	"NotFoundError":   errors.NotFound,
+	"ForbiddenError":  errors.Forbidden,
+	"ValidationError": errors.Validation,
+}
+
+// phaseOf return the phase that run the step
+// The detached and terminated resources are waited for together, after the terminate phase
+func phaseOf(step Step) string {
+	switch step.ResourceType {
+	case ResourceSnapshot, ResourceImage, ResourceManifest:
+		return PhaseBackup
+	case ResourceLambdaFunction, ResourceLoadBalancer, ResourceClassicLoadBalancer, ResourceVpcEndpoint,
+		ResourceTransitGatewayAttachment, ResourcePeeringConnection, ResourceVpnGateway:
+		if step.Action == ActionWait {
+			return PhaseWait
+		}
+		return PhaseDetach
+	case ResourceInstance, ResourceNatGateway:
+		if step.Action == ActionWait {
+			return PhaseWait
+		}
+		return PhaseTerminate
+	case ResourceVpc:
+		return PhaseDelete
+	}
+	return PhaseClean
+}
+
+// handlePhase run the phase of the request on its state and return the state for the next phase
+func handlePhase(ctx context.Context, request Request) (*State, error) {
+	state := request.State
+	if state == nil {
+		return nil, phaseError(errors.Wrapf(errors.Validation, "phase %s without state", request.Phase))
+	}
+	var err error
+	switch request.Phase {
+	case PhaseVerify:
+		err = verifyPhase(state)
+	case PhaseApprove:
+		err = approvePhase(ctx, state, request.TaskToken)
+	case PhaseFail:
+		err = failPhase(ctx, state)
+	default:
+		err = runPhase(state, request.Phase)
+	}
+	if err != nil {
+		fmt.Printf("Phase %s failed: %s %s\n", request.Phase, errors.CategoryOf(err), err.Error())
+		return nil, phaseError(err)
+	}
+	return state, nil
+}
+
+// verifyPhase validate the request and plan the remediation, the plan is recorded in the journal
+func verifyPhase(state *State) error {
+	request := &state.Request
+	if request.IsResolved() {
+		state.Done = true
+		return closeAlert(context.Background(), request.AlertEvent)
+	}
+	mode, err := request.mode()
+	if err != nil {
+		return err
+	}
+	remediation, err := request.remediation()
+	if err != nil {
+		return err
+	}
+	approvalRequired, err := request.approvalRequired()
+	if err != nil {
+		return err
+	}
+	switch remediation {
+	case RemediationRollback:
+		return errors.Wrapf(errors.Validation, "a %s is not run by the state machine", remediation)
+	case RemediationRestore:
+		err = request.validateRestore()
+	default:
+		err = request.AlertEvent.Validate()
+	}
+	if err != nil {
+		return err
+	}
+	plan, _, err := prepareVpc(request.AlertEvent, remediation, mode)
+	if err != nil {
+		return err
+	}
+	state.Plan = plan
+	state.Done = !plan.executable() || len(plan.Steps) == 0
+	if approvalRequired && !state.Done {
+		state.ApprovalTimeout = int(gate.TTL.Seconds())
+	}
+	return nil
+}
+
+// runPhase run the steps of the plan that belong to the phase, in the order of the plan
+// With a journal, the plan and its backups are read from the journal and the done steps are skipped,
+// so a retried phase resume from its failed step
+func runPhase(state *State, phase string) error {
+	if !containsID(phases, phase) {
+		return errors.Wrapf(errors.Validation, "unknown phase %q", phase)
+	}
+	plan := state.Plan
+	if plan == nil {
+		return errors.Wrapf(errors.Validation, "phase %s without plan", phase)
+	}
+	client, err := clients.Client(plan.Region, plan.AccountID)
+	if err != nil {
+		return err
+	}
+	record, err := openJournal(plan.AlertID, plan.Remediation, plan.VpcID)
+	if err != nil {
+		return err
+	}
+	// a retried phase get its input again, the recorded plan keep the backups of the failed attempt
+	if record != nil {
+		recorded := &Plan{}
+		if ok, err := record.Plan(recorded); err != nil {
+			return err
+		} else if ok {
+			plan, state.Plan = recorded, recorded
+		}
+		plan.journal = record
+	}
+	if state.Approval != nil {
+		fmt.Printf("Plan of %s %s by %s at %s\n", plan.VpcID, state.Approval.Status, state.Approval.Approver, state.Approval.DecidedAt.Format(time.RFC3339))
+	}
+	count := 0
+	for i, step := range plan.Steps {
+		if phaseOf(step) != phase {
+			continue
+		}
+		if err := client.journaled(plan, i+1, step); err != nil {
+			return err
+		}
+		count++
+	}
+	fmt.Printf("%d steps of the %s phase of %s are done\n", count, phase, plan.VpcID)
+	return nil
+}
+
+// failPhase dead-letter the alert with the error the state machine caught
+func failPhase(ctx context.Context, state *State) error {
+	if state.Error == nil {
+		return errors.Wrapf(errors.Validation, "phase %s without error", PhaseFail)
+	}
+	category, ok := errorCategories[state.Error.Error]
+	if !ok {
+		category = errors.Retryable
+	}
+	// the cause of a function error is the error message and type the function returned
+	message := state.Error.Cause
+	cause := struct {
+		ErrorMessage string `json:"errorMessage"`
+	}{}
+	if json.Unmarshal([]byte(state.Error.Cause), &cause) == nil && cause.ErrorMessage != "" {
+		message = cause.ErrorMessage
+	}
+	err := errors.Wrapf(category, "%s: %s", state.Error.Error, message)
+	fmt.Printf("VPC Killer failed: %s %s\n", category, err.Error())
+	state.Done = true
+	if errors.DispositionOf(err) == errors.Ack {
+		return nil
+	}
+	// the state machine retried the phase already, a Retryable error is dead-lettered too
+	body, marshalErr := json.Marshal(state.Request.AlertEvent)
+	if marshalErr != nil {
+		return errors.Wrap(errors.Permanent, marshalErr)
+	}
+	return deadLetters.Publish(ctx, deadletter.NewRecord(deadLetters.Source, lambdacontext.FunctionName, err, 1, body))
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/backup"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/deadletter"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/exemption"
+)
+
+type mockSQS struct {
+	sqsiface.SQSAPI
+	inputs []*sqs.SendMessageInput
+}
+
+func (m *mockSQS) SendMessageWithContext(ctx aws.Context, input *sqs.SendMessageInput, opts ...request.Option) (*sqs.SendMessageOutput, error) {
+	m.inputs = append(m.inputs, input)
+	return &sqs.SendMessageOutput{MessageId: aws.String("message-id")}, nil
+}
+
+func TestPhaseOf(t *testing.T) {
+	testCases := []struct {
+		step     Step
+		expected string
+	}{
+		{step: Step{ResourceType: ResourceSnapshot, Action: ActionCreate}, expected: PhaseBackup},
+		{step: Step{ResourceType: ResourceManifest, Action: ActionCreate}, expected: PhaseBackup},
+		{step: Step{ResourceType: ResourceLoadBalancer, Action: ActionDelete}, expected: PhaseDetach},
+		{step: Step{ResourceType: ResourceLoadBalancer, Action: ActionWait}, expected: PhaseWait},
+		{step: Step{ResourceType: ResourceInstance, Action: ActionTerminate}, expected: PhaseTerminate},
+		{step: Step{ResourceType: ResourceInstance, Action: ActionWait}, expected: PhaseWait},
+		{step: Step{ResourceType: ResourceNatGateway, Action: ActionDelete}, expected: PhaseTerminate},
+		{step: Step{ResourceType: ResourceInternetGateway, Action: ActionDetach}, expected: PhaseClean},
+		{step: Step{ResourceType: ResourceSecurityGroup, Action: ActionRevoke}, expected: PhaseClean},
+		{step: Step{ResourceType: ResourceSubnet, Action: ActionDelete}, expected: PhaseClean},
+		{step: Step{ResourceType: ResourceVpc, Action: ActionDelete}, expected: PhaseDelete},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s %s", i, tc.step.ResourceType, tc.step.Action), func(t *testing.T) {
+			assert.Equal(t, tc.expected, phaseOf(tc.step))
+		})
+	}
+}
+
+func TestPhaseError(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected string
+	}{
+		{err: awserr.New("DependencyViolation", "has dependencies", nil), expected: "RetryableError"},
+		{err: awserr.New("InvalidVpcID.NotFound", "not found", nil), expected: "NotFoundError"},
+		{err: awserr.New("UnauthorizedOperation", "not authorized", nil), expected: "ForbiddenError"},
+		{err: errors.Wrapf(errors.Validation, "invalid"), expected: "ValidationError"},
+		{err: awserr.New("OperationNotPermitted", "not permitted", nil), expected: "PermanentError"},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, tc.expected), func(t *testing.T) {
+			err := phaseError(tc.err)
+			// Lambda name the error of a function after its type
+			assert.Equal(t, tc.expected, reflect.TypeOf(err).Elem().Name())
+			assert.Equal(t, errorCategories[tc.expected], errors.CategoryOf(err))
+			assert.Equal(t, tc.err.Error(), err.Error())
+		})
+	}
+}
+
+// stateMachineInput is the alert that start the state machine, on vpc-1 of the mock EC2
+func stateMachineInput(t *testing.T, fields map[string]interface{}) []byte {
+	body, err := ioutil.ReadFile("../../events/testdata/vpc-killer-event.json")
+	assert.NoError(t, err)
+	input := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(body, &input))
+	input["resourceRegionId"], input["resourceId"] = journalEvent.ResourceRegionID, journalEvent.ResourceID
+	for name, value := range fields {
+		input[name] = value
+	}
+	body, err = json.Marshal(input)
+	assert.NoError(t, err)
+	return body
+}
+
+func TestStateMachine(t *testing.T) {
+	phaseStates := []string{"Request", "Verify", "Remediate", "Backup", "Detach", "Terminate", "Wait", "Clean", "Delete"}
+	testCases := []struct {
+		name      string
+		fields    map[string]interface{}
+		failures  map[string]error
+		retried   bool
+		terminal  string
+		visited   []string
+		mutations []string
+		category  string
+	}{
+		{
+			name:      "teardown",
+			terminal:  stateSucceeded,
+			visited:   append(phaseStates, stateSucceeded),
+			mutations: twoTierTeardown,
+		},
+		{
+			name:      "retried phase resume",
+			failures:  map[string]error{"DeleteSecurityGroup sg-db": awserr.New("DependencyViolation", "has dependencies", nil)},
+			retried:   true,
+			terminal:  stateSucceeded,
+			visited:   append(phaseStates, stateSucceeded),
+			mutations: append(append([]string{}, twoTierTeardown[:9]...), twoTierTeardown[8:]...),
+		},
+		{
+			name:      "failed phase",
+			failures:  map[string]error{"DeleteSubnet subnet-1": awserr.New("OperationNotPermitted", "not permitted", nil)},
+			terminal:  stateFailed,
+			visited:   append(phaseStates[:len(phaseStates)-1], "Fail", stateFailed),
+			mutations: twoTierTeardown[:11],
+			category:  "Permanent",
+		},
+		{
+			name:      "exhausted retries",
+			failures:  map[string]error{"DeleteVpc vpc-1": awserr.New("DependencyViolation", "has dependencies", nil)},
+			terminal:  stateFailed,
+			visited:   append(phaseStates, "Fail", stateFailed),
+			mutations: append(append([]string{}, twoTierTeardown...), twoTierTeardown[12], twoTierTeardown[12], twoTierTeardown[12]),
+			category:  "Retryable",
+		},
+		{
+			name:     "plan",
+			fields:   map[string]interface{}{"mode": ModePlan},
+			terminal: stateSucceeded,
+			visited:  []string{"Request", "Verify", "Remediate", stateSucceeded},
+		},
+		{
+			name:     "missing vpc",
+			fields:   map[string]interface{}{"resourceId": "vpc-9"},
+			terminal: stateSucceeded,
+			visited:  []string{"Request", "Verify", stateSucceeded},
+		},
+		{
+			name:     "invalid alert",
+			fields:   map[string]interface{}{"accountId": ""},
+			terminal: stateFailed,
+			visited:  []string{"Request", "Verify", "Fail", stateFailed},
+			category: "Validation",
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, tc.name), func(t *testing.T) {
+			ec2svc := newTwoTierVpc()
+			defer withJournal(t, ec2svc)()
+			sqsvc := &mockSQS{}
+			deadLetters = deadletter.NewPublisher("PrismaVPCKiller", "queue-url", sqsvc)
+			defer func() { deadLetters = nil }()
+			for call, err := range tc.failures {
+				ec2svc.failures[call] = err
+			}
+
+			e := &execution{}
+			if tc.retried {
+				e.beforeRetry = func(state string, errorName string) { ec2svc.failures = map[string]error{} }
+			}
+			terminal, _ := e.run(t, definition("arn"), stateMachineInput(t, tc.fields))
+			assert.Equal(t, tc.terminal, terminal)
+			assert.Equal(t, tc.visited, e.visited)
+			assert.Equal(t, tc.mutations, ec2svc.mutations())
+
+			if tc.category == "" {
+				assert.Empty(t, sqsvc.inputs)
+				return
+			}
+			assert.Len(t, sqsvc.inputs, 1)
+			record := deadletter.Record{}
+			assert.NoError(t, json.Unmarshal([]byte(aws.StringValue(sqsvc.inputs[0].MessageBody)), &record))
+			assert.Equal(t, tc.category, record.Category)
+			assert.Equal(t, "P-39425", record.AlertID)
+		})
+	}
+}
+
+func TestStateMachineExemption(t *testing.T) {
+	ec2svc := newTwoTierVpc()
+	defer withJournal(t, ec2svc)()
+	ec2svc.vpcs[0].Tags = []*ec2.Tag{{Key: aws.String(exemption.TagKey), Value: aws.String(exemption.TagValue)}}
+
+	e := &execution{}
+	terminal, output := e.run(t, definition("arn"), stateMachineInput(t, nil))
+	assert.Equal(t, stateSucceeded, terminal)
+	assert.Equal(t, true, output["done"])
+	assert.Equal(t, "vpc vpc-1 is tagged prisma-remediation=exempt", output["plan"].(map[string]interface{})["exemption"].(map[string]interface{})["reason"])
+	assert.Empty(t, ec2svc.mutations())
+}
+
+func TestRunPhaseRetry(t *testing.T) {
+	ec2svc := newBackedUpVpc()
+	defer withJournal(t, ec2svc)()
+	dir, err := ioutil.TempDir("", "backup")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	backups = backup.NewDirStore(dir)
+	defer func() { backups = nil }()
+
+	plan, _, err := prepareVpc(journalEvent, RemediationDelete, ModeExecute)
+	assert.NoError(t, err)
+	input, err := json.Marshal(&State{Plan: plan})
+	assert.NoError(t, err)
+	// the state machine retry a phase with its input, the input has no backup
+	retry := func(phase string) *State {
+		state := &State{}
+		assert.NoError(t, json.Unmarshal(input, state))
+		assert.NoError(t, runPhase(state, phase))
+		return state
+	}
+
+	ec2svc.pending = map[string]bool{"ami-i-1": true}
+	assert.Equal(t, errors.Retryable, errors.CategoryOf(runPhase(&State{Plan: plan}, PhaseBackup)))
+	ec2svc.pending = nil
+	state := retry(PhaseBackup)
+	assert.Len(t, state.Plan.Backups, 5)
+	state = retry(PhaseTerminate)
+	assert.Len(t, state.Plan.Backups, 5)
+	assert.Contains(t, ec2svc.mutations(), "TerminateInstances i-1")
+}
+
+func TestHandlePhaseValidation(t *testing.T) {
+	testCases := []struct {
+		name    string
+		request Request
+	}{
+		{name: "no state", request: Request{Phase: PhaseClean}},
+		{name: "no plan", request: Request{Phase: PhaseClean, State: &State{}}},
+		{name: "unknown phase", request: Request{Phase: "drain", State: &State{Plan: &Plan{}}}},
+		{name: "rollback", request: Request{Phase: PhaseVerify, State: &State{Request: Request{Remediation: RemediationRollback}}}},
+		{name: "fail without error", request: Request{Phase: PhaseFail, State: &State{}}},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, tc.name), func(t *testing.T) {
+			_, err := handlePhase(nil, tc.request)
+			assert.IsType(t, &ValidationError{}, err)
+		})
+	}
+}
@@ -0,0 +1,494 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/exemption"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/journal"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// Resource types of the plan steps
+const (
+	ResourceVpc                       = "vpc"
+	ResourceLambdaFunction            = "lambda-function"
+	ResourceLoadBalancer              = "load-balancer"
+	ResourceClassicLoadBalancer       = "classic-load-balancer"
+	ResourceVpcEndpoint               = "vpc-endpoint"
+	ResourceTransitGatewayAttachment  = "transit-gateway-attachment"
+	ResourcePeeringConnection         = "vpc-peering-connection"
+	ResourceVpnGateway                = "vpn-gateway"
+	ResourceInstance                  = "instance"
+	ResourceNatGateway                = "nat-gateway"
+	ResourceElasticIP                 = "elastic-ip"
+	ResourceInternetGateway           = "internet-gateway"
+	ResourceEgressOnlyInternetGateway = "egress-only-internet-gateway"
+	ResourceNetworkInterface          = "network-interface"
+	ResourceSecurityGroup             = "security-group"
+	ResourceDBSubnetGroup             = "db-subnet-group"
+	ResourceSubnet                    = "subnet"
+	ResourceRouteTable                = "route-table"
+	ResourceNetworkACL                = "network-acl"
+	ResourceQuarantineSecurityGroup   = "quarantine-security-group"
+	ResourceQuarantineNetworkACL      = "quarantine-network-acl"
+	ResourceSnapshot                  = "snapshot"
+	ResourceImage                     = "image"
+	ResourceManifest                  = "manifest"
+)
+
+// Actions of the plan steps, wait block until the resource is removed or detached,
+// isolate quarantine a resource and restore undo it
+const (
+	ActionCreate       = "create"
+	ActionIsolate      = "isolate"
+	ActionRestore      = "restore"
+	ActionDetach       = "detach"
+	ActionDisassociate = "disassociate"
+	ActionRevoke       = "revoke"
+	ActionTerminate    = "terminate"
+	ActionWait         = "wait"
+	ActionRelease      = "release"
+	ActionDelete       = "delete"
+)
+
+// Step is an action on a resource of the VPC, it runs after the resources it depends on
+type Step struct {
+	ResourceID   string   `json:"resourceId"`
+	ResourceType string   `json:"resourceType"`
+	Action       string   `json:"action"`
+	DependsOn    []string `json:"dependsOn,omitempty"`
+}
+
+// operation return the resource type and action of the step, such as internet-gateway/detach
+func (s Step) operation() string {
+	return s.ResourceType + "/" + s.Action
+}
+
+// Plan is the ordered steps of the remediation of a VPC
+type Plan struct {
+	AlertID     string `json:"alertId,omitempty"`
+	AccountID   string `json:"accountId,omitempty"`
+	Region      string `json:"region,omitempty"`
+	VpcID       string `json:"vpcId"`
+	Mode        string `json:"mode,omitempty"`
+	Remediation string `json:"remediation,omitempty"`
+	// Exemption is why the VPC is spared, a spared VPC has no steps
+	Exemption *exemption.Exemption `json:"exemption,omitempty"`
+	Steps     []Step               `json:"steps"`
+	// Backups is the snapshots, the images and the manifest created by the executed steps
+	Backups []Backup `json:"backups,omitempty"`
+
+	// journal record the executed steps, it is nil when the journal is disabled
+	journal *journal.Journal
+}
+
+// executable return true when the plan is executed, the plan of the plan mode or of an exempt VPC is only returned
+func (p *Plan) executable() bool {
+	return p.Mode == ModeExecute && p.Exemption == nil
+}
+
+// add append the action on a resource to the plan
+func (p *Plan) add(resourceType string, resourceID string, action string, dependsOn ...string) {
+	p.Steps = append(p.Steps, Step{
+		ResourceID:   resourceID,
+		ResourceType: resourceType,
+		Action:       action,
+		DependsOn:    dependsOn,
+	})
+}
+
+// resourceIDs return the IDs of the resources in the plan, in the order of their first step
+func (p *Plan) resourceIDs() []string {
+	seen := map[string]bool{}
+	var ids []string
+	for _, step := range p.Steps {
+		if !seen[step.ResourceID] {
+			seen[step.ResourceID] = true
+			ids = append(ids, step.ResourceID)
+		}
+	}
+	return ids
+}
+
+// planned return the resource IDs that have a step in the plan
+func (p *Plan) planned(resourceIDs ...string) []string {
+	var ids []string
+	for _, id := range resourceIDs {
+		for _, step := range p.Steps {
+			if step.ResourceID == id {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	return ids
+}
+
+// String return a step per line
+func (p *Plan) String() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "Plan to %s %s in %s %s:\n", p.Remediation, p.VpcID, p.AccountID, p.Region)
+	if p.Exemption != nil {
+		fmt.Fprintf(&builder, "  skipped, %s\n", p.Exemption.String())
+	}
+	for i, step := range p.Steps {
+		fmt.Fprintf(&builder, "%3d. %s %s %s", i+1, step.Action, step.ResourceType, step.ResourceID)
+		if len(step.DependsOn) > 0 {
+			fmt.Fprintf(&builder, " after %s", strings.Join(step.DependsOn, ", "))
+		}
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// plan describe the resources of the VPC and return the steps of the remediation, nothing is modified
+func (c *Client) plan(remediation string, vpcID string) (*Plan, error) {
+	vpcs, err := c.verifyVpcs([]*string{aws.String(vpcID)})
+	if err != nil {
+		return nil, err
+	}
+	inv, err := c.describe(vpcID)
+	if err != nil {
+		return nil, err
+	}
+	if remediation != RemediationRestore {
+		if exempt := inv.exemption(vpcs[0]); exempt != nil {
+			return &Plan{VpcID: vpcID, Remediation: remediation, Exemption: exempt, Steps: []Step{}}, nil
+		}
+	}
+	var plan *Plan
+	switch remediation {
+	case RemediationDelete:
+		plan = inv.plan(vpcID)
+		if backups != nil {
+			plan = inv.backupPlan(plan)
+		}
+	case RemediationQuarantine:
+		plan = inv.quarantinePlan(vpcID)
+	case RemediationRestore:
+		if plan, err = c.restorePlan(vpcID, inv); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Wrapf(errors.Validation, "unknown remediation %q", remediation)
+	}
+	plan.Remediation = remediation
+	fmt.Printf("Planned %d steps to %s %s\n", len(plan.Steps), remediation, vpcID)
+	return plan, nil
+}
+
+// plan order the removal of the resources, a resource is removed after the resources that depend on it
+func (inv *inventory) plan(vpcID string) *Plan {
+	plan := &Plan{VpcID: vpcID}
+
+	// Lambda functions release their network interfaces once they leave the VPC
+	for _, function := range inv.functions {
+		plan.add(ResourceLambdaFunction, *function.FunctionName, ActionDetach)
+	}
+	for _, loadBalancer := range inv.loadBalancers {
+		plan.add(ResourceLoadBalancer, *loadBalancer.LoadBalancerArn, ActionDelete)
+	}
+	for _, loadBalancer := range inv.classicLoadBalancers {
+		plan.add(ResourceClassicLoadBalancer, *loadBalancer.LoadBalancerName, ActionDelete)
+	}
+	for _, loadBalancer := range inv.loadBalancers {
+		plan.add(ResourceLoadBalancer, *loadBalancer.LoadBalancerArn, ActionWait)
+	}
+	for _, endpoint := range inv.endpoints {
+		plan.add(ResourceVpcEndpoint, *endpoint.VpcEndpointId, ActionDelete)
+	}
+	for _, endpoint := range inv.endpoints {
+		plan.add(ResourceVpcEndpoint, *endpoint.VpcEndpointId, ActionWait)
+	}
+	for _, attachment := range inv.attachments {
+		plan.add(ResourceTransitGatewayAttachment, *attachment.TransitGatewayAttachmentId, ActionDelete)
+	}
+	for _, attachment := range inv.attachments {
+		plan.add(ResourceTransitGatewayAttachment, *attachment.TransitGatewayAttachmentId, ActionWait)
+	}
+	for _, peering := range inv.peerings {
+		plan.add(ResourcePeeringConnection, *peering.VpcPeeringConnectionId, ActionDelete)
+	}
+	for _, peering := range inv.peerings {
+		plan.add(ResourcePeeringConnection, *peering.VpcPeeringConnectionId, ActionWait)
+	}
+	for _, gateway := range inv.vpnGateways {
+		plan.add(ResourceVpnGateway, *gateway.VpnGatewayId, ActionDetach)
+	}
+	for _, gateway := range inv.vpnGateways {
+		plan.add(ResourceVpnGateway, *gateway.VpnGatewayId, ActionWait)
+	}
+
+	for _, instance := range inv.instances {
+		plan.add(ResourceInstance, *instance.InstanceId, ActionTerminate)
+	}
+	for _, instance := range inv.instances {
+		plan.add(ResourceInstance, *instance.InstanceId, ActionWait)
+	}
+	for _, gateway := range inv.natGateways {
+		plan.add(ResourceNatGateway, *gateway.NatGatewayId, ActionDelete)
+	}
+	for _, gateway := range inv.natGateways {
+		plan.add(ResourceNatGateway, *gateway.NatGatewayId, ActionWait)
+	}
+
+	// the internet gateway cannot be detached while the VPC has mapped public addresses
+	var mapped []string
+	for _, address := range inv.addresses {
+		if owner := inv.addressOwner(address); owner != "" {
+			plan.add(ResourceElasticIP, *address.AllocationId, ActionRelease, plan.planned(owner)...)
+		} else {
+			plan.add(ResourceElasticIP, *address.AllocationId, ActionDisassociate)
+			plan.add(ResourceElasticIP, *address.AllocationId, ActionRelease)
+		}
+		mapped = append(mapped, *address.AllocationId)
+	}
+	for _, instance := range inv.instances {
+		if instance.PublicIpAddress != nil {
+			mapped = append(mapped, *instance.InstanceId)
+		}
+	}
+	for _, gateway := range inv.internetGateways {
+		plan.add(ResourceInternetGateway, *gateway.InternetGatewayId, ActionDetach, plan.planned(mapped...)...)
+		plan.add(ResourceInternetGateway, *gateway.InternetGatewayId, ActionDelete)
+	}
+	for _, gateway := range inv.egressOnlyGateways {
+		plan.add(ResourceEgressOnlyInternetGateway, *gateway.EgressOnlyInternetGatewayId, ActionDelete)
+	}
+
+	for _, networkInterface := range inv.interfaces {
+		inv.planInterface(plan, networkInterface)
+	}
+
+	// rules that reference a security group are revoked before any group is deleted
+	for _, group := range inv.groups {
+		if referencesGroups(group) {
+			plan.add(ResourceSecurityGroup, *group.GroupId, ActionRevoke)
+		}
+	}
+	for _, group := range inv.groups {
+		// the default security group is deleted with the VPC
+		if aws.StringValue(group.GroupName) == "default" {
+			continue
+		}
+		plan.add(ResourceSecurityGroup, *group.GroupId, ActionDelete, plan.planned(inv.groupUsers(*group.GroupId)...)...)
+	}
+
+	for _, group := range inv.dbSubnetGroups {
+		plan.add(ResourceDBSubnetGroup, *group.DBSubnetGroupName, ActionDelete)
+	}
+	for _, subnet := range inv.subnets {
+		plan.add(ResourceSubnet, *subnet.SubnetId, ActionDelete, plan.planned(inv.subnetUsers(*subnet.SubnetId)...)...)
+	}
+
+	// the main route table and the default network ACL are deleted with the VPC
+	for _, table := range inv.routeTables {
+		if isMain(table) {
+			continue
+		}
+		if len(table.Associations) > 0 {
+			plan.add(ResourceRouteTable, *table.RouteTableId, ActionDisassociate)
+		}
+		plan.add(ResourceRouteTable, *table.RouteTableId, ActionDelete)
+	}
+	for _, acl := range inv.acls {
+		if aws.BoolValue(acl.IsDefault) {
+			continue
+		}
+		var subnetIDs []string
+		for _, association := range acl.Associations {
+			subnetIDs = append(subnetIDs, aws.StringValue(association.SubnetId))
+		}
+		plan.add(ResourceNetworkACL, *acl.NetworkAclId, ActionDelete, plan.planned(subnetIDs...)...)
+	}
+
+	plan.add(ResourceVpc, vpcID, ActionDelete, plan.resourceIDs()...)
+	return plan
+}
+
+// planInterface add the removal of a network interface, the interfaces of the services and the instances are removed with them
+func (inv *inventory) planInterface(plan *Plan, networkInterface *ec2.NetworkInterface) {
+	id := *networkInterface.NetworkInterfaceId
+	attachment := networkInterface.Attachment
+	switch {
+	case aws.BoolValue(networkInterface.RequesterManaged) || isLambdaInterface(networkInterface):
+		plan.add(ResourceNetworkInterface, id, ActionWait, plan.planned(inv.interfaceOwners(id)...)...)
+	case attachment != nil && attachment.InstanceId != nil && aws.BoolValue(attachment.DeleteOnTermination):
+		// deleted with its instance
+	case attachment != nil && attachment.InstanceId != nil:
+		plan.add(ResourceNetworkInterface, id, ActionDelete, plan.planned(*attachment.InstanceId)...)
+	case attachment != nil && attachment.AttachmentId != nil:
+		plan.add(ResourceNetworkInterface, id, ActionDetach)
+		plan.add(ResourceNetworkInterface, id, ActionDelete)
+	default:
+		plan.add(ResourceNetworkInterface, id, ActionDelete)
+	}
+}
+
+// addressOwner return the NAT gateway or the instance an Elastic IP is released with
+func (inv *inventory) addressOwner(address *ec2.Address) string {
+	if address.InstanceId != nil {
+		return *address.InstanceId
+	}
+	return inv.addressNatGateway(address)
+}
+
+// addressNatGateway return the NAT gateway of an Elastic IP, an empty string when the address is not a NAT gateway address
+func (inv *inventory) addressNatGateway(address *ec2.Address) string {
+	for _, gateway := range inv.natGateways {
+		for _, natAddress := range gateway.NatGatewayAddresses {
+			if aws.StringValue(natAddress.AllocationId) == aws.StringValue(address.AllocationId) {
+				return *gateway.NatGatewayId
+			}
+		}
+	}
+	return ""
+}
+
+// interfaceOwners return the resources that release the network interface when they are removed
+func (inv *inventory) interfaceOwners(interfaceID string) []string {
+	var owners []string
+	for _, gateway := range inv.natGateways {
+		for _, address := range gateway.NatGatewayAddresses {
+			if aws.StringValue(address.NetworkInterfaceId) == interfaceID {
+				owners = append(owners, *gateway.NatGatewayId)
+			}
+		}
+	}
+	for _, endpoint := range inv.endpoints {
+		for _, endpointInterfaceID := range endpoint.NetworkInterfaceIds {
+			if aws.StringValue(endpointInterfaceID) == interfaceID {
+				owners = append(owners, *endpoint.VpcEndpointId)
+			}
+		}
+	}
+	return owners
+}
+
+// groupUsers return the resources that use or reference the security group
+func (inv *inventory) groupUsers(groupID string) []string {
+	var users []string
+	for _, function := range inv.functions {
+		if containsString(function.VpcConfig.SecurityGroupIds, groupID) {
+			users = append(users, *function.FunctionName)
+		}
+	}
+	for _, loadBalancer := range inv.loadBalancers {
+		if containsString(loadBalancer.SecurityGroups, groupID) {
+			users = append(users, *loadBalancer.LoadBalancerArn)
+		}
+	}
+	for _, loadBalancer := range inv.classicLoadBalancers {
+		if containsString(loadBalancer.SecurityGroups, groupID) {
+			users = append(users, *loadBalancer.LoadBalancerName)
+		}
+	}
+	for _, instance := range inv.instances {
+		for _, identifier := range instance.SecurityGroups {
+			if aws.StringValue(identifier.GroupId) == groupID {
+				users = append(users, *instance.InstanceId)
+			}
+		}
+	}
+	for _, networkInterface := range inv.interfaces {
+		for _, identifier := range networkInterface.Groups {
+			if aws.StringValue(identifier.GroupId) == groupID {
+				users = append(users, *networkInterface.NetworkInterfaceId)
+			}
+		}
+	}
+	for _, group := range inv.groups {
+		if *group.GroupId != groupID && referencesGroup(group, groupID) {
+			users = append(users, *group.GroupId)
+		}
+	}
+	return users
+}
+
+// subnetUsers return the resources in the subnet
+func (inv *inventory) subnetUsers(subnetID string) []string {
+	var users []string
+	for _, function := range inv.functions {
+		if containsString(function.VpcConfig.SubnetIds, subnetID) {
+			users = append(users, *function.FunctionName)
+		}
+	}
+	for _, endpoint := range inv.endpoints {
+		if containsString(endpoint.SubnetIds, subnetID) {
+			users = append(users, *endpoint.VpcEndpointId)
+		}
+	}
+	for _, instance := range inv.instances {
+		if aws.StringValue(instance.SubnetId) == subnetID {
+			users = append(users, *instance.InstanceId)
+		}
+	}
+	for _, gateway := range inv.natGateways {
+		if aws.StringValue(gateway.SubnetId) == subnetID {
+			users = append(users, *gateway.NatGatewayId)
+		}
+	}
+	for _, networkInterface := range inv.interfaces {
+		if aws.StringValue(networkInterface.SubnetId) == subnetID {
+			users = append(users, *networkInterface.NetworkInterfaceId)
+		}
+	}
+	for _, group := range inv.dbSubnetGroups {
+		for _, subnet := range group.Subnets {
+			if aws.StringValue(subnet.SubnetIdentifier) == subnetID {
+				users = append(users, *group.DBSubnetGroupName)
+			}
+		}
+	}
+	return users
+}
+
+// referencesGroups return true when a rule of the security group reference a security group
+func referencesGroups(group *ec2.SecurityGroup) bool {
+	for _, permission := range append(group.IpPermissions, group.IpPermissionsEgress...) {
+		if len(permission.UserIdGroupPairs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// referencesGroup return true when a rule of the security group reference the group ID
+func referencesGroup(group *ec2.SecurityGroup, groupID string) bool {
+	for _, permission := range append(group.IpPermissions, group.IpPermissionsEgress...) {
+		for _, pair := range permission.UserIdGroupPairs {
+			if aws.StringValue(pair.GroupId) == groupID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isMain return true for the main route table of the VPC
+func isMain(table *ec2.RouteTable) bool {
+	for _, association := range table.Associations {
+		if aws.BoolValue(association.Main) {
+			return true
+		}
+	}
+	return false
+}
+
+// isLambdaInterface return true for the network interfaces Lambda create for the functions in the VPC
+func isLambdaInterface(networkInterface *ec2.NetworkInterface) bool {
+	return aws.StringValue(networkInterface.InterfaceType) == "lambda" ||
+		strings.HasPrefix(aws.StringValue(networkInterface.Description), "AWS Lambda VPC ENI")
+}
+
+func containsString(values []*string, value string) bool {
+	for _, candidate := range values {
+		if aws.StringValue(candidate) == value {
+			return true
+		}
+	}
+	return false
+}
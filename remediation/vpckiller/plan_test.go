@@ -0,0 +1,552 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elb/elbiface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+)
+
+// mockLambda list no function in a VPC
+type mockLambda struct {
+	lambdaiface.LambdaAPI
+}
+
+func (m *mockLambda) ListFunctionsPages(input *lambda.ListFunctionsInput, fn func(*lambda.ListFunctionsOutput, bool) bool) error {
+	fn(&lambda.ListFunctionsOutput{}, true)
+	return nil
+}
+
+// mockELBV2 list no load balancer
+type mockELBV2 struct {
+	elbv2iface.ELBV2API
+}
+
+func (m *mockELBV2) DescribeLoadBalancersPages(input *elbv2.DescribeLoadBalancersInput, fn func(*elbv2.DescribeLoadBalancersOutput, bool) bool) error {
+	fn(&elbv2.DescribeLoadBalancersOutput{}, true)
+	return nil
+}
+
+// mockELB list no classic load balancer
+type mockELB struct {
+	elbiface.ELBAPI
+}
+
+func (m *mockELB) DescribeLoadBalancersPages(input *elb.DescribeLoadBalancersInput, fn func(*elb.DescribeLoadBalancersOutput, bool) bool) error {
+	fn(&elb.DescribeLoadBalancersOutput{}, true)
+	return nil
+}
+
+// mockRDS list no DB subnet group
+type mockRDS struct {
+	rdsiface.RDSAPI
+}
+
+func (m *mockRDS) DescribeDBSubnetGroupsPages(input *rds.DescribeDBSubnetGroupsInput, fn func(*rds.DescribeDBSubnetGroupsOutput, bool) bool) error {
+	fn(&rds.DescribeDBSubnetGroupsOutput{}, true)
+	return nil
+}
+
+func newClient(ec2svc ec2iface.EC2API) *Client {
+	return &Client{
+		ec2svc:    ec2svc,
+		elbsvc:    &mockELB{},
+		elbv2svc:  &mockELBV2{},
+		lambdasvc: &mockLambda{},
+		rdssvc:    &mockRDS{},
+	}
+}
+
+// instance return an instance of vpc-1
+func instance(id string, subnetID string, state string, groupIDs ...string) *ec2.Instance {
+	instance := &ec2.Instance{
+		InstanceId: aws.String(id),
+		SubnetId:   aws.String(subnetID),
+		VpcId:      aws.String("vpc-1"),
+		State:      &ec2.InstanceState{Name: aws.String(state)},
+	}
+	for _, groupID := range groupIDs {
+		instance.SecurityGroups = append(instance.SecurityGroups, &ec2.GroupIdentifier{GroupId: aws.String(groupID)})
+	}
+	return instance
+}
+
+// newMockVpc return vpc-1 with an instance of each state, a NAT gateway, Elastic IPs, network interfaces,
+// route tables and network ACLs
+func newMockVpc() *mockEC2 {
+	web := instance("i-1", "subnet-1", ec2.InstanceStateNameRunning, "sg-1")
+	web.PublicIpAddress = aws.String("203.0.113.10")
+	vpcID := aws.String("vpc-1")
+	return &mockEC2{
+		vpcs: []*ec2.Vpc{{VpcId: vpcID, IsDefault: aws.Bool(false)}},
+		gateways: []*ec2.InternetGateway{
+			{
+				InternetGatewayId: aws.String("igw-1"),
+				Attachments:       []*ec2.InternetGatewayAttachment{{VpcId: vpcID, State: aws.String("available")}},
+			},
+		},
+		instances: []*ec2.Instance{
+			web,
+			instance("i-2", "subnet-2", ec2.InstanceStateNameStopped, "sg-0"),
+			instance("i-3", "subnet-1", ec2.InstanceStateNameTerminated, "sg-1"),
+		},
+		natGateways: []*ec2.NatGateway{
+			{
+				NatGatewayId: aws.String("nat-1"),
+				SubnetId:     aws.String("subnet-1"),
+				VpcId:        vpcID,
+				State:        aws.String(ec2.NatGatewayStateAvailable),
+				NatGatewayAddresses: []*ec2.NatGatewayAddress{
+					{AllocationId: aws.String("eipalloc-nat"), NetworkInterfaceId: aws.String("eni-nat")},
+				},
+			},
+		},
+		interfaces: []*ec2.NetworkInterface{
+			{NetworkInterfaceId: aws.String("eni-nat"), SubnetId: aws.String("subnet-1"), VpcId: vpcID, RequesterManaged: aws.Bool(true)},
+			{
+				NetworkInterfaceId: aws.String("eni-i1"),
+				SubnetId:           aws.String("subnet-1"),
+				VpcId:              vpcID,
+				Groups:             []*ec2.GroupIdentifier{{GroupId: aws.String("sg-1")}},
+				Attachment:         &ec2.NetworkInterfaceAttachment{InstanceId: aws.String("i-1"), DeleteOnTermination: aws.Bool(true)},
+			},
+			{NetworkInterfaceId: aws.String("eni-free"), SubnetId: aws.String("subnet-2"), VpcId: vpcID},
+		},
+		addresses: []*ec2.Address{
+			{AllocationId: aws.String("eipalloc-nat"), AssociationId: aws.String("eipassoc-nat"), NetworkInterfaceId: aws.String("eni-nat")},
+			{AllocationId: aws.String("eipalloc-2"), AssociationId: aws.String("eipassoc-2"), NetworkInterfaceId: aws.String("eni-free")},
+		},
+		groups: []*ec2.SecurityGroup{
+			{
+				GroupId:   aws.String("sg-0"),
+				VpcId:     vpcID,
+				GroupName: aws.String("default"),
+				IpPermissions: []*ec2.IpPermission{
+					{IpProtocol: aws.String("tcp"), UserIdGroupPairs: []*ec2.UserIdGroupPair{{GroupId: aws.String("sg-1")}}},
+					{IpProtocol: aws.String("-1"), IpRanges: []*ec2.IpRange{{CidrIp: aws.String("10.0.0.0/16")}}},
+				},
+			},
+			{GroupId: aws.String("sg-1"), VpcId: vpcID, GroupName: aws.String("web")},
+		},
+		subnets: []*ec2.Subnet{
+			{SubnetId: aws.String("subnet-1"), VpcId: vpcID},
+			{SubnetId: aws.String("subnet-2"), VpcId: vpcID},
+		},
+		routeTables: []*ec2.RouteTable{
+			{RouteTableId: aws.String("rtb-main"), VpcId: vpcID, Associations: []*ec2.RouteTableAssociation{{Main: aws.Bool(true)}}},
+			{RouteTableId: aws.String("rtb-1"), VpcId: vpcID, Associations: []*ec2.RouteTableAssociation{{SubnetId: aws.String("subnet-1")}}},
+		},
+		acls: []*ec2.NetworkAcl{
+			{
+				NetworkAclId: aws.String("acl-default"),
+				VpcId:        vpcID,
+				IsDefault:    aws.Bool(true),
+				Associations: []*ec2.NetworkAclAssociation{
+					{NetworkAclAssociationId: aws.String("aclassoc-1"), NetworkAclId: aws.String("acl-default"), SubnetId: aws.String("subnet-1")},
+				},
+			},
+			{
+				NetworkAclId: aws.String("acl-1"),
+				VpcId:        vpcID,
+				Associations: []*ec2.NetworkAclAssociation{
+					{NetworkAclAssociationId: aws.String("aclassoc-2"), NetworkAclId: aws.String("acl-1"), SubnetId: aws.String("subnet-2")},
+				},
+			},
+		},
+		failures: map[string]error{},
+	}
+}
+
+func TestPlan(t *testing.T) {
+	testCases := []struct {
+		name     string
+		ec2svc   *mockEC2
+		expected []Step
+		category errors.Category
+	}{
+		{
+			name:   "empty VPC",
+			ec2svc: newMockEC2().addVpc("vpc-1", false),
+			expected: []Step{
+				{ResourceID: "vpc-1", ResourceType: ResourceVpc, Action: ActionDelete},
+			},
+		},
+		{
+			name:   "VPC with resources",
+			ec2svc: newMockVpc(),
+			expected: []Step{
+				{ResourceID: "i-1", ResourceType: ResourceInstance, Action: ActionTerminate},
+				{ResourceID: "i-2", ResourceType: ResourceInstance, Action: ActionTerminate},
+				{ResourceID: "i-1", ResourceType: ResourceInstance, Action: ActionWait},
+				{ResourceID: "i-2", ResourceType: ResourceInstance, Action: ActionWait},
+				{ResourceID: "nat-1", ResourceType: ResourceNatGateway, Action: ActionDelete},
+				{ResourceID: "nat-1", ResourceType: ResourceNatGateway, Action: ActionWait},
+				{ResourceID: "eipalloc-nat", ResourceType: ResourceElasticIP, Action: ActionRelease, DependsOn: []string{"nat-1"}},
+				{ResourceID: "eipalloc-2", ResourceType: ResourceElasticIP, Action: ActionDisassociate},
+				{ResourceID: "eipalloc-2", ResourceType: ResourceElasticIP, Action: ActionRelease},
+				{ResourceID: "igw-1", ResourceType: ResourceInternetGateway, Action: ActionDetach, DependsOn: []string{"eipalloc-nat", "eipalloc-2", "i-1"}},
+				{ResourceID: "igw-1", ResourceType: ResourceInternetGateway, Action: ActionDelete},
+				{ResourceID: "eni-nat", ResourceType: ResourceNetworkInterface, Action: ActionWait, DependsOn: []string{"nat-1"}},
+				{ResourceID: "eni-free", ResourceType: ResourceNetworkInterface, Action: ActionDelete},
+				{ResourceID: "sg-0", ResourceType: ResourceSecurityGroup, Action: ActionRevoke},
+				{ResourceID: "sg-1", ResourceType: ResourceSecurityGroup, Action: ActionDelete, DependsOn: []string{"i-1", "sg-0"}},
+				{ResourceID: "subnet-1", ResourceType: ResourceSubnet, Action: ActionDelete, DependsOn: []string{"i-1", "nat-1", "eni-nat"}},
+				{ResourceID: "subnet-2", ResourceType: ResourceSubnet, Action: ActionDelete, DependsOn: []string{"i-2", "eni-free"}},
+				{ResourceID: "rtb-1", ResourceType: ResourceRouteTable, Action: ActionDisassociate},
+				{ResourceID: "rtb-1", ResourceType: ResourceRouteTable, Action: ActionDelete},
+				{ResourceID: "acl-1", ResourceType: ResourceNetworkACL, Action: ActionDelete, DependsOn: []string{"subnet-2"}},
+				{ResourceID: "vpc-1", ResourceType: ResourceVpc, Action: ActionDelete, DependsOn: []string{
+					"i-1", "i-2", "nat-1", "eipalloc-nat", "eipalloc-2", "igw-1", "eni-nat", "eni-free",
+					"sg-0", "sg-1", "subnet-1", "subnet-2", "rtb-1", "acl-1",
+				}},
+			},
+		},
+		{
+			name:     "VPC not found",
+			ec2svc:   newMockEC2(),
+			category: errors.NotFound,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, tc.name), func(t *testing.T) {
+			client := newClient(tc.ec2svc)
+			plan, err := client.plan(RemediationDelete, "vpc-1")
+			assert.Empty(t, tc.ec2svc.mutations())
+			if tc.category != 0 {
+				assert.Equal(t, tc.category, errors.CategoryOf(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "vpc-1", plan.VpcID)
+			assert.Equal(t, tc.expected, plan.Steps)
+		})
+	}
+}
+
+func TestPlanServices(t *testing.T) {
+	inv := &inventory{
+		functions: []*lambda.FunctionConfiguration{
+			{
+				FunctionName: aws.String("worker"),
+				VpcConfig: &lambda.VpcConfigResponse{
+					VpcId:            aws.String("vpc-1"),
+					SubnetIds:        []*string{aws.String("subnet-1")},
+					SecurityGroupIds: []*string{aws.String("sg-1")},
+				},
+			},
+		},
+		loadBalancers: []*elbv2.LoadBalancer{
+			{LoadBalancerArn: aws.String("arn:alb"), SecurityGroups: []*string{aws.String("sg-1")}},
+		},
+		classicLoadBalancers: []*elb.LoadBalancerDescription{
+			{LoadBalancerName: aws.String("classic")},
+		},
+		endpoints: []*ec2.VpcEndpoint{
+			{VpcEndpointId: aws.String("vpce-1"), SubnetIds: []*string{aws.String("subnet-1")}, NetworkInterfaceIds: []*string{aws.String("eni-vpce")}},
+		},
+		attachments:        []*ec2.TransitGatewayVpcAttachment{{TransitGatewayAttachmentId: aws.String("tgw-attach-1")}},
+		peerings:           []*ec2.VpcPeeringConnection{{VpcPeeringConnectionId: aws.String("pcx-1")}},
+		vpnGateways:        []*ec2.VpnGateway{{VpnGatewayId: aws.String("vgw-1")}},
+		egressOnlyGateways: []*ec2.EgressOnlyInternetGateway{{EgressOnlyInternetGatewayId: aws.String("eigw-1")}},
+		interfaces: []*ec2.NetworkInterface{
+			{NetworkInterfaceId: aws.String("eni-vpce"), SubnetId: aws.String("subnet-1"), RequesterManaged: aws.Bool(true)},
+			{NetworkInterfaceId: aws.String("eni-lambda"), SubnetId: aws.String("subnet-1"), InterfaceType: aws.String("lambda")},
+			{
+				NetworkInterfaceId: aws.String("eni-attached"),
+				SubnetId:           aws.String("subnet-1"),
+				Attachment:         &ec2.NetworkInterfaceAttachment{AttachmentId: aws.String("eni-attach-1")},
+			},
+		},
+		groups: []*ec2.SecurityGroup{
+			{GroupId: aws.String("sg-1"), GroupName: aws.String("app")},
+		},
+		dbSubnetGroups: []*rds.DBSubnetGroup{
+			{DBSubnetGroupName: aws.String("db"), Subnets: []*rds.Subnet{{SubnetIdentifier: aws.String("subnet-1")}}},
+		},
+		subnets: []*ec2.Subnet{{SubnetId: aws.String("subnet-1")}},
+	}
+
+	expected := []string{
+		"detach lambda-function worker",
+		"delete load-balancer arn:alb",
+		"delete classic-load-balancer classic",
+		"wait load-balancer arn:alb",
+		"delete vpc-endpoint vpce-1",
+		"wait vpc-endpoint vpce-1",
+		"delete transit-gateway-attachment tgw-attach-1",
+		"wait transit-gateway-attachment tgw-attach-1",
+		"delete vpc-peering-connection pcx-1",
+		"wait vpc-peering-connection pcx-1",
+		"detach vpn-gateway vgw-1",
+		"wait vpn-gateway vgw-1",
+		"delete egress-only-internet-gateway eigw-1",
+		"wait network-interface eni-vpce after vpce-1",
+		"wait network-interface eni-lambda",
+		"detach network-interface eni-attached",
+		"delete network-interface eni-attached",
+		"delete security-group sg-1 after worker, arn:alb",
+		"delete db-subnet-group db",
+		"delete subnet subnet-1 after worker, vpce-1, eni-vpce, eni-lambda, eni-attached, db",
+	}
+	plan := inv.plan("vpc-1")
+	var actual []string
+	for _, step := range plan.Steps[:len(plan.Steps)-1] {
+		line := fmt.Sprintf("%s %s %s", step.Action, step.ResourceType, step.ResourceID)
+		if len(step.DependsOn) > 0 {
+			line += " after " + strings.Join(step.DependsOn, ", ")
+		}
+		actual = append(actual, line)
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestExecute(t *testing.T) {
+	all := []string{
+		"TerminateInstances i-1",
+		"TerminateInstances i-2",
+		"DescribeInstances i-1",
+		"DescribeInstances i-2",
+		"DeleteNatGateway nat-1",
+		"ReleaseAddress eipalloc-nat",
+		"DisassociateAddress eipassoc-2",
+		"ReleaseAddress eipalloc-2",
+		"DetachInternetGateway igw-1",
+		"DeleteInternetGateway igw-1",
+		"DeleteNetworkInterface eni-free",
+		"RevokeSecurityGroupIngress sg-0",
+		"DeleteSecurityGroup sg-1",
+		"DeleteSubnet subnet-1",
+		"DeleteSubnet subnet-2",
+		"DeleteRouteTable rtb-1",
+		"DeleteNetworkAcl acl-1",
+		"DeleteVpc vpc-1",
+	}
+	testCases := []struct {
+		name     string
+		failure  string
+		expected []string
+		category errors.Category
+	}{
+		{
+			name:     "all steps",
+			expected: all,
+		},
+		{
+			name:     "stop at the failed step",
+			failure:  "DeleteSecurityGroup sg-1",
+			expected: all[:13],
+			category: errors.Retryable,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, tc.name), func(t *testing.T) {
+			ec2svc := newMockVpc()
+			client := newClient(ec2svc)
+			plan, err := client.plan(RemediationDelete, "vpc-1")
+			assert.NoError(t, err)
+
+			if tc.failure != "" {
+				ec2svc.failures[tc.failure] = dependencyViolation(tc.failure + " has dependencies")
+			}
+			err = client.execute(context.Background(), plan)
+			assert.Equal(t, tc.expected, ec2svc.mutations())
+			if tc.category != 0 {
+				assert.Equal(t, tc.category, errors.CategoryOf(err))
+				// the revoke keep the rules of the default group that do not reference a group
+				assert.Equal(t, newMockVpc().groups[0].IpPermissions[1:], ec2svc.findGroup("sg-0").IpPermissions)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Empty(t, ec2svc.resources("vpc-1"))
+		})
+	}
+}
+
+func TestPoll(t *testing.T) {
+	testCases := []struct {
+		name     string
+		finished bool
+		err      error
+		category errors.Category
+	}{
+		{
+			name:     "done",
+			finished: true,
+		},
+		{
+			name:     "pending",
+			category: errors.Retryable,
+		},
+		{
+			name: "resource not found",
+			err:  awserr.New("InvalidNatGatewayID.NotFound", "not found", nil),
+		},
+		{
+			name:     "describe failed",
+			err:      awserr.New("UnauthorizedOperation", "denied", nil),
+			category: errors.Forbidden,
+		},
+		{
+			name:     "resource failed",
+			err:      errors.Wrapf(errors.Permanent, "NAT gateway nat-1 failed"),
+			category: errors.Permanent,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, tc.name), func(t *testing.T) {
+			calls := 0
+			err := poll("NAT gateway nat-1", func() (bool, error) {
+				calls++
+				return tc.finished, tc.err
+			})
+			// the poll does not wait, the resource is described once
+			assert.Equal(t, 1, calls)
+			if tc.category != 0 {
+				assert.Equal(t, tc.category, errors.CategoryOf(err))
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+// settlingEC2 terminate the pending instances after a few describes
+type settlingEC2 struct {
+	*mockEC2
+	describes int
+}
+
+func (m *settlingEC2) DescribeInstances(input *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+	if m.describes++; m.describes == 3 {
+		m.pending = nil
+	}
+	return m.mockEC2.DescribeInstances(input)
+}
+
+func TestExecuteWait(t *testing.T) {
+	defer func(interval time.Duration) { waitInterval = interval }(waitInterval)
+	waitInterval = time.Millisecond
+
+	// the invocation that remediate the whole VPC wait for the pending instance
+	ec2svc := newTwoTierVpc()
+	ec2svc.pending = map[string]bool{"i-1": true}
+	client := newClient(&settlingEC2{mockEC2: ec2svc})
+	plan, err := client.plan(RemediationDelete, "vpc-1")
+	assert.NoError(t, err)
+	assert.NoError(t, client.execute(context.Background(), plan))
+	mutations := ec2svc.mutations()
+	assert.Equal(t, twoTierTeardown[:3], mutations[:3])
+	assert.Equal(t, []string{"DescribeInstances i-1", "DescribeInstances i-1"}, mutations[3:5])
+	assert.Equal(t, twoTierTeardown[3:], mutations[5:])
+
+	// without time left before the deadline, the pending step fail as retryable
+	ec2svc = newTwoTierVpc()
+	ec2svc.pending = map[string]bool{"i-1": true}
+	client = newClient(ec2svc)
+	plan, err = client.plan(RemediationDelete, "vpc-1")
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	err = client.execute(ctx, plan)
+	assert.Equal(t, errors.Retryable, errors.CategoryOf(err))
+	assert.True(t, isPending(err))
+	assert.Equal(t, twoTierTeardown[:3], ec2svc.mutations())
+}
+
+func TestExecuteUnknownStep(t *testing.T) {
+	ec2svc := newMockEC2()
+	client := newClient(ec2svc)
+	err := client.execute(context.Background(), &Plan{VpcID: "vpc-1", Steps: []Step{
+		{ResourceID: "vpc-1", ResourceType: ResourceVpc, Action: "shred"},
+	}})
+	assert.Equal(t, errors.Validation, errors.CategoryOf(err))
+	assert.Empty(t, ec2svc.calls)
+}
+
+func TestRequestMode(t *testing.T) {
+	testCases := []struct {
+		payload     string
+		defaultMode string
+		expected    string
+		hasError    bool
+	}{
+		{
+			payload:     `{"alertId": "P-1", "resourceId": "vpc-1"}`,
+			defaultMode: ModeExecute,
+			expected:    ModeExecute,
+		},
+		{
+			payload:     `{"alertId": "P-1", "resourceId": "vpc-1"}`,
+			defaultMode: ModePlan,
+			expected:    ModePlan,
+		},
+		{
+			payload:     `{"alertId": "P-1", "resourceId": "vpc-1", "mode": "plan"}`,
+			defaultMode: ModeExecute,
+			expected:    ModePlan,
+		},
+		{
+			payload:     `{"alertId": "P-1", "resourceId": "vpc-1", "mode": "delete"}`,
+			defaultMode: ModeExecute,
+			hasError:    true,
+		},
+	}
+
+	defer func() { defaultMode = ModeExecute }()
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, tc.payload), func(t *testing.T) {
+			defaultMode = tc.defaultMode
+			request := Request{}
+			assert.NoError(t, json.Unmarshal([]byte(tc.payload), &request))
+			assert.Equal(t, "P-1", request.AlertID)
+			assert.Equal(t, "vpc-1", request.ResourceID)
+
+			mode, err := request.mode()
+			if tc.hasError {
+				assert.Equal(t, errors.Validation, errors.CategoryOf(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, mode)
+		})
+	}
+}
+
+func TestPlanJSON(t *testing.T) {
+	plan := &Plan{AlertID: "P-1", VpcID: "vpc-1", Mode: ModePlan}
+	plan.add(ResourceSubnet, "subnet-1", ActionDelete)
+	plan.add(ResourceVpc, "vpc-1", ActionDelete, "subnet-1")
+
+	document, err := json.Marshal(plan)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"alertId": "P-1",
+		"vpcId": "vpc-1",
+		"mode": "plan",
+		"steps": [
+			{"resourceId": "subnet-1", "resourceType": "subnet", "action": "delete"},
+			{"resourceId": "vpc-1", "resourceType": "vpc", "action": "delete", "dependsOn": ["subnet-1"]}
+		]
+	}`, string(document))
+}
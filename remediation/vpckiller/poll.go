@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+)
+
+// waitInterval and waitTimeout bound the waits of an invocation that remediate the whole VPC,
+// waitTimeout is used when the context has no deadline
+var (
+	waitInterval = 10 * time.Second
+	waitTimeout  = 10 * time.Minute
+)
+
+// pendingError is the error of a wait step whose resource is not ready yet
+type pendingError struct {
+	description string
+}
+
+func (e *pendingError) Error() string {
+	return e.description + " is pending"
+}
+
+// pending return the Retryable error of a pending resource
+func pending(description string) error {
+	return errors.Wrap(errors.Retryable, &pendingError{description: description})
+}
+
+// isPending return true when the step failed because its resource is pending
+func isPending(err error) bool {
+	for current := err; current != nil; {
+		if _, ok := current.(*pendingError); ok {
+			return true
+		}
+		wrapper, ok := current.(interface{ Unwrap() error })
+		if !ok {
+			return false
+		}
+		current = wrapper.Unwrap()
+	}
+	return false
+}
+
+// poll call done once and does not wait, a pending error is returned while the resource is pending
+// The phases of the state machine are retried on it, execute wait and run the step again
+func poll(description string, done func() (bool, error)) error {
+	finished, err := done()
+	if err != nil {
+		if errors.CategoryOf(err) == errors.NotFound {
+			return nil
+		}
+		return awsErrorHandler(err)
+	}
+	if !finished {
+		return pending(description)
+	}
+	return nil
+}
+
+// sleep wait the interval, it return false when the interval end after the deadline or the context is done
+func sleep(ctx context.Context, deadline time.Time, interval time.Duration) bool {
+	if time.Until(deadline) < interval {
+		return false
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(interval):
+		return true
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// QuarantineGroupName and QuarantineACLName name the isolation security group and the deny-all network ACL of a quarantined VPC
+const (
+	QuarantineGroupName = "prisma-quarantine-sg"
+	QuarantineACLName   = "prisma-quarantine-acl"
+)
+
+// Tags of the quarantined resources, the original tags record the state a restore put back
+const (
+	TagAlertID           = "prisma-remediation-alert-id"
+	TagQuarantinedAt     = "prisma-remediation-quarantined-at"
+	TagOriginalVpc       = "prisma-quarantine-original-vpc"
+	TagOriginalACL       = "prisma-quarantine-original-acl"
+	TagOriginalGroups    = "prisma-quarantine-original-groups"
+	TagOriginalInterface = "prisma-quarantine-original-interface"
+	TagOriginalPrivateIP = "prisma-quarantine-original-private-ip"
+)
+
+// quarantineGroupDescription is the description of the isolation security group
+const quarantineGroupDescription = "Deny all the traffic of the resources quarantined by Prisma Cloud remediation"
+
+// now is the time of the quarantine tags
+var now = time.Now
+
+// quarantineTags return the alert ID and timestamp tags with the original state tags
+func quarantineTags(plan *Plan, original ...*ec2.Tag) []*ec2.Tag {
+	return append([]*ec2.Tag{
+		{Key: aws.String(TagAlertID), Value: aws.String(plan.AlertID)},
+		{Key: aws.String(TagQuarantinedAt), Value: aws.String(now().UTC().Format(time.RFC3339))},
+	}, original...)
+}
+
+// quarantinePlan isolate the VPC, its internet gateways are detached, its subnets use a deny-all network ACL
+// and the network interfaces of its instances use a security group without rules
+func (inv *inventory) quarantinePlan(vpcID string) *Plan {
+	plan := &Plan{VpcID: vpcID}
+
+	groupID := QuarantineGroupName
+	if group := inv.quarantineGroup(); group != nil {
+		groupID = *group.GroupId
+	} else {
+		plan.add(ResourceQuarantineSecurityGroup, QuarantineGroupName, ActionCreate)
+	}
+	for _, networkInterface := range inv.interfaces {
+		attachment := networkInterface.Attachment
+		if aws.BoolValue(networkInterface.RequesterManaged) || attachment == nil || attachment.InstanceId == nil {
+			continue
+		}
+		plan.add(ResourceNetworkInterface, *networkInterface.NetworkInterfaceId, ActionIsolate, plan.planned(groupID)...)
+	}
+
+	aclID := QuarantineACLName
+	if acl := inv.quarantineACL(); acl != nil {
+		aclID = *acl.NetworkAclId
+	} else {
+		plan.add(ResourceQuarantineNetworkACL, QuarantineACLName, ActionCreate)
+	}
+	for _, subnet := range inv.subnets {
+		plan.add(ResourceSubnet, *subnet.SubnetId, ActionIsolate, plan.planned(aclID)...)
+	}
+
+	// the internet gateway cannot be detached while the VPC has mapped Elastic IPs,
+	// the address of a NAT gateway cannot be disassociated, the network ACL isolate its subnet
+	var addressIDs []string
+	for _, address := range inv.addresses {
+		if inv.addressNatGateway(address) != "" {
+			continue
+		}
+		plan.add(ResourceElasticIP, *address.AllocationId, ActionIsolate)
+		addressIDs = append(addressIDs, *address.AllocationId)
+	}
+	for _, gateway := range inv.internetGateways {
+		plan.add(ResourceInternetGateway, *gateway.InternetGatewayId, ActionIsolate, addressIDs...)
+	}
+
+	plan.add(ResourceVpc, vpcID, ActionIsolate, plan.resourceIDs()...)
+	return plan
+}
+
+// quarantineGroup return the isolation security group of the VPC, nil when it does not exist yet
+func (inv *inventory) quarantineGroup() *ec2.SecurityGroup {
+	for _, group := range inv.groups {
+		if aws.StringValue(group.GroupName) == QuarantineGroupName {
+			return group
+		}
+	}
+	return nil
+}
+
+// quarantineACL return the deny-all network ACL of the VPC, nil when it does not exist yet
+func (inv *inventory) quarantineACL() *ec2.NetworkAcl {
+	for _, acl := range inv.acls {
+		if tagValue(acl.Tags, "Name") == QuarantineACLName {
+			return acl
+		}
+	}
+	return nil
+}
+
+// findQuarantineGroup return the ID of the isolation security group of the VPC, nil when it does not exist
+func (c *Client) findQuarantineGroup(vpcID string) (*string, error) {
+	output, err := c.ec2svc.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{
+		Filters: append(vpcFilter("vpc-id", vpcID), &ec2.Filter{
+			Name:   aws.String("group-name"),
+			Values: []*string{aws.String(QuarantineGroupName)},
+		}),
+	})
+	if err != nil {
+		return nil, awsErrorHandler(err)
+	}
+	if len(output.SecurityGroups) == 0 {
+		return nil, nil
+	}
+	return output.SecurityGroups[0].GroupId, nil
+}
+
+// findQuarantineACL return the ID of the deny-all network ACL of the VPC, nil when it does not exist
+func (c *Client) findQuarantineACL(vpcID string) (*string, error) {
+	output, err := c.ec2svc.DescribeNetworkAcls(&ec2.DescribeNetworkAclsInput{
+		Filters: append(vpcFilter("vpc-id", vpcID), &ec2.Filter{
+			Name:   aws.String("tag:Name"),
+			Values: []*string{aws.String(QuarantineACLName)},
+		}),
+	})
+	if err != nil {
+		return nil, awsErrorHandler(err)
+	}
+	if len(output.NetworkAcls) == 0 {
+		return nil, nil
+	}
+	return output.NetworkAcls[0].NetworkAclId, nil
+}
+
+// createQuarantineGroup create the isolation security group, it has no rule so it deny all the traffic
+func (c *Client) createQuarantineGroup(plan *Plan, step Step) error {
+	if groupID, err := c.findQuarantineGroup(plan.VpcID); err != nil || groupID != nil {
+		return err
+	}
+	output, err := c.ec2svc.CreateSecurityGroup(&ec2.CreateSecurityGroupInput{
+		GroupName:   aws.String(QuarantineGroupName),
+		Description: aws.String(quarantineGroupDescription),
+		VpcId:       aws.String(plan.VpcID),
+	})
+	if err != nil {
+		return awsErrorHandler(err)
+	}
+	// a new security group allow all the outbound traffic
+	if _, err := c.ec2svc.RevokeSecurityGroupEgress(&ec2.RevokeSecurityGroupEgressInput{
+		GroupId: output.GroupId,
+		IpPermissions: []*ec2.IpPermission{
+			{IpProtocol: aws.String("-1"), IpRanges: []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}}},
+		},
+	}); err != nil {
+		return awsErrorHandler(err)
+	}
+	return c.tag(output.GroupId, quarantineTags(plan))
+}
+
+// createQuarantineACL create the deny-all network ACL, a new network ACL deny all the traffic
+func (c *Client) createQuarantineACL(plan *Plan, step Step) error {
+	if aclID, err := c.findQuarantineACL(plan.VpcID); err != nil || aclID != nil {
+		return err
+	}
+	output, err := c.ec2svc.CreateNetworkAcl(&ec2.CreateNetworkAclInput{
+		VpcId: aws.String(plan.VpcID),
+	})
+	if err != nil {
+		return awsErrorHandler(err)
+	}
+	return c.tag(output.NetworkAcl.NetworkAclId, quarantineTags(plan, &ec2.Tag{
+		Key:   aws.String("Name"),
+		Value: aws.String(QuarantineACLName),
+	}))
+}
+
+// isolateNetworkInterface record the security groups of the network interface and replace them by the isolation group
+func (c *Client) isolateNetworkInterface(plan *Plan, step Step) error {
+	groupID, err := c.findQuarantineGroup(plan.VpcID)
+	if err != nil {
+		return err
+	}
+	if groupID == nil {
+		return errors.Wrapf(errors.Permanent, "isolate %s: the quarantine security group of %s does not exist", step.ResourceID, plan.VpcID)
+	}
+	networkInterface, err := c.networkInterface(step.ResourceID)
+	if err != nil {
+		return err
+	}
+	var groupIDs []string
+	for _, group := range networkInterface.Groups {
+		groupIDs = append(groupIDs, aws.StringValue(group.GroupId))
+	}
+	// an isolated interface keep the groups it was recorded with
+	if len(groupIDs) == 1 && groupIDs[0] == *groupID {
+		return nil
+	}
+	if err := c.tag(networkInterface.NetworkInterfaceId, quarantineTags(plan, &ec2.Tag{
+		Key:   aws.String(TagOriginalGroups),
+		Value: aws.String(strings.Join(groupIDs, ",")),
+	})); err != nil {
+		return err
+	}
+	_, err = c.ec2svc.ModifyNetworkInterfaceAttribute(&ec2.ModifyNetworkInterfaceAttributeInput{
+		NetworkInterfaceId: networkInterface.NetworkInterfaceId,
+		Groups:             []*string{groupID},
+	})
+	return awsErrorHandler(err)
+}
+
+// isolateSubnet record the network ACL of the subnet and associate the subnet with the deny-all network ACL
+func (c *Client) isolateSubnet(plan *Plan, step Step) error {
+	aclID, err := c.findQuarantineACL(plan.VpcID)
+	if err != nil {
+		return err
+	}
+	if aclID == nil {
+		return errors.Wrapf(errors.Permanent, "isolate %s: the quarantine network ACL of %s does not exist", step.ResourceID, plan.VpcID)
+	}
+	currentACLID, associationID, err := c.subnetACL(step.ResourceID)
+	if err != nil {
+		return err
+	}
+	if currentACLID == *aclID {
+		return nil
+	}
+	if err := c.tag(aws.String(step.ResourceID), quarantineTags(plan, &ec2.Tag{
+		Key:   aws.String(TagOriginalACL),
+		Value: aws.String(currentACLID),
+	})); err != nil {
+		return err
+	}
+	_, err = c.ec2svc.ReplaceNetworkAclAssociation(&ec2.ReplaceNetworkAclAssociationInput{
+		AssociationId: aws.String(associationID),
+		NetworkAclId:  aclID,
+	})
+	return awsErrorHandler(err)
+}
+
+// isolateAddress record the association of the Elastic IP and disassociate it
+func (c *Client) isolateAddress(plan *Plan, step Step) error {
+	output, err := c.ec2svc.DescribeAddresses(&ec2.DescribeAddressesInput{
+		AllocationIds: []*string{aws.String(step.ResourceID)},
+	})
+	if err != nil {
+		return awsErrorHandler(err)
+	}
+	for _, address := range output.Addresses {
+		if address.AssociationId == nil {
+			continue
+		}
+		if err := c.tag(address.AllocationId, quarantineTags(plan,
+			&ec2.Tag{Key: aws.String(TagOriginalInterface), Value: address.NetworkInterfaceId},
+			&ec2.Tag{Key: aws.String(TagOriginalPrivateIP), Value: address.PrivateIpAddress},
+		)); err != nil {
+			return err
+		}
+		if _, err := c.ec2svc.DisassociateAddress(&ec2.DisassociateAddressInput{
+			AssociationId: address.AssociationId,
+		}); err != nil {
+			return awsErrorHandler(err)
+		}
+	}
+	return nil
+}
+
+// isolateInternetGateway record the VPC of the internet gateway and detach it,
+// the gateway stay attached when the VPC has public addresses that cannot be unmapped, such as NAT gateway addresses
+func (c *Client) isolateInternetGateway(plan *Plan, step Step) error {
+	if err := c.tag(aws.String(step.ResourceID), quarantineTags(plan, &ec2.Tag{
+		Key:   aws.String(TagOriginalVpc),
+		Value: aws.String(plan.VpcID),
+	})); err != nil {
+		return err
+	}
+	_, err := c.ec2svc.DetachInternetGateway(&ec2.DetachInternetGatewayInput{
+		InternetGatewayId: aws.String(step.ResourceID),
+		VpcId:             aws.String(plan.VpcID),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "DependencyViolation" {
+		fmt.Printf("%s stay attached, %s: the subnets of %s are isolated by the network ACL\n", step.ResourceID, aerr.Message(), plan.VpcID)
+		return c.untag(step.ResourceID)
+	}
+	return awsErrorHandler(err)
+}
+
+// isolateVpc tag the VPC with the alert that quarantined it
+func (c *Client) isolateVpc(plan *Plan, step Step) error {
+	return c.tag(aws.String(step.ResourceID), quarantineTags(plan))
+}
+
+// networkInterface describe a network interface
+func (c *Client) networkInterface(interfaceID string) (*ec2.NetworkInterface, error) {
+	output, err := c.ec2svc.DescribeNetworkInterfaces(&ec2.DescribeNetworkInterfacesInput{
+		NetworkInterfaceIds: []*string{aws.String(interfaceID)},
+	})
+	if err != nil {
+		return nil, awsErrorHandler(err)
+	}
+	if len(output.NetworkInterfaces) == 0 {
+		return nil, errors.Wrapf(errors.NotFound, "network interface %s not found", interfaceID)
+	}
+	return output.NetworkInterfaces[0], nil
+}
+
+// subnetACL return the network ACL of the subnet and the ID of its association
+func (c *Client) subnetACL(subnetID string) (string, string, error) {
+	output, err := c.ec2svc.DescribeNetworkAcls(&ec2.DescribeNetworkAclsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("association.subnet-id"),
+				Values: []*string{aws.String(subnetID)},
+			},
+		},
+	})
+	if err != nil {
+		return "", "", awsErrorHandler(err)
+	}
+	for _, acl := range output.NetworkAcls {
+		for _, association := range acl.Associations {
+			if aws.StringValue(association.SubnetId) == subnetID {
+				return *acl.NetworkAclId, aws.StringValue(association.NetworkAclAssociationId), nil
+			}
+		}
+	}
+	return "", "", errors.Wrapf(errors.NotFound, "network ACL association of subnet %s not found", subnetID)
+}
+
+// tag add the tags to the resource
+func (c *Client) tag(resourceID *string, tags []*ec2.Tag) error {
+	_, err := c.ec2svc.CreateTags(&ec2.CreateTagsInput{
+		Resources: []*string{resourceID},
+		Tags:      tags,
+	})
+	return awsErrorHandler(err)
+}
+
+// tagValue return the value of the tag key, an empty string when the tag is missing
+func tagValue(tags []*ec2.Tag, key string) string {
+	for _, tag := range tags {
+		if aws.StringValue(tag.Key) == key {
+			return aws.StringValue(tag.Value)
+		}
+	}
+	return ""
+}
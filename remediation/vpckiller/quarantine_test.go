@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+)
+
+func (m *mockEC2) CreateSecurityGroup(input *ec2.CreateSecurityGroupInput) (*ec2.CreateSecurityGroupOutput, error) {
+	m.groups = append(m.groups, &ec2.SecurityGroup{GroupId: aws.String("sg-quarantine"), VpcId: input.VpcId, GroupName: input.GroupName})
+	return &ec2.CreateSecurityGroupOutput{GroupId: aws.String("sg-quarantine")}, m.call("CreateSecurityGroup", *input.GroupName)
+}
+
+func (m *mockEC2) RevokeSecurityGroupEgress(input *ec2.RevokeSecurityGroupEgressInput) (*ec2.RevokeSecurityGroupEgressOutput, error) {
+	return &ec2.RevokeSecurityGroupEgressOutput{}, m.call("RevokeSecurityGroupEgress", *input.GroupId)
+}
+
+func (m *mockEC2) CreateNetworkAcl(input *ec2.CreateNetworkAclInput) (*ec2.CreateNetworkAclOutput, error) {
+	acl := &ec2.NetworkAcl{NetworkAclId: aws.String("acl-quarantine"), VpcId: input.VpcId}
+	m.acls = append(m.acls, acl)
+	return &ec2.CreateNetworkAclOutput{NetworkAcl: acl}, m.call("CreateNetworkAcl", *input.VpcId)
+}
+
+// CreateTags tag the resource, the original state tags are recorded in the call
+func (m *mockEC2) CreateTags(input *ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error) {
+	resourceTags := m.tagsOf(*input.Resources[0])
+	var tags []string
+	for _, tag := range input.Tags {
+		switch aws.StringValue(tag.Key) {
+		case TagAlertID, TagQuarantinedAt, "Name":
+		default:
+			tags = append(tags, aws.StringValue(tag.Key)+"="+aws.StringValue(tag.Value))
+		}
+		if resourceTags != nil {
+			*resourceTags = append(removeTag(*resourceTags, *tag.Key), tag)
+		}
+	}
+	return &ec2.CreateTagsOutput{}, m.call("CreateTags", *input.Resources[0]+" "+strings.Join(tags, ","))
+}
+
+func (m *mockEC2) DeleteTags(input *ec2.DeleteTagsInput) (*ec2.DeleteTagsOutput, error) {
+	if resourceTags := m.tagsOf(*input.Resources[0]); resourceTags != nil {
+		for _, tag := range input.Tags {
+			*resourceTags = removeTag(*resourceTags, *tag.Key)
+		}
+	}
+	return &ec2.DeleteTagsOutput{}, m.call("DeleteTags", *input.Resources[0])
+}
+
+// tagsOf return the tags of the resource, nil when the resource does not exist
+func (m *mockEC2) tagsOf(id string) *[]*ec2.Tag {
+	for _, vpc := range m.vpcs {
+		if *vpc.VpcId == id {
+			return &vpc.Tags
+		}
+	}
+	for _, subnet := range m.subnets {
+		if *subnet.SubnetId == id {
+			return &subnet.Tags
+		}
+	}
+	for _, gateway := range m.gateways {
+		if *gateway.InternetGatewayId == id {
+			return &gateway.Tags
+		}
+	}
+	for _, networkInterface := range m.interfaces {
+		if *networkInterface.NetworkInterfaceId == id {
+			return &networkInterface.TagSet
+		}
+	}
+	for _, address := range m.addresses {
+		if *address.AllocationId == id {
+			return &address.Tags
+		}
+	}
+	for _, group := range m.groups {
+		if *group.GroupId == id {
+			return &group.Tags
+		}
+	}
+	for _, acl := range m.acls {
+		if *acl.NetworkAclId == id {
+			return &acl.Tags
+		}
+	}
+	return nil
+}
+
+func removeTag(tags []*ec2.Tag, key string) []*ec2.Tag {
+	var kept []*ec2.Tag
+	for _, tag := range tags {
+		if *tag.Key != key {
+			kept = append(kept, tag)
+		}
+	}
+	return kept
+}
+
+func (m *mockEC2) ModifyNetworkInterfaceAttribute(input *ec2.ModifyNetworkInterfaceAttributeInput) (*ec2.ModifyNetworkInterfaceAttributeOutput, error) {
+	groups := strings.Join(aws.StringValueSlice(input.Groups), ",")
+	if err := m.call("ModifyNetworkInterfaceAttribute", *input.NetworkInterfaceId+" "+groups); err != nil {
+		return nil, err
+	}
+	for _, networkInterface := range m.interfaces {
+		if *networkInterface.NetworkInterfaceId == *input.NetworkInterfaceId {
+			networkInterface.Groups = nil
+			for _, groupID := range input.Groups {
+				networkInterface.Groups = append(networkInterface.Groups, &ec2.GroupIdentifier{GroupId: groupID})
+			}
+		}
+	}
+	return &ec2.ModifyNetworkInterfaceAttributeOutput{}, nil
+}
+
+// ReplaceNetworkAclAssociation move the association to the network ACL
+func (m *mockEC2) ReplaceNetworkAclAssociation(input *ec2.ReplaceNetworkAclAssociationInput) (*ec2.ReplaceNetworkAclAssociationOutput, error) {
+	var moved *ec2.NetworkAclAssociation
+	for _, acl := range m.acls {
+		for i, association := range acl.Associations {
+			if *association.NetworkAclAssociationId == *input.AssociationId {
+				moved = association
+				acl.Associations = append(acl.Associations[:i], acl.Associations[i+1:]...)
+				break
+			}
+		}
+	}
+	for _, acl := range m.acls {
+		if moved != nil && *acl.NetworkAclId == *input.NetworkAclId {
+			acl.Associations = append(acl.Associations, moved)
+		}
+	}
+	return &ec2.ReplaceNetworkAclAssociationOutput{NewAssociationId: input.AssociationId},
+		m.call("ReplaceNetworkAclAssociation", *input.AssociationId+" "+*input.NetworkAclId)
+}
+
+func (m *mockEC2) AssociateAddress(input *ec2.AssociateAddressInput) (*ec2.AssociateAddressOutput, error) {
+	call := fmt.Sprintf("%s %s %s", *input.AllocationId, *input.NetworkInterfaceId, aws.StringValue(input.PrivateIpAddress))
+	if err := m.call("AssociateAddress", call); err != nil {
+		return nil, err
+	}
+	for _, address := range m.addresses {
+		if *address.AllocationId == *input.AllocationId {
+			address.AssociationId = aws.String("eipassoc-" + strings.TrimPrefix(*input.AllocationId, "eipalloc-"))
+			address.NetworkInterfaceId = input.NetworkInterfaceId
+		}
+	}
+	return &ec2.AssociateAddressOutput{}, nil
+}
+
+func stepLines(plan *Plan) []string {
+	var lines []string
+	for _, step := range plan.Steps {
+		line := fmt.Sprintf("%s %s %s", step.Action, step.ResourceType, step.ResourceID)
+		if len(step.DependsOn) > 0 {
+			line += " after " + strings.Join(step.DependsOn, ", ")
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestQuarantine(t *testing.T) {
+	defer func() { now = time.Now }()
+	now = func() time.Time { return time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC) }
+
+	ec2svc := newMockVpc()
+	// EC2 do not detach an internet gateway while an instance of the VPC has a public IP
+	ec2svc.instances[0].PublicIpAddress = nil
+	client := newClient(ec2svc)
+	plan, err := client.plan(RemediationQuarantine, "vpc-1")
+	assert.NoError(t, err)
+	assert.Equal(t, RemediationQuarantine, plan.Remediation)
+	assert.Equal(t, []string{
+		"create quarantine-security-group prisma-quarantine-sg",
+		"isolate network-interface eni-i1 after prisma-quarantine-sg",
+		"create quarantine-network-acl prisma-quarantine-acl",
+		"isolate subnet subnet-1 after prisma-quarantine-acl",
+		"isolate subnet subnet-2 after prisma-quarantine-acl",
+		"isolate elastic-ip eipalloc-2",
+		"isolate internet-gateway igw-1 after eipalloc-2",
+		"isolate vpc vpc-1 after prisma-quarantine-sg, eni-i1, prisma-quarantine-acl, subnet-1, subnet-2, eipalloc-2, igw-1",
+	}, stepLines(plan))
+	assert.Empty(t, ec2svc.mutations())
+
+	plan.AlertID = "P-1"
+	assert.NoError(t, client.execute(context.Background(), plan))
+	assert.Equal(t, []string{
+		"CreateSecurityGroup prisma-quarantine-sg",
+		"RevokeSecurityGroupEgress sg-quarantine",
+		"CreateTags sg-quarantine ",
+		"CreateTags eni-i1 prisma-quarantine-original-groups=sg-1",
+		"ModifyNetworkInterfaceAttribute eni-i1 sg-quarantine",
+		"CreateNetworkAcl vpc-1",
+		"CreateTags acl-quarantine ",
+		"CreateTags subnet-1 prisma-quarantine-original-acl=acl-default",
+		"ReplaceNetworkAclAssociation aclassoc-1 acl-quarantine",
+		"CreateTags subnet-2 prisma-quarantine-original-acl=acl-1",
+		"ReplaceNetworkAclAssociation aclassoc-2 acl-quarantine",
+		"CreateTags eipalloc-2 prisma-quarantine-original-interface=eni-free,prisma-quarantine-original-private-ip=",
+		"DisassociateAddress eipassoc-2",
+		"CreateTags igw-1 prisma-quarantine-original-vpc=vpc-1",
+		"DetachInternetGateway igw-1",
+		"CreateTags vpc-1 ",
+	}, ec2svc.mutations())
+
+	// a second run find the quarantine group and network ACL, and the subnets already isolated
+	ec2svc.calls = nil
+	plan, err = client.plan(RemediationQuarantine, "vpc-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "isolate network-interface eni-i1", stepLines(plan)[0])
+	for _, step := range plan.Steps {
+		if step.ResourceType == ResourceSubnet {
+			assert.NoError(t, client.run(plan, step))
+		}
+	}
+	assert.Empty(t, ec2svc.mutations())
+}
+
+func TestQuarantineTags(t *testing.T) {
+	defer func() { now = time.Now }()
+	now = func() time.Time { return time.Date(2020, 3, 1, 12, 0, 0, 0, time.FixedZone("EST", -5*3600)) }
+
+	tags := quarantineTags(&Plan{AlertID: "P-1"}, &ec2.Tag{Key: aws.String(TagOriginalVpc), Value: aws.String("vpc-1")})
+	var actual []string
+	for _, tag := range tags {
+		actual = append(actual, aws.StringValue(tag.Key)+"="+aws.StringValue(tag.Value))
+	}
+	sort.Strings(actual)
+	assert.Equal(t, []string{
+		"prisma-quarantine-original-vpc=vpc-1",
+		"prisma-remediation-alert-id=P-1",
+		"prisma-remediation-quarantined-at=2020-03-01T17:00:00Z",
+	}, actual)
+}
+
+func tags(pairs ...string) []*ec2.Tag {
+	var tags []*ec2.Tag
+	for i := 0; i < len(pairs); i += 2 {
+		tags = append(tags, &ec2.Tag{Key: aws.String(pairs[i]), Value: aws.String(pairs[i+1])})
+	}
+	return tags
+}
+
+func newQuarantinedVpc() *mockEC2 {
+	ec2svc := newMockVpc()
+	ec2svc.gateways[0].Attachments = nil
+	ec2svc.gateways[0].Tags = tags(TagOriginalVpc, "vpc-1")
+	ec2svc.interfaces[1].Groups = []*ec2.GroupIdentifier{{GroupId: aws.String("sg-quarantine")}}
+	ec2svc.interfaces[1].TagSet = tags(TagOriginalGroups, "sg-1,sg-0")
+	ec2svc.addresses[1].AssociationId = nil
+	ec2svc.addresses[1].Tags = tags(TagOriginalInterface, "eni-free", TagOriginalPrivateIP, "10.0.2.10")
+	ec2svc.subnets[0].Tags = tags(TagOriginalACL, "acl-default")
+	ec2svc.groups = append(ec2svc.groups, &ec2.SecurityGroup{
+		GroupId:   aws.String("sg-quarantine"),
+		VpcId:     aws.String("vpc-1"),
+		GroupName: aws.String(QuarantineGroupName),
+	})
+	quarantined := ec2svc.acls[0].Associations[0]
+	ec2svc.acls[0].Associations = nil
+	ec2svc.acls = append(ec2svc.acls, &ec2.NetworkAcl{
+		NetworkAclId: aws.String("acl-quarantine"),
+		VpcId:        aws.String("vpc-1"),
+		Tags:         tags("Name", QuarantineACLName),
+		Associations: []*ec2.NetworkAclAssociation{quarantined},
+	})
+	return ec2svc
+}
+
+func TestRestore(t *testing.T) {
+	ec2svc := newQuarantinedVpc()
+	client := newClient(ec2svc)
+	plan, err := client.plan(RemediationRestore, "vpc-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"restore internet-gateway igw-1",
+		"restore elastic-ip eipalloc-2 after igw-1",
+		"restore subnet subnet-1",
+		"restore network-interface eni-i1",
+		"delete quarantine-network-acl acl-quarantine after subnet-1",
+		"delete quarantine-security-group sg-quarantine after eni-i1",
+		"restore vpc vpc-1 after igw-1, eipalloc-2, subnet-1, eni-i1, acl-quarantine, sg-quarantine",
+	}, stepLines(plan))
+	assert.Empty(t, ec2svc.mutations())
+
+	assert.NoError(t, client.execute(context.Background(), plan))
+	assert.Equal(t, []string{
+		"AttachInternetGateway igw-1",
+		"DeleteTags igw-1",
+		"AssociateAddress eipalloc-2 eni-free 10.0.2.10",
+		"DeleteTags eipalloc-2",
+		"ReplaceNetworkAclAssociation aclassoc-1 acl-default",
+		"DeleteTags subnet-1",
+		"ModifyNetworkInterfaceAttribute eni-i1 sg-1,sg-0",
+		"DeleteTags eni-i1",
+		"DeleteNetworkAcl acl-quarantine",
+		"DeleteSecurityGroup sg-quarantine",
+		"DeleteTags vpc-1",
+	}, ec2svc.mutations())
+}
+
+func TestRequestRemediation(t *testing.T) {
+	testCases := []struct {
+		remediation string
+		accountID   string
+		expected    string
+		hasError    bool
+	}{
+		{accountID: "123456789012", expected: RemediationDelete},
+		{accountID: "210987654321", expected: RemediationQuarantine},
+		{remediation: RemediationDelete, accountID: "210987654321", expected: RemediationDelete},
+		{remediation: RemediationRestore, accountID: "123456789012", expected: RemediationRestore},
+		{remediation: RemediationRollback, accountID: "123456789012", expected: RemediationRollback},
+		{remediation: "shred", hasError: true},
+	}
+
+	defer func() { accountRemediations = map[string]string{} }()
+	accountRemediations = map[string]string{"210987654321": RemediationQuarantine}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s %s", i, tc.remediation, tc.accountID), func(t *testing.T) {
+			request := &Request{Remediation: tc.remediation}
+			request.AccountID = tc.accountID
+			remediation, err := request.remediation()
+			if tc.hasError {
+				assert.Equal(t, errors.Validation, errors.CategoryOf(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, remediation)
+		})
+	}
+}
+
+func TestValidateRestore(t *testing.T) {
+	request := &Request{Remediation: RemediationRestore}
+	request.ResourceID = "vpc-1"
+	err := request.validateRestore()
+	assert.Equal(t, []string{"alertId", "accountId", "resourceRegionId"}, err.(errors.ValidationErrors).Paths())
+
+	request.AlertID = "P-1"
+	request.AccountID = "123456789012"
+	request.ResourceRegionID = "us-west-2"
+	assert.NoError(t, request.validateRestore())
+
+	request = &Request{Remediation: RemediationRollback}
+	request.ResourceID = "vpc-1"
+	err = request.validateRestore()
+	assert.Equal(t, []string{"alertId"}, err.(errors.ValidationErrors).Paths())
+	assert.Contains(t, err.Error(), "required field alertId of a rollback is empty")
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+)
+
+// RemediationEnv select the remediation of the alerts, delete by default
+const RemediationEnv = "VPCKILLER_REMEDIATION"
+
+// AccountRemediationsEnv select the remediation by account, it is a JSON object of account IDs to remediations
+//
+//	{"123456789012": "quarantine"}
+const AccountRemediationsEnv = "VPCKILLER_ACCOUNT_REMEDIATIONS"
+
+// Remediations of the VPC killer, restore undo a quarantine and rollback undo the journal of an alert,
+// they are only requested explicitly
+const (
+	RemediationDelete     = "delete"
+	RemediationQuarantine = "quarantine"
+	RemediationRestore    = "restore"
+	RemediationRollback   = "rollback"
+)
+
+// defaultRemediation and accountRemediations are read at cold start
+var (
+	defaultRemediation  = RemediationDelete
+	accountRemediations = map[string]string{}
+)
+
+// remediation return the remediation of the request, then the remediation of its account, then the default remediation
+func (r *Request) remediation() (string, error) {
+	switch r.Remediation {
+	case "":
+	case RemediationDelete, RemediationQuarantine, RemediationRestore, RemediationRollback:
+		return r.Remediation, nil
+	default:
+		return "", errors.Wrapf(errors.Validation, "invalid remediation %q: must be %s, %s, %s or %s",
+			r.Remediation, RemediationDelete, RemediationQuarantine, RemediationRestore, RemediationRollback)
+	}
+	if remediation, ok := accountRemediations[r.AccountID]; ok {
+		return remediation, nil
+	}
+	return defaultRemediation, nil
+}
+
+// validateRestore verify the fields a restore or a rollback need, they are not alerts
+// A restore is journaled with the alert ID of the quarantine, a rollback find the account and the region in the journal of the alert
+func (r *Request) validateRestore() error {
+	fields := []struct{ name, value string }{
+		{"alertId", r.AlertID},
+		{"accountId", r.AccountID},
+		{"resourceRegionId", r.ResourceRegionID},
+		{"resourceId", r.ResourceID},
+	}
+	if r.Remediation == RemediationRollback {
+		fields = []struct{ name, value string }{
+			{"alertId", r.AlertID},
+			{"resourceId", r.ResourceID},
+		}
+	}
+	validationErrors := errors.ValidationErrors{}
+	for _, field := range fields {
+		if field.value == "" {
+			validationErrors = append(validationErrors, &errors.FieldError{
+				Path:    field.name,
+				Rule:    "required",
+				Message: fmt.Sprintf("required field %s of a %s is empty", field.name, r.Remediation),
+			})
+		}
+	}
+	if len(validationErrors) > 0 {
+		return validationErrors
+	}
+	return nil
+}
+
+// loadRemediations read VPCKILLER_REMEDIATION and VPCKILLER_ACCOUNT_REMEDIATIONS
+func loadRemediations() error {
+	if value := os.Getenv(RemediationEnv); value != "" {
+		if !isAlertRemediation(value) {
+			return fmt.Errorf("invalid %s %q: must be %s or %s", RemediationEnv, value, RemediationDelete, RemediationQuarantine)
+		}
+		defaultRemediation = value
+	}
+	if value := os.Getenv(AccountRemediationsEnv); value != "" {
+		remediations := map[string]string{}
+		if err := json.Unmarshal([]byte(value), &remediations); err != nil {
+			return fmt.Errorf("invalid %s: %s", AccountRemediationsEnv, err.Error())
+		}
+		for accountID, remediation := range remediations {
+			if !isAlertRemediation(remediation) {
+				return fmt.Errorf("invalid %s remediation %q of account %s: must be %s or %s",
+					AccountRemediationsEnv, remediation, accountID, RemediationDelete, RemediationQuarantine)
+			}
+		}
+		accountRemediations = remediations
+	}
+	return nil
+}
+
+// isAlertRemediation return true for the remediations an alert can select by default
+func isAlertRemediation(remediation string) bool {
+	return remediation == RemediationDelete || remediation == RemediationQuarantine
+}
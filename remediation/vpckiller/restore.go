@@ -0,0 +1,190 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// restoreTags are removed from the restored resources
+var restoreTags = []string{
+	TagAlertID,
+	TagQuarantinedAt,
+	TagOriginalVpc,
+	TagOriginalACL,
+	TagOriginalGroups,
+	TagOriginalInterface,
+	TagOriginalPrivateIP,
+}
+
+// restorePlan undo the quarantine of the VPC with the original state recorded in the tags of its resources
+func (c *Client) restorePlan(vpcID string, inv *inventory) (*Plan, error) {
+	plan := &Plan{VpcID: vpcID}
+
+	// the detached internet gateways are not in the VPC anymore, they are found by their tag
+	var gatewayIDs []string
+	err := c.ec2svc.DescribeInternetGatewaysPages(&ec2.DescribeInternetGatewaysInput{Filters: vpcFilter("tag:"+TagOriginalVpc, vpcID)},
+		func(page *ec2.DescribeInternetGatewaysOutput, lastPage bool) bool {
+			for _, gateway := range page.InternetGateways {
+				plan.add(ResourceInternetGateway, *gateway.InternetGatewayId, ActionRestore)
+				gatewayIDs = append(gatewayIDs, *gateway.InternetGatewayId)
+			}
+			return true
+		})
+	if err != nil {
+		return nil, awsErrorHandler(err)
+	}
+
+	// Elastic IPs are associated again once the internet gateway is attached
+	addresses, err := c.ec2svc.DescribeAddresses(&ec2.DescribeAddressesInput{
+		Filters: []*ec2.Filter{{Name: aws.String("tag-key"), Values: []*string{aws.String(TagOriginalInterface)}}},
+	})
+	if err != nil {
+		return nil, awsErrorHandler(err)
+	}
+	for _, address := range addresses.Addresses {
+		if inv.hasInterface(tagValue(address.Tags, TagOriginalInterface)) {
+			plan.add(ResourceElasticIP, *address.AllocationId, ActionRestore, gatewayIDs...)
+		}
+	}
+
+	var subnetIDs []string
+	for _, subnet := range inv.subnets {
+		if tagValue(subnet.Tags, TagOriginalACL) != "" {
+			plan.add(ResourceSubnet, *subnet.SubnetId, ActionRestore)
+			subnetIDs = append(subnetIDs, *subnet.SubnetId)
+		}
+	}
+	var interfaceIDs []string
+	for _, networkInterface := range inv.interfaces {
+		if tagValue(networkInterface.TagSet, TagOriginalGroups) != "" {
+			plan.add(ResourceNetworkInterface, *networkInterface.NetworkInterfaceId, ActionRestore)
+			interfaceIDs = append(interfaceIDs, *networkInterface.NetworkInterfaceId)
+		}
+	}
+	if acl := inv.quarantineACL(); acl != nil {
+		plan.add(ResourceQuarantineNetworkACL, *acl.NetworkAclId, ActionDelete, subnetIDs...)
+	}
+	if group := inv.quarantineGroup(); group != nil {
+		plan.add(ResourceQuarantineSecurityGroup, *group.GroupId, ActionDelete, interfaceIDs...)
+	}
+	plan.add(ResourceVpc, vpcID, ActionRestore, plan.resourceIDs()...)
+	return plan, nil
+}
+
+// hasInterface return true when the network interface is in the VPC
+func (inv *inventory) hasInterface(interfaceID string) bool {
+	for _, networkInterface := range inv.interfaces {
+		if aws.StringValue(networkInterface.NetworkInterfaceId) == interfaceID {
+			return true
+		}
+	}
+	return false
+}
+
+// restoreInternetGateway attach the internet gateway to the VPC it was detached from
+func (c *Client) restoreInternetGateway(plan *Plan, step Step) error {
+	if _, err := c.ec2svc.AttachInternetGateway(&ec2.AttachInternetGatewayInput{
+		InternetGatewayId: aws.String(step.ResourceID),
+		VpcId:             aws.String(plan.VpcID),
+	}); err != nil {
+		return awsErrorHandler(err)
+	}
+	return c.untag(step.ResourceID)
+}
+
+// restoreAddress associate the Elastic IP with its original network interface and private IP
+func (c *Client) restoreAddress(plan *Plan, step Step) error {
+	output, err := c.ec2svc.DescribeAddresses(&ec2.DescribeAddressesInput{
+		AllocationIds: []*string{aws.String(step.ResourceID)},
+	})
+	if err != nil {
+		return awsErrorHandler(err)
+	}
+	for _, address := range output.Addresses {
+		interfaceID := tagValue(address.Tags, TagOriginalInterface)
+		if interfaceID == "" || address.AssociationId != nil {
+			continue
+		}
+		input := &ec2.AssociateAddressInput{
+			AllocationId:       address.AllocationId,
+			NetworkInterfaceId: aws.String(interfaceID),
+		}
+		if privateIP := tagValue(address.Tags, TagOriginalPrivateIP); privateIP != "" {
+			input.PrivateIpAddress = aws.String(privateIP)
+		}
+		if _, err := c.ec2svc.AssociateAddress(input); err != nil {
+			return awsErrorHandler(err)
+		}
+	}
+	return c.untag(step.ResourceID)
+}
+
+// restoreSubnet associate the subnet with its original network ACL
+func (c *Client) restoreSubnet(plan *Plan, step Step) error {
+	output, err := c.ec2svc.DescribeSubnets(&ec2.DescribeSubnetsInput{
+		SubnetIds: []*string{aws.String(step.ResourceID)},
+	})
+	if err != nil {
+		return awsErrorHandler(err)
+	}
+	if len(output.Subnets) == 0 {
+		return errors.Wrapf(errors.NotFound, "subnet %s not found", step.ResourceID)
+	}
+	originalACLID := tagValue(output.Subnets[0].Tags, TagOriginalACL)
+	if originalACLID == "" {
+		return nil
+	}
+	currentACLID, associationID, err := c.subnetACL(step.ResourceID)
+	if err != nil {
+		return err
+	}
+	if currentACLID != originalACLID {
+		if _, err := c.ec2svc.ReplaceNetworkAclAssociation(&ec2.ReplaceNetworkAclAssociationInput{
+			AssociationId: aws.String(associationID),
+			NetworkAclId:  aws.String(originalACLID),
+		}); err != nil {
+			return awsErrorHandler(err)
+		}
+	}
+	return c.untag(step.ResourceID)
+}
+
+// restoreNetworkInterface put back the original security groups of the network interface
+func (c *Client) restoreNetworkInterface(plan *Plan, step Step) error {
+	networkInterface, err := c.networkInterface(step.ResourceID)
+	if err != nil {
+		return err
+	}
+	original := tagValue(networkInterface.TagSet, TagOriginalGroups)
+	if original == "" {
+		return nil
+	}
+	if _, err := c.ec2svc.ModifyNetworkInterfaceAttribute(&ec2.ModifyNetworkInterfaceAttributeInput{
+		NetworkInterfaceId: networkInterface.NetworkInterfaceId,
+		Groups:             aws.StringSlice(strings.Split(original, ",")),
+	}); err != nil {
+		return awsErrorHandler(err)
+	}
+	return c.untag(step.ResourceID)
+}
+
+// restoreVpc remove the quarantine tags of the VPC
+func (c *Client) restoreVpc(plan *Plan, step Step) error {
+	return c.untag(step.ResourceID)
+}
+
+// untag remove the quarantine tags of the resource
+func (c *Client) untag(resourceID string) error {
+	var tags []*ec2.Tag
+	for _, key := range restoreTags {
+		tags = append(tags, &ec2.Tag{Key: aws.String(key)})
+	}
+	_, err := c.ec2svc.DeleteTags(&ec2.DeleteTagsInput{
+		Resources: []*string{aws.String(resourceID)},
+		Tags:      tags,
+	})
+	return awsErrorHandler(err)
+}
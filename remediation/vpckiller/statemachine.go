@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/approval"
+)
+
+// FunctionArnVariable is the variable of the function ARN in the state machine definition,
+// the template substitute it with DefinitionSubstitutions
+const FunctionArnVariable = "${PrismaVPCKillerArn}"
+
+// stateMachine is an Amazon States Language definition
+type stateMachine struct {
+	Comment string                 `json:"Comment"`
+	StartAt string                 `json:"StartAt"`
+	States  map[string]interface{} `json:"States"`
+}
+
+type taskState struct {
+	Type               string                 `json:"Type"`
+	Resource           string                 `json:"Resource"`
+	Parameters         map[string]interface{} `json:"Parameters"`
+	TimeoutSecondsPath string                 `json:"TimeoutSecondsPath,omitempty"`
+	Retry              []retrier              `json:"Retry,omitempty"`
+	Catch              []catcher              `json:"Catch,omitempty"`
+	Next               string                 `json:"Next,omitempty"`
+	End                bool                   `json:"End,omitempty"`
+}
+
+type retrier struct {
+	ErrorEquals     []string `json:"ErrorEquals"`
+	IntervalSeconds int      `json:"IntervalSeconds"`
+	MaxAttempts     int      `json:"MaxAttempts"`
+	BackoffRate     float64  `json:"BackoffRate"`
+}
+
+type catcher struct {
+	ErrorEquals []string `json:"ErrorEquals"`
+	ResultPath  string   `json:"ResultPath"`
+	Next        string   `json:"Next"`
+}
+
+type choiceState struct {
+	Type    string       `json:"Type"`
+	Choices []choiceRule `json:"Choices"`
+	Default string       `json:"Default"`
+}
+
+type choiceRule struct {
+	Variable      string `json:"Variable"`
+	BooleanEquals *bool  `json:"BooleanEquals,omitempty"`
+	IsPresent     *bool  `json:"IsPresent,omitempty"`
+	Next          string `json:"Next"`
+}
+
+type passState struct {
+	Type       string                 `json:"Type"`
+	Parameters map[string]interface{} `json:"Parameters"`
+	Next       string                 `json:"Next"`
+}
+
+type terminalState struct {
+	Type  string `json:"Type"`
+	Error string `json:"Error,omitempty"`
+	Cause string `json:"Cause,omitempty"`
+}
+
+// Names of the states that are not phases
+const (
+	stateRequest   = "Request"
+	stateRemediate = "Remediate"
+	stateSucceeded = "Succeeded"
+	stateRejected  = "Rejected"
+	stateFailed    = "Failed"
+)
+
+// waitForTaskToken is the integration that invoke the function and wait for the task token to be sent back
+const waitForTaskToken = "arn:aws:states:::lambda:invoke.waitForTaskToken"
+
+// lambdaErrors are the errors of Lambda that a new attempt can fix
+var lambdaErrors = []string{
+	"Lambda.ServiceException",
+	"Lambda.AWSLambdaException",
+	"Lambda.SdkClientException",
+	"Lambda.TooManyRequestsException",
+}
+
+// serviceErrors are the errors of Lambda and of Step Functions that a new attempt can fix
+var serviceErrors = append([]string{"States.Timeout"}, lambdaErrors...)
+
+// waitAttempts are the attempts of the phases that wait, 30 seconds apart
+var waitAttempts = map[string]int{
+	PhaseBackup: 60,
+	PhaseWait:   20,
+	PhaseClean:  20,
+}
+
+// definition return the state machine of the phases of a remediation
+// The phases are retried on a Retryable error or a timeout. A wait step fail as Retryable while its resource
+// is pending, so the phases that wait, the backup, wait and clean phases, are retried longer:
+// the snapshots and the images, the terminated instances and the detached network interfaces take many invocations.
+// Any other error go to the fail phase that dead-letter the alert.
+// A missing VPC found by verify succeed, there is nothing left to remediate.
+// A request that require an approval wait for the approver before the first phase, a rejected plan succeed
+// without running it and an expired approval go to the fail phase.
+func definition(functionArn string) *stateMachine {
+	machine := &stateMachine{
+		Comment: "Remediate a VPC of a Prisma Cloud alert phase by phase, the input is the alert",
+		StartAt: stateRequest,
+		States:  map[string]interface{}{},
+	}
+
+	// the alert is the request of the state, so every state take a state
+	machine.States[stateRequest] = &passState{
+		Type:       "Pass",
+		Parameters: map[string]interface{}{"request.$": "$"},
+		Next:       stateName(PhaseVerify),
+	}
+	verify := phaseTask(functionArn, PhaseVerify, stateRemediate)
+	verify.Catch = append([]catcher{{ErrorEquals: []string{"NotFoundError"}, ResultPath: "$.error", Next: stateSucceeded}}, verify.Catch...)
+	machine.States[stateName(PhaseVerify)] = verify
+
+	machine.States[stateRemediate] = &choiceState{
+		Type: "Choice",
+		Choices: []choiceRule{
+			{Variable: "$.done", BooleanEquals: aws.Bool(true), Next: stateSucceeded},
+			{Variable: "$.approvalTimeout", IsPresent: aws.Bool(true), Next: stateName(PhaseApprove)},
+		},
+		Default: stateName(phases[0]),
+	}
+	machine.States[stateName(PhaseApprove)] = approveTask(functionArn, stateName(phases[0]))
+	for i, phase := range phases {
+		next := stateSucceeded
+		if i+1 < len(phases) {
+			next = stateName(phases[i+1])
+		}
+		task := phaseTask(functionArn, phase, next)
+		if attempts, ok := waitAttempts[phase]; ok {
+			task.Retry[0] = retrier{ErrorEquals: []string{"RetryableError"}, IntervalSeconds: 30, MaxAttempts: attempts, BackoffRate: 1}
+		}
+		machine.States[stateName(phase)] = task
+	}
+
+	fail := phaseTask(functionArn, PhaseFail, stateFailed)
+	fail.Retry, fail.Catch = fail.Retry[1:], nil
+	machine.States[stateName(PhaseFail)] = fail
+	machine.States[stateSucceeded] = &terminalState{Type: "Succeed"}
+	machine.States[stateRejected] = &terminalState{Type: "Succeed"}
+	machine.States[stateFailed] = &terminalState{Type: "Fail", Error: "RemediationFailed", Cause: "The remediation failed, the alert is dead-lettered"}
+	return machine
+}
+
+// phaseTask invoke the function with the phase and the state, the state it return is the input of the next state
+func phaseTask(functionArn string, phase string, next string) *taskState {
+	return &taskState{
+		Type:       "Task",
+		Resource:   functionArn,
+		Parameters: map[string]interface{}{"phase": phase, "state.$": "$"},
+		Retry: []retrier{
+			{ErrorEquals: []string{"RetryableError"}, IntervalSeconds: 5, MaxAttempts: 3, BackoffRate: 2},
+			{ErrorEquals: serviceErrors, IntervalSeconds: 5, MaxAttempts: 3, BackoffRate: 2},
+		},
+		Catch: []catcher{{ErrorEquals: []string{"States.ALL"}, ResultPath: "$.error", Next: stateName(PhaseFail)}},
+		Next:  next,
+	}
+}
+
+// approveTask invoke the function with the task token and wait until the approver send it back
+// The approver send the stored state, so the phases execute the plan that was approved.
+// The task time out when the approval expire, it is not retried so the approvers are not asked again.
+func approveTask(functionArn string, next string) *taskState {
+	return &taskState{
+		Type:     "Task",
+		Resource: waitForTaskToken,
+		Parameters: map[string]interface{}{
+			"FunctionName": functionArn,
+			"Payload":      map[string]interface{}{"phase": PhaseApprove, "state.$": "$", "taskToken.$": "$$.Task.Token"},
+		},
+		TimeoutSecondsPath: "$.approvalTimeout",
+		Retry: []retrier{
+			{ErrorEquals: []string{"RetryableError"}, IntervalSeconds: 5, MaxAttempts: 3, BackoffRate: 2},
+			{ErrorEquals: lambdaErrors, IntervalSeconds: 5, MaxAttempts: 3, BackoffRate: 2},
+		},
+		Catch: []catcher{
+			{ErrorEquals: []string{approval.RejectedErrorName}, ResultPath: "$.error", Next: stateRejected},
+			{ErrorEquals: []string{"States.ALL"}, ResultPath: "$.error", Next: stateName(PhaseFail)},
+		},
+		Next: next,
+	}
+}
+
+// stateName return the state of the phase, such as Verify
+func stateName(phase string) string {
+	return strings.ToUpper(phase[:1]) + phase[1:]
+}
+
+// JSON return the indented definition
+func (m *stateMachine) JSON() ([]byte, error) {
+	return json.MarshalIndent(m, "", "  ")
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "write the state machine definition")
+
+// DefinitionFile is the state machine definition deployed by the template
+const definitionFile = "statemachine.asl.json"
+
+func TestStateMachineDefinition(t *testing.T) {
+	actual, err := definition(FunctionArnVariable).JSON()
+	assert.NoError(t, err)
+	actual = append(actual, '\n')
+	if *update {
+		assert.NoError(t, ioutil.WriteFile(definitionFile, actual, 0644))
+	}
+	expected, err := ioutil.ReadFile(definitionFile)
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), string(actual), "run go test -run TestStateMachineDefinition -update")
+}
+
+// execution is a run of the state machine on the handler, the retries do not wait
+type execution struct {
+	// beforeRetry is called before a task is retried
+	beforeRetry func(state string, errorName string)
+	// callback send back the task token of a task that wait for it, like the approver
+	// The output is the input of the next state, the error fail the task
+	callback func(taskToken string) ([]byte, error)
+	visited  []string
+}
+
+// taskError is an error sent back with a task token
+type taskError struct {
+	name  string
+	cause string
+}
+
+func (e *taskError) Error() string {
+	return e.cause
+}
+
+// run the state machine on the input and return its terminal state and its output
+func (e *execution) run(t *testing.T, machine *stateMachine, input []byte) (string, map[string]interface{}) {
+	name := machine.StartAt
+	document := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(input, &document))
+	attempts := map[string]int{}
+	for {
+		e.visited = append(e.visited, name)
+		switch state := machine.States[name].(type) {
+		case *passState:
+			document = map[string]interface{}{"request": document}
+			name = state.Next
+		case *choiceState:
+			name = state.Default
+			for _, choice := range state.Choices {
+				field := strings.TrimPrefix(choice.Variable, "$.")
+				value, present := document[field]
+				if (choice.BooleanEquals != nil && value == *choice.BooleanEquals) ||
+					(choice.IsPresent != nil && present == *choice.IsPresent) {
+					name = choice.Next
+					break
+				}
+			}
+		case *terminalState:
+			return name, document
+		case *taskState:
+			output, err := e.task(state, document)
+			if err == nil {
+				document, name = output, state.Next
+				attempts = map[string]int{}
+				continue
+			}
+			errorName := reflect.TypeOf(err).Elem().Name()
+			if callbackErr, ok := err.(*taskError); ok {
+				errorName = callbackErr.name
+			}
+			if retrier := findRetrier(state.Retry, errorName); retrier != nil && attempts[name] < retrier.MaxAttempts {
+				attempts[name]++
+				if e.beforeRetry != nil {
+					e.beforeRetry(name, errorName)
+				}
+				e.visited = e.visited[:len(e.visited)-1]
+				continue
+			}
+			catcher := findCatcher(state.Catch, errorName)
+			if catcher == nil {
+				t.Fatalf("%s failed with %s: %s", name, errorName, err.Error())
+			}
+			cause, _ := json.Marshal(map[string]string{"errorMessage": err.Error(), "errorType": errorName})
+			document["error"] = map[string]interface{}{"Error": errorName, "Cause": string(cause)}
+			name = catcher.Next
+		default:
+			t.Fatalf("unknown state %s", name)
+		}
+	}
+}
+
+// task invoke the handler with the phase of the task, a task that wait for its token return the output of the callback
+func (e *execution) task(state *taskState, document map[string]interface{}) (map[string]interface{}, error) {
+	if state.Resource != waitForTaskToken {
+		return e.invoke(map[string]interface{}{"phase": state.Parameters["phase"], "state": document})
+	}
+	taskToken := fmt.Sprintf("task-token-%d", len(e.visited))
+	payload := state.Parameters["Payload"].(map[string]interface{})
+	if _, err := e.invoke(map[string]interface{}{"phase": payload["phase"], "state": document, "taskToken": taskToken}); err != nil {
+		return nil, err
+	}
+	if e.callback == nil {
+		return nil, &taskError{name: "States.Timeout", cause: "no callback"}
+	}
+	body, err := e.callback(taskToken)
+	if err != nil {
+		return nil, err
+	}
+	output := map[string]interface{}{}
+	return output, json.Unmarshal(body, &output)
+}
+
+// invoke the handler like Lambda, the state is serialized between the phases
+func (e *execution) invoke(input map[string]interface{}) (map[string]interface{}, error) {
+	payload, _ := json.Marshal(input)
+	request := Request{}
+	if err := json.Unmarshal(payload, &request); err != nil {
+		return nil, err
+	}
+	result, err := handler(context.Background(), request)
+	if err != nil {
+		return nil, err
+	}
+	body, _ := json.Marshal(result)
+	output := map[string]interface{}{}
+	return output, json.Unmarshal(body, &output)
+}
+
+func findRetrier(retriers []retrier, errorName string) *retrier {
+	for i, retrier := range retriers {
+		for _, name := range retrier.ErrorEquals {
+			if name == errorName || name == "States.ALL" {
+				return &retriers[i]
+			}
+		}
+	}
+	return nil
+}
+
+func findCatcher(catchers []catcher, errorName string) *catcher {
+	for i, catcher := range catchers {
+		for _, name := range catcher.ErrorEquals {
+			if name == errorName || name == "States.ALL" {
+				return &catchers[i]
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTwoTierVpc return a VPC with public and private instances whose security groups reference each other,
+// vpc-2 is a bystander that no remediation of vpc-1 may touch
+func newTwoTierVpc() *mockEC2 {
+	return newMockEC2().
+		addVpc("vpc-1", false).
+		addSubnet("subnet-1", "vpc-1").
+		addSubnet("subnet-2", "vpc-1").
+		addGateway("igw-1", "vpc-1").
+		addGroup("sg-db", "vpc-1", "db", "sg-web").
+		addGroup("sg-web", "vpc-1", "web", "sg-db").
+		addInstance("i-1", "subnet-1", true, "sg-web").
+		addInstance("i-2", "subnet-2", false, "sg-db").
+		addVpc("vpc-2", false).
+		addSubnet("subnet-3", "vpc-2").
+		addGateway("igw-2", "vpc-2").
+		addInstance("i-3", "subnet-3", true, "sg-vpc-2")
+}
+
+var twoTierTeardown = []string{
+	"TerminateInstances i-1",
+	"TerminateInstances i-2",
+	"DescribeInstances i-1",
+	"DescribeInstances i-2",
+	"DetachInternetGateway igw-1",
+	"DeleteInternetGateway igw-1",
+	"RevokeSecurityGroupIngress sg-db",
+	"RevokeSecurityGroupIngress sg-web",
+	"DeleteSecurityGroup sg-db",
+	"DeleteSecurityGroup sg-web",
+	"DeleteSubnet subnet-1",
+	"DeleteSubnet subnet-2",
+	"DeleteVpc vpc-1",
+}
+
+func TestTeardown(t *testing.T) {
+	testCases := []struct {
+		name     string
+		ec2svc   *mockEC2
+		vpcID    string
+		expected []string
+	}{
+		{
+			name:     "empty VPC",
+			ec2svc:   newMockEC2().addVpc("vpc-1", false).addVpc("vpc-2", false).addSubnet("subnet-3", "vpc-2"),
+			vpcID:    "vpc-1",
+			expected: []string{"DeleteVpc vpc-1"},
+		},
+		{
+			name:     "VPC with instances and security groups",
+			ec2svc:   newTwoTierVpc(),
+			vpcID:    "vpc-1",
+			expected: twoTierTeardown,
+		},
+		{
+			name: "default VPC",
+			ec2svc: newMockEC2().
+				addVpc("vpc-default", true).
+				addSubnet("subnet-a", "vpc-default").
+				addSubnet("subnet-b", "vpc-default").
+				addGateway("igw-default", "vpc-default").
+				addVpc("vpc-2", false).
+				addGateway("igw-2", "vpc-2"),
+			vpcID: "vpc-default",
+			expected: []string{
+				"DetachInternetGateway igw-default",
+				"DeleteInternetGateway igw-default",
+				"DeleteSubnet subnet-a",
+				"DeleteSubnet subnet-b",
+				"DeleteVpc vpc-default",
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, tc.name), func(t *testing.T) {
+			bystander := tc.ec2svc.resources("vpc-2")
+			client := newClient(tc.ec2svc)
+			plan, err := client.plan(RemediationDelete, tc.vpcID)
+			assert.NoError(t, err)
+			assert.Empty(t, tc.ec2svc.mutations())
+
+			assert.NoError(t, client.execute(context.Background(), plan))
+			assert.Equal(t, tc.expected, tc.ec2svc.mutations())
+			assert.Empty(t, tc.ec2svc.resources(tc.vpcID))
+			assert.Equal(t, bystander, tc.ec2svc.resources("vpc-2"))
+		})
+	}
+}
+
+func TestTeardownFailure(t *testing.T) {
+	failures := []struct {
+		err      awserr.Error
+		category errors.Category
+	}{
+		{awserr.New("DependencyViolation", "has dependencies", nil), errors.Retryable},
+		{awserr.New("UnauthorizedOperation", "not authorized", nil), errors.Forbidden},
+		{awserr.New("InternalError", "internal error", nil), errors.Retryable},
+		{awserr.New("OperationNotPermitted", "not permitted", nil), errors.Permanent},
+	}
+
+	for _, failure := range failures {
+		for i, call := range twoTierTeardown {
+			t.Run(fmt.Sprintf("testCase[%d] %s fail with %s", i, call, failure.err.Code()), func(t *testing.T) {
+				ec2svc := newTwoTierVpc()
+				ec2svc.failures[call] = failure.err
+				client := newClient(ec2svc)
+				plan, err := client.plan(RemediationDelete, "vpc-1")
+				assert.NoError(t, err)
+
+				err = client.execute(context.Background(), plan)
+				assert.Equal(t, failure.category, errors.CategoryOf(err))
+				assert.Equal(t, twoTierTeardown[:i+1], ec2svc.mutations())
+				assert.Contains(t, ec2svc.resources("vpc-1"), "vpc-1")
+
+				// the next run plan what is left and finish the teardown
+				delete(ec2svc.failures, call)
+				plan, err = client.plan(RemediationDelete, "vpc-1")
+				assert.NoError(t, err)
+				assert.NoError(t, client.execute(context.Background(), plan))
+				assert.Empty(t, ec2svc.resources("vpc-1"))
+				assert.Len(t, ec2svc.resources("vpc-2"), 5)
+			})
+		}
+	}
+}
+
+// TestDependencyConstraints run the steps out of order, EC2 refuse them until their dependencies are removed
+func TestDependencyConstraints(t *testing.T) {
+	testCases := []struct {
+		name     string
+		step     Step
+		category errors.Category
+	}{
+		{
+			name:     "subnet with a running instance",
+			step:     Step{ResourceType: ResourceSubnet, ResourceID: "subnet-1", Action: ActionDelete},
+			category: errors.Retryable,
+		},
+		{
+			name:     "internet gateway with a mapped public IP",
+			step:     Step{ResourceType: ResourceInternetGateway, ResourceID: "igw-1", Action: ActionDetach},
+			category: errors.Retryable,
+		},
+		{
+			name:     "attached internet gateway",
+			step:     Step{ResourceType: ResourceInternetGateway, ResourceID: "igw-1", Action: ActionDelete},
+			category: errors.Retryable,
+		},
+		{
+			name:     "security group used by an instance",
+			step:     Step{ResourceType: ResourceSecurityGroup, ResourceID: "sg-web", Action: ActionDelete},
+			category: errors.Retryable,
+		},
+		{
+			name:     "default security group",
+			step:     Step{ResourceType: ResourceSecurityGroup, ResourceID: "sg-vpc-1", Action: ActionDelete},
+			category: errors.Permanent,
+		},
+		{
+			name:     "VPC with subnets",
+			step:     Step{ResourceType: ResourceVpc, ResourceID: "vpc-1", Action: ActionDelete},
+			category: errors.Retryable,
+		},
+		{
+			name:     "instance not terminated",
+			step:     Step{ResourceType: ResourceInstance, ResourceID: "i-1", Action: ActionWait},
+			category: errors.Retryable,
+		},
+		{
+			name:     "missing subnet",
+			step:     Step{ResourceType: ResourceSubnet, ResourceID: "subnet-9", Action: ActionDelete},
+			category: errors.NotFound,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, tc.name), func(t *testing.T) {
+			ec2svc := newTwoTierVpc()
+			before := ec2svc.resources("vpc-1")
+			// the step run once, a pending step is not waited for after the deadline
+			ctx, cancel := context.WithTimeout(context.Background(), 0)
+			defer cancel()
+			err := newClient(ec2svc).execute(ctx, &Plan{VpcID: "vpc-1", Steps: []Step{tc.step}})
+			assert.Equal(t, tc.category, errors.CategoryOf(err))
+			assert.Equal(t, before, ec2svc.resources("vpc-1"))
+		})
+	}
+}
+
+func TestRemediateVpc(t *testing.T) {
+	defer func() { clients = nil }()
+	event := events.AlertEvent{AlertID: "P-1", AccountID: "123456789012", ResourceRegionID: "us-west-2", ResourceID: "vpc-1"}
+	testCases := []struct {
+		name      string
+		vpcID     string
+		mode      string
+		factory   error
+		mutations []string
+		category  errors.Category
+	}{
+		{
+			name:      "execute",
+			vpcID:     "vpc-1",
+			mode:      ModeExecute,
+			mutations: twoTierTeardown,
+		},
+		{
+			name:  "plan",
+			vpcID: "vpc-1",
+			mode:  ModePlan,
+		},
+		{
+			name:     "missing VPC",
+			vpcID:    "vpc-9",
+			mode:     ModeExecute,
+			category: errors.NotFound,
+		},
+		{
+			name:     "client failure",
+			vpcID:    "vpc-1",
+			mode:     ModeExecute,
+			factory:  errors.Wrapf(errors.Forbidden, "cannot assume role"),
+			category: errors.Forbidden,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, tc.name), func(t *testing.T) {
+			ec2svc := newTwoTierVpc()
+			clients = &mockFactory{regions: map[string]*mockEC2{"us-west-2": ec2svc}, err: tc.factory}
+			event.ResourceID = tc.vpcID
+			plan, err := remediateVpc(context.Background(), event, RemediationDelete, tc.mode)
+			assert.Equal(t, tc.mutations, ec2svc.mutations())
+			if tc.category != 0 {
+				assert.Equal(t, tc.category, errors.CategoryOf(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "123456789012", plan.AccountID)
+			assert.Equal(t, "us-west-2", plan.Region)
+			assert.Len(t, plan.Steps, len(twoTierTeardown))
+		})
+	}
+}
+
+func TestFindVpcs(t *testing.T) {
+	defer func() { clients = nil }()
+	clients = &mockFactory{regions: map[string]*mockEC2{
+		"us-west-2": newTwoTierVpc().addVpc("vpc-default", true),
+	}}
+	testCases := []struct {
+		name     string
+		region   string
+		vpcIDs   []string
+		expected []string
+	}{
+		{
+			name:     "default VPCs",
+			region:   "us-west-2",
+			expected: []string{"vpc-default"},
+		},
+		{
+			name:     "listed VPCs that exist",
+			region:   "us-west-2",
+			vpcIDs:   []string{"vpc-2", "vpc-9", "vpc-1"},
+			expected: []string{"vpc-1", "vpc-2"},
+		},
+		{
+			name:     "region without VPCs",
+			region:   "eu-west-1",
+			vpcIDs:   []string{"vpc-1"},
+			expected: []string{},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("testCase[%d] %s", i, tc.name), func(t *testing.T) {
+			vpcIDs, err := findVpcs("123456789012", tc.region, tc.vpcIDs)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, vpcIDs)
+		})
+	}
+}
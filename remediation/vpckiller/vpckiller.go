@@ -2,340 +2,225 @@ package main
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"net/http"
+	"os"
+	"time"
 
+	"github.com/CityOfNewYork/prisma-cloud-remediation/errors"
 	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/approval"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/backup"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/deadletter"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/exemption"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/remediation/journal"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
 )
 
-// Client contain session and configure of the EC2 Client to cal ec2 API
-type Client struct {
-	session *session.Session
-	config  *aws.Config
-	ec2svc  *ec2.EC2
-}
+// deadLetters publish the alerts that cannot be remediated
+var deadLetters *deadletter.Publisher
 
-// Role is the assume role that used to access other accounts with the ExternalID
-const (
-	Role       = "Prisma_VPC_Term_Role"
-	ExternalID = "PrismaVPCKiller"
-	Virginia   = "us-east-1"
-	DevAccount = "1234567890"
-)
+// webhookTimeout is the timeout of the webhook that notify the approvers
+const webhookTimeout = 10 * time.Second
 
-func (c *Client) initClient(region string, accountID string, role string, externalID string) error {
-	if c.session == nil {
-		c.session = session.Must(session.NewSession())
+// handler remediate the VPC of an alert and return its plan, or the VPCs of a batch and return the report
+// The phases of the state machine return their state
+func handler(ctx context.Context, request Request) (interface{}, error) {
+	if request.Phase != "" {
+		return handlePhase(ctx, request)
 	}
-
-	arn := fmt.Sprintf("arn:aws:iam::%v:role/%v", accountID, role)
-
-	creds := stscreds.NewCredentials(
-		c.session,
-		arn,
-		func(provider *stscreds.AssumeRoleProvider) {
-			provider.ExternalID = aws.String(externalID)
-		})
-
-	c.config = &aws.Config{
-		Region:      aws.String(region),
-		Credentials: creds,
+	if request.Batch != nil {
+		return handleBatch(ctx, request)
 	}
-
-	c.ec2svc = ec2.New(c.session, c.config)
-
-	return nil
+	return handleAlert(ctx, request)
 }
 
-func handler(ctx context.Context, event events.VPCAlert) error {
-	client := &Client{}
-	var vpcIds []*string
-
-	fmt.Printf("%+v\n", event)
-	if event.ResourceRegionID == Virginia && event.AccountID != DevAccount {
-		fmt.Printf("Region: %s is allowed in %s", event.ResourceRegionID, event.AccountID)
-	}
-
-	client.initClient(event.ResourceRegionID, event.AccountID, Role, ExternalID)
-	vpcs, verifyError := client.verifyVpcs([]*string{
-		aws.String(event.ResourceID),
-	})
-	if verifyError != nil {
-		fmt.Println(verifyError.Error())
-		return verifyError
-	}
-
-	for _, vpc := range vpcs {
-		vpcIds = append(vpcIds, vpc.VpcId)
-	}
-
-	vpcFilters := []*ec2.Filter{
-		{
-			Name:   aws.String("vpc-id"),
-			Values: vpcIds,
-		},
+func handleAlert(ctx context.Context, request Request) (*Plan, error) {
+	event := request.AlertEvent
+	if event.IsResolved() {
+		return nil, closeAlert(ctx, event)
 	}
-
-	if err := client.cleanInternetGateways(vpcIds); err != nil {
-		fmt.Println(err.Error())
-		return err
-	}
-
-	if err := client.cleanEC2Instances(vpcFilters); err != nil {
-		fmt.Println(err.Error())
-		return err
-	}
-
-	if err := client.cleanSecurityGroups(vpcFilters); err != nil {
-		fmt.Println(err.Error())
-		return err
-	}
-
-	if err := client.cleanSubnets(vpcFilters); err != nil {
-		fmt.Println(err.Error())
-		return err
-	}
-
-	if err := client.deleteVpc(aws.String(event.ResourceID)); err != nil {
-		fmt.Println(err.Error())
-		return err
-	}
-
-	fmt.Println("VPC Killer tasks: done")
-	return nil
-}
-
-func (c *Client) deleteVpc(vpcID *string) error {
-	fmt.Printf("Deleting Vpc %s\n", *vpcID)
-	_, err := c.ec2svc.DeleteVpc(&ec2.DeleteVpcInput{
-		VpcId: vpcID,
-	})
+	mode, err := request.mode()
 	if err != nil {
-		fmt.Println(err.Error())
-		return err
-	}
-	fmt.Printf("VPC %s has been deleted\n", *vpcID)
-	return nil
-}
-
-func (c *Client) cleanEC2Instances(filters []*ec2.Filter) error {
-	if filters == nil {
-		return errors.New("deleteEC2Instances: missing required variable vpdId")
-	}
-
-	input := &ec2.DescribeInstancesInput{
-		Filters: filters,
+		return nil, err
 	}
-
-	instances, err := c.ec2svc.DescribeInstances(input)
-
+	remediation, err := request.remediation()
 	if err != nil {
-		fmt.Println(err.Error())
-		return err
+		return nil, err
 	}
-
-	var instanceIds []*string
-
-	if len(instances.Reservations) < 1 {
-		fmt.Println("No instances was found")
-		return nil
+	// the function cannot wait for an approver, the state machine does
+	if approvalRequired, err := request.approvalRequired(); err != nil {
+		return nil, err
+	} else if approvalRequired && mode == ModeExecute {
+		return nil, errors.Wrapf(errors.Validation, "alert %s require an approval, route it to the state machine", event.AlertID)
 	}
-	for _, reservation := range instances.Reservations {
-		for _, instance := range reservation.Instances {
-			instanceIds = append(instanceIds, instance.InstanceId)
+	if remediation == RemediationRestore || remediation == RemediationRollback {
+		if err := request.validateRestore(); err != nil {
+			return nil, err
 		}
+	} else if err := event.Validate(); err != nil {
+		fmt.Printf("Invalid alert: %s\n", err.Error())
+		return nil, deadLetters.HandleAsync(ctx, lambdacontext.FunctionName, event, err)
 	}
-
-	fmt.Printf("%d Instances found", len(instanceIds))
-	termInput := &ec2.TerminateInstancesInput{
-		InstanceIds: instanceIds,
-	}
-
-	fmt.Println("Terminating Instances...")
-	_, termError := c.ec2svc.TerminateInstances(termInput)
-
-	if termError != nil {
-		fmt.Println(termError.Error())
-		return termError
-	}
-
-	fmt.Println("Waiting for instances to be terminated")
-	if err := c.waitUntilInstanceTerminated(
-		&ec2.DescribeInstancesInput{
-			Filters: filters,
-		}); err != nil {
-		return err
-	}
-	fmt.Println("All instances has been terminiated")
-
-	return nil
-}
-
-func (c *Client) waitUntilInstanceTerminated(input *ec2.DescribeInstancesInput) error {
-	err := c.ec2svc.WaitUntilInstanceTerminated(input)
+	plan, err := remediateVpc(ctx, event, remediation, mode)
 	if err != nil {
-		fmt.Println(err.Error())
-		return err
+		fmt.Printf("VPC Killer failed: %s %s\n", errors.CategoryOf(err), err.Error())
+		return plan, deadLetters.HandleAsync(ctx, lambdacontext.FunctionName, event, err)
 	}
-	return nil
+	fmt.Printf("VPC Killer tasks: %s %s done\n", mode, remediation)
+	return plan, nil
 }
 
-func (c *Client) cleanSecurityGroups(filters []*ec2.Filter) error {
-	input := &ec2.DescribeSecurityGroupsInput{
-		Filters: filters,
-	}
-
-	fmt.Println("Searching custom security groups")
-	sgs, err := c.ec2svc.DescribeSecurityGroups(input)
-
-	if err != nil {
-		fmt.Println(err.Error())
-		return err
-	}
-
-	if len(sgs.SecurityGroups) == 1 && *sgs.SecurityGroups[0].GroupName == "default" {
-		fmt.Println("No custom security group was found")
-		return nil
-	}
-	for _, sg := range sgs.SecurityGroups {
-		if *sg.GroupName != "default" {
-			fmt.Printf("Deleting custom security group: %s\n", *sg.GroupId)
-			_, err := c.ec2svc.DeleteSecurityGroup(&ec2.DeleteSecurityGroupInput{
-				GroupId: sg.GroupId,
-			})
-			if err != nil {
-				fmt.Println(err.Error())
+// closeAlert close the journals of the delete and the quarantine of the resolved alert and its pending approvals,
+// a reopened alert is planned again
+func closeAlert(ctx context.Context, event events.AlertEvent) error {
+	fmt.Printf("Alert %s is resolved, nothing to remediate\n", event.AlertID)
+	for _, remediation := range []string{RemediationDelete, RemediationQuarantine} {
+		record, err := openJournal(event.AlertID, remediation, event.ResourceID)
+		if err != nil {
+			return err
+		}
+		if record != nil {
+			if err := record.Close(ctx); err != nil {
 				return err
 			}
-			fmt.Printf("%s has deleted", *sg.GroupId)
 		}
 	}
-	fmt.Println("All custom security groups have been deleted")
-	return nil
+	return closeApprovals(ctx, event.AlertID)
 }
 
-func (c *Client) cleanSubnets(filters []*ec2.Filter) error {
-	input := &ec2.DescribeSubnetsInput{
-		Filters: filters,
-	}
-	output, err := c.ec2svc.DescribeSubnets(input)
-
-	if err != nil {
-		fmt.Println(err.Error())
-		return err
-	}
-
-	if len(output.Subnets) == 0 {
-		fmt.Println("No subnets was found")
-		return nil
+// remediateVpc plan the remediation of the VPC of the event, the plan is executed unless the mode is plan
+// The destructive remediations spare the exempt VPCs, restore put back a VPC that was not exempt
+// With a journal, the executed steps are recorded and a rerun of the alert resume its plan
+func remediateVpc(ctx context.Context, event events.AlertEvent, remediation string, mode string) (*Plan, error) {
+	if remediation == RemediationRollback {
+		return rollbackVpc(event, mode)
 	}
-
-	for _, subnet := range output.Subnets {
-		fmt.Printf("Deleting subnet: %s\n", *subnet.SubnetId)
-		_, err := c.ec2svc.DeleteSubnet(&ec2.DeleteSubnetInput{
-			SubnetId: subnet.SubnetId,
-		})
-		if err != nil {
-			fmt.Println(err.Error())
-			return err
-		}
-		fmt.Printf("%s has deleted", *subnet.SubnetId)
+	plan, client, err := prepareVpc(event, remediation, mode)
+	if err != nil || !plan.executable() {
+		return plan, err
 	}
-	fmt.Println("All subnets has been deleted")
-
-	return nil
+	return plan, client.execute(ctx, plan)
 }
 
-func (c *Client) cleanInternetGateways(vpcID []*string) error {
-
-	input := &ec2.DescribeInternetGatewaysInput{
-		Filters: []*ec2.Filter{
-			{
-				Name:   aws.String("attachment.vpc-id"),
-				Values: vpcID,
-			},
-		},
-	}
-
-	output, err := c.ec2svc.DescribeInternetGateways(input)
-
-	if err != nil {
-		fmt.Println(err.Error())
-		return err
-	}
-
-	if len(output.InternetGateways) == 0 {
-		fmt.Println("No internet gateways was found")
-		return nil
-	}
-
-	for _, internetgateway := range output.InternetGateways {
-		fmt.Printf("Deleting InternetGateway: %s\n", *internetgateway.InternetGatewayId)
-		for _, attachment := range internetgateway.Attachments {
-			_, err := c.ec2svc.DetachInternetGateway(&ec2.DetachInternetGatewayInput{
-				InternetGatewayId: internetgateway.InternetGatewayId,
-				VpcId:             attachment.VpcId,
-			})
-			if err != nil {
-				fmt.Println(err.Error())
-				return err
+// prepareVpc plan the remediation of the VPC of the event, or resume the plan of its journal
+// The plan that will be executed is recorded in the journal
+func prepareVpc(event events.AlertEvent, remediation string, mode string) (*Plan, *Client, error) {
+	var exempt *exemption.Exemption
+	if remediation != RemediationRestore {
+		exempt = exemptions.Event(&event)
+	}
+	plan := &Plan{VpcID: event.ResourceID, Remediation: remediation, Exemption: exempt, Steps: []Step{}}
+	var client *Client
+	if exempt == nil {
+		var err error
+		if client, err = clients.Client(event.ResourceRegionID, event.AccountID); err != nil {
+			return nil, nil, err
+		}
+		var record *journal.Journal
+		if mode == ModeExecute {
+			if record, err = openJournal(event.AlertID, remediation, event.ResourceID); err != nil {
+				return nil, nil, err
 			}
-			fmt.Printf("%s has detached from %s", *internetgateway.InternetGatewayId, *attachment.VpcId)
 		}
-
-		_, derr := c.ec2svc.DeleteInternetGateway(&ec2.DeleteInternetGatewayInput{
-			InternetGatewayId: internetgateway.InternetGatewayId,
-		})
-		if derr != nil {
-			fmt.Println(derr.Error())
-			return derr
+		if plan, err = client.resume(record, remediation, event.ResourceID); err != nil {
+			return nil, nil, err
 		}
-		fmt.Printf("%s has been deleted", *internetgateway.InternetGatewayId)
 	}
-	fmt.Println("All InternetGateways has been detached")
-
-	return nil
+	plan.AlertID = event.AlertID
+	plan.AccountID = event.AccountID
+	plan.Region = event.ResourceRegionID
+	plan.Mode = mode
+	fmt.Print(plan.String())
+	if plan.executable() && plan.journal != nil {
+		if err := plan.journal.Record(context.Background(), plan); err != nil {
+			return plan, nil, err
+		}
+	}
+	return plan, client, nil
 }
 
+// verifyVpcs return the VPCs that exist, a missing VPC is left out
+// The VPCs are filtered by ID because DescribeVpcs fail on any missing VpcIds
 func (c *Client) verifyVpcs(vpcIds []*string) ([]*ec2.Vpc, error) {
 	fmt.Println("Searching Vpcs")
 	output, err := c.ec2svc.DescribeVpcs(&ec2.DescribeVpcsInput{
-		VpcIds: vpcIds,
+		Filters: []*ec2.Filter{{Name: aws.String("vpc-id"), Values: vpcIds}},
 	},
 	)
 	if err != nil {
-		fmt.Println(err.Error())
-		return nil, err
+		return nil, awsErrorHandler(err)
 	}
 	if len(output.Vpcs) == 0 {
-		return nil, fmt.Errorf("Vpcs not found")
+		return nil, errors.Wrapf(errors.NotFound, "Vpcs not found")
 	}
 
 	fmt.Printf("verify %d Vpcs found %d\n", len(vpcIds), len(output.Vpcs))
 	return output.Vpcs, nil
 }
 
+// defaultVpcs return the default VPCs of the region
+func (c *Client) defaultVpcs() ([]*ec2.Vpc, error) {
+	fmt.Println("Searching default Vpcs")
+	output, err := c.ec2svc.DescribeVpcs(&ec2.DescribeVpcsInput{
+		Filters: []*ec2.Filter{{Name: aws.String("is-default"), Values: []*string{aws.String("true")}}},
+	})
+	if err != nil {
+		return nil, awsErrorHandler(err)
+	}
+	return output.Vpcs, nil
+}
+
+// awsErrorHandler print the error and classify it by the AWS error code
 func awsErrorHandler(err error) error {
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			default:
-				fmt.Println(aerr.Error())
-			}
+			fmt.Printf("%s: %s\n", aerr.Code(), aerr.Message())
 		} else {
 			fmt.Println(err.Error())
 		}
-		return err
+		return errors.Classify(err)
 	}
 	return nil
 }
 
 func main() {
+	sess := session.Must(session.NewSession())
+	deadLetters = deadletter.NewPublisherFromEnv(lambdacontext.FunctionName, sqs.New(sess))
+	backups = backup.NewStoreFromEnv(s3.New(sess))
+	journals = journal.NewStoreFromEnv(dynamodb.New(sess))
+	clients = NewAssumeRoleFactory(sess, Role, ExternalID)
+	if err := loadMode(); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	if err := loadRemediations(); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	if err := loadAccountLimit(); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	var err error
+	if exemptions, err = exemption.LoadFromEnv(); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	if gate, err = approval.NewGateFromEnv(dynamodb.New(sess), sns.New(sess), &http.Client{Timeout: webhookTimeout}); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	tasks = sfn.New(sess)
 	lambda.Start(handler)
 }
@@ -1,7 +1,7 @@
 package response
 
 import (
-	"time"
+	"github.com/CityOfNewYork/prisma-cloud-remediation/events"
 )
 
 // Alert Prisma simple alert response
@@ -47,41 +47,16 @@ type AWSAlert []struct {
 		Score  string `json:"score"`
 	} `json:"riskDetail"`
 	Resource struct {
-		ID                 string        `json:"id"`
-		Name               string        `json:"name"`
-		Account            string        `json:"account"`
-		AccountID          string        `json:"accountId"`
-		CloudAccountGroups []interface{} `json:"cloudAccountGroups"`
-		Region             string        `json:"region"`
-		RegionID           string        `json:"regionId"`
-		ResourceType       string        `json:"resourceType"`
-		Data               struct {
-			EventVersion string `json:"eventVersion"`
-			UserIdentity struct {
-				Type        string `json:"type"`
-				PrincipalID string `json:"principalId"`
-				Arn         string `json:"arn"`
-				AccountID   string `json:"accountId"`
-			} `json:"userIdentity"`
-			EventTime         time.Time   `json:"eventTime"`
-			EventSource       string      `json:"eventSource"`
-			EventName         string      `json:"eventName"`
-			AwsRegion         string      `json:"awsRegion"`
-			SourceIPAddress   string      `json:"sourceIPAddress"`
-			UserAgent         string      `json:"userAgent"`
-			RequestParameters interface{} `json:"requestParameters"`
-			ResponseElements  struct {
-				RenewRole string `json:"RenewRole"`
-			} `json:"responseElements"`
-			AdditionalEventData struct {
-				RenewedBy  string `json:"RenewedBy"`
-				RedirectTo string `json:"RedirectTo"`
-			} `json:"additionalEventData"`
-			EventID            string `json:"eventID"`
-			EventType          string `json:"eventType"`
-			RecipientAccountID string `json:"recipientAccountId"`
-		} `json:"data"`
-		CloudType string `json:"cloudType"`
+		ID                 string                 `json:"id"`
+		Name               string                 `json:"name"`
+		Account            string                 `json:"account"`
+		AccountID          string                 `json:"accountId"`
+		CloudAccountGroups []interface{}          `json:"cloudAccountGroups"`
+		Region             string                 `json:"region"`
+		RegionID           string                 `json:"regionId"`
+		ResourceType       string                 `json:"resourceType"`
+		Data               events.CloudTrailEvent `json:"data"`
+		CloudType          string                 `json:"cloudType"`
 	} `json:"resource"`
 	TriggeredBy        string `json:"triggeredBy"`
 	InvestigateOptions struct {